@@ -0,0 +1,201 @@
+// Command library administers the library's databases. It migrates the schema of a SQL database with
+//
+//	library migrate [-backend sqlite|postgres] [-dsn DSN] up|down|status
+//
+// The backend defaults to STORAGE_BACKEND, and the DSN to DATABASE_URL for Postgres or SQLITE_PATH for
+// SQLite.
+//
+// It creates the DynamoDB table and indexes, or adds the indexes that an existing table is missing, and
+// copies the items of the tables the storage used before the single library table into it, with
+//
+//	library schema [-endpoint URL] [-region REGION] [-prefix PREFIX] apply|migrate
+//
+// The endpoint defaults to DYNAMODB_ENDPOINT, or AWS's if it's not set, the region to AWS_REGION, and the
+// prefix of the tables' names to TABLE_PREFIX.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aaron-zeisler/library-api/internal/storage"
+	"github.com/aaron-zeisler/library-api/lambdas"
+)
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "library:", err)
+		os.Exit(1)
+	}
+}
+
+const (
+	migrateUsage = "usage: library migrate [-backend sqlite|postgres] [-dsn DSN] up|down|status"
+	schemaUsage  = "usage: library schema [-endpoint URL] [-region REGION] [-prefix PREFIX] apply|migrate"
+	usage        = migrateUsage + "\n       library schema [-endpoint URL] [-region REGION] [-prefix PREFIX] apply|migrate"
+)
+
+func run(ctx context.Context, args []string, stdout io.Writer, getenv func(string) string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "migrate":
+		return migrate(ctx, args[1:], stdout, getenv)
+	case "schema":
+		return schema(ctx, args[1:], stdout, getenv)
+	default:
+		return errors.New(usage)
+	}
+}
+
+func migrate(ctx context.Context, args []string, stdout io.Writer, getenv func(string) string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	backend := flags.String("backend", getenv("STORAGE_BACKEND"), "the database to migrate, sqlite or postgres")
+	dsn := flags.String("dsn", "", "the database's DSN (default $DATABASE_URL for postgres, $SQLITE_PATH for sqlite)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	if *dsn == "" {
+		switch *backend {
+		case "postgres":
+			*dsn = getenv("DATABASE_URL")
+		case "sqlite":
+			*dsn = getenv("SQLITE_PATH")
+			if *dsn == "" {
+				*dsn = lambdas.DefaultSQLitePath
+			}
+		}
+	}
+	if *backend == "postgres" && *dsn == "" {
+		return errors.New("-dsn or DATABASE_URL must be set for the postgres backend")
+	}
+
+	m, err := storage.NewMigrator(*backend, *dsn)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch command := flags.Arg(0); command {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(stdout, "applied %s\n", migration)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(stdout, "the database is up to date")
+		}
+		return err
+
+	case "down":
+		reverted, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			fmt.Fprintln(stdout, "there are no migrations to revert")
+			return nil
+		}
+		fmt.Fprintf(stdout, "reverted %s\n", reverted)
+		return nil
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MIGRATION\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", status, appliedAt)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command '%s'; %s", command, migrateUsage)
+	}
+}
+
+func schema(ctx context.Context, args []string, stdout io.Writer, getenv func(string) string) error {
+	region := getenv("AWS_REGION")
+	if region == "" {
+		region = "us-west-1"
+	}
+	prefix := getenv("TABLE_PREFIX")
+	if prefix == "" {
+		prefix = storage.DefaultTablePrefix
+	}
+
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	endpoint := flags.String("endpoint", getenv("DYNAMODB_ENDPOINT"), "the DynamoDB the tables are in, e.g. DynamoDB Local's http://localhost:8000 (default AWS's)")
+	flags.StringVar(&region, "region", region, "the AWS region of the tables")
+	flags.StringVar(&prefix, "prefix", prefix, "the prefix of the tables' names")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(schemaUsage)
+	}
+
+	opts := []storage.DynamoBooksStorageOption{storage.WithAWSRegion(region), storage.WithTablePrefix(prefix)}
+	if *endpoint != "" {
+		opts = append(opts, storage.WithEndpoint(*endpoint))
+	}
+	db, err := storage.NewDynamoDBBooksStorage(opts...)
+	if err != nil {
+		return err
+	}
+
+	switch command := flags.Arg(0); command {
+	case "apply":
+		changes, err := db.ApplySchema(ctx)
+		for _, change := range changes {
+			fmt.Fprintf(stdout, "created %s\n", change)
+		}
+		if err == nil && len(changes) == 0 {
+			fmt.Fprintln(stdout, "the tables are up to date")
+		}
+		return err
+	case "migrate":
+		migrations, err := db.MigrateLegacyTables(ctx)
+		for _, migration := range migrations {
+			fmt.Fprintf(stdout, "migrated %s\n", migration)
+		}
+		if err == nil && len(migrations) == 0 {
+			fmt.Fprintln(stdout, "there are no tables to migrate")
+		}
+		if err != nil {
+			return err
+		}
+
+		moved, err := db.ShardCatalog(ctx)
+		if moved > 0 {
+			fmt.Fprintf(stdout, "moved %d books into their catalog shards\n", moved)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown schema command '%s'; %s", command, schemaUsage)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/storage/dynamodbtest"
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+func Test_run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "library.db")
+	env := map[string]string{"STORAGE_BACKEND": "sqlite", "SQLITE_PATH": path}
+
+	dynamoDB := httptest.NewServer(dynamodbtest.NewFake())
+	defer dynamoDB.Close()
+	dynamoDBEnv := map[string]string{"DYNAMODB_ENDPOINT": dynamoDB.URL, "TABLE_PREFIX": "branch"}
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	type state struct {
+		args []string
+		env  map[string]string
+	}
+	type expected struct {
+		output []string // The start of each line
+		err    error
+	}
+	// The cases run in order, against the same database
+	testCases := []struct {
+		name     string
+		state    state
+		expected expected
+	}{
+		{
+			"Nothing has been applied",
+			state{args: []string{"migrate", "status"}, env: env},
+			expected{output: []string{"MIGRATION            APPLIED AT", "0001_create_catalog  pending"}},
+		},
+		{
+			"The migrations are applied",
+			state{args: []string{"migrate", "up"}, env: env},
+			expected{output: []string{"applied 0001_create_catalog"}},
+		},
+		{
+			"The migrations are applied again",
+			state{args: []string{"migrate", "up"}, env: env},
+			expected{output: []string{"the database is up to date"}},
+		},
+		{
+			"The database is named by a flag",
+			state{args: []string{"migrate", "-backend", "sqlite", "-dsn", path, "status"}},
+			expected{output: []string{"MIGRATION            APPLIED AT", "0001_create_catalog  20"}},
+		},
+		{
+			"The latest migration is reverted",
+			state{args: []string{"migrate", "down"}, env: env},
+			expected{output: []string{"reverted 0001_create_catalog"}},
+		},
+		{
+			"There's nothing to revert",
+			state{args: []string{"migrate", "down"}, env: env},
+			expected{output: []string{"there are no migrations to revert"}},
+		},
+		{
+			"There's no command",
+			state{args: []string{}, env: env},
+			expected{err: errors.New(usage)},
+		},
+		{
+			"The migrate command is unknown",
+			state{args: []string{"migrate", "sideways"}, env: env},
+			expected{err: errors.New("unknown migrate command 'sideways'; " + migrateUsage)},
+		},
+		{
+			"The backend isn't a SQL database",
+			state{args: []string{"migrate", "up"}, env: map[string]string{"STORAGE_BACKEND": "dynamodb"}},
+			expected{err: errors.New("the backend must be sqlite or postgres, not 'dynamodb'")},
+		},
+		{
+			"Postgres has no DSN",
+			state{args: []string{"migrate", "up"}, env: map[string]string{"STORAGE_BACKEND": "postgres"}},
+			expected{err: errors.New("-dsn or DATABASE_URL must be set for the postgres backend")},
+		},
+		{
+			"The DynamoDB table is created",
+			state{args: []string{"schema", "apply"}, env: dynamoDBEnv},
+			expected{output: []string{"created table branch-library"}},
+		},
+		{
+			"The DynamoDB table is created again",
+			state{args: []string{"schema", "apply"}, env: dynamoDBEnv},
+			expected{output: []string{"the tables are up to date"}},
+		},
+		{
+			"The DynamoDB table is named by a flag",
+			state{args: []string{"schema", "-endpoint", dynamoDB.URL, "-prefix", "other", "apply"}},
+			expected{output: []string{"created table other-library"}},
+		},
+		{
+			"There are no legacy tables to migrate",
+			state{args: []string{"schema", "migrate"}, env: dynamoDBEnv},
+			expected{output: []string{"there are no tables to migrate"}},
+		},
+		{
+			"The schema command is unknown",
+			state{args: []string{"schema", "drop"}, env: dynamoDBEnv},
+			expected{err: errors.New("unknown schema command 'drop'; " + schemaUsage)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assertions.New(t)
+			var stdout bytes.Buffer
+
+			err := run(context.Background(), tc.state.args, &stdout, func(name string) string { return tc.state.env[name] })
+
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			for i, prefix := range tc.expected.output {
+				if assert.So(len(lines), should.BeGreaterThan, i) {
+					assert.So(lines[i], should.StartWith, prefix)
+				}
+			}
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
@@ -4,11 +4,86 @@ package mocks
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/aaron-zeisler/library-api/internal"
 )
 
 type MockBooksDB struct {
+	AddCopyStub        func(context.Context, internal.Copy) (internal.Copy, error)
+	addCopyMutex       sync.RWMutex
+	addCopyArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.Copy
+	}
+	addCopyReturns struct {
+		result1 internal.Copy
+		result2 error
+	}
+	addCopyReturnsOnCall map[int]struct {
+		result1 internal.Copy
+		result2 error
+	}
+	AddLedgerEntryStub        func(context.Context, internal.LedgerEntry) (internal.LedgerEntry, error)
+	addLedgerEntryMutex       sync.RWMutex
+	addLedgerEntryArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.LedgerEntry
+	}
+	addLedgerEntryReturns struct {
+		result1 internal.LedgerEntry
+		result2 error
+	}
+	addLedgerEntryReturnsOnCall map[int]struct {
+		result1 internal.LedgerEntry
+		result2 error
+	}
+	CheckOutCopyStub        func(context.Context, internal.Loan, internal.BookStatus, *internal.Hold) (internal.Copy, internal.Loan, error)
+	checkOutCopyMutex       sync.RWMutex
+	checkOutCopyArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.Loan
+		arg3 internal.BookStatus
+		arg4 *internal.Hold
+	}
+	checkOutCopyReturns struct {
+		result1 internal.Copy
+		result2 internal.Loan
+		result3 error
+	}
+	checkOutCopyReturnsOnCall map[int]struct {
+		result1 internal.Copy
+		result2 internal.Loan
+		result3 error
+	}
+	CloseHoldStub        func(context.Context, internal.Hold, internal.HoldStatus) error
+	closeHoldMutex       sync.RWMutex
+	closeHoldArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.Hold
+		arg3 internal.HoldStatus
+	}
+	closeHoldReturns struct {
+		result1 error
+	}
+	closeHoldReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CloseLoanStub        func(context.Context, internal.Loan, time.Time) (internal.Loan, error)
+	closeLoanMutex       sync.RWMutex
+	closeLoanArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.Loan
+		arg3 time.Time
+	}
+	closeLoanReturns struct {
+		result1 internal.Loan
+		result2 error
+	}
+	closeLoanReturnsOnCall map[int]struct {
+		result1 internal.Loan
+		result2 error
+	}
 	CreateBookStub        func(context.Context, string, string, string, string) (internal.Book, error)
 	createBookMutex       sync.RWMutex
 	createBookArgsForCall []struct {
@@ -26,6 +101,20 @@ type MockBooksDB struct {
 		result1 internal.Book
 		result2 error
 	}
+	CreateLoanStub        func(context.Context, internal.Loan) (internal.Loan, error)
+	createLoanMutex       sync.RWMutex
+	createLoanArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.Loan
+	}
+	createLoanReturns struct {
+		result1 internal.Loan
+		result2 error
+	}
+	createLoanReturnsOnCall map[int]struct {
+		result1 internal.Loan
+		result2 error
+	}
 	DeleteBookStub        func(context.Context, string) error
 	deleteBookMutex       sync.RWMutex
 	deleteBookArgsForCall []struct {
@@ -38,6 +127,20 @@ type MockBooksDB struct {
 	deleteBookReturnsOnCall map[int]struct {
 		result1 error
 	}
+	GetAuditEventsStub        func(context.Context, internal.AuditFilter) ([]internal.AuditEvent, error)
+	getAuditEventsMutex       sync.RWMutex
+	getAuditEventsArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.AuditFilter
+	}
+	getAuditEventsReturns struct {
+		result1 []internal.AuditEvent
+		result2 error
+	}
+	getAuditEventsReturnsOnCall map[int]struct {
+		result1 []internal.AuditEvent
+		result2 error
+	}
 	GetBookByIDStub        func(context.Context, string) (internal.Book, error)
 	getBookByIDMutex       sync.RWMutex
 	getBookByIDArgsForCall []struct {
@@ -52,10 +155,25 @@ type MockBooksDB struct {
 		result1 internal.Book
 		result2 error
 	}
-	GetBooksStub        func(context.Context) ([]internal.Book, error)
+	GetBookHistoryStub        func(context.Context, string) ([]internal.AuditEvent, error)
+	getBookHistoryMutex       sync.RWMutex
+	getBookHistoryArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getBookHistoryReturns struct {
+		result1 []internal.AuditEvent
+		result2 error
+	}
+	getBookHistoryReturnsOnCall map[int]struct {
+		result1 []internal.AuditEvent
+		result2 error
+	}
+	GetBooksStub        func(context.Context, internal.BookFilter) ([]internal.Book, error)
 	getBooksMutex       sync.RWMutex
 	getBooksArgsForCall []struct {
 		arg1 context.Context
+		arg2 internal.BookFilter
 	}
 	getBooksReturns struct {
 		result1 []internal.Book
@@ -65,6 +183,217 @@ type MockBooksDB struct {
 		result1 []internal.Book
 		result2 error
 	}
+	GetCopiesStub        func(context.Context, string) ([]internal.Copy, error)
+	getCopiesMutex       sync.RWMutex
+	getCopiesArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getCopiesReturns struct {
+		result1 []internal.Copy
+		result2 error
+	}
+	getCopiesReturnsOnCall map[int]struct {
+		result1 []internal.Copy
+		result2 error
+	}
+	GetCopyByBarcodeStub        func(context.Context, string) (internal.Copy, error)
+	getCopyByBarcodeMutex       sync.RWMutex
+	getCopyByBarcodeArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getCopyByBarcodeReturns struct {
+		result1 internal.Copy
+		result2 error
+	}
+	getCopyByBarcodeReturnsOnCall map[int]struct {
+		result1 internal.Copy
+		result2 error
+	}
+	GetDeletedBooksStub        func(context.Context) ([]internal.Book, error)
+	getDeletedBooksMutex       sync.RWMutex
+	getDeletedBooksArgsForCall []struct {
+		arg1 context.Context
+	}
+	getDeletedBooksReturns struct {
+		result1 []internal.Book
+		result2 error
+	}
+	getDeletedBooksReturnsOnCall map[int]struct {
+		result1 []internal.Book
+		result2 error
+	}
+	GetExpiredHoldsStub        func(context.Context, time.Time) ([]internal.Hold, error)
+	getExpiredHoldsMutex       sync.RWMutex
+	getExpiredHoldsArgsForCall []struct {
+		arg1 context.Context
+		arg2 time.Time
+	}
+	getExpiredHoldsReturns struct {
+		result1 []internal.Hold
+		result2 error
+	}
+	getExpiredHoldsReturnsOnCall map[int]struct {
+		result1 []internal.Hold
+		result2 error
+	}
+	GetHoldsStub        func(context.Context, string) ([]internal.Hold, error)
+	getHoldsMutex       sync.RWMutex
+	getHoldsArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getHoldsReturns struct {
+		result1 []internal.Hold
+		result2 error
+	}
+	getHoldsReturnsOnCall map[int]struct {
+		result1 []internal.Hold
+		result2 error
+	}
+	GetLedgerStub        func(context.Context, string) ([]internal.LedgerEntry, error)
+	getLedgerMutex       sync.RWMutex
+	getLedgerArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getLedgerReturns struct {
+		result1 []internal.LedgerEntry
+		result2 error
+	}
+	getLedgerReturnsOnCall map[int]struct {
+		result1 []internal.LedgerEntry
+		result2 error
+	}
+	GetOpenLoanStub        func(context.Context, string) (internal.Loan, error)
+	getOpenLoanMutex       sync.RWMutex
+	getOpenLoanArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getOpenLoanReturns struct {
+		result1 internal.Loan
+		result2 error
+	}
+	getOpenLoanReturnsOnCall map[int]struct {
+		result1 internal.Loan
+		result2 error
+	}
+	GetOverdueLoansStub        func(context.Context, time.Time) ([]internal.Loan, error)
+	getOverdueLoansMutex       sync.RWMutex
+	getOverdueLoansArgsForCall []struct {
+		arg1 context.Context
+		arg2 time.Time
+	}
+	getOverdueLoansReturns struct {
+		result1 []internal.Loan
+		result2 error
+	}
+	getOverdueLoansReturnsOnCall map[int]struct {
+		result1 []internal.Loan
+		result2 error
+	}
+	GetPatronLoansStub        func(context.Context, string) ([]internal.Loan, error)
+	getPatronLoansMutex       sync.RWMutex
+	getPatronLoansArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getPatronLoansReturns struct {
+		result1 []internal.Loan
+		result2 error
+	}
+	getPatronLoansReturnsOnCall map[int]struct {
+		result1 []internal.Loan
+		result2 error
+	}
+	PlaceHoldStub        func(context.Context, internal.Hold) (internal.Hold, error)
+	placeHoldMutex       sync.RWMutex
+	placeHoldArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.Hold
+	}
+	placeHoldReturns struct {
+		result1 internal.Hold
+		result2 error
+	}
+	placeHoldReturnsOnCall map[int]struct {
+		result1 internal.Hold
+		result2 error
+	}
+	PurgeBookStub        func(context.Context, string) error
+	purgeBookMutex       sync.RWMutex
+	purgeBookArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	purgeBookReturns struct {
+		result1 error
+	}
+	purgeBookReturnsOnCall map[int]struct {
+		result1 error
+	}
+	PurgeDeletedBooksStub        func(context.Context, time.Time) (int, error)
+	purgeDeletedBooksMutex       sync.RWMutex
+	purgeDeletedBooksArgsForCall []struct {
+		arg1 context.Context
+		arg2 time.Time
+	}
+	purgeDeletedBooksReturns struct {
+		result1 int
+		result2 error
+	}
+	purgeDeletedBooksReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
+	RenewLoanStub        func(context.Context, internal.Loan, time.Time) (internal.Loan, error)
+	renewLoanMutex       sync.RWMutex
+	renewLoanArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.Loan
+		arg3 time.Time
+	}
+	renewLoanReturns struct {
+		result1 internal.Loan
+		result2 error
+	}
+	renewLoanReturnsOnCall map[int]struct {
+		result1 internal.Loan
+		result2 error
+	}
+	ReserveCopyStub        func(context.Context, internal.Hold, string, internal.BookStatus, time.Time) (internal.Hold, error)
+	reserveCopyMutex       sync.RWMutex
+	reserveCopyArgsForCall []struct {
+		arg1 context.Context
+		arg2 internal.Hold
+		arg3 string
+		arg4 internal.BookStatus
+		arg5 time.Time
+	}
+	reserveCopyReturns struct {
+		result1 internal.Hold
+		result2 error
+	}
+	reserveCopyReturnsOnCall map[int]struct {
+		result1 internal.Hold
+		result2 error
+	}
+	RestoreBookStub        func(context.Context, string) (internal.Book, error)
+	restoreBookMutex       sync.RWMutex
+	restoreBookArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	restoreBookReturns struct {
+		result1 internal.Book
+		result2 error
+	}
+	restoreBookReturnsOnCall map[int]struct {
+		result1 internal.Book
+		result2 error
+	}
 	UpdateBookStub        func(context.Context, string, internal.Book) (internal.Book, error)
 	updateBookMutex       sync.RWMutex
 	updateBookArgsForCall []struct {
@@ -76,30 +405,1411 @@ type MockBooksDB struct {
 		result1 internal.Book
 		result2 error
 	}
-	updateBookReturnsOnCall map[int]struct {
-		result1 internal.Book
+	updateBookReturnsOnCall map[int]struct {
+		result1 internal.Book
+		result2 error
+	}
+	UpdateCopyStatusStub        func(context.Context, string, internal.BookStatus, internal.BookStatus) (internal.Copy, error)
+	updateCopyStatusMutex       sync.RWMutex
+	updateCopyStatusArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 internal.BookStatus
+		arg4 internal.BookStatus
+	}
+	updateCopyStatusReturns struct {
+		result1 internal.Copy
+		result2 error
+	}
+	updateCopyStatusReturnsOnCall map[int]struct {
+		result1 internal.Copy
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *MockBooksDB) AddCopy(arg1 context.Context, arg2 internal.Copy) (internal.Copy, error) {
+	fake.addCopyMutex.Lock()
+	ret, specificReturn := fake.addCopyReturnsOnCall[len(fake.addCopyArgsForCall)]
+	fake.addCopyArgsForCall = append(fake.addCopyArgsForCall, struct {
+		arg1 context.Context
+		arg2 internal.Copy
+	}{arg1, arg2})
+	stub := fake.AddCopyStub
+	fakeReturns := fake.addCopyReturns
+	fake.recordInvocation("AddCopy", []interface{}{arg1, arg2})
+	fake.addCopyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) AddCopyCallCount() int {
+	fake.addCopyMutex.RLock()
+	defer fake.addCopyMutex.RUnlock()
+	return len(fake.addCopyArgsForCall)
+}
+
+func (fake *MockBooksDB) AddCopyCalls(stub func(context.Context, internal.Copy) (internal.Copy, error)) {
+	fake.addCopyMutex.Lock()
+	defer fake.addCopyMutex.Unlock()
+	fake.AddCopyStub = stub
+}
+
+func (fake *MockBooksDB) AddCopyArgsForCall(i int) (context.Context, internal.Copy) {
+	fake.addCopyMutex.RLock()
+	defer fake.addCopyMutex.RUnlock()
+	argsForCall := fake.addCopyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) AddCopyReturns(result1 internal.Copy, result2 error) {
+	fake.addCopyMutex.Lock()
+	defer fake.addCopyMutex.Unlock()
+	fake.AddCopyStub = nil
+	fake.addCopyReturns = struct {
+		result1 internal.Copy
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) AddCopyReturnsOnCall(i int, result1 internal.Copy, result2 error) {
+	fake.addCopyMutex.Lock()
+	defer fake.addCopyMutex.Unlock()
+	fake.AddCopyStub = nil
+	if fake.addCopyReturnsOnCall == nil {
+		fake.addCopyReturnsOnCall = make(map[int]struct {
+			result1 internal.Copy
+			result2 error
+		})
+	}
+	fake.addCopyReturnsOnCall[i] = struct {
+		result1 internal.Copy
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) AddLedgerEntry(arg1 context.Context, arg2 internal.LedgerEntry) (internal.LedgerEntry, error) {
+	fake.addLedgerEntryMutex.Lock()
+	ret, specificReturn := fake.addLedgerEntryReturnsOnCall[len(fake.addLedgerEntryArgsForCall)]
+	fake.addLedgerEntryArgsForCall = append(fake.addLedgerEntryArgsForCall, struct {
+		arg1 context.Context
+		arg2 internal.LedgerEntry
+	}{arg1, arg2})
+	stub := fake.AddLedgerEntryStub
+	fakeReturns := fake.addLedgerEntryReturns
+	fake.recordInvocation("AddLedgerEntry", []interface{}{arg1, arg2})
+	fake.addLedgerEntryMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) AddLedgerEntryCallCount() int {
+	fake.addLedgerEntryMutex.RLock()
+	defer fake.addLedgerEntryMutex.RUnlock()
+	return len(fake.addLedgerEntryArgsForCall)
+}
+
+func (fake *MockBooksDB) AddLedgerEntryCalls(stub func(context.Context, internal.LedgerEntry) (internal.LedgerEntry, error)) {
+	fake.addLedgerEntryMutex.Lock()
+	defer fake.addLedgerEntryMutex.Unlock()
+	fake.AddLedgerEntryStub = stub
+}
+
+func (fake *MockBooksDB) AddLedgerEntryArgsForCall(i int) (context.Context, internal.LedgerEntry) {
+	fake.addLedgerEntryMutex.RLock()
+	defer fake.addLedgerEntryMutex.RUnlock()
+	argsForCall := fake.addLedgerEntryArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) AddLedgerEntryReturns(result1 internal.LedgerEntry, result2 error) {
+	fake.addLedgerEntryMutex.Lock()
+	defer fake.addLedgerEntryMutex.Unlock()
+	fake.AddLedgerEntryStub = nil
+	fake.addLedgerEntryReturns = struct {
+		result1 internal.LedgerEntry
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) AddLedgerEntryReturnsOnCall(i int, result1 internal.LedgerEntry, result2 error) {
+	fake.addLedgerEntryMutex.Lock()
+	defer fake.addLedgerEntryMutex.Unlock()
+	fake.AddLedgerEntryStub = nil
+	if fake.addLedgerEntryReturnsOnCall == nil {
+		fake.addLedgerEntryReturnsOnCall = make(map[int]struct {
+			result1 internal.LedgerEntry
+			result2 error
+		})
+	}
+	fake.addLedgerEntryReturnsOnCall[i] = struct {
+		result1 internal.LedgerEntry
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) CheckOutCopy(arg1 context.Context, arg2 internal.Loan, arg3 internal.BookStatus, arg4 *internal.Hold) (internal.Copy, internal.Loan, error) {
+	fake.checkOutCopyMutex.Lock()
+	ret, specificReturn := fake.checkOutCopyReturnsOnCall[len(fake.checkOutCopyArgsForCall)]
+	fake.checkOutCopyArgsForCall = append(fake.checkOutCopyArgsForCall, struct {
+		arg1 context.Context
+		arg2 internal.Loan
+		arg3 internal.BookStatus
+		arg4 *internal.Hold
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.CheckOutCopyStub
+	fakeReturns := fake.checkOutCopyReturns
+	fake.recordInvocation("CheckOutCopy", []interface{}{arg1, arg2, arg3, arg4})
+	fake.checkOutCopyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *MockBooksDB) CheckOutCopyCallCount() int {
+	fake.checkOutCopyMutex.RLock()
+	defer fake.checkOutCopyMutex.RUnlock()
+	return len(fake.checkOutCopyArgsForCall)
+}
+
+func (fake *MockBooksDB) CheckOutCopyCalls(stub func(context.Context, internal.Loan, internal.BookStatus, *internal.Hold) (internal.Copy, internal.Loan, error)) {
+	fake.checkOutCopyMutex.Lock()
+	defer fake.checkOutCopyMutex.Unlock()
+	fake.CheckOutCopyStub = stub
+}
+
+func (fake *MockBooksDB) CheckOutCopyArgsForCall(i int) (context.Context, internal.Loan, internal.BookStatus, *internal.Hold) {
+	fake.checkOutCopyMutex.RLock()
+	defer fake.checkOutCopyMutex.RUnlock()
+	argsForCall := fake.checkOutCopyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *MockBooksDB) CheckOutCopyReturns(result1 internal.Copy, result2 internal.Loan, result3 error) {
+	fake.checkOutCopyMutex.Lock()
+	defer fake.checkOutCopyMutex.Unlock()
+	fake.CheckOutCopyStub = nil
+	fake.checkOutCopyReturns = struct {
+		result1 internal.Copy
+		result2 internal.Loan
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *MockBooksDB) CheckOutCopyReturnsOnCall(i int, result1 internal.Copy, result2 internal.Loan, result3 error) {
+	fake.checkOutCopyMutex.Lock()
+	defer fake.checkOutCopyMutex.Unlock()
+	fake.CheckOutCopyStub = nil
+	if fake.checkOutCopyReturnsOnCall == nil {
+		fake.checkOutCopyReturnsOnCall = make(map[int]struct {
+			result1 internal.Copy
+			result2 internal.Loan
+			result3 error
+		})
+	}
+	fake.checkOutCopyReturnsOnCall[i] = struct {
+		result1 internal.Copy
+		result2 internal.Loan
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *MockBooksDB) CloseHold(arg1 context.Context, arg2 internal.Hold, arg3 internal.HoldStatus) error {
+	fake.closeHoldMutex.Lock()
+	ret, specificReturn := fake.closeHoldReturnsOnCall[len(fake.closeHoldArgsForCall)]
+	fake.closeHoldArgsForCall = append(fake.closeHoldArgsForCall, struct {
+		arg1 context.Context
+		arg2 internal.Hold
+		arg3 internal.HoldStatus
+	}{arg1, arg2, arg3})
+	stub := fake.CloseHoldStub
+	fakeReturns := fake.closeHoldReturns
+	fake.recordInvocation("CloseHold", []interface{}{arg1, arg2, arg3})
+	fake.closeHoldMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *MockBooksDB) CloseHoldCallCount() int {
+	fake.closeHoldMutex.RLock()
+	defer fake.closeHoldMutex.RUnlock()
+	return len(fake.closeHoldArgsForCall)
+}
+
+func (fake *MockBooksDB) CloseHoldCalls(stub func(context.Context, internal.Hold, internal.HoldStatus) error) {
+	fake.closeHoldMutex.Lock()
+	defer fake.closeHoldMutex.Unlock()
+	fake.CloseHoldStub = stub
+}
+
+func (fake *MockBooksDB) CloseHoldArgsForCall(i int) (context.Context, internal.Hold, internal.HoldStatus) {
+	fake.closeHoldMutex.RLock()
+	defer fake.closeHoldMutex.RUnlock()
+	argsForCall := fake.closeHoldArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockBooksDB) CloseHoldReturns(result1 error) {
+	fake.closeHoldMutex.Lock()
+	defer fake.closeHoldMutex.Unlock()
+	fake.CloseHoldStub = nil
+	fake.closeHoldReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *MockBooksDB) CloseHoldReturnsOnCall(i int, result1 error) {
+	fake.closeHoldMutex.Lock()
+	defer fake.closeHoldMutex.Unlock()
+	fake.CloseHoldStub = nil
+	if fake.closeHoldReturnsOnCall == nil {
+		fake.closeHoldReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.closeHoldReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *MockBooksDB) CloseLoan(arg1 context.Context, arg2 internal.Loan, arg3 time.Time) (internal.Loan, error) {
+	fake.closeLoanMutex.Lock()
+	ret, specificReturn := fake.closeLoanReturnsOnCall[len(fake.closeLoanArgsForCall)]
+	fake.closeLoanArgsForCall = append(fake.closeLoanArgsForCall, struct {
+		arg1 context.Context
+		arg2 internal.Loan
+		arg3 time.Time
+	}{arg1, arg2, arg3})
+	stub := fake.CloseLoanStub
+	fakeReturns := fake.closeLoanReturns
+	fake.recordInvocation("CloseLoan", []interface{}{arg1, arg2, arg3})
+	fake.closeLoanMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) CloseLoanCallCount() int {
+	fake.closeLoanMutex.RLock()
+	defer fake.closeLoanMutex.RUnlock()
+	return len(fake.closeLoanArgsForCall)
+}
+
+func (fake *MockBooksDB) CloseLoanCalls(stub func(context.Context, internal.Loan, time.Time) (internal.Loan, error)) {
+	fake.closeLoanMutex.Lock()
+	defer fake.closeLoanMutex.Unlock()
+	fake.CloseLoanStub = stub
+}
+
+func (fake *MockBooksDB) CloseLoanArgsForCall(i int) (context.Context, internal.Loan, time.Time) {
+	fake.closeLoanMutex.RLock()
+	defer fake.closeLoanMutex.RUnlock()
+	argsForCall := fake.closeLoanArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockBooksDB) CloseLoanReturns(result1 internal.Loan, result2 error) {
+	fake.closeLoanMutex.Lock()
+	defer fake.closeLoanMutex.Unlock()
+	fake.CloseLoanStub = nil
+	fake.closeLoanReturns = struct {
+		result1 internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) CloseLoanReturnsOnCall(i int, result1 internal.Loan, result2 error) {
+	fake.closeLoanMutex.Lock()
+	defer fake.closeLoanMutex.Unlock()
+	fake.CloseLoanStub = nil
+	if fake.closeLoanReturnsOnCall == nil {
+		fake.closeLoanReturnsOnCall = make(map[int]struct {
+			result1 internal.Loan
+			result2 error
+		})
+	}
+	fake.closeLoanReturnsOnCall[i] = struct {
+		result1 internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) CreateBook(arg1 context.Context, arg2 string, arg3 string, arg4 string, arg5 string) (internal.Book, error) {
+	fake.createBookMutex.Lock()
+	ret, specificReturn := fake.createBookReturnsOnCall[len(fake.createBookArgsForCall)]
+	fake.createBookArgsForCall = append(fake.createBookArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+		arg5 string
+	}{arg1, arg2, arg3, arg4, arg5})
+	stub := fake.CreateBookStub
+	fakeReturns := fake.createBookReturns
+	fake.recordInvocation("CreateBook", []interface{}{arg1, arg2, arg3, arg4, arg5})
+	fake.createBookMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) CreateBookCallCount() int {
+	fake.createBookMutex.RLock()
+	defer fake.createBookMutex.RUnlock()
+	return len(fake.createBookArgsForCall)
+}
+
+func (fake *MockBooksDB) CreateBookCalls(stub func(context.Context, string, string, string, string) (internal.Book, error)) {
+	fake.createBookMutex.Lock()
+	defer fake.createBookMutex.Unlock()
+	fake.CreateBookStub = stub
+}
+
+func (fake *MockBooksDB) CreateBookArgsForCall(i int) (context.Context, string, string, string, string) {
+	fake.createBookMutex.RLock()
+	defer fake.createBookMutex.RUnlock()
+	argsForCall := fake.createBookArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *MockBooksDB) CreateBookReturns(result1 internal.Book, result2 error) {
+	fake.createBookMutex.Lock()
+	defer fake.createBookMutex.Unlock()
+	fake.CreateBookStub = nil
+	fake.createBookReturns = struct {
+		result1 internal.Book
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) CreateBookReturnsOnCall(i int, result1 internal.Book, result2 error) {
+	fake.createBookMutex.Lock()
+	defer fake.createBookMutex.Unlock()
+	fake.CreateBookStub = nil
+	if fake.createBookReturnsOnCall == nil {
+		fake.createBookReturnsOnCall = make(map[int]struct {
+			result1 internal.Book
+			result2 error
+		})
+	}
+	fake.createBookReturnsOnCall[i] = struct {
+		result1 internal.Book
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) CreateLoan(arg1 context.Context, arg2 internal.Loan) (internal.Loan, error) {
+	fake.createLoanMutex.Lock()
+	ret, specificReturn := fake.createLoanReturnsOnCall[len(fake.createLoanArgsForCall)]
+	fake.createLoanArgsForCall = append(fake.createLoanArgsForCall, struct {
+		arg1 context.Context
+		arg2 internal.Loan
+	}{arg1, arg2})
+	stub := fake.CreateLoanStub
+	fakeReturns := fake.createLoanReturns
+	fake.recordInvocation("CreateLoan", []interface{}{arg1, arg2})
+	fake.createLoanMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) CreateLoanCallCount() int {
+	fake.createLoanMutex.RLock()
+	defer fake.createLoanMutex.RUnlock()
+	return len(fake.createLoanArgsForCall)
+}
+
+func (fake *MockBooksDB) CreateLoanCalls(stub func(context.Context, internal.Loan) (internal.Loan, error)) {
+	fake.createLoanMutex.Lock()
+	defer fake.createLoanMutex.Unlock()
+	fake.CreateLoanStub = stub
+}
+
+func (fake *MockBooksDB) CreateLoanArgsForCall(i int) (context.Context, internal.Loan) {
+	fake.createLoanMutex.RLock()
+	defer fake.createLoanMutex.RUnlock()
+	argsForCall := fake.createLoanArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) CreateLoanReturns(result1 internal.Loan, result2 error) {
+	fake.createLoanMutex.Lock()
+	defer fake.createLoanMutex.Unlock()
+	fake.CreateLoanStub = nil
+	fake.createLoanReturns = struct {
+		result1 internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) CreateLoanReturnsOnCall(i int, result1 internal.Loan, result2 error) {
+	fake.createLoanMutex.Lock()
+	defer fake.createLoanMutex.Unlock()
+	fake.CreateLoanStub = nil
+	if fake.createLoanReturnsOnCall == nil {
+		fake.createLoanReturnsOnCall = make(map[int]struct {
+			result1 internal.Loan
+			result2 error
+		})
+	}
+	fake.createLoanReturnsOnCall[i] = struct {
+		result1 internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) DeleteBook(arg1 context.Context, arg2 string) error {
+	fake.deleteBookMutex.Lock()
+	ret, specificReturn := fake.deleteBookReturnsOnCall[len(fake.deleteBookArgsForCall)]
+	fake.deleteBookArgsForCall = append(fake.deleteBookArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.DeleteBookStub
+	fakeReturns := fake.deleteBookReturns
+	fake.recordInvocation("DeleteBook", []interface{}{arg1, arg2})
+	fake.deleteBookMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *MockBooksDB) DeleteBookCallCount() int {
+	fake.deleteBookMutex.RLock()
+	defer fake.deleteBookMutex.RUnlock()
+	return len(fake.deleteBookArgsForCall)
+}
+
+func (fake *MockBooksDB) DeleteBookCalls(stub func(context.Context, string) error) {
+	fake.deleteBookMutex.Lock()
+	defer fake.deleteBookMutex.Unlock()
+	fake.DeleteBookStub = stub
+}
+
+func (fake *MockBooksDB) DeleteBookArgsForCall(i int) (context.Context, string) {
+	fake.deleteBookMutex.RLock()
+	defer fake.deleteBookMutex.RUnlock()
+	argsForCall := fake.deleteBookArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) DeleteBookReturns(result1 error) {
+	fake.deleteBookMutex.Lock()
+	defer fake.deleteBookMutex.Unlock()
+	fake.DeleteBookStub = nil
+	fake.deleteBookReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *MockBooksDB) DeleteBookReturnsOnCall(i int, result1 error) {
+	fake.deleteBookMutex.Lock()
+	defer fake.deleteBookMutex.Unlock()
+	fake.DeleteBookStub = nil
+	if fake.deleteBookReturnsOnCall == nil {
+		fake.deleteBookReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteBookReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *MockBooksDB) GetAuditEvents(arg1 context.Context, arg2 internal.AuditFilter) ([]internal.AuditEvent, error) {
+	fake.getAuditEventsMutex.Lock()
+	ret, specificReturn := fake.getAuditEventsReturnsOnCall[len(fake.getAuditEventsArgsForCall)]
+	fake.getAuditEventsArgsForCall = append(fake.getAuditEventsArgsForCall, struct {
+		arg1 context.Context
+		arg2 internal.AuditFilter
+	}{arg1, arg2})
+	stub := fake.GetAuditEventsStub
+	fakeReturns := fake.getAuditEventsReturns
+	fake.recordInvocation("GetAuditEvents", []interface{}{arg1, arg2})
+	fake.getAuditEventsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetAuditEventsCallCount() int {
+	fake.getAuditEventsMutex.RLock()
+	defer fake.getAuditEventsMutex.RUnlock()
+	return len(fake.getAuditEventsArgsForCall)
+}
+
+func (fake *MockBooksDB) GetAuditEventsCalls(stub func(context.Context, internal.AuditFilter) ([]internal.AuditEvent, error)) {
+	fake.getAuditEventsMutex.Lock()
+	defer fake.getAuditEventsMutex.Unlock()
+	fake.GetAuditEventsStub = stub
+}
+
+func (fake *MockBooksDB) GetAuditEventsArgsForCall(i int) (context.Context, internal.AuditFilter) {
+	fake.getAuditEventsMutex.RLock()
+	defer fake.getAuditEventsMutex.RUnlock()
+	argsForCall := fake.getAuditEventsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetAuditEventsReturns(result1 []internal.AuditEvent, result2 error) {
+	fake.getAuditEventsMutex.Lock()
+	defer fake.getAuditEventsMutex.Unlock()
+	fake.GetAuditEventsStub = nil
+	fake.getAuditEventsReturns = struct {
+		result1 []internal.AuditEvent
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetAuditEventsReturnsOnCall(i int, result1 []internal.AuditEvent, result2 error) {
+	fake.getAuditEventsMutex.Lock()
+	defer fake.getAuditEventsMutex.Unlock()
+	fake.GetAuditEventsStub = nil
+	if fake.getAuditEventsReturnsOnCall == nil {
+		fake.getAuditEventsReturnsOnCall = make(map[int]struct {
+			result1 []internal.AuditEvent
+			result2 error
+		})
+	}
+	fake.getAuditEventsReturnsOnCall[i] = struct {
+		result1 []internal.AuditEvent
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetBookByID(arg1 context.Context, arg2 string) (internal.Book, error) {
+	fake.getBookByIDMutex.Lock()
+	ret, specificReturn := fake.getBookByIDReturnsOnCall[len(fake.getBookByIDArgsForCall)]
+	fake.getBookByIDArgsForCall = append(fake.getBookByIDArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.GetBookByIDStub
+	fakeReturns := fake.getBookByIDReturns
+	fake.recordInvocation("GetBookByID", []interface{}{arg1, arg2})
+	fake.getBookByIDMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetBookByIDCallCount() int {
+	fake.getBookByIDMutex.RLock()
+	defer fake.getBookByIDMutex.RUnlock()
+	return len(fake.getBookByIDArgsForCall)
+}
+
+func (fake *MockBooksDB) GetBookByIDCalls(stub func(context.Context, string) (internal.Book, error)) {
+	fake.getBookByIDMutex.Lock()
+	defer fake.getBookByIDMutex.Unlock()
+	fake.GetBookByIDStub = stub
+}
+
+func (fake *MockBooksDB) GetBookByIDArgsForCall(i int) (context.Context, string) {
+	fake.getBookByIDMutex.RLock()
+	defer fake.getBookByIDMutex.RUnlock()
+	argsForCall := fake.getBookByIDArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetBookByIDReturns(result1 internal.Book, result2 error) {
+	fake.getBookByIDMutex.Lock()
+	defer fake.getBookByIDMutex.Unlock()
+	fake.GetBookByIDStub = nil
+	fake.getBookByIDReturns = struct {
+		result1 internal.Book
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetBookByIDReturnsOnCall(i int, result1 internal.Book, result2 error) {
+	fake.getBookByIDMutex.Lock()
+	defer fake.getBookByIDMutex.Unlock()
+	fake.GetBookByIDStub = nil
+	if fake.getBookByIDReturnsOnCall == nil {
+		fake.getBookByIDReturnsOnCall = make(map[int]struct {
+			result1 internal.Book
+			result2 error
+		})
+	}
+	fake.getBookByIDReturnsOnCall[i] = struct {
+		result1 internal.Book
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetBookHistory(arg1 context.Context, arg2 string) ([]internal.AuditEvent, error) {
+	fake.getBookHistoryMutex.Lock()
+	ret, specificReturn := fake.getBookHistoryReturnsOnCall[len(fake.getBookHistoryArgsForCall)]
+	fake.getBookHistoryArgsForCall = append(fake.getBookHistoryArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.GetBookHistoryStub
+	fakeReturns := fake.getBookHistoryReturns
+	fake.recordInvocation("GetBookHistory", []interface{}{arg1, arg2})
+	fake.getBookHistoryMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetBookHistoryCallCount() int {
+	fake.getBookHistoryMutex.RLock()
+	defer fake.getBookHistoryMutex.RUnlock()
+	return len(fake.getBookHistoryArgsForCall)
+}
+
+func (fake *MockBooksDB) GetBookHistoryCalls(stub func(context.Context, string) ([]internal.AuditEvent, error)) {
+	fake.getBookHistoryMutex.Lock()
+	defer fake.getBookHistoryMutex.Unlock()
+	fake.GetBookHistoryStub = stub
+}
+
+func (fake *MockBooksDB) GetBookHistoryArgsForCall(i int) (context.Context, string) {
+	fake.getBookHistoryMutex.RLock()
+	defer fake.getBookHistoryMutex.RUnlock()
+	argsForCall := fake.getBookHistoryArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetBookHistoryReturns(result1 []internal.AuditEvent, result2 error) {
+	fake.getBookHistoryMutex.Lock()
+	defer fake.getBookHistoryMutex.Unlock()
+	fake.GetBookHistoryStub = nil
+	fake.getBookHistoryReturns = struct {
+		result1 []internal.AuditEvent
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetBookHistoryReturnsOnCall(i int, result1 []internal.AuditEvent, result2 error) {
+	fake.getBookHistoryMutex.Lock()
+	defer fake.getBookHistoryMutex.Unlock()
+	fake.GetBookHistoryStub = nil
+	if fake.getBookHistoryReturnsOnCall == nil {
+		fake.getBookHistoryReturnsOnCall = make(map[int]struct {
+			result1 []internal.AuditEvent
+			result2 error
+		})
+	}
+	fake.getBookHistoryReturnsOnCall[i] = struct {
+		result1 []internal.AuditEvent
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetBooks(arg1 context.Context, arg2 internal.BookFilter) ([]internal.Book, error) {
+	fake.getBooksMutex.Lock()
+	ret, specificReturn := fake.getBooksReturnsOnCall[len(fake.getBooksArgsForCall)]
+	fake.getBooksArgsForCall = append(fake.getBooksArgsForCall, struct {
+		arg1 context.Context
+		arg2 internal.BookFilter
+	}{arg1, arg2})
+	stub := fake.GetBooksStub
+	fakeReturns := fake.getBooksReturns
+	fake.recordInvocation("GetBooks", []interface{}{arg1, arg2})
+	fake.getBooksMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetBooksCallCount() int {
+	fake.getBooksMutex.RLock()
+	defer fake.getBooksMutex.RUnlock()
+	return len(fake.getBooksArgsForCall)
+}
+
+func (fake *MockBooksDB) GetBooksCalls(stub func(context.Context, internal.BookFilter) ([]internal.Book, error)) {
+	fake.getBooksMutex.Lock()
+	defer fake.getBooksMutex.Unlock()
+	fake.GetBooksStub = stub
+}
+
+func (fake *MockBooksDB) GetBooksArgsForCall(i int) (context.Context, internal.BookFilter) {
+	fake.getBooksMutex.RLock()
+	defer fake.getBooksMutex.RUnlock()
+	argsForCall := fake.getBooksArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetBooksReturns(result1 []internal.Book, result2 error) {
+	fake.getBooksMutex.Lock()
+	defer fake.getBooksMutex.Unlock()
+	fake.GetBooksStub = nil
+	fake.getBooksReturns = struct {
+		result1 []internal.Book
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetBooksReturnsOnCall(i int, result1 []internal.Book, result2 error) {
+	fake.getBooksMutex.Lock()
+	defer fake.getBooksMutex.Unlock()
+	fake.GetBooksStub = nil
+	if fake.getBooksReturnsOnCall == nil {
+		fake.getBooksReturnsOnCall = make(map[int]struct {
+			result1 []internal.Book
+			result2 error
+		})
+	}
+	fake.getBooksReturnsOnCall[i] = struct {
+		result1 []internal.Book
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetCopies(arg1 context.Context, arg2 string) ([]internal.Copy, error) {
+	fake.getCopiesMutex.Lock()
+	ret, specificReturn := fake.getCopiesReturnsOnCall[len(fake.getCopiesArgsForCall)]
+	fake.getCopiesArgsForCall = append(fake.getCopiesArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.GetCopiesStub
+	fakeReturns := fake.getCopiesReturns
+	fake.recordInvocation("GetCopies", []interface{}{arg1, arg2})
+	fake.getCopiesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetCopiesCallCount() int {
+	fake.getCopiesMutex.RLock()
+	defer fake.getCopiesMutex.RUnlock()
+	return len(fake.getCopiesArgsForCall)
+}
+
+func (fake *MockBooksDB) GetCopiesCalls(stub func(context.Context, string) ([]internal.Copy, error)) {
+	fake.getCopiesMutex.Lock()
+	defer fake.getCopiesMutex.Unlock()
+	fake.GetCopiesStub = stub
+}
+
+func (fake *MockBooksDB) GetCopiesArgsForCall(i int) (context.Context, string) {
+	fake.getCopiesMutex.RLock()
+	defer fake.getCopiesMutex.RUnlock()
+	argsForCall := fake.getCopiesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetCopiesReturns(result1 []internal.Copy, result2 error) {
+	fake.getCopiesMutex.Lock()
+	defer fake.getCopiesMutex.Unlock()
+	fake.GetCopiesStub = nil
+	fake.getCopiesReturns = struct {
+		result1 []internal.Copy
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetCopiesReturnsOnCall(i int, result1 []internal.Copy, result2 error) {
+	fake.getCopiesMutex.Lock()
+	defer fake.getCopiesMutex.Unlock()
+	fake.GetCopiesStub = nil
+	if fake.getCopiesReturnsOnCall == nil {
+		fake.getCopiesReturnsOnCall = make(map[int]struct {
+			result1 []internal.Copy
+			result2 error
+		})
+	}
+	fake.getCopiesReturnsOnCall[i] = struct {
+		result1 []internal.Copy
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetCopyByBarcode(arg1 context.Context, arg2 string) (internal.Copy, error) {
+	fake.getCopyByBarcodeMutex.Lock()
+	ret, specificReturn := fake.getCopyByBarcodeReturnsOnCall[len(fake.getCopyByBarcodeArgsForCall)]
+	fake.getCopyByBarcodeArgsForCall = append(fake.getCopyByBarcodeArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.GetCopyByBarcodeStub
+	fakeReturns := fake.getCopyByBarcodeReturns
+	fake.recordInvocation("GetCopyByBarcode", []interface{}{arg1, arg2})
+	fake.getCopyByBarcodeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetCopyByBarcodeCallCount() int {
+	fake.getCopyByBarcodeMutex.RLock()
+	defer fake.getCopyByBarcodeMutex.RUnlock()
+	return len(fake.getCopyByBarcodeArgsForCall)
+}
+
+func (fake *MockBooksDB) GetCopyByBarcodeCalls(stub func(context.Context, string) (internal.Copy, error)) {
+	fake.getCopyByBarcodeMutex.Lock()
+	defer fake.getCopyByBarcodeMutex.Unlock()
+	fake.GetCopyByBarcodeStub = stub
+}
+
+func (fake *MockBooksDB) GetCopyByBarcodeArgsForCall(i int) (context.Context, string) {
+	fake.getCopyByBarcodeMutex.RLock()
+	defer fake.getCopyByBarcodeMutex.RUnlock()
+	argsForCall := fake.getCopyByBarcodeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetCopyByBarcodeReturns(result1 internal.Copy, result2 error) {
+	fake.getCopyByBarcodeMutex.Lock()
+	defer fake.getCopyByBarcodeMutex.Unlock()
+	fake.GetCopyByBarcodeStub = nil
+	fake.getCopyByBarcodeReturns = struct {
+		result1 internal.Copy
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetCopyByBarcodeReturnsOnCall(i int, result1 internal.Copy, result2 error) {
+	fake.getCopyByBarcodeMutex.Lock()
+	defer fake.getCopyByBarcodeMutex.Unlock()
+	fake.GetCopyByBarcodeStub = nil
+	if fake.getCopyByBarcodeReturnsOnCall == nil {
+		fake.getCopyByBarcodeReturnsOnCall = make(map[int]struct {
+			result1 internal.Copy
+			result2 error
+		})
+	}
+	fake.getCopyByBarcodeReturnsOnCall[i] = struct {
+		result1 internal.Copy
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetDeletedBooks(arg1 context.Context) ([]internal.Book, error) {
+	fake.getDeletedBooksMutex.Lock()
+	ret, specificReturn := fake.getDeletedBooksReturnsOnCall[len(fake.getDeletedBooksArgsForCall)]
+	fake.getDeletedBooksArgsForCall = append(fake.getDeletedBooksArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	stub := fake.GetDeletedBooksStub
+	fakeReturns := fake.getDeletedBooksReturns
+	fake.recordInvocation("GetDeletedBooks", []interface{}{arg1})
+	fake.getDeletedBooksMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetDeletedBooksCallCount() int {
+	fake.getDeletedBooksMutex.RLock()
+	defer fake.getDeletedBooksMutex.RUnlock()
+	return len(fake.getDeletedBooksArgsForCall)
+}
+
+func (fake *MockBooksDB) GetDeletedBooksCalls(stub func(context.Context) ([]internal.Book, error)) {
+	fake.getDeletedBooksMutex.Lock()
+	defer fake.getDeletedBooksMutex.Unlock()
+	fake.GetDeletedBooksStub = stub
+}
+
+func (fake *MockBooksDB) GetDeletedBooksArgsForCall(i int) context.Context {
+	fake.getDeletedBooksMutex.RLock()
+	defer fake.getDeletedBooksMutex.RUnlock()
+	argsForCall := fake.getDeletedBooksArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *MockBooksDB) GetDeletedBooksReturns(result1 []internal.Book, result2 error) {
+	fake.getDeletedBooksMutex.Lock()
+	defer fake.getDeletedBooksMutex.Unlock()
+	fake.GetDeletedBooksStub = nil
+	fake.getDeletedBooksReturns = struct {
+		result1 []internal.Book
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetDeletedBooksReturnsOnCall(i int, result1 []internal.Book, result2 error) {
+	fake.getDeletedBooksMutex.Lock()
+	defer fake.getDeletedBooksMutex.Unlock()
+	fake.GetDeletedBooksStub = nil
+	if fake.getDeletedBooksReturnsOnCall == nil {
+		fake.getDeletedBooksReturnsOnCall = make(map[int]struct {
+			result1 []internal.Book
+			result2 error
+		})
+	}
+	fake.getDeletedBooksReturnsOnCall[i] = struct {
+		result1 []internal.Book
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetExpiredHolds(arg1 context.Context, arg2 time.Time) ([]internal.Hold, error) {
+	fake.getExpiredHoldsMutex.Lock()
+	ret, specificReturn := fake.getExpiredHoldsReturnsOnCall[len(fake.getExpiredHoldsArgsForCall)]
+	fake.getExpiredHoldsArgsForCall = append(fake.getExpiredHoldsArgsForCall, struct {
+		arg1 context.Context
+		arg2 time.Time
+	}{arg1, arg2})
+	stub := fake.GetExpiredHoldsStub
+	fakeReturns := fake.getExpiredHoldsReturns
+	fake.recordInvocation("GetExpiredHolds", []interface{}{arg1, arg2})
+	fake.getExpiredHoldsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetExpiredHoldsCallCount() int {
+	fake.getExpiredHoldsMutex.RLock()
+	defer fake.getExpiredHoldsMutex.RUnlock()
+	return len(fake.getExpiredHoldsArgsForCall)
+}
+
+func (fake *MockBooksDB) GetExpiredHoldsCalls(stub func(context.Context, time.Time) ([]internal.Hold, error)) {
+	fake.getExpiredHoldsMutex.Lock()
+	defer fake.getExpiredHoldsMutex.Unlock()
+	fake.GetExpiredHoldsStub = stub
+}
+
+func (fake *MockBooksDB) GetExpiredHoldsArgsForCall(i int) (context.Context, time.Time) {
+	fake.getExpiredHoldsMutex.RLock()
+	defer fake.getExpiredHoldsMutex.RUnlock()
+	argsForCall := fake.getExpiredHoldsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetExpiredHoldsReturns(result1 []internal.Hold, result2 error) {
+	fake.getExpiredHoldsMutex.Lock()
+	defer fake.getExpiredHoldsMutex.Unlock()
+	fake.GetExpiredHoldsStub = nil
+	fake.getExpiredHoldsReturns = struct {
+		result1 []internal.Hold
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetExpiredHoldsReturnsOnCall(i int, result1 []internal.Hold, result2 error) {
+	fake.getExpiredHoldsMutex.Lock()
+	defer fake.getExpiredHoldsMutex.Unlock()
+	fake.GetExpiredHoldsStub = nil
+	if fake.getExpiredHoldsReturnsOnCall == nil {
+		fake.getExpiredHoldsReturnsOnCall = make(map[int]struct {
+			result1 []internal.Hold
+			result2 error
+		})
+	}
+	fake.getExpiredHoldsReturnsOnCall[i] = struct {
+		result1 []internal.Hold
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetHolds(arg1 context.Context, arg2 string) ([]internal.Hold, error) {
+	fake.getHoldsMutex.Lock()
+	ret, specificReturn := fake.getHoldsReturnsOnCall[len(fake.getHoldsArgsForCall)]
+	fake.getHoldsArgsForCall = append(fake.getHoldsArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.GetHoldsStub
+	fakeReturns := fake.getHoldsReturns
+	fake.recordInvocation("GetHolds", []interface{}{arg1, arg2})
+	fake.getHoldsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetHoldsCallCount() int {
+	fake.getHoldsMutex.RLock()
+	defer fake.getHoldsMutex.RUnlock()
+	return len(fake.getHoldsArgsForCall)
+}
+
+func (fake *MockBooksDB) GetHoldsCalls(stub func(context.Context, string) ([]internal.Hold, error)) {
+	fake.getHoldsMutex.Lock()
+	defer fake.getHoldsMutex.Unlock()
+	fake.GetHoldsStub = stub
+}
+
+func (fake *MockBooksDB) GetHoldsArgsForCall(i int) (context.Context, string) {
+	fake.getHoldsMutex.RLock()
+	defer fake.getHoldsMutex.RUnlock()
+	argsForCall := fake.getHoldsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetHoldsReturns(result1 []internal.Hold, result2 error) {
+	fake.getHoldsMutex.Lock()
+	defer fake.getHoldsMutex.Unlock()
+	fake.GetHoldsStub = nil
+	fake.getHoldsReturns = struct {
+		result1 []internal.Hold
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetHoldsReturnsOnCall(i int, result1 []internal.Hold, result2 error) {
+	fake.getHoldsMutex.Lock()
+	defer fake.getHoldsMutex.Unlock()
+	fake.GetHoldsStub = nil
+	if fake.getHoldsReturnsOnCall == nil {
+		fake.getHoldsReturnsOnCall = make(map[int]struct {
+			result1 []internal.Hold
+			result2 error
+		})
+	}
+	fake.getHoldsReturnsOnCall[i] = struct {
+		result1 []internal.Hold
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetLedger(arg1 context.Context, arg2 string) ([]internal.LedgerEntry, error) {
+	fake.getLedgerMutex.Lock()
+	ret, specificReturn := fake.getLedgerReturnsOnCall[len(fake.getLedgerArgsForCall)]
+	fake.getLedgerArgsForCall = append(fake.getLedgerArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.GetLedgerStub
+	fakeReturns := fake.getLedgerReturns
+	fake.recordInvocation("GetLedger", []interface{}{arg1, arg2})
+	fake.getLedgerMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetLedgerCallCount() int {
+	fake.getLedgerMutex.RLock()
+	defer fake.getLedgerMutex.RUnlock()
+	return len(fake.getLedgerArgsForCall)
+}
+
+func (fake *MockBooksDB) GetLedgerCalls(stub func(context.Context, string) ([]internal.LedgerEntry, error)) {
+	fake.getLedgerMutex.Lock()
+	defer fake.getLedgerMutex.Unlock()
+	fake.GetLedgerStub = stub
+}
+
+func (fake *MockBooksDB) GetLedgerArgsForCall(i int) (context.Context, string) {
+	fake.getLedgerMutex.RLock()
+	defer fake.getLedgerMutex.RUnlock()
+	argsForCall := fake.getLedgerArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetLedgerReturns(result1 []internal.LedgerEntry, result2 error) {
+	fake.getLedgerMutex.Lock()
+	defer fake.getLedgerMutex.Unlock()
+	fake.GetLedgerStub = nil
+	fake.getLedgerReturns = struct {
+		result1 []internal.LedgerEntry
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetLedgerReturnsOnCall(i int, result1 []internal.LedgerEntry, result2 error) {
+	fake.getLedgerMutex.Lock()
+	defer fake.getLedgerMutex.Unlock()
+	fake.GetLedgerStub = nil
+	if fake.getLedgerReturnsOnCall == nil {
+		fake.getLedgerReturnsOnCall = make(map[int]struct {
+			result1 []internal.LedgerEntry
+			result2 error
+		})
+	}
+	fake.getLedgerReturnsOnCall[i] = struct {
+		result1 []internal.LedgerEntry
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetOpenLoan(arg1 context.Context, arg2 string) (internal.Loan, error) {
+	fake.getOpenLoanMutex.Lock()
+	ret, specificReturn := fake.getOpenLoanReturnsOnCall[len(fake.getOpenLoanArgsForCall)]
+	fake.getOpenLoanArgsForCall = append(fake.getOpenLoanArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.GetOpenLoanStub
+	fakeReturns := fake.getOpenLoanReturns
+	fake.recordInvocation("GetOpenLoan", []interface{}{arg1, arg2})
+	fake.getOpenLoanMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetOpenLoanCallCount() int {
+	fake.getOpenLoanMutex.RLock()
+	defer fake.getOpenLoanMutex.RUnlock()
+	return len(fake.getOpenLoanArgsForCall)
+}
+
+func (fake *MockBooksDB) GetOpenLoanCalls(stub func(context.Context, string) (internal.Loan, error)) {
+	fake.getOpenLoanMutex.Lock()
+	defer fake.getOpenLoanMutex.Unlock()
+	fake.GetOpenLoanStub = stub
+}
+
+func (fake *MockBooksDB) GetOpenLoanArgsForCall(i int) (context.Context, string) {
+	fake.getOpenLoanMutex.RLock()
+	defer fake.getOpenLoanMutex.RUnlock()
+	argsForCall := fake.getOpenLoanArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetOpenLoanReturns(result1 internal.Loan, result2 error) {
+	fake.getOpenLoanMutex.Lock()
+	defer fake.getOpenLoanMutex.Unlock()
+	fake.GetOpenLoanStub = nil
+	fake.getOpenLoanReturns = struct {
+		result1 internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetOpenLoanReturnsOnCall(i int, result1 internal.Loan, result2 error) {
+	fake.getOpenLoanMutex.Lock()
+	defer fake.getOpenLoanMutex.Unlock()
+	fake.GetOpenLoanStub = nil
+	if fake.getOpenLoanReturnsOnCall == nil {
+		fake.getOpenLoanReturnsOnCall = make(map[int]struct {
+			result1 internal.Loan
+			result2 error
+		})
+	}
+	fake.getOpenLoanReturnsOnCall[i] = struct {
+		result1 internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetOverdueLoans(arg1 context.Context, arg2 time.Time) ([]internal.Loan, error) {
+	fake.getOverdueLoansMutex.Lock()
+	ret, specificReturn := fake.getOverdueLoansReturnsOnCall[len(fake.getOverdueLoansArgsForCall)]
+	fake.getOverdueLoansArgsForCall = append(fake.getOverdueLoansArgsForCall, struct {
+		arg1 context.Context
+		arg2 time.Time
+	}{arg1, arg2})
+	stub := fake.GetOverdueLoansStub
+	fakeReturns := fake.getOverdueLoansReturns
+	fake.recordInvocation("GetOverdueLoans", []interface{}{arg1, arg2})
+	fake.getOverdueLoansMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetOverdueLoansCallCount() int {
+	fake.getOverdueLoansMutex.RLock()
+	defer fake.getOverdueLoansMutex.RUnlock()
+	return len(fake.getOverdueLoansArgsForCall)
+}
+
+func (fake *MockBooksDB) GetOverdueLoansCalls(stub func(context.Context, time.Time) ([]internal.Loan, error)) {
+	fake.getOverdueLoansMutex.Lock()
+	defer fake.getOverdueLoansMutex.Unlock()
+	fake.GetOverdueLoansStub = stub
+}
+
+func (fake *MockBooksDB) GetOverdueLoansArgsForCall(i int) (context.Context, time.Time) {
+	fake.getOverdueLoansMutex.RLock()
+	defer fake.getOverdueLoansMutex.RUnlock()
+	argsForCall := fake.getOverdueLoansArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetOverdueLoansReturns(result1 []internal.Loan, result2 error) {
+	fake.getOverdueLoansMutex.Lock()
+	defer fake.getOverdueLoansMutex.Unlock()
+	fake.GetOverdueLoansStub = nil
+	fake.getOverdueLoansReturns = struct {
+		result1 []internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetOverdueLoansReturnsOnCall(i int, result1 []internal.Loan, result2 error) {
+	fake.getOverdueLoansMutex.Lock()
+	defer fake.getOverdueLoansMutex.Unlock()
+	fake.GetOverdueLoansStub = nil
+	if fake.getOverdueLoansReturnsOnCall == nil {
+		fake.getOverdueLoansReturnsOnCall = make(map[int]struct {
+			result1 []internal.Loan
+			result2 error
+		})
+	}
+	fake.getOverdueLoansReturnsOnCall[i] = struct {
+		result1 []internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetPatronLoans(arg1 context.Context, arg2 string) ([]internal.Loan, error) {
+	fake.getPatronLoansMutex.Lock()
+	ret, specificReturn := fake.getPatronLoansReturnsOnCall[len(fake.getPatronLoansArgsForCall)]
+	fake.getPatronLoansArgsForCall = append(fake.getPatronLoansArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.GetPatronLoansStub
+	fakeReturns := fake.getPatronLoansReturns
+	fake.recordInvocation("GetPatronLoans", []interface{}{arg1, arg2})
+	fake.getPatronLoansMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) GetPatronLoansCallCount() int {
+	fake.getPatronLoansMutex.RLock()
+	defer fake.getPatronLoansMutex.RUnlock()
+	return len(fake.getPatronLoansArgsForCall)
+}
+
+func (fake *MockBooksDB) GetPatronLoansCalls(stub func(context.Context, string) ([]internal.Loan, error)) {
+	fake.getPatronLoansMutex.Lock()
+	defer fake.getPatronLoansMutex.Unlock()
+	fake.GetPatronLoansStub = stub
+}
+
+func (fake *MockBooksDB) GetPatronLoansArgsForCall(i int) (context.Context, string) {
+	fake.getPatronLoansMutex.RLock()
+	defer fake.getPatronLoansMutex.RUnlock()
+	argsForCall := fake.getPatronLoansArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) GetPatronLoansReturns(result1 []internal.Loan, result2 error) {
+	fake.getPatronLoansMutex.Lock()
+	defer fake.getPatronLoansMutex.Unlock()
+	fake.GetPatronLoansStub = nil
+	fake.getPatronLoansReturns = struct {
+		result1 []internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) GetPatronLoansReturnsOnCall(i int, result1 []internal.Loan, result2 error) {
+	fake.getPatronLoansMutex.Lock()
+	defer fake.getPatronLoansMutex.Unlock()
+	fake.GetPatronLoansStub = nil
+	if fake.getPatronLoansReturnsOnCall == nil {
+		fake.getPatronLoansReturnsOnCall = make(map[int]struct {
+			result1 []internal.Loan
+			result2 error
+		})
+	}
+	fake.getPatronLoansReturnsOnCall[i] = struct {
+		result1 []internal.Loan
 		result2 error
-	}
-	invocations      map[string][][]interface{}
-	invocationsMutex sync.RWMutex
+	}{result1, result2}
 }
 
-func (fake *MockBooksDB) CreateBook(arg1 context.Context, arg2 string, arg3 string, arg4 string, arg5 string) (internal.Book, error) {
-	fake.createBookMutex.Lock()
-	ret, specificReturn := fake.createBookReturnsOnCall[len(fake.createBookArgsForCall)]
-	fake.createBookArgsForCall = append(fake.createBookArgsForCall, struct {
+func (fake *MockBooksDB) PlaceHold(arg1 context.Context, arg2 internal.Hold) (internal.Hold, error) {
+	fake.placeHoldMutex.Lock()
+	ret, specificReturn := fake.placeHoldReturnsOnCall[len(fake.placeHoldArgsForCall)]
+	fake.placeHoldArgsForCall = append(fake.placeHoldArgsForCall, struct {
 		arg1 context.Context
-		arg2 string
-		arg3 string
-		arg4 string
-		arg5 string
-	}{arg1, arg2, arg3, arg4, arg5})
-	stub := fake.CreateBookStub
-	fakeReturns := fake.createBookReturns
-	fake.recordInvocation("CreateBook", []interface{}{arg1, arg2, arg3, arg4, arg5})
-	fake.createBookMutex.Unlock()
+		arg2 internal.Hold
+	}{arg1, arg2})
+	stub := fake.PlaceHoldStub
+	fakeReturns := fake.placeHoldReturns
+	fake.recordInvocation("PlaceHold", []interface{}{arg1, arg2})
+	fake.placeHoldMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3, arg4, arg5)
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -107,62 +1817,62 @@ func (fake *MockBooksDB) CreateBook(arg1 context.Context, arg2 string, arg3 stri
 	return fakeReturns.result1, fakeReturns.result2
 }
 
-func (fake *MockBooksDB) CreateBookCallCount() int {
-	fake.createBookMutex.RLock()
-	defer fake.createBookMutex.RUnlock()
-	return len(fake.createBookArgsForCall)
+func (fake *MockBooksDB) PlaceHoldCallCount() int {
+	fake.placeHoldMutex.RLock()
+	defer fake.placeHoldMutex.RUnlock()
+	return len(fake.placeHoldArgsForCall)
 }
 
-func (fake *MockBooksDB) CreateBookCalls(stub func(context.Context, string, string, string, string) (internal.Book, error)) {
-	fake.createBookMutex.Lock()
-	defer fake.createBookMutex.Unlock()
-	fake.CreateBookStub = stub
+func (fake *MockBooksDB) PlaceHoldCalls(stub func(context.Context, internal.Hold) (internal.Hold, error)) {
+	fake.placeHoldMutex.Lock()
+	defer fake.placeHoldMutex.Unlock()
+	fake.PlaceHoldStub = stub
 }
 
-func (fake *MockBooksDB) CreateBookArgsForCall(i int) (context.Context, string, string, string, string) {
-	fake.createBookMutex.RLock()
-	defer fake.createBookMutex.RUnlock()
-	argsForCall := fake.createBookArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+func (fake *MockBooksDB) PlaceHoldArgsForCall(i int) (context.Context, internal.Hold) {
+	fake.placeHoldMutex.RLock()
+	defer fake.placeHoldMutex.RUnlock()
+	argsForCall := fake.placeHoldArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *MockBooksDB) CreateBookReturns(result1 internal.Book, result2 error) {
-	fake.createBookMutex.Lock()
-	defer fake.createBookMutex.Unlock()
-	fake.CreateBookStub = nil
-	fake.createBookReturns = struct {
-		result1 internal.Book
+func (fake *MockBooksDB) PlaceHoldReturns(result1 internal.Hold, result2 error) {
+	fake.placeHoldMutex.Lock()
+	defer fake.placeHoldMutex.Unlock()
+	fake.PlaceHoldStub = nil
+	fake.placeHoldReturns = struct {
+		result1 internal.Hold
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *MockBooksDB) CreateBookReturnsOnCall(i int, result1 internal.Book, result2 error) {
-	fake.createBookMutex.Lock()
-	defer fake.createBookMutex.Unlock()
-	fake.CreateBookStub = nil
-	if fake.createBookReturnsOnCall == nil {
-		fake.createBookReturnsOnCall = make(map[int]struct {
-			result1 internal.Book
+func (fake *MockBooksDB) PlaceHoldReturnsOnCall(i int, result1 internal.Hold, result2 error) {
+	fake.placeHoldMutex.Lock()
+	defer fake.placeHoldMutex.Unlock()
+	fake.PlaceHoldStub = nil
+	if fake.placeHoldReturnsOnCall == nil {
+		fake.placeHoldReturnsOnCall = make(map[int]struct {
+			result1 internal.Hold
 			result2 error
 		})
 	}
-	fake.createBookReturnsOnCall[i] = struct {
-		result1 internal.Book
+	fake.placeHoldReturnsOnCall[i] = struct {
+		result1 internal.Hold
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *MockBooksDB) DeleteBook(arg1 context.Context, arg2 string) error {
-	fake.deleteBookMutex.Lock()
-	ret, specificReturn := fake.deleteBookReturnsOnCall[len(fake.deleteBookArgsForCall)]
-	fake.deleteBookArgsForCall = append(fake.deleteBookArgsForCall, struct {
+func (fake *MockBooksDB) PurgeBook(arg1 context.Context, arg2 string) error {
+	fake.purgeBookMutex.Lock()
+	ret, specificReturn := fake.purgeBookReturnsOnCall[len(fake.purgeBookArgsForCall)]
+	fake.purgeBookArgsForCall = append(fake.purgeBookArgsForCall, struct {
 		arg1 context.Context
 		arg2 string
 	}{arg1, arg2})
-	stub := fake.DeleteBookStub
-	fakeReturns := fake.deleteBookReturns
-	fake.recordInvocation("DeleteBook", []interface{}{arg1, arg2})
-	fake.deleteBookMutex.Unlock()
+	stub := fake.PurgeBookStub
+	fakeReturns := fake.purgeBookReturns
+	fake.recordInvocation("PurgeBook", []interface{}{arg1, arg2})
+	fake.purgeBookMutex.Unlock()
 	if stub != nil {
 		return stub(arg1, arg2)
 	}
@@ -172,59 +1882,59 @@ func (fake *MockBooksDB) DeleteBook(arg1 context.Context, arg2 string) error {
 	return fakeReturns.result1
 }
 
-func (fake *MockBooksDB) DeleteBookCallCount() int {
-	fake.deleteBookMutex.RLock()
-	defer fake.deleteBookMutex.RUnlock()
-	return len(fake.deleteBookArgsForCall)
+func (fake *MockBooksDB) PurgeBookCallCount() int {
+	fake.purgeBookMutex.RLock()
+	defer fake.purgeBookMutex.RUnlock()
+	return len(fake.purgeBookArgsForCall)
 }
 
-func (fake *MockBooksDB) DeleteBookCalls(stub func(context.Context, string) error) {
-	fake.deleteBookMutex.Lock()
-	defer fake.deleteBookMutex.Unlock()
-	fake.DeleteBookStub = stub
+func (fake *MockBooksDB) PurgeBookCalls(stub func(context.Context, string) error) {
+	fake.purgeBookMutex.Lock()
+	defer fake.purgeBookMutex.Unlock()
+	fake.PurgeBookStub = stub
 }
 
-func (fake *MockBooksDB) DeleteBookArgsForCall(i int) (context.Context, string) {
-	fake.deleteBookMutex.RLock()
-	defer fake.deleteBookMutex.RUnlock()
-	argsForCall := fake.deleteBookArgsForCall[i]
+func (fake *MockBooksDB) PurgeBookArgsForCall(i int) (context.Context, string) {
+	fake.purgeBookMutex.RLock()
+	defer fake.purgeBookMutex.RUnlock()
+	argsForCall := fake.purgeBookArgsForCall[i]
 	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *MockBooksDB) DeleteBookReturns(result1 error) {
-	fake.deleteBookMutex.Lock()
-	defer fake.deleteBookMutex.Unlock()
-	fake.DeleteBookStub = nil
-	fake.deleteBookReturns = struct {
+func (fake *MockBooksDB) PurgeBookReturns(result1 error) {
+	fake.purgeBookMutex.Lock()
+	defer fake.purgeBookMutex.Unlock()
+	fake.PurgeBookStub = nil
+	fake.purgeBookReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *MockBooksDB) DeleteBookReturnsOnCall(i int, result1 error) {
-	fake.deleteBookMutex.Lock()
-	defer fake.deleteBookMutex.Unlock()
-	fake.DeleteBookStub = nil
-	if fake.deleteBookReturnsOnCall == nil {
-		fake.deleteBookReturnsOnCall = make(map[int]struct {
+func (fake *MockBooksDB) PurgeBookReturnsOnCall(i int, result1 error) {
+	fake.purgeBookMutex.Lock()
+	defer fake.purgeBookMutex.Unlock()
+	fake.PurgeBookStub = nil
+	if fake.purgeBookReturnsOnCall == nil {
+		fake.purgeBookReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.deleteBookReturnsOnCall[i] = struct {
+	fake.purgeBookReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *MockBooksDB) GetBookByID(arg1 context.Context, arg2 string) (internal.Book, error) {
-	fake.getBookByIDMutex.Lock()
-	ret, specificReturn := fake.getBookByIDReturnsOnCall[len(fake.getBookByIDArgsForCall)]
-	fake.getBookByIDArgsForCall = append(fake.getBookByIDArgsForCall, struct {
+func (fake *MockBooksDB) PurgeDeletedBooks(arg1 context.Context, arg2 time.Time) (int, error) {
+	fake.purgeDeletedBooksMutex.Lock()
+	ret, specificReturn := fake.purgeDeletedBooksReturnsOnCall[len(fake.purgeDeletedBooksArgsForCall)]
+	fake.purgeDeletedBooksArgsForCall = append(fake.purgeDeletedBooksArgsForCall, struct {
 		arg1 context.Context
-		arg2 string
+		arg2 time.Time
 	}{arg1, arg2})
-	stub := fake.GetBookByIDStub
-	fakeReturns := fake.getBookByIDReturns
-	fake.recordInvocation("GetBookByID", []interface{}{arg1, arg2})
-	fake.getBookByIDMutex.Unlock()
+	stub := fake.PurgeDeletedBooksStub
+	fakeReturns := fake.purgeDeletedBooksReturns
+	fake.recordInvocation("PurgeDeletedBooks", []interface{}{arg1, arg2})
+	fake.purgeDeletedBooksMutex.Unlock()
 	if stub != nil {
 		return stub(arg1, arg2)
 	}
@@ -234,63 +1944,65 @@ func (fake *MockBooksDB) GetBookByID(arg1 context.Context, arg2 string) (interna
 	return fakeReturns.result1, fakeReturns.result2
 }
 
-func (fake *MockBooksDB) GetBookByIDCallCount() int {
-	fake.getBookByIDMutex.RLock()
-	defer fake.getBookByIDMutex.RUnlock()
-	return len(fake.getBookByIDArgsForCall)
+func (fake *MockBooksDB) PurgeDeletedBooksCallCount() int {
+	fake.purgeDeletedBooksMutex.RLock()
+	defer fake.purgeDeletedBooksMutex.RUnlock()
+	return len(fake.purgeDeletedBooksArgsForCall)
 }
 
-func (fake *MockBooksDB) GetBookByIDCalls(stub func(context.Context, string) (internal.Book, error)) {
-	fake.getBookByIDMutex.Lock()
-	defer fake.getBookByIDMutex.Unlock()
-	fake.GetBookByIDStub = stub
+func (fake *MockBooksDB) PurgeDeletedBooksCalls(stub func(context.Context, time.Time) (int, error)) {
+	fake.purgeDeletedBooksMutex.Lock()
+	defer fake.purgeDeletedBooksMutex.Unlock()
+	fake.PurgeDeletedBooksStub = stub
 }
 
-func (fake *MockBooksDB) GetBookByIDArgsForCall(i int) (context.Context, string) {
-	fake.getBookByIDMutex.RLock()
-	defer fake.getBookByIDMutex.RUnlock()
-	argsForCall := fake.getBookByIDArgsForCall[i]
+func (fake *MockBooksDB) PurgeDeletedBooksArgsForCall(i int) (context.Context, time.Time) {
+	fake.purgeDeletedBooksMutex.RLock()
+	defer fake.purgeDeletedBooksMutex.RUnlock()
+	argsForCall := fake.purgeDeletedBooksArgsForCall[i]
 	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *MockBooksDB) GetBookByIDReturns(result1 internal.Book, result2 error) {
-	fake.getBookByIDMutex.Lock()
-	defer fake.getBookByIDMutex.Unlock()
-	fake.GetBookByIDStub = nil
-	fake.getBookByIDReturns = struct {
-		result1 internal.Book
+func (fake *MockBooksDB) PurgeDeletedBooksReturns(result1 int, result2 error) {
+	fake.purgeDeletedBooksMutex.Lock()
+	defer fake.purgeDeletedBooksMutex.Unlock()
+	fake.PurgeDeletedBooksStub = nil
+	fake.purgeDeletedBooksReturns = struct {
+		result1 int
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *MockBooksDB) GetBookByIDReturnsOnCall(i int, result1 internal.Book, result2 error) {
-	fake.getBookByIDMutex.Lock()
-	defer fake.getBookByIDMutex.Unlock()
-	fake.GetBookByIDStub = nil
-	if fake.getBookByIDReturnsOnCall == nil {
-		fake.getBookByIDReturnsOnCall = make(map[int]struct {
-			result1 internal.Book
+func (fake *MockBooksDB) PurgeDeletedBooksReturnsOnCall(i int, result1 int, result2 error) {
+	fake.purgeDeletedBooksMutex.Lock()
+	defer fake.purgeDeletedBooksMutex.Unlock()
+	fake.PurgeDeletedBooksStub = nil
+	if fake.purgeDeletedBooksReturnsOnCall == nil {
+		fake.purgeDeletedBooksReturnsOnCall = make(map[int]struct {
+			result1 int
 			result2 error
 		})
 	}
-	fake.getBookByIDReturnsOnCall[i] = struct {
-		result1 internal.Book
+	fake.purgeDeletedBooksReturnsOnCall[i] = struct {
+		result1 int
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *MockBooksDB) GetBooks(arg1 context.Context) ([]internal.Book, error) {
-	fake.getBooksMutex.Lock()
-	ret, specificReturn := fake.getBooksReturnsOnCall[len(fake.getBooksArgsForCall)]
-	fake.getBooksArgsForCall = append(fake.getBooksArgsForCall, struct {
+func (fake *MockBooksDB) RenewLoan(arg1 context.Context, arg2 internal.Loan, arg3 time.Time) (internal.Loan, error) {
+	fake.renewLoanMutex.Lock()
+	ret, specificReturn := fake.renewLoanReturnsOnCall[len(fake.renewLoanArgsForCall)]
+	fake.renewLoanArgsForCall = append(fake.renewLoanArgsForCall, struct {
 		arg1 context.Context
-	}{arg1})
-	stub := fake.GetBooksStub
-	fakeReturns := fake.getBooksReturns
-	fake.recordInvocation("GetBooks", []interface{}{arg1})
-	fake.getBooksMutex.Unlock()
+		arg2 internal.Loan
+		arg3 time.Time
+	}{arg1, arg2, arg3})
+	stub := fake.RenewLoanStub
+	fakeReturns := fake.renewLoanReturns
+	fake.recordInvocation("RenewLoan", []interface{}{arg1, arg2, arg3})
+	fake.renewLoanMutex.Unlock()
 	if stub != nil {
-		return stub(arg1)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -298,47 +2010,180 @@ func (fake *MockBooksDB) GetBooks(arg1 context.Context) ([]internal.Book, error)
 	return fakeReturns.result1, fakeReturns.result2
 }
 
-func (fake *MockBooksDB) GetBooksCallCount() int {
-	fake.getBooksMutex.RLock()
-	defer fake.getBooksMutex.RUnlock()
-	return len(fake.getBooksArgsForCall)
+func (fake *MockBooksDB) RenewLoanCallCount() int {
+	fake.renewLoanMutex.RLock()
+	defer fake.renewLoanMutex.RUnlock()
+	return len(fake.renewLoanArgsForCall)
 }
 
-func (fake *MockBooksDB) GetBooksCalls(stub func(context.Context) ([]internal.Book, error)) {
-	fake.getBooksMutex.Lock()
-	defer fake.getBooksMutex.Unlock()
-	fake.GetBooksStub = stub
+func (fake *MockBooksDB) RenewLoanCalls(stub func(context.Context, internal.Loan, time.Time) (internal.Loan, error)) {
+	fake.renewLoanMutex.Lock()
+	defer fake.renewLoanMutex.Unlock()
+	fake.RenewLoanStub = stub
 }
 
-func (fake *MockBooksDB) GetBooksArgsForCall(i int) context.Context {
-	fake.getBooksMutex.RLock()
-	defer fake.getBooksMutex.RUnlock()
-	argsForCall := fake.getBooksArgsForCall[i]
-	return argsForCall.arg1
+func (fake *MockBooksDB) RenewLoanArgsForCall(i int) (context.Context, internal.Loan, time.Time) {
+	fake.renewLoanMutex.RLock()
+	defer fake.renewLoanMutex.RUnlock()
+	argsForCall := fake.renewLoanArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
-func (fake *MockBooksDB) GetBooksReturns(result1 []internal.Book, result2 error) {
-	fake.getBooksMutex.Lock()
-	defer fake.getBooksMutex.Unlock()
-	fake.GetBooksStub = nil
-	fake.getBooksReturns = struct {
-		result1 []internal.Book
+func (fake *MockBooksDB) RenewLoanReturns(result1 internal.Loan, result2 error) {
+	fake.renewLoanMutex.Lock()
+	defer fake.renewLoanMutex.Unlock()
+	fake.RenewLoanStub = nil
+	fake.renewLoanReturns = struct {
+		result1 internal.Loan
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *MockBooksDB) GetBooksReturnsOnCall(i int, result1 []internal.Book, result2 error) {
-	fake.getBooksMutex.Lock()
-	defer fake.getBooksMutex.Unlock()
-	fake.GetBooksStub = nil
-	if fake.getBooksReturnsOnCall == nil {
-		fake.getBooksReturnsOnCall = make(map[int]struct {
-			result1 []internal.Book
+func (fake *MockBooksDB) RenewLoanReturnsOnCall(i int, result1 internal.Loan, result2 error) {
+	fake.renewLoanMutex.Lock()
+	defer fake.renewLoanMutex.Unlock()
+	fake.RenewLoanStub = nil
+	if fake.renewLoanReturnsOnCall == nil {
+		fake.renewLoanReturnsOnCall = make(map[int]struct {
+			result1 internal.Loan
 			result2 error
 		})
 	}
-	fake.getBooksReturnsOnCall[i] = struct {
-		result1 []internal.Book
+	fake.renewLoanReturnsOnCall[i] = struct {
+		result1 internal.Loan
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) ReserveCopy(arg1 context.Context, arg2 internal.Hold, arg3 string, arg4 internal.BookStatus, arg5 time.Time) (internal.Hold, error) {
+	fake.reserveCopyMutex.Lock()
+	ret, specificReturn := fake.reserveCopyReturnsOnCall[len(fake.reserveCopyArgsForCall)]
+	fake.reserveCopyArgsForCall = append(fake.reserveCopyArgsForCall, struct {
+		arg1 context.Context
+		arg2 internal.Hold
+		arg3 string
+		arg4 internal.BookStatus
+		arg5 time.Time
+	}{arg1, arg2, arg3, arg4, arg5})
+	stub := fake.ReserveCopyStub
+	fakeReturns := fake.reserveCopyReturns
+	fake.recordInvocation("ReserveCopy", []interface{}{arg1, arg2, arg3, arg4, arg5})
+	fake.reserveCopyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) ReserveCopyCallCount() int {
+	fake.reserveCopyMutex.RLock()
+	defer fake.reserveCopyMutex.RUnlock()
+	return len(fake.reserveCopyArgsForCall)
+}
+
+func (fake *MockBooksDB) ReserveCopyCalls(stub func(context.Context, internal.Hold, string, internal.BookStatus, time.Time) (internal.Hold, error)) {
+	fake.reserveCopyMutex.Lock()
+	defer fake.reserveCopyMutex.Unlock()
+	fake.ReserveCopyStub = stub
+}
+
+func (fake *MockBooksDB) ReserveCopyArgsForCall(i int) (context.Context, internal.Hold, string, internal.BookStatus, time.Time) {
+	fake.reserveCopyMutex.RLock()
+	defer fake.reserveCopyMutex.RUnlock()
+	argsForCall := fake.reserveCopyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *MockBooksDB) ReserveCopyReturns(result1 internal.Hold, result2 error) {
+	fake.reserveCopyMutex.Lock()
+	defer fake.reserveCopyMutex.Unlock()
+	fake.ReserveCopyStub = nil
+	fake.reserveCopyReturns = struct {
+		result1 internal.Hold
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) ReserveCopyReturnsOnCall(i int, result1 internal.Hold, result2 error) {
+	fake.reserveCopyMutex.Lock()
+	defer fake.reserveCopyMutex.Unlock()
+	fake.ReserveCopyStub = nil
+	if fake.reserveCopyReturnsOnCall == nil {
+		fake.reserveCopyReturnsOnCall = make(map[int]struct {
+			result1 internal.Hold
+			result2 error
+		})
+	}
+	fake.reserveCopyReturnsOnCall[i] = struct {
+		result1 internal.Hold
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) RestoreBook(arg1 context.Context, arg2 string) (internal.Book, error) {
+	fake.restoreBookMutex.Lock()
+	ret, specificReturn := fake.restoreBookReturnsOnCall[len(fake.restoreBookArgsForCall)]
+	fake.restoreBookArgsForCall = append(fake.restoreBookArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.RestoreBookStub
+	fakeReturns := fake.restoreBookReturns
+	fake.recordInvocation("RestoreBook", []interface{}{arg1, arg2})
+	fake.restoreBookMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) RestoreBookCallCount() int {
+	fake.restoreBookMutex.RLock()
+	defer fake.restoreBookMutex.RUnlock()
+	return len(fake.restoreBookArgsForCall)
+}
+
+func (fake *MockBooksDB) RestoreBookCalls(stub func(context.Context, string) (internal.Book, error)) {
+	fake.restoreBookMutex.Lock()
+	defer fake.restoreBookMutex.Unlock()
+	fake.RestoreBookStub = stub
+}
+
+func (fake *MockBooksDB) RestoreBookArgsForCall(i int) (context.Context, string) {
+	fake.restoreBookMutex.RLock()
+	defer fake.restoreBookMutex.RUnlock()
+	argsForCall := fake.restoreBookArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *MockBooksDB) RestoreBookReturns(result1 internal.Book, result2 error) {
+	fake.restoreBookMutex.Lock()
+	defer fake.restoreBookMutex.Unlock()
+	fake.RestoreBookStub = nil
+	fake.restoreBookReturns = struct {
+		result1 internal.Book
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) RestoreBookReturnsOnCall(i int, result1 internal.Book, result2 error) {
+	fake.restoreBookMutex.Lock()
+	defer fake.restoreBookMutex.Unlock()
+	fake.RestoreBookStub = nil
+	if fake.restoreBookReturnsOnCall == nil {
+		fake.restoreBookReturnsOnCall = make(map[int]struct {
+			result1 internal.Book
+			result2 error
+		})
+	}
+	fake.restoreBookReturnsOnCall[i] = struct {
+		result1 internal.Book
 		result2 error
 	}{result1, result2}
 }
@@ -409,19 +2254,134 @@ func (fake *MockBooksDB) UpdateBookReturnsOnCall(i int, result1 internal.Book, r
 	}{result1, result2}
 }
 
+func (fake *MockBooksDB) UpdateCopyStatus(arg1 context.Context, arg2 string, arg3 internal.BookStatus, arg4 internal.BookStatus) (internal.Copy, error) {
+	fake.updateCopyStatusMutex.Lock()
+	ret, specificReturn := fake.updateCopyStatusReturnsOnCall[len(fake.updateCopyStatusArgsForCall)]
+	fake.updateCopyStatusArgsForCall = append(fake.updateCopyStatusArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 internal.BookStatus
+		arg4 internal.BookStatus
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.UpdateCopyStatusStub
+	fakeReturns := fake.updateCopyStatusReturns
+	fake.recordInvocation("UpdateCopyStatus", []interface{}{arg1, arg2, arg3, arg4})
+	fake.updateCopyStatusMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockBooksDB) UpdateCopyStatusCallCount() int {
+	fake.updateCopyStatusMutex.RLock()
+	defer fake.updateCopyStatusMutex.RUnlock()
+	return len(fake.updateCopyStatusArgsForCall)
+}
+
+func (fake *MockBooksDB) UpdateCopyStatusCalls(stub func(context.Context, string, internal.BookStatus, internal.BookStatus) (internal.Copy, error)) {
+	fake.updateCopyStatusMutex.Lock()
+	defer fake.updateCopyStatusMutex.Unlock()
+	fake.UpdateCopyStatusStub = stub
+}
+
+func (fake *MockBooksDB) UpdateCopyStatusArgsForCall(i int) (context.Context, string, internal.BookStatus, internal.BookStatus) {
+	fake.updateCopyStatusMutex.RLock()
+	defer fake.updateCopyStatusMutex.RUnlock()
+	argsForCall := fake.updateCopyStatusArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *MockBooksDB) UpdateCopyStatusReturns(result1 internal.Copy, result2 error) {
+	fake.updateCopyStatusMutex.Lock()
+	defer fake.updateCopyStatusMutex.Unlock()
+	fake.UpdateCopyStatusStub = nil
+	fake.updateCopyStatusReturns = struct {
+		result1 internal.Copy
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockBooksDB) UpdateCopyStatusReturnsOnCall(i int, result1 internal.Copy, result2 error) {
+	fake.updateCopyStatusMutex.Lock()
+	defer fake.updateCopyStatusMutex.Unlock()
+	fake.UpdateCopyStatusStub = nil
+	if fake.updateCopyStatusReturnsOnCall == nil {
+		fake.updateCopyStatusReturnsOnCall = make(map[int]struct {
+			result1 internal.Copy
+			result2 error
+		})
+	}
+	fake.updateCopyStatusReturnsOnCall[i] = struct {
+		result1 internal.Copy
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *MockBooksDB) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.addCopyMutex.RLock()
+	defer fake.addCopyMutex.RUnlock()
+	fake.addLedgerEntryMutex.RLock()
+	defer fake.addLedgerEntryMutex.RUnlock()
+	fake.checkOutCopyMutex.RLock()
+	defer fake.checkOutCopyMutex.RUnlock()
+	fake.closeHoldMutex.RLock()
+	defer fake.closeHoldMutex.RUnlock()
+	fake.closeLoanMutex.RLock()
+	defer fake.closeLoanMutex.RUnlock()
 	fake.createBookMutex.RLock()
 	defer fake.createBookMutex.RUnlock()
+	fake.createLoanMutex.RLock()
+	defer fake.createLoanMutex.RUnlock()
 	fake.deleteBookMutex.RLock()
 	defer fake.deleteBookMutex.RUnlock()
+	fake.getAuditEventsMutex.RLock()
+	defer fake.getAuditEventsMutex.RUnlock()
 	fake.getBookByIDMutex.RLock()
 	defer fake.getBookByIDMutex.RUnlock()
+	fake.getBookHistoryMutex.RLock()
+	defer fake.getBookHistoryMutex.RUnlock()
 	fake.getBooksMutex.RLock()
 	defer fake.getBooksMutex.RUnlock()
+	fake.getCopiesMutex.RLock()
+	defer fake.getCopiesMutex.RUnlock()
+	fake.getCopyByBarcodeMutex.RLock()
+	defer fake.getCopyByBarcodeMutex.RUnlock()
+	fake.getDeletedBooksMutex.RLock()
+	defer fake.getDeletedBooksMutex.RUnlock()
+	fake.getExpiredHoldsMutex.RLock()
+	defer fake.getExpiredHoldsMutex.RUnlock()
+	fake.getHoldsMutex.RLock()
+	defer fake.getHoldsMutex.RUnlock()
+	fake.getLedgerMutex.RLock()
+	defer fake.getLedgerMutex.RUnlock()
+	fake.getOpenLoanMutex.RLock()
+	defer fake.getOpenLoanMutex.RUnlock()
+	fake.getOverdueLoansMutex.RLock()
+	defer fake.getOverdueLoansMutex.RUnlock()
+	fake.getPatronLoansMutex.RLock()
+	defer fake.getPatronLoansMutex.RUnlock()
+	fake.placeHoldMutex.RLock()
+	defer fake.placeHoldMutex.RUnlock()
+	fake.purgeBookMutex.RLock()
+	defer fake.purgeBookMutex.RUnlock()
+	fake.purgeDeletedBooksMutex.RLock()
+	defer fake.purgeDeletedBooksMutex.RUnlock()
+	fake.renewLoanMutex.RLock()
+	defer fake.renewLoanMutex.RUnlock()
+	fake.reserveCopyMutex.RLock()
+	defer fake.reserveCopyMutex.RUnlock()
+	fake.restoreBookMutex.RLock()
+	defer fake.restoreBookMutex.RUnlock()
 	fake.updateBookMutex.RLock()
 	defer fake.updateBookMutex.RUnlock()
+	fake.updateCopyStatusMutex.RLock()
+	defer fake.updateCopyStatusMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
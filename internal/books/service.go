@@ -2,34 +2,93 @@ package books
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/identity"
+	"github.com/aaron-zeisler/library-api/internal/logging"
+	"github.com/aaron-zeisler/library-api/internal/metrics"
+	"github.com/aaron-zeisler/library-api/internal/policy"
+	"github.com/aaron-zeisler/library-api/internal/tracing"
 )
 
 type service struct {
-	db     booksDB
-	logger *logrus.Logger
+	db             booksDB
+	logger         *logrus.Logger
+	metrics        metrics.Sink
+	trashRetention time.Duration
+	holdShelfTime  time.Duration
+	policy         policy.Policy
+	balanceLimit   internal.Money
+	now            func() time.Time // Defaults to time.Now
 }
 
+const (
+	// DefaultTrashRetention is how long deleted books stay in the trash before they're purged
+	DefaultTrashRetention = 30 * 24 * time.Hour
+
+	// DefaultHoldShelfTime is how long a copy stays on the hold shelf for a patron before the hold expires
+	DefaultHoldShelfTime = 7 * 24 * time.Hour
+
+	// DefaultBalanceLimit is how much a patron may owe, in minor units, and still check out copies
+	DefaultBalanceLimit internal.Money = 1000
+)
+
 type booksDB interface {
-	GetBooks(ctx context.Context) ([]internal.Book, error)
+	GetBooks(ctx context.Context, filter internal.BookFilter) ([]internal.Book, error)
 	GetBookByID(ctx context.Context, bookID string) (internal.Book, error)
 	CreateBook(ctx context.Context, title, author, isbn, description string) (internal.Book, error)
 	UpdateBook(ctx context.Context, bookID string, book internal.Book) (internal.Book, error)
 	DeleteBook(ctx context.Context, bookID string) error
+	GetDeletedBooks(ctx context.Context) ([]internal.Book, error)
+	RestoreBook(ctx context.Context, bookID string) (internal.Book, error)
+	PurgeBook(ctx context.Context, bookID string) error
+	PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int, error)
+	GetCopies(ctx context.Context, bookID string) ([]internal.Copy, error)
+	GetCopyByBarcode(ctx context.Context, barcode string) (internal.Copy, error)
+	AddCopy(ctx context.Context, bookCopy internal.Copy) (internal.Copy, error)
+	UpdateCopyStatus(ctx context.Context, barcode string, from, to internal.BookStatus) (internal.Copy, error)
+	PlaceHold(ctx context.Context, hold internal.Hold) (internal.Hold, error)
+	GetHolds(ctx context.Context, bookID string) ([]internal.Hold, error)
+	GetExpiredHolds(ctx context.Context, now time.Time) ([]internal.Hold, error)
+	ReserveCopy(ctx context.Context, hold internal.Hold, barcode string, from internal.BookStatus, expiresAt time.Time) (internal.Hold, error)
+	CloseHold(ctx context.Context, hold internal.Hold, status internal.HoldStatus) error
+	CreateLoan(ctx context.Context, loan internal.Loan) (internal.Loan, error)
+	CheckOutCopy(ctx context.Context, loan internal.Loan, from internal.BookStatus, hold *internal.Hold) (internal.Copy, internal.Loan, error)
+	GetOpenLoan(ctx context.Context, barcode string) (internal.Loan, error)
+	GetOverdueLoans(ctx context.Context, now time.Time) ([]internal.Loan, error)
+	RenewLoan(ctx context.Context, loan internal.Loan, dueAt time.Time) (internal.Loan, error)
+	CloseLoan(ctx context.Context, loan internal.Loan, returnedAt time.Time) (internal.Loan, error)
+	GetPatronLoans(ctx context.Context, patronID string) ([]internal.Loan, error)
+	AddLedgerEntry(ctx context.Context, entry internal.LedgerEntry) (internal.LedgerEntry, error)
+	GetLedger(ctx context.Context, patronID string) ([]internal.LedgerEntry, error)
+	GetBookHistory(ctx context.Context, bookID string) ([]internal.AuditEvent, error)
+	GetAuditEvents(ctx context.Context, filter internal.AuditFilter) ([]internal.AuditEvent, error)
 }
 
 func NewService(db booksDB, opts ...ServiceOption) service {
 	s := service{
-		db:     db,
-		logger: logrus.New(),
+		db:             db,
+		logger:         logrus.New(),
+		metrics:        metrics.NewNoopSink(),
+		trashRetention: DefaultTrashRetention,
+		holdShelfTime:  DefaultHoldShelfTime,
+		policy:         policy.DefaultPolicy(),
+		balanceLimit:   DefaultBalanceLimit,
+		now:            time.Now,
 	}
 
 	for _, opt := range opts {
@@ -48,15 +107,90 @@ func WithLogger(logger *logrus.Logger) ServiceOption {
 	}
 }
 
+func WithMetrics(sink metrics.Sink) ServiceOption {
+	return func(s service) service {
+		s.metrics = sink
+		return s
+	}
+}
+
+// WithTrashRetention sets how long deleted books stay in the trash before PurgeDeletedBooks removes them
+func WithTrashRetention(retention time.Duration) ServiceOption {
+	return func(s service) service {
+		s.trashRetention = retention
+		return s
+	}
+}
+
+// WithHoldShelfTime sets how long a copy is reserved on the hold shelf for the patron at the head of the
+// queue before the hold expires
+func WithHoldShelfTime(holdShelfTime time.Duration) ServiceOption {
+	return func(s service) service {
+		s.holdShelfTime = holdShelfTime
+		return s
+	}
+}
+
+// WithPolicy sets the circulation policy, which decides how long copies are lent for, how often loans may
+// be renewed, how many copies patrons may borrow, and how late returns are fined
+func WithPolicy(p policy.Policy) ServiceOption {
+	return func(s service) service {
+		s.policy = p
+		return s
+	}
+}
+
+// WithBalanceLimit sets how much a patron may owe and still check out copies
+func WithBalanceLimit(limit internal.Money) ServiceOption {
+	return func(s service) service {
+		s.balanceLimit = limit
+		return s
+	}
+}
+
+// WithClock sets the clock used for due dates, hold expiries and the trash retention
+func WithClock(now func() time.Time) ServiceOption {
+	return func(s service) service {
+		s.now = now
+		return s
+	}
+}
+
 func (s service) GetBooks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	books, err := s.db.GetBooks(ctx)
+	return s.handle(ctx, "GetBooks", request, s.getBooks)
+}
+
+func (s service) getBooks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	filter := internal.BookFilter{}
+
+	// Downstream systems pass the time of their last sync to receive only the books changed since then,
+	// including the ones moved to the trash
+	if updatedSince := request.QueryStringParameters["updated_since"]; updatedSince != "" {
+		var err error
+		filter.UpdatedSince, err = time.Parse(time.RFC3339, updatedSince)
+		if err != nil {
+			return s.logAndReturnError(ctx, err, "the 'updated_since' parameter must be an RFC 3339 timestamp", http.StatusBadRequest, logrus.Fields{})
+		}
+	}
+
+	filter.ISBN = request.QueryStringParameters["isbn"]
+	filter.Author = request.QueryStringParameters["author"]
+	switch status := internal.BookStatus(request.QueryStringParameters["status"]); status {
+	case "", internal.CheckedIn, internal.CheckedOut, internal.OnHoldShelf:
+		filter.Status = status
+	default:
+		err := fmt.Errorf("unknown status '%s'", status)
+		return s.logAndReturnError(ctx, err, "the 'status' parameter must be 'in', 'out' or 'on_hold_shelf'", http.StatusBadRequest, logrus.Fields{})
+	}
+
+	books, err := s.db.GetBooks(ctx, filter)
 	if err != nil {
-		return s.logAndReturnError(err, "failed to retrieve books from the database", http.StatusInternalServerError, logrus.Fields{})
+		return s.logAndReturnError(ctx, err, "failed to retrieve books from the database", http.StatusInternalServerError, logrus.Fields{})
 	}
 
 	responseBody, err := json.Marshal(books)
 	if err != nil {
-		return s.logAndReturnError(err, "failed to encode the books into an http response", http.StatusInternalServerError, logrus.Fields{})
+		return s.logAndReturnError(ctx, err, "failed to encode the books into an http response", http.StatusInternalServerError, logrus.Fields{})
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -66,6 +200,10 @@ func (s service) GetBooks(ctx context.Context, request events.APIGatewayProxyReq
 }
 
 func (s service) GetBookByID(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "GetBookByID", request, s.getBookByID)
+}
+
+func (s service) getBookByID(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	bookID := request.PathParameters["book_id"]
 
 	book, err := s.db.GetBookByID(ctx, bookID)
@@ -75,12 +213,17 @@ func (s service) GetBookByID(ctx context.Context, request events.APIGatewayProxy
 			statusCode = http.StatusNotFound
 		}
 
-		return s.logAndReturnError(err, "failed to retrieve the book from the database", statusCode, logrus.Fields{"book_id": bookID})
+		return s.logAndReturnError(ctx, err, "failed to retrieve the book from the database", statusCode, logrus.Fields{"book_id": bookID})
 	}
 
-	responseBody, err := json.Marshal(book)
+	copies, err := s.db.GetCopies(ctx, bookID)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the book's copies from the database", http.StatusInternalServerError, logrus.Fields{"book_id": bookID})
+	}
+
+	responseBody, err := json.Marshal(bookDetails{Book: book, Availability: internal.NewAvailability(copies)})
 	if err != nil {
-		return s.logAndReturnError(err, "failed to encode the book into an http response", http.StatusInternalServerError, logrus.Fields{})
+		return s.logAndReturnError(ctx, err, "failed to encode the book into an http response", http.StatusInternalServerError, logrus.Fields{})
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -89,21 +232,36 @@ func (s service) GetBookByID(ctx context.Context, request events.APIGatewayProxy
 	}, nil
 }
 
+// bookDetails is a book along with how many of its copies are available
+type bookDetails struct {
+	internal.Book
+	internal.Availability
+}
+
 func (s service) CreateBook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "CreateBook", request, s.createBook)
+}
+
+func (s service) createBook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	var book internal.Book
 	err := json.Unmarshal([]byte(request.Body), &book)
 	if err != nil {
-		return s.logAndReturnError(err, "failed to decode the request body into a book object", http.StatusBadRequest, logrus.Fields{})
+		return s.logAndReturnError(ctx, err, "failed to decode the request body into a book object", http.StatusBadRequest, logrus.Fields{})
 	}
 
 	newBook, err := s.db.CreateBook(ctx, book.Title, book.Author, book.ISBN, book.Description)
 	if err != nil {
-		return s.logAndReturnError(err, "failed to create a new book in the database", http.StatusInternalServerError, logrus.Fields{})
+		statusCode := http.StatusInternalServerError
+		if errors.As(err, &internal.ErrDuplicateISBN{}) {
+			statusCode = http.StatusConflict
+		}
+
+		return s.logAndReturnError(ctx, err, "failed to create a new book in the database", statusCode, logrus.Fields{})
 	}
 
 	responseBody, err := json.Marshal(newBook)
 	if err != nil {
-		return s.logAndReturnError(err, "failed to encode the book into an http response", http.StatusInternalServerError, logrus.Fields{})
+		return s.logAndReturnError(ctx, err, "failed to encode the book into an http response", http.StatusInternalServerError, logrus.Fields{})
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -113,27 +271,34 @@ func (s service) CreateBook(ctx context.Context, request events.APIGatewayProxyR
 }
 
 func (s service) UpdateBook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "UpdateBook", request, s.updateBook)
+}
+
+func (s service) updateBook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	bookID := request.PathParameters["book_id"]
 
 	var book internal.Book
 	err := json.Unmarshal([]byte(request.Body), &book)
 	if err != nil {
-		return s.logAndReturnError(err, "failed to decode the request body into a book object", http.StatusBadRequest, logrus.Fields{})
+		return s.logAndReturnError(ctx, err, "failed to decode the request body into a book object", http.StatusBadRequest, logrus.Fields{})
 	}
 
 	updatedBook, err := s.db.UpdateBook(ctx, bookID, book)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if errors.As(err, &internal.ErrBookNotFound{}) {
+		switch {
+		case errors.As(err, &internal.ErrBookNotFound{}):
 			statusCode = http.StatusNotFound
+		case errors.As(err, &internal.ErrDuplicateISBN{}):
+			statusCode = http.StatusConflict
 		}
 
-		return s.logAndReturnError(err, "failed to update the book in the database", statusCode, logrus.Fields{"book_id": bookID})
+		return s.logAndReturnError(ctx, err, "failed to update the book in the database", statusCode, logrus.Fields{"book_id": bookID})
 	}
 
 	responseBody, err := json.Marshal(updatedBook)
 	if err != nil {
-		return s.logAndReturnError(err, "failed to encode the book into an http response", http.StatusInternalServerError, logrus.Fields{})
+		return s.logAndReturnError(ctx, err, "failed to encode the book into an http response", http.StatusInternalServerError, logrus.Fields{})
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -143,57 +308,240 @@ func (s service) UpdateBook(ctx context.Context, request events.APIGatewayProxyR
 }
 
 func (s service) DeleteBook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "DeleteBook", request, s.deleteBook)
+}
+
+func (s service) deleteBook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	bookID := request.PathParameters["book_id"]
+	purge := request.QueryStringParameters["purge"] == "true"
+
+	if purge && !identity.IsAdmin(request) {
+		return s.logAndReturnError(ctx, errors.New("only administrators may purge books"), "failed to purge the book", http.StatusForbidden, logrus.Fields{"book_id": bookID})
+	}
+
+	book, err := s.db.GetBookByID(ctx, bookID)
+	switch {
+	case purge && errors.As(err, &internal.ErrBookNotFound{}):
+		// The book may be in the trash, which GetBookByID doesn't look in. None of its copies can be out,
+		// since it couldn't have been deleted otherwise, and PurgeBook reports whether it exists at all.
+	case err != nil:
+		statusCode := http.StatusInternalServerError
+		if errors.As(err, &internal.ErrBookNotFound{}) {
+			statusCode = http.StatusNotFound
+		}
+
+		return s.logAndReturnError(ctx, err, "failed to retrieve the book from the database", statusCode, logrus.Fields{"book_id": bookID})
+	default:
+		copies, err := s.db.GetCopies(ctx, bookID)
+		if err != nil {
+			return s.logAndReturnError(ctx, err, "failed to retrieve the book's copies from the database", http.StatusInternalServerError, logrus.Fields{"book_id": bookID})
+		}
+
+		availability := internal.NewAvailability(copies)
+		if book.Status == internal.CheckedOut || availability.Available < availability.Total {
+			return s.logAndReturnError(ctx, errors.New("the book is checked out"), "failed to delete the book", http.StatusConflict, logrus.Fields{"book_id": bookID})
+		}
+	}
+
+	if purge {
+		err = s.db.PurgeBook(ctx, bookID)
+	} else {
+		err = s.db.DeleteBook(ctx, bookID)
+	}
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.As(err, &internal.ErrBookNotFound{}) {
+			statusCode = http.StatusNotFound
+		}
+
+		return s.logAndReturnError(ctx, err, "failed to delete the book from the database", statusCode, logrus.Fields{"book_id": bookID})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+func (s service) GetDeletedBooks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "GetDeletedBooks", request, s.getDeletedBooks)
+}
+
+func (s service) getDeletedBooks(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	books, err := s.db.GetDeletedBooks(ctx)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the deleted books from the database", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	responseBody, err := json.Marshal(books)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the books into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+func (s service) RestoreBook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "RestoreBook", request, s.restoreBook)
+}
+
+func (s service) restoreBook(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	bookID := request.PathParameters["book_id"]
 
-	err := s.db.DeleteBook(ctx, bookID)
-	if err != nil && !errors.As(err, &internal.ErrBookNotFound{}) { // 'Book not found' doesn't cause a 404 for the DELETE action
-		return s.logAndReturnError(err, "failed to delete the book from the database", http.StatusInternalServerError, logrus.Fields{"book_id": bookID})
+	book, err := s.db.RestoreBook(ctx, bookID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.As(err, &internal.ErrBookNotFound{}) {
+			statusCode = http.StatusNotFound
+		}
+
+		return s.logAndReturnError(ctx, err, "failed to restore the book in the database", statusCode, logrus.Fields{"book_id": bookID})
+	}
+
+	responseBody, err := json.Marshal(book)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the book into an http response", http.StatusInternalServerError, logrus.Fields{})
 	}
 
 	return events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
 	}, nil
 }
 
+// PurgeDeletedBooks permanently removes the books that have been in the trash for longer than the
+// retention window. It runs on a schedule rather than behind the API.
+func (s service) PurgeDeletedBooks(ctx context.Context, event events.CloudWatchEvent) error {
+	ctx, span := tracing.Tracer().Start(ctx, "books.service.PurgeDeletedBooks")
+	defer span.End()
+
+	ctx = internal.ContextWithActor(ctx, "scheduler:"+event.ID)
+
+	cutoff := s.timestamp().Add(-s.trashRetention)
+	purged, err := s.db.PurgeDeletedBooks(ctx, cutoff)
+	s.emit(metrics.Value("BooksPurged", metrics.Count, float64(purged), nil))
+	if err != nil {
+		s.logger.WithError(err).WithField("purged", purged).Error("failed to purge the deleted books")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{"purged": purged, "cutoff": cutoff}).Info("purged the deleted books")
+	return nil
+}
+
 func (s service) CheckOut(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return s.updateStatus(ctx, request, internal.CheckedOut)
+	return s.handle(ctx, "CheckOut", request, s.checkOut)
+}
+
+func (s service) checkOut(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	response, err := s.updateStatus(ctx, request, internal.CheckedOut)
+	if response.StatusCode == http.StatusOK {
+		s.emit(metrics.Counter("CheckOuts", nil))
+	}
+	return response, err
 }
 
 func (s service) CheckIn(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return s.updateStatus(ctx, request, internal.CheckedIn)
+	return s.handle(ctx, "CheckIn", request, s.checkIn)
 }
 
-func (s service) updateStatus(ctx context.Context, request events.APIGatewayProxyRequest, newStatus internal.BookStatus) (events.APIGatewayProxyResponse, error) {
+func (s service) checkIn(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	response, err := s.updateStatus(ctx, request, internal.CheckedIn)
+	if response.StatusCode == http.StatusOK {
+		s.emit(metrics.Counter("CheckIns", nil))
+	}
+	return response, err
+}
+
+func (s service) GetCopies(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "GetCopies", request, s.getCopies)
+}
+
+func (s service) getCopies(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	bookID := request.PathParameters["book_id"]
 
-	// Retrieve the book
-	book, err := s.db.GetBookByID(ctx, bookID)
+	_, err := s.db.GetBookByID(ctx, bookID)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
 		if errors.As(err, &internal.ErrBookNotFound{}) {
 			statusCode = http.StatusNotFound
 		}
 
-		return s.logAndReturnError(err, "failed to retrieve the book from the database", statusCode, logrus.Fields{"book_id": bookID})
+		return s.logAndReturnError(ctx, err, "failed to retrieve the book from the database", statusCode, logrus.Fields{"book_id": bookID})
+	}
+
+	copies, err := s.db.GetCopies(ctx, bookID)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the book's copies from the database", http.StatusInternalServerError, logrus.Fields{"book_id": bookID})
 	}
 
-	// Set the book's new stsatus
-	book.Status = newStatus
+	responseBody, err := json.Marshal(copies)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the copies into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
 
-	// And save it
-	updatedBook, err := s.db.UpdateBook(ctx, bookID, book)
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+func (s service) AddCopy(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "AddCopy", request, s.addCopy)
+}
+
+func (s service) addCopy(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	bookID := request.PathParameters["book_id"]
+
+	var bookCopy internal.Copy
+	err := json.Unmarshal([]byte(request.Body), &bookCopy)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to decode the request body into a copy object", http.StatusBadRequest, logrus.Fields{})
+	}
+	bookCopy.BookID = bookID
+
+	if bookCopy.Barcode == "" {
+		return s.logAndReturnError(ctx, errors.New("the barcode is required"), "failed to add the copy", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+
+	switch bookCopy.Condition {
+	case "":
+		bookCopy.Condition = internal.ConditionGood
+	case internal.ConditionNew, internal.ConditionGood, internal.ConditionWorn, internal.ConditionDamaged:
+	default:
+		err = fmt.Errorf("unknown condition '%s'", bookCopy.Condition)
+		return s.logAndReturnError(ctx, err, "failed to add the copy", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+
+	switch bookCopy.ItemType {
+	case "":
+		bookCopy.ItemType = internal.ItemBook
+	case internal.ItemBook, internal.ItemDVD, internal.ItemReference:
+	default:
+		err = fmt.Errorf("unknown item type '%s'", bookCopy.ItemType)
+		return s.logAndReturnError(ctx, err, "failed to add the copy", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+
+	newCopy, err := s.db.AddCopy(ctx, bookCopy)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if errors.As(err, &internal.ErrBookNotFound{}) {
+		switch {
+		case errors.As(err, &internal.ErrBookNotFound{}):
 			statusCode = http.StatusNotFound
+		case errors.As(err, &internal.ErrDuplicateBarcode{}):
+			statusCode = http.StatusConflict
 		}
 
-		return s.logAndReturnError(err, "failed to update the book in the database", statusCode, logrus.Fields{"book_id": bookID})
+		return s.logAndReturnError(ctx, err, "failed to add the copy to the database", statusCode, logrus.Fields{"book_id": bookID, "barcode": bookCopy.Barcode})
 	}
 
-	responseBody, err := json.Marshal(updatedBook)
+	responseBody, err := json.Marshal(newCopy)
 	if err != nil {
-		return s.logAndReturnError(err, "failed to encode the book into an http response", http.StatusInternalServerError, logrus.Fields{})
+		return s.logAndReturnError(ctx, err, "failed to encode the copy into an http response", http.StatusInternalServerError, logrus.Fields{})
 	}
 
 	return events.APIGatewayProxyResponse{
@@ -202,18 +550,840 @@ func (s service) updateStatus(ctx context.Context, request events.APIGatewayProx
 	}, nil
 }
 
-func (s service) logAndReturnError(err error, message string, statusCode int, logFields logrus.Fields) (events.APIGatewayProxyResponse, error) {
-	s.logger.WithError(err).WithFields(logFields).Error(message)
+func (s service) GetBookHistory(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "GetBookHistory", request, s.getBookHistory)
+}
+
+func (s service) getBookHistory(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	bookID := request.PathParameters["book_id"]
+
+	history, err := s.db.GetBookHistory(ctx, bookID)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the book's history from the database", http.StatusInternalServerError, logrus.Fields{"book_id": bookID})
+	}
+
+	responseBody, err := json.Marshal(history)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the book's history into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
 	return events.APIGatewayProxyResponse{
-		StatusCode: statusCode,
-		Body:       formatErrorForResponseBody(fmt.Errorf("%s: %w", message, err)),
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
 	}, nil
 }
 
-func formatErrorForResponseBody(err error) string {
-	//TODO: Use a struct to represent the error
-	//TODO: Allow this service to support Content-Type other than JSON
-	return fmt.Sprintf(`{"error":"%s"}`, err.Error())
+func (s service) GetAuditEvents(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "GetAuditEvents", request, s.getAuditEvents)
+}
+
+// The audit log is read a page at a time, of the size given by the 'limit' parameter
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+)
+
+// getAuditEvents searches the audit log, which only administrators may read. The search is by actor, from
+// a time, or both, and a page that's full carries the token of the next one in the X-Next-Page-Token
+// header, which is passed back as the 'page_token' parameter.
+func (s service) getAuditEvents(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if !identity.IsAdmin(request) {
+		return s.logAndReturnError(ctx, errors.New("only administrators may read the audit log"), "failed to retrieve the audit events", http.StatusForbidden, logrus.Fields{})
+	}
+
+	filter := internal.AuditFilter{
+		Actor: request.QueryStringParameters["actor"],
+		Limit: defaultAuditPageSize,
+	}
+
+	if since := request.QueryStringParameters["since"]; since != "" {
+		var err error
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			return s.logAndReturnError(ctx, err, "the 'since' parameter must be an RFC 3339 timestamp", http.StatusBadRequest, logrus.Fields{})
+		}
+	}
+
+	if limit := request.QueryStringParameters["limit"]; limit != "" {
+		var err error
+		filter.Limit, err = strconv.Atoi(limit)
+		if err == nil && (filter.Limit < 1 || filter.Limit > maxAuditPageSize) {
+			err = fmt.Errorf("%d is out of range", filter.Limit)
+		}
+		if err != nil {
+			return s.logAndReturnError(ctx, err, fmt.Sprintf("the 'limit' parameter must be a number from 1 to %d", maxAuditPageSize), http.StatusBadRequest, logrus.Fields{})
+		}
+	}
+
+	if token := request.QueryStringParameters["page_token"]; token != "" {
+		after, err := decodeAuditPageToken(token)
+		if err != nil {
+			return s.logAndReturnError(ctx, err, "the 'page_token' parameter is invalid", http.StatusBadRequest, logrus.Fields{})
+		}
+		filter.After = &after
+	}
+
+	if filter.Actor == "" && filter.Since.IsZero() && filter.After == nil {
+		return s.logAndReturnError(ctx, errors.New("an 'actor' or 'since' parameter is required"), "failed to search the audit log", http.StatusBadRequest, logrus.Fields{})
+	}
+
+	auditEvents, err := s.db.GetAuditEvents(ctx, filter)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the audit events from the database", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	responseBody, err := json.Marshal(auditEvents)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the audit events into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}
+	if len(auditEvents) == filter.Limit {
+		response.Headers = map[string]string{"X-Next-Page-Token": encodeAuditPageToken(auditEvents[len(auditEvents)-1])}
+	}
+	return response, nil
+}
+
+// encodeAuditPageToken encodes where the next page of the audit log starts: after the event, which is
+// found by its time and ID
+func encodeAuditPageToken(event internal.AuditEvent) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(event.Timestamp.UTC().Format(time.RFC3339Nano) + " " + event.ID))
+}
+
+func decodeAuditPageToken(token string) (internal.AuditEvent, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return internal.AuditEvent{}, err
+	}
+
+	timestamp, id, ok := strings.Cut(string(decoded), " ")
+	if !ok || id == "" {
+		return internal.AuditEvent{}, errors.New("the token is malformed")
+	}
+	result := internal.AuditEvent{ID: id}
+	result.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return internal.AuditEvent{}, err
+	}
+	return result, nil
+}
+
+// circulationRequest is the body of a check-out or check-in: the barcode of the copy at the desk, and for
+// a check-out the patron borrowing it. The patron type is optional for patrons checking out for
+// themselves, and required from a librarian checking out for another patron.
+type circulationRequest struct {
+	Barcode    string              `json:"barcode"`
+	PatronID   string              `json:"patron_id"`
+	PatronType internal.PatronType `json:"patron_type"`
+}
+
+// circulationResponse is the copy after a check-out or check-in, with the loan that was opened or closed,
+// and the fine charged if it was returned late
+type circulationResponse struct {
+	internal.Copy
+	Loan *internal.Loan        `json:"loan,omitempty"`
+	Fine *internal.LedgerEntry `json:"fine,omitempty"`
+}
+
+// patronTypeOf is the type of the patron the request was authenticated as, from the groups the authorizer
+// placed them in. Patrons in neither the staff nor the student group are members of the public.
+func patronTypeOf(request events.APIGatewayProxyRequest) internal.PatronType {
+	for _, patronType := range []internal.PatronType{internal.PatronStaff, internal.PatronStudent} {
+		if identity.InGroup(request, string(patronType)) {
+			return patronType
+		}
+	}
+	return internal.PatronPublic
+}
+
+// borrowerType is the type of the patron a check-out is for, which decides the lending rule. A patron
+// checking out for themselves is the type the authorizer says they are, and a body that claims another
+// one is rejected instead of being quietly ignored. A librarian at the desk checks out for any patron, and
+// vouches for the patron type given in the body.
+func borrowerType(request events.APIGatewayProxyRequest, body circulationRequest) (internal.PatronType, int, error) {
+	if caller := identity.FromRequest(request); caller.Kind == identity.Subject && caller.Value == body.PatronID {
+		patronType := patronTypeOf(request)
+		if body.PatronType != "" && body.PatronType != patronType {
+			return "", http.StatusForbidden, fmt.Errorf("the patron type '%s' doesn't match the authenticated patron's, '%s'", body.PatronType, patronType)
+		}
+		return patronType, 0, nil
+	}
+
+	if !identity.InGroup(request, identity.LibrarianGroup) && !identity.IsAdmin(request) {
+		return "", http.StatusForbidden, errors.New("only a librarian may check out a copy for another patron")
+	}
+	if body.PatronType == "" {
+		return "", http.StatusBadRequest, errors.New("the patron_type is required to check out a copy for another patron")
+	}
+	return body.PatronType, 0, nil
+}
+
+func (s service) updateStatus(ctx context.Context, request events.APIGatewayProxyRequest, newStatus internal.BookStatus) (events.APIGatewayProxyResponse, error) {
+	bookID := request.PathParameters["book_id"]
+
+	var body circulationRequest
+	err := json.Unmarshal([]byte(request.Body), &body)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to decode the request body", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+	if body.Barcode == "" {
+		return s.logAndReturnError(ctx, errors.New("the barcode is required"), "failed to decode the request body", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+	if newStatus == internal.CheckedOut && body.PatronID == "" {
+		return s.logAndReturnError(ctx, errors.New("the patron_id is required"), "failed to decode the request body", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+	if body.PatronType != "" && !policy.KnownPatronType(body.PatronType) {
+		err = fmt.Errorf("unknown patron type '%s'", body.PatronType)
+		return s.logAndReturnError(ctx, err, "failed to decode the request body", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+
+	var patronType internal.PatronType
+	if newStatus == internal.CheckedOut {
+		var statusCode int
+		patronType, statusCode, err = borrowerType(request, body)
+		if err != nil {
+			return s.logAndReturnError(ctx, err, "failed to verify the patron type", statusCode, logrus.Fields{"book_id": bookID, "patron_id": body.PatronID})
+		}
+	}
+	logFields := logrus.Fields{"book_id": bookID, "barcode": body.Barcode}
+
+	// Retrieve the copy, and make sure it belongs to the book
+	bookCopy, err := s.db.GetCopyByBarcode(ctx, body.Barcode)
+	if err == nil && bookCopy.BookID != bookID {
+		err = internal.ErrCopyNotFound{Barcode: body.Barcode}
+	}
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.As(err, &internal.ErrCopyNotFound{}) {
+			statusCode = http.StatusNotFound
+		}
+
+		return s.logAndReturnError(ctx, err, "failed to retrieve the copy from the database", statusCode, logFields)
+	}
+
+	// Patrons who owe too much can't borrow anything until they've paid, and may only have as many copies
+	// of each item type out at once as the policy allows
+	rule := s.policy.Rule(patronType, bookCopy.ItemType)
+	if newStatus == internal.CheckedOut {
+		logFields["patron_id"] = body.PatronID
+
+		ledger, err := s.db.GetLedger(ctx, body.PatronID)
+		if err != nil {
+			return s.logAndReturnError(ctx, err, "failed to retrieve the patron's account from the database", http.StatusInternalServerError, logFields)
+		}
+		if balance := internal.NewAccount(body.PatronID, ledger).Balance; balance > s.balanceLimit {
+			err = fmt.Errorf("the patron owes %s, more than the limit of %s", balance, s.balanceLimit)
+			return s.logAndReturnError(ctx, err, "failed to check out the copy", http.StatusForbidden, logFields)
+		}
+
+		if rule.MaxLoans > 0 {
+			loans, err := s.db.GetPatronLoans(ctx, body.PatronID)
+			if err != nil {
+				return s.logAndReturnError(ctx, err, "failed to retrieve the patron's loans from the database", http.StatusInternalServerError, logFields)
+			}
+			if n := countLoans(loans, bookCopy.ItemType); n >= rule.MaxLoans {
+				err = fmt.Errorf("the patron already has %d of the %d loans allowed", n, rule.MaxLoans)
+				return s.logAndReturnError(ctx, err, "failed to check out the copy", http.StatusForbidden, logFields)
+			}
+		}
+	}
+
+	// Change the copy's status, as long as it's in the opposite status. A copy on the hold shelf can only
+	// be checked out by the patron it's reserved for.
+	from := internal.CheckedIn
+	if newStatus == internal.CheckedIn {
+		from = internal.CheckedOut
+	}
+
+	var hold *internal.Hold
+	if newStatus == internal.CheckedOut && bookCopy.Status == internal.OnHoldShelf {
+		holds, err := s.db.GetHolds(ctx, bookID)
+		if err != nil {
+			return s.logAndReturnError(ctx, err, "failed to retrieve the book's holds from the database", http.StatusInternalServerError, logFields)
+		}
+
+		reserved, ok := reservedHold(holds, body.Barcode)
+		if !ok || reserved.PatronID != body.PatronID {
+			return s.logAndReturnError(ctx, errors.New("the copy is on the hold shelf for another patron"), "failed to check out the copy", http.StatusConflict, logFields)
+		}
+		hold, from = &reserved, internal.OnHoldShelf
+	}
+
+	now := s.timestamp()
+	if newStatus == internal.CheckedOut {
+		// The copy, the hold it fulfils and the new loan change together
+		updatedCopy, loan, err := s.db.CheckOutCopy(ctx, internal.Loan{
+			Barcode:      body.Barcode,
+			BookID:       bookID,
+			PatronID:     body.PatronID,
+			PatronType:   patronType,
+			ItemType:     bookCopy.ItemType,
+			CheckedOutAt: now,
+			DueAt:        now.Add(rule.LoanPeriod()),
+		}, from, hold)
+		if err != nil {
+			return s.logAndReturnError(ctx, err, "failed to check out the copy in the database", circulationErrorCode(err), logFields)
+		}
+		return s.circulationResponse(ctx, circulationResponse{Copy: updatedCopy, Loan: &loan})
+	}
+
+	updatedCopy, err := s.db.UpdateCopyStatus(ctx, body.Barcode, from, newStatus)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to update the copy in the database", circulationErrorCode(err), logFields)
+	}
+
+	// Copies checked out before loans were recorded have no loan to close
+	response := circulationResponse{Copy: updatedCopy}
+	loan, err := s.db.GetOpenLoan(ctx, body.Barcode)
+	if err == nil {
+		loan, err = s.db.CloseLoan(ctx, loan, now)
+		response.Loan = &loan
+	}
+	if err != nil && !errors.As(err, &internal.ErrLoanNotFound{}) {
+		return s.logAndReturnError(ctx, err, "failed to close the loan in the database", http.StatusInternalServerError, logFields)
+	}
+
+	if response.Loan != nil {
+		response.Fine, err = s.chargeFine(ctx, loan, bookCopy.ItemType, now)
+		if err != nil {
+			return s.logAndReturnError(ctx, err, "failed to charge the fine in the database", http.StatusInternalServerError, logFields)
+		}
+	}
+
+	response.Copy, err = s.passToNextHold(ctx, updatedCopy)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to reserve the copy for the next hold", http.StatusInternalServerError, logFields)
+	}
+
+	return s.circulationResponse(ctx, response)
+}
+
+// circulationErrorCode is the response code for a failure to change a copy's status
+func circulationErrorCode(err error) int {
+	switch {
+	case errors.As(err, &internal.ErrBookNotFound{}), errors.As(err, &internal.ErrCopyNotFound{}):
+		return http.StatusNotFound
+	case errors.As(err, &internal.ErrCopyStatusConflict{}), errors.As(err, &internal.ErrHoldNotFound{}):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (s service) circulationResponse(ctx context.Context, response circulationResponse) (events.APIGatewayProxyResponse, error) {
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the copy into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+// chargeFine charges the patron for a loan returned late. Loans returned on time, or within the grace
+// period, aren't charged anything.
+func (s service) chargeFine(ctx context.Context, loan internal.Loan, itemType internal.ItemType, returnedAt time.Time) (*internal.LedgerEntry, error) {
+	amount := s.policy.Rule(loan.PatronType, itemType).Fine.LoanFine(loan, returnedAt)
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	entry, err := s.db.AddLedgerEntry(ctx, internal.LedgerEntry{
+		PatronID: loan.PatronID,
+		Type:     internal.LedgerCharge,
+		Amount:   amount,
+		LoanID:   loan.ID,
+		Barcode:  loan.Barcode,
+		Note:     fmt.Sprintf("Returned %d days late", loan.DaysOverdue(returnedAt)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// countLoans counts the loans of items of the given type
+func countLoans(loans []internal.Loan, itemType internal.ItemType) int {
+	result := 0
+	for _, loan := range loans {
+		if loan.ItemType == itemType {
+			result++
+		}
+	}
+	return result
+}
+
+// passToNextHold reserves a copy that's checked in, or whose hold was cancelled or expired, for the patron
+// at the head of the book's queue. With nobody waiting, the copy goes back into circulation.
+func (s service) passToNextHold(ctx context.Context, bookCopy internal.Copy) (internal.Copy, error) {
+	holds, err := s.db.GetHolds(ctx, bookCopy.BookID)
+	if err != nil {
+		return bookCopy, err
+	}
+
+	for _, hold := range holds {
+		if hold.Status != internal.HoldWaiting {
+			continue
+		}
+
+		_, err = s.db.ReserveCopy(ctx, hold, bookCopy.Barcode, bookCopy.Status, s.timestamp().Add(s.holdShelfTime))
+		if err != nil {
+			return bookCopy, err
+		}
+		bookCopy.Status = internal.OnHoldShelf
+		return bookCopy, nil
+	}
+
+	if bookCopy.Status != internal.CheckedIn {
+		return s.db.UpdateCopyStatus(ctx, bookCopy.Barcode, bookCopy.Status, internal.CheckedIn)
+	}
+	return bookCopy, nil
+}
+
+// reservedHold finds the hold that the copy is on the hold shelf for
+func reservedHold(holds []internal.Hold, barcode string) (internal.Hold, bool) {
+	for _, hold := range holds {
+		if hold.Status == internal.HoldReady && hold.Barcode == barcode {
+			return hold, true
+		}
+	}
+	return internal.Hold{}, false
+}
+
+func (s service) RenewLoan(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "RenewLoan", request, s.renewLoan)
+}
+
+// renewLoan pushes back the due date of a copy's loan by another loan period. A loan can't be renewed past
+// the policy's maximum, or while other patrons are holding the book.
+func (s service) renewLoan(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	bookID := request.PathParameters["book_id"]
+
+	var body circulationRequest
+	err := json.Unmarshal([]byte(request.Body), &body)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to decode the request body", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+	if body.Barcode == "" {
+		return s.logAndReturnError(ctx, errors.New("the barcode is required"), "failed to decode the request body", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+	logFields := logrus.Fields{"book_id": bookID, "barcode": body.Barcode}
+
+	loan, err := s.db.GetOpenLoan(ctx, body.Barcode)
+	if err == nil && loan.BookID != bookID {
+		err = internal.ErrLoanNotFound{Barcode: body.Barcode}
+	}
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.As(err, &internal.ErrLoanNotFound{}) {
+			statusCode = http.StatusNotFound
+		}
+
+		return s.logAndReturnError(ctx, err, "failed to retrieve the loan from the database", statusCode, logFields)
+	}
+	logFields["loan_id"] = loan.ID
+
+	rule := s.policy.Rule(loan.PatronType, loan.ItemType)
+	if loan.Renewals >= rule.MaxRenewals {
+		return s.logAndReturnError(ctx, fmt.Errorf("the loan can't be renewed more than %d times", rule.MaxRenewals), "failed to renew the loan", http.StatusConflict, logFields)
+	}
+
+	holds, err := s.db.GetHolds(ctx, bookID)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the book's holds from the database", http.StatusInternalServerError, logFields)
+	}
+	if len(holds) > 0 {
+		return s.logAndReturnError(ctx, errors.New("other patrons are holding the book"), "failed to renew the loan", http.StatusConflict, logFields)
+	}
+
+	renewed, err := s.db.RenewLoan(ctx, loan, s.timestamp().Add(rule.LoanPeriod()))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.As(err, &internal.ErrLoanChanged{}) {
+			statusCode = http.StatusConflict
+		}
+
+		return s.logAndReturnError(ctx, err, "failed to renew the loan in the database", statusCode, logFields)
+	}
+
+	responseBody, err := json.Marshal(renewed)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the loan into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+func (s service) GetOverdueLoans(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "GetOverdueLoans", request, s.getOverdueLoans)
+}
+
+// overdueLoan is a loan past its due date, with how many days late it is
+type overdueLoan struct {
+	internal.Loan
+	DaysOverdue int `json:"days_overdue"`
+}
+
+func (s service) getOverdueLoans(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	now := s.timestamp()
+
+	loans, err := s.db.GetOverdueLoans(ctx, now)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the overdue loans from the database", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	result := make([]overdueLoan, 0, len(loans))
+	for _, loan := range loans {
+		result = append(result, overdueLoan{Loan: loan, DaysOverdue: loan.DaysOverdue(now)})
+	}
+
+	responseBody, err := json.Marshal(result)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the loans into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+func (s service) GetPolicies(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "GetPolicies", request, s.getPolicies)
+}
+
+func (s service) getPolicies(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	responseBody, err := json.Marshal(s.policy)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the policy into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+func (s service) GetAccount(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "GetAccount", request, s.getAccount)
+}
+
+func (s service) getAccount(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	patronID := request.PathParameters["patron_id"]
+
+	ledger, err := s.db.GetLedger(ctx, patronID)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the patron's account from the database", http.StatusInternalServerError, logrus.Fields{"patron_id": patronID})
+	}
+
+	responseBody, err := json.Marshal(internal.NewAccount(patronID, ledger))
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the account into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+func (s service) AddPayment(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "AddPayment", request, s.addPayment)
+}
+
+// addPayment takes a payment off the patron's balance. Administrators may waive fines the same way, with
+// a type of "waiver". Neither may take the balance below zero.
+func (s service) addPayment(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	patronID := request.PathParameters["patron_id"]
+	logFields := logrus.Fields{"patron_id": patronID}
+
+	var entry internal.LedgerEntry
+	err := json.Unmarshal([]byte(request.Body), &entry)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to decode the request body into a payment", http.StatusBadRequest, logFields)
+	}
+	entry.PatronID = patronID
+
+	switch entry.Type {
+	case "":
+		entry.Type = internal.LedgerPayment
+	case internal.LedgerPayment:
+	case internal.LedgerWaiver:
+		if !identity.IsAdmin(request) {
+			return s.logAndReturnError(ctx, errors.New("only administrators may waive fines"), "failed to record the payment", http.StatusForbidden, logFields)
+		}
+	default:
+		err = fmt.Errorf("unknown payment type '%s'", entry.Type)
+		return s.logAndReturnError(ctx, err, "failed to record the payment", http.StatusBadRequest, logFields)
+	}
+	if entry.Amount <= 0 {
+		return s.logAndReturnError(ctx, errors.New("the amount must be more than zero"), "failed to record the payment", http.StatusBadRequest, logFields)
+	}
+
+	ledger, err := s.db.GetLedger(ctx, patronID)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the patron's account from the database", http.StatusInternalServerError, logFields)
+	}
+	if balance := internal.NewAccount(patronID, ledger).Balance; entry.Amount > balance {
+		err = fmt.Errorf("the patron only owes %s", balance)
+		return s.logAndReturnError(ctx, err, "failed to record the payment", http.StatusConflict, logFields)
+	}
+
+	newEntry, err := s.db.AddLedgerEntry(ctx, internal.LedgerEntry{
+		PatronID: patronID,
+		Type:     entry.Type,
+		Amount:   entry.Amount,
+		Note:     entry.Note,
+	})
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to record the payment in the database", http.StatusInternalServerError, logFields)
+	}
+
+	responseBody, err := json.Marshal(internal.NewAccount(patronID, append(ledger, newEntry)))
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the account into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+func (s service) PlaceHold(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "PlaceHold", request, s.placeHold)
+}
+
+func (s service) placeHold(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	bookID := request.PathParameters["book_id"]
+
+	var hold internal.Hold
+	err := json.Unmarshal([]byte(request.Body), &hold)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to decode the request body into a hold object", http.StatusBadRequest, logrus.Fields{})
+	}
+	hold.BookID = bookID
+
+	if hold.PatronID == "" {
+		return s.logAndReturnError(ctx, errors.New("the patron_id is required"), "failed to place the hold", http.StatusBadRequest, logrus.Fields{"book_id": bookID})
+	}
+
+	newHold, err := s.db.PlaceHold(ctx, hold)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.As(err, &internal.ErrBookNotFound{}):
+			statusCode = http.StatusNotFound
+		case errors.As(err, &internal.ErrDuplicateHold{}):
+			statusCode = http.StatusConflict
+		}
+
+		return s.logAndReturnError(ctx, err, "failed to place the hold in the database", statusCode, logrus.Fields{"book_id": bookID, "patron_id": hold.PatronID})
+	}
+
+	responseBody, err := json.Marshal(newHold)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the hold into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+func (s service) GetHolds(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "GetHolds", request, s.getHolds)
+}
+
+func (s service) getHolds(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	bookID := request.PathParameters["book_id"]
+
+	holds, err := s.db.GetHolds(ctx, bookID)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the book's holds from the database", http.StatusInternalServerError, logrus.Fields{"book_id": bookID})
+	}
+
+	responseBody, err := json.Marshal(holds)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to encode the holds into an http response", http.StatusInternalServerError, logrus.Fields{})
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(responseBody),
+	}, nil
+}
+
+func (s service) CancelHold(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return s.handle(ctx, "CancelHold", request, s.cancelHold)
+}
+
+func (s service) cancelHold(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	bookID := request.PathParameters["book_id"]
+	holdID := request.PathParameters["hold_id"]
+	logFields := logrus.Fields{"book_id": bookID, "hold_id": holdID}
+
+	holds, err := s.db.GetHolds(ctx, bookID)
+	if err != nil {
+		return s.logAndReturnError(ctx, err, "failed to retrieve the book's holds from the database", http.StatusInternalServerError, logFields)
+	}
+
+	for _, hold := range holds {
+		if hold.ID != holdID {
+			continue
+		}
+
+		err = s.closeHold(ctx, hold, internal.HoldCancelled)
+		if err != nil {
+			statusCode := http.StatusInternalServerError
+			if errors.As(err, &internal.ErrHoldNotFound{}) {
+				statusCode = http.StatusNotFound
+			}
+
+			return s.logAndReturnError(ctx, err, "failed to cancel the hold in the database", statusCode, logFields)
+		}
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+
+	return s.logAndReturnError(ctx, internal.ErrHoldNotFound{HoldID: holdID}, "failed to cancel the hold", http.StatusNotFound, logFields)
+}
+
+// ExpireHolds closes the holds whose copy has been on the hold shelf longer than the hold shelf time, and
+// passes each copy on to the next patron in the queue. It runs on a schedule rather than behind the API.
+func (s service) ExpireHolds(ctx context.Context, event events.CloudWatchEvent) error {
+	ctx, span := tracing.Tracer().Start(ctx, "books.service.ExpireHolds")
+	defer span.End()
+
+	ctx = internal.ContextWithActor(ctx, "scheduler:"+event.ID)
+
+	holds, err := s.db.GetExpiredHolds(ctx, s.timestamp())
+	if err != nil {
+		s.logger.WithError(err).Error("failed to retrieve the expired holds")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	expired := 0
+	for _, hold := range holds {
+		// A hold that's no longer found was closed in the meantime, e.g. by the patron checking out the copy
+		err = s.closeHold(ctx, hold, internal.HoldExpired)
+		switch {
+		case err == nil:
+			expired++
+		case !errors.As(err, &internal.ErrHoldNotFound{}):
+			s.logger.WithError(err).WithFields(logrus.Fields{"hold_id": hold.ID, "expired": expired}).Error("failed to expire the hold")
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+	}
+
+	s.emit(metrics.Value("HoldsExpired", metrics.Count, float64(expired), nil))
+	s.logger.WithField("expired", expired).Info("expired the holds")
+	return nil
+}
+
+// closeHold takes the hold out of the queue, and passes on the copy that was reserved for it
+func (s service) closeHold(ctx context.Context, hold internal.Hold, status internal.HoldStatus) error {
+	err := s.db.CloseHold(ctx, hold, status)
+	if err != nil {
+		return err
+	}
+
+	if hold.Status != internal.HoldReady {
+		return nil
+	}
+
+	_, err = s.passToNextHold(ctx, internal.Copy{Barcode: hold.Barcode, BookID: hold.BookID, Status: internal.OnHoldShelf})
+	return err
+}
+
+// handle runs a handler inside a span named after it, tagged with the book ID and the response's status
+// code. The caller is recorded in the context as the actor of any changes the handler makes.
+func (s service) handle(ctx context.Context, name string, request events.APIGatewayProxyRequest, handler func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) (events.APIGatewayProxyResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "books.service."+name)
+	defer span.End()
+
+	ctx = internal.ContextWithActor(ctx, identity.FromRequest(request).String())
+
+	if bookID, ok := request.PathParameters["book_id"]; ok {
+		span.SetAttributes(attribute.String("book_id", bookID))
+	}
+
+	response, err := handler(ctx, request)
+
+	span.SetAttributes(attribute.Int("http.status_code", response.StatusCode))
+	if response.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, response.Body)
+	}
+
+	return response, err
+}
+
+// timestamp reads the service's clock, falling back to the system clock
+func (s service) timestamp() time.Time {
+	if s.now == nil {
+		return time.Now().UTC()
+	}
+	return s.now().UTC()
+}
+
+// emit sends domain metrics to the service's sink, if it has one
+func (s service) emit(data ...metrics.Datum) {
+	if s.metrics != nil {
+		s.metrics.Emit(data...)
+	}
+}
+
+// logAndReturnError logs with the request's logger if the handler was wrapped by the logging middleware,
+// so the line carries the request and correlation IDs, and with the service's logger otherwise. A server
+// error that the database says may pass later, such as throttling, is a 503 that tells the client when to
+// try again.
+func (s service) logAndReturnError(ctx context.Context, err error, message string, statusCode int, logFields logrus.Fields) (events.APIGatewayProxyResponse, error) {
+	logging.FromContextOr(ctx, s.logger).WithError(err).WithFields(logFields).Error(message)
+	trace.SpanFromContext(ctx).RecordError(err)
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Body:       formatErrorForResponseBody(fmt.Errorf("%s: %w", message, err)),
+	}
+	var retryable internal.ErrRetryable
+	if statusCode >= http.StatusInternalServerError && errors.As(err, &retryable) {
+		response.StatusCode = http.StatusServiceUnavailable
+		response.Headers = map[string]string{"Retry-After": strconv.Itoa(retryAfterSeconds(retryable.RetryAfter))}
+	}
+	return response, nil
+}
+
+// retryAfterSeconds rounds the wait up to the whole seconds a Retry-After header counts in, and to at
+// least one, since a client may take zero to mean it needn't wait
+func retryAfterSeconds(wait time.Duration) int {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+func formatErrorForResponseBody(err error) string {
+	//TODO: Allow this service to support Content-Type other than JSON
+	body, marshalErr := json.Marshal(errorResponse{ErrorMessage: err.Error()})
+	if marshalErr != nil {
+		return `{"error":"internal server error"}`
+	}
+	return string(body)
 }
 
 type errorResponse struct {
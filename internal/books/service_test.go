@@ -4,18 +4,35 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/aaron-zeisler/library-api/internal"
 	"github.com/aaron-zeisler/library-api/internal/books/mocks"
+	"github.com/aaron-zeisler/library-api/internal/fines"
+	"github.com/aaron-zeisler/library-api/internal/policy"
+	"github.com/aaron-zeisler/library-api/internal/storage"
 	"github.com/aaron-zeisler/library-api/internal/testutils"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/smartystreets/assertions"
 	"github.com/smartystreets/assertions/should"
 )
 
+// testPolicy lends DVDs to students for a week, one at a time, and everything else to everyone for two
+// weeks, renewable once
+var testPolicy = policy.Policy{Rules: map[string]map[string]policy.Rule{
+	policy.Default: {
+		policy.Default: {LoanDays: 14, MaxRenewals: 1, Fine: fines.Rate{PerDay: 25, GraceDays: 1}},
+	},
+	"student": {
+		"dvd": {LoanDays: 7, MaxLoans: 1, Fine: fines.Rate{PerDay: 100}},
+	},
+}}
+
 func Test_service_GetBooks(t *testing.T) {
 	type state struct {
 		request    events.APIGatewayProxyRequest
@@ -26,6 +43,7 @@ func Test_service_GetBooks(t *testing.T) {
 		responseCode int
 		responseBody interface{}
 		err          error
+		filter       internal.BookFilter
 	}
 	testCases := map[string]struct {
 		state    state
@@ -44,6 +62,66 @@ func Test_service_GetBooks(t *testing.T) {
 				},
 			},
 		},
+		"The updated_since parameter isn't a timestamp": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"updated_since": "last week"},
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "the 'updated_since' parameter must be an RFC 3339 timestamp: parsing time \"last week\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"last week\" as \"2006\"",
+				},
+			},
+		},
+		"Only the books updated since the given time are requested": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"updated_since": "2021-03-01T09:30:00Z"},
+				},
+				dbResponse: []internal.Book{
+					{ID: "12345", ISBN: "12345", Title: "GetBooks Test"},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: []internal.Book{
+					{ID: "12345", ISBN: "12345", Title: "GetBooks Test"},
+				},
+				filter: internal.BookFilter{UpdatedSince: time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)},
+			},
+		},
+		"The status parameter isn't a book status": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"status": "lost"},
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "the 'status' parameter must be 'in', 'out' or 'on_hold_shelf': unknown status 'lost'",
+				},
+			},
+		},
+		"Only the books with the given ISBN, author and status are requested": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"isbn": "12345", "author": "Anonymous", "status": "out"},
+				},
+				dbResponse: []internal.Book{
+					{ID: "12345", ISBN: "12345", Title: "GetBooks Test", Author: "Anonymous", Status: internal.CheckedOut},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: []internal.Book{
+					{ID: "12345", ISBN: "12345", Title: "GetBooks Test", Author: "Anonymous", Status: internal.CheckedOut},
+				},
+				filter: internal.BookFilter{ISBN: "12345", Author: "Anonymous", Status: internal.CheckedOut},
+			},
+		},
 		"Happy path": {
 			state{
 				request: events.APIGatewayProxyRequest{},
@@ -90,17 +168,83 @@ func Test_service_GetBooks(t *testing.T) {
 				assert.So(resp, should.Resemble, tc.expected.responseBody)
 			}
 
+			// Verify the filter passed to the database
+			if db.GetBooksCallCount() > 0 {
+				_, filter := db.GetBooksArgsForCall(0)
+				assert.So(filter.UpdatedSince.Equal(tc.expected.filter.UpdatedSince), should.BeTrue)
+				assert.So(filter.ISBN, should.Equal, tc.expected.filter.ISBN)
+				assert.So(filter.Author, should.Equal, tc.expected.filter.Author)
+				assert.So(filter.Status, should.Equal, tc.expected.filter.Status)
+			}
+
 			// Verify the error
 			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
 		})
 	}
 }
 
+func Test_service_databaseUnavailable(t *testing.T) {
+	type state struct {
+		dbError error
+	}
+	type expected struct {
+		responseCode int
+		headers      map[string]string
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The database was throttled for less than a second": {
+			state{dbError: internal.ErrRetryable{Err: errors.New("throttled"), Throttled: true, RetryAfter: 800 * time.Millisecond}},
+			expected{responseCode: http.StatusServiceUnavailable, headers: map[string]string{"Retry-After": "1"}},
+		},
+		"The wait is rounded up to whole seconds": {
+			state{dbError: internal.ErrRetryable{Err: errors.New("internal server error"), RetryAfter: 2500 * time.Millisecond}},
+			expected{responseCode: http.StatusServiceUnavailable, headers: map[string]string{"Retry-After": "3"}},
+		},
+		"The retryable error is wrapped": {
+			state{dbError: fmt.Errorf("failed to query the books: %w", internal.ErrRetryable{Err: errors.New("throttled"), RetryAfter: time.Second})},
+			expected{responseCode: http.StatusServiceUnavailable, headers: map[string]string{"Retry-After": "1"}},
+		},
+		"The database failed in a way that won't pass": {
+			state{dbError: internal.ErrNonRetryable{Err: errors.New("validation error")}},
+			expected{responseCode: http.StatusInternalServerError},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.GetBooksReturns(nil, tc.state.dbError)
+
+			s := service{
+				db:     db,
+				logger: logrus.New(),
+			}
+
+			result, err := s.GetBooks(context.Background(), events.APIGatewayProxyRequest{})
+
+			assert.So(err, should.BeNil)
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+			assert.So(result.Headers, should.Resemble, tc.expected.headers)
+
+			resp := errorResponse{}
+			jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+			assert.So(jsonErr, should.BeNil)
+			assert.So(resp.ErrorMessage, should.Equal, "failed to retrieve books from the database: "+tc.state.dbError.Error())
+		})
+	}
+}
+
 func Test_service_GetBookByID(t *testing.T) {
 	type state struct {
 		request    events.APIGatewayProxyRequest
 		dbResponse internal.Book
 		dbError    error
+		dbCopies   []internal.Copy
 	}
 	type expected struct {
 		responseCode int
@@ -149,11 +293,17 @@ func Test_service_GetBookByID(t *testing.T) {
 				dbResponse: internal.Book{
 					ID: "12345", ISBN: "12345", Title: "GetBookByID Test",
 				},
+				dbCopies: []internal.Copy{
+					{Barcode: "1", BookID: "12345", Status: internal.CheckedIn},
+					{Barcode: "2", BookID: "12345", Status: internal.CheckedOut},
+					{Barcode: "3", BookID: "12345", Status: internal.CheckedIn},
+				},
 			},
 			expected{
 				responseCode: http.StatusOK,
-				responseBody: internal.Book{
-					ID: "12345", ISBN: "12345", Title: "GetBookByID Test",
+				responseBody: bookDetails{
+					Book:         internal.Book{ID: "12345", ISBN: "12345", Title: "GetBookByID Test"},
+					Availability: internal.Availability{Available: 2, Total: 3},
 				},
 			},
 		},
@@ -165,6 +315,7 @@ func Test_service_GetBookByID(t *testing.T) {
 
 			db := &mocks.MockBooksDB{}
 			db.GetBookByIDReturns(tc.state.dbResponse, tc.state.dbError)
+			db.GetCopiesReturns(tc.state.dbCopies, nil)
 
 			s := service{
 				db:     db,
@@ -178,7 +329,7 @@ func Test_service_GetBookByID(t *testing.T) {
 
 			// Verify the response body
 			if tc.expected.responseCode == http.StatusOK {
-				resp := internal.Book{}
+				resp := bookDetails{}
 				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
 				assert.So(jsonErr, should.BeNil)
 				assert.So(resp, should.Resemble, tc.expected.responseBody)
@@ -238,6 +389,21 @@ func Test_service_CreateBook(t *testing.T) {
 				},
 			},
 		},
+		"Another book has the ISBN": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					Body: `{"isbn": "12345", "title": "CreateBook Test", "author": "Testy McTesterson"}`,
+				},
+				dbResponse: internal.Book{},
+				dbError:    internal.ErrDuplicateISBN{ISBN: "12345"},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to create a new book in the database: A book with ISBN '12345' already exists",
+				},
+			},
+		},
 		"Happy path": {
 			state{
 				request: events.APIGatewayProxyRequest{
@@ -337,6 +503,22 @@ func Test_service_UpdateBook(t *testing.T) {
 				},
 			},
 		},
+		"Another book has the ISBN": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"id": "12345", "isbn": "12345", "title": "UpdateBook Test", "author": "Testy McTesterson"}`,
+				},
+				dbResponse: internal.Book{},
+				dbError:    internal.ErrDuplicateISBN{ISBN: "12345"},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to update the book in the database: A book with ISBN '12345' already exists",
+				},
+			},
+		},
 		"db.UpdateBook returns an unexpected error": {
 			state{
 				request: events.APIGatewayProxyRequest{
@@ -410,27 +592,68 @@ func Test_service_UpdateBook(t *testing.T) {
 
 func Test_service_DeleteBook(t *testing.T) {
 	type state struct {
-		request events.APIGatewayProxyRequest
-		dbError error
+		request       events.APIGatewayProxyRequest
+		dbBook        internal.Book
+		dbGetError    error
+		dbDeleteError error
 	}
 	type expected struct {
 		responseCode int
 		responseBody interface{}
 		err          error
+		purged       bool
+	}
+	adminRequest := events.APIGatewayProxyRequest{
+		PathParameters:        map[string]string{"book_id": "12345"},
+		QueryStringParameters: map[string]string{"purge": "true"},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "librarian-1", "cognito:groups": "admin"}},
+		},
 	}
 	testCases := map[string]struct {
 		state    state
 		expected expected
 	}{
+		"db.GetBookByID returns a BookNotFound error": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+				},
+				dbGetError: internal.ErrBookNotFound{BookID: "12345"},
+			},
+			expected{
+				responseCode: http.StatusNotFound,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to retrieve the book from the database: The book with ID '12345' was not found",
+				},
+			},
+		},
+		"The book is checked out": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+				},
+				dbBook: internal.Book{ID: "12345", Status: internal.CheckedOut},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to delete the book: the book is checked out",
+				},
+			},
+		},
 		"db.DeleteBook returns a BookNotFound error": {
 			state{
 				request: events.APIGatewayProxyRequest{
 					PathParameters: map[string]string{"book_id": "12345"},
 				},
-				dbError: internal.ErrBookNotFound{BookID: "12345"},
+				dbDeleteError: internal.ErrBookNotFound{BookID: "12345"},
 			},
 			expected{
-				responseCode: http.StatusOK,
+				responseCode: http.StatusNotFound,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to delete the book from the database: The book with ID '12345' was not found",
+				},
 			},
 		},
 		"db.DeleteBook returns an unexpected error": {
@@ -438,7 +661,7 @@ func Test_service_DeleteBook(t *testing.T) {
 				request: events.APIGatewayProxyRequest{
 					PathParameters: map[string]string{"book_id": "12345"},
 				},
-				dbError: errors.New("db.DeleteBook error"),
+				dbDeleteError: errors.New("db.DeleteBook error"),
 			},
 			expected{
 				responseCode: http.StatusInternalServerError,
@@ -447,6 +670,52 @@ func Test_service_DeleteBook(t *testing.T) {
 				},
 			},
 		},
+		"Only administrators may purge a book": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters:        map[string]string{"book_id": "12345"},
+					QueryStringParameters: map[string]string{"purge": "true"},
+				},
+			},
+			expected{
+				responseCode: http.StatusForbidden,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to purge the book: only administrators may purge books",
+				},
+			},
+		},
+		"An administrator purges a book": {
+			state{
+				request: adminRequest,
+			},
+			expected{
+				responseCode: http.StatusOK,
+				purged:       true,
+			},
+		},
+		"An administrator purges a book in the trash": {
+			state{
+				request:    adminRequest,
+				dbGetError: internal.ErrBookNotFound{BookID: "12345"},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				purged:       true,
+			},
+		},
+		"An administrator purges a book that doesn't exist": {
+			state{
+				request:       adminRequest,
+				dbGetError:    internal.ErrBookNotFound{BookID: "12345"},
+				dbDeleteError: internal.ErrBookNotFound{BookID: "12345"},
+			},
+			expected{
+				responseCode: http.StatusNotFound,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to delete the book from the database: The book with ID '12345' was not found",
+				},
+			},
+		},
 		"Happy path": {
 			state{
 				request: events.APIGatewayProxyRequest{
@@ -464,7 +733,9 @@ func Test_service_DeleteBook(t *testing.T) {
 			assert := assertions.New(t)
 
 			db := &mocks.MockBooksDB{}
-			db.DeleteBookReturns(tc.state.dbError)
+			db.GetBookByIDReturns(tc.state.dbBook, tc.state.dbGetError)
+			db.DeleteBookReturns(tc.state.dbDeleteError)
+			db.PurgeBookReturns(tc.state.dbDeleteError)
 
 			s := service{
 				db:     db,
@@ -484,8 +755,1822 @@ func Test_service_DeleteBook(t *testing.T) {
 				assert.So(resp, should.Resemble, tc.expected.responseBody)
 			}
 
+			// Verify the book was purged, rather than moved to the trash
+			if tc.expected.responseCode == http.StatusOK {
+				assert.So(db.PurgeBookCallCount() == 1, should.Equal, tc.expected.purged)
+				assert.So(db.DeleteBookCallCount() == 1, should.Equal, !tc.expected.purged)
+			}
+
 			// Verify the error
 			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
 		})
 	}
 }
+
+// A book is deleted to the trash and then purged from it, in a storage that hides the trash like the real ones do
+func Test_service_DeleteBook_purgeFromTrash(t *testing.T) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+	db := storage.NewStaticBooksStorage(storage.WithSeedBooks(internal.Book{ID: "12345", Title: "Dune", Status: internal.CheckedIn}))
+	s := NewService(db)
+	request := events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"book_id": "12345"},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "librarian-1", "cognito:groups": "admin"}},
+		},
+	}
+
+	result, err := s.DeleteBook(ctx, request)
+	assert.So(err, should.BeNil)
+	assert.So(result.StatusCode, should.Equal, http.StatusOK)
+
+	request.QueryStringParameters = map[string]string{"purge": "true"}
+	result, err = s.DeleteBook(ctx, request)
+	assert.So(err, should.BeNil)
+	assert.So(result.StatusCode, should.Equal, http.StatusOK)
+
+	deleted, err := db.GetDeletedBooks(ctx)
+	assert.So(err, should.BeNil)
+	assert.So(deleted, should.BeEmpty)
+
+	// It's gone for good, so purging it again finds nothing
+	result, err = s.DeleteBook(ctx, request)
+	assert.So(err, should.BeNil)
+	assert.So(result.StatusCode, should.Equal, http.StatusNotFound)
+}
+
+func Test_service_RestoreBook(t *testing.T) {
+	type state struct {
+		request  events.APIGatewayProxyRequest
+		dbResult internal.Book
+		dbError  error
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"db.RestoreBook returns a BookNotFound error": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+				},
+				dbError: internal.ErrBookNotFound{BookID: "12345"},
+			},
+			expected{
+				responseCode: http.StatusNotFound,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to restore the book in the database: The book with ID '12345' was not found",
+				},
+			},
+		},
+		"db.RestoreBook returns an unexpected error": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+				},
+				dbError: errors.New("db.RestoreBook error"),
+			},
+			expected{
+				responseCode: http.StatusInternalServerError,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to restore the book in the database: db.RestoreBook error",
+				},
+			},
+		},
+		"Happy path": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+				},
+				dbResult: internal.Book{ID: "12345", Title: "Beloved"},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: internal.Book{ID: "12345", Title: "Beloved"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.RestoreBookReturns(tc.state.dbResult, tc.state.dbError)
+
+			s := service{
+				db:     db,
+				logger: logrus.New(),
+			}
+
+			result, err := s.RestoreBook(context.Background(), tc.state.request)
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := internal.Book{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_GetAuditEvents(t *testing.T) {
+	since := time.Date(2021, 2, 14, 12, 0, 0, 0, time.UTC)
+	adminContext := events.APIGatewayProxyRequestContext{
+		Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "librarian-1", "cognito:groups": "admin"}},
+	}
+
+	type state struct {
+		request    events.APIGatewayProxyRequest
+		dbResponse []internal.AuditEvent
+		dbError    error
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		headers      map[string]string
+		filter       internal.AuditFilter // The filter that should be passed to the database
+		err          error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"Only administrators may read the audit log": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"since": "2021-02-14T12:00:00Z"},
+				},
+			},
+			expected{
+				responseCode: http.StatusForbidden,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to retrieve the audit events: only administrators may read the audit log",
+				},
+			},
+		},
+		"The 'since' parameter is malformed": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"since": "yesterday"},
+					RequestContext:        adminContext,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: `the 'since' parameter must be an RFC 3339 timestamp: parsing time "yesterday" as "2006-01-02T15:04:05Z07:00": cannot parse "yesterday" as "2006"`,
+				},
+			},
+		},
+		"The 'limit' parameter is out of range": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"since": "2021-02-14T12:00:00Z", "limit": "0"},
+					RequestContext:        adminContext,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "the 'limit' parameter must be a number from 1 to 1000: 0 is out of range",
+				},
+			},
+		},
+		"The 'page_token' parameter is malformed": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"page_token": "bm90LWEtdG9rZW4"},
+					RequestContext:        adminContext,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "the 'page_token' parameter is invalid: the token is malformed",
+				},
+			},
+		},
+		"The search has nowhere to start": {
+			state{
+				request: events.APIGatewayProxyRequest{RequestContext: adminContext},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to search the audit log: an 'actor' or 'since' parameter is required",
+				},
+			},
+		},
+		"db.GetAuditEvents returns an error": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"since": "2021-02-14T12:00:00Z"},
+					RequestContext:        adminContext,
+				},
+				dbError: errors.New("db.GetAuditEvents error"),
+			},
+			expected{
+				responseCode: http.StatusInternalServerError,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to retrieve the audit events from the database: db.GetAuditEvents error",
+				},
+			},
+		},
+		"Happy path": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"actor": "sub:librarian-1", "since": "2021-02-14T12:00:00Z"},
+					RequestContext:        adminContext,
+				},
+				dbResponse: []internal.AuditEvent{
+					{ID: "1", BookID: "12345", Action: internal.AuditCheckOut, Actor: "sub:librarian-1", Timestamp: since},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: []internal.AuditEvent{
+					{ID: "1", BookID: "12345", Action: internal.AuditCheckOut, Actor: "sub:librarian-1", Timestamp: since},
+				},
+				filter: internal.AuditFilter{Actor: "sub:librarian-1", Since: since, Limit: 100},
+			},
+		},
+		"A full page links to the next one": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					QueryStringParameters: map[string]string{"limit": "1", "page_token": "MjAyMS0wMi0xNFQxMjowMDowMFogMQ"},
+					RequestContext:        adminContext,
+				},
+				dbResponse: []internal.AuditEvent{
+					{ID: "2", BookID: "12345", Action: internal.AuditCheckIn, Actor: "sub:librarian-1", Timestamp: since.Add(30 * time.Minute)},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: []internal.AuditEvent{
+					{ID: "2", BookID: "12345", Action: internal.AuditCheckIn, Actor: "sub:librarian-1", Timestamp: since.Add(30 * time.Minute)},
+				},
+				headers: map[string]string{"X-Next-Page-Token": "MjAyMS0wMi0xNFQxMjozMDowMFogMg"},
+				filter:  internal.AuditFilter{After: &internal.AuditEvent{ID: "1", Timestamp: since}, Limit: 1},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.GetAuditEventsReturns(tc.state.dbResponse, tc.state.dbError)
+
+			s := service{
+				db:     db,
+				logger: logrus.New(),
+			}
+
+			result, err := s.GetAuditEvents(context.Background(), tc.state.request)
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := []internal.AuditEvent{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+				assert.So(result.Headers, should.Resemble, tc.expected.headers)
+
+				_, filter := db.GetAuditEventsArgsForCall(0)
+				assert.So(filter, should.Resemble, tc.expected.filter)
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_CheckOut(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+
+	type state struct {
+		request          events.APIGatewayProxyRequest
+		dbCopy           internal.Copy
+		dbGetError       error
+		dbCheckOutResult internal.Copy
+		dbCheckOutError  error
+		dbHolds          []internal.Hold
+		dbLedger         []internal.LedgerEntry
+		dbPatronLoans    []internal.Loan
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+		from         internal.BookStatus // The status the copy is checked out from
+		fulfilled    bool                // Whether a hold was fulfilled by the check-out
+	}
+	loan := internal.Loan{ID: "loan-1", Barcode: "31234000012345", BookID: "12345", PatronID: "patron-1", PatronType: internal.PatronPublic, CheckedOutAt: now, DueAt: now.Add(14 * 24 * time.Hour)}
+	dvdLoan := internal.Loan{ID: "loan-1", Barcode: "31234000012345", BookID: "12345", PatronID: "patron-1", PatronType: internal.PatronStudent, ItemType: internal.ItemDVD, CheckedOutAt: now, DueAt: now.Add(7 * 24 * time.Hour)}
+	patronContext := events.APIGatewayProxyRequestContext{
+		Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "patron-1"}},
+	}
+	studentContext := events.APIGatewayProxyRequestContext{
+		Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "patron-1", "cognito:groups": "student"}},
+	}
+	librarianContext := events.APIGatewayProxyRequestContext{
+		Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "librarian-1", "cognito:groups": "librarian"}},
+	}
+	readyHold := internal.Hold{ID: "hold-1", BookID: "12345", PatronID: "patron-1", Status: internal.HoldReady, Barcode: "31234000012345"}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The request doesn't have a barcode": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to decode the request body: the barcode is required",
+				},
+			},
+		},
+		"The request doesn't have a patron_id": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to decode the request body: the patron_id is required",
+				},
+			},
+		},
+		"The patron type is unknown": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1","patron_type":"alumni"}`,
+					RequestContext: patronContext,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to decode the request body: unknown patron type 'alumni'",
+				},
+			},
+		},
+		"The patron claims a patron type they aren't": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1","patron_type":"staff"}`,
+					RequestContext: studentContext,
+				},
+			},
+			expected{
+				responseCode: http.StatusForbidden,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to verify the patron type: the patron type 'staff' doesn't match the authenticated patron's, 'student'",
+				},
+			},
+		},
+		"A patron checks out a copy for another patron": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-2","patron_type":"staff"}`,
+					RequestContext: studentContext,
+				},
+			},
+			expected{
+				responseCode: http.StatusForbidden,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to verify the patron type: only a librarian may check out a copy for another patron",
+				},
+			},
+		},
+		"A librarian checks out a copy for a patron without giving their type": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: librarianContext,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to verify the patron type: the patron_type is required to check out a copy for another patron",
+				},
+			},
+		},
+		"db.GetCopyByBarcode returns a CopyNotFound error": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: patronContext,
+				},
+				dbGetError: internal.ErrCopyNotFound{Barcode: "31234000012345"},
+			},
+			expected{
+				responseCode: http.StatusNotFound,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to retrieve the copy from the database: The copy with barcode '31234000012345' was not found",
+				},
+			},
+		},
+		"The copy belongs to another book": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: patronContext,
+				},
+				dbCopy: internal.Copy{Barcode: "31234000012345", BookID: "67890", Status: internal.CheckedIn},
+			},
+			expected{
+				responseCode: http.StatusNotFound,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to retrieve the copy from the database: The copy with barcode '31234000012345' was not found",
+				},
+			},
+		},
+		"The copy is already checked out": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: patronContext,
+				},
+				dbCopy:          internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedOut},
+				dbCheckOutError: internal.ErrCopyStatusConflict{Barcode: "31234000012345", Status: internal.CheckedIn},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to check out the copy in the database: The copy with barcode '31234000012345' is not checked in",
+				},
+			},
+		},
+		"The patron owes more than the balance limit": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: patronContext,
+				},
+				dbCopy: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn},
+				dbLedger: []internal.LedgerEntry{
+					{PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 1500},
+					{PatronID: "patron-1", Type: internal.LedgerPayment, Amount: 400},
+				},
+			},
+			expected{
+				responseCode: http.StatusForbidden,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to check out the copy: the patron owes 11.00, more than the limit of 10.00",
+				},
+			},
+		},
+		"The patron has as many loans of the item type as the policy allows": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1","patron_type":"student"}`,
+					RequestContext: studentContext,
+				},
+				dbCopy:        internal.Copy{Barcode: "31234000012345", BookID: "12345", ItemType: internal.ItemDVD, Status: internal.CheckedIn},
+				dbPatronLoans: []internal.Loan{{ID: "loan-0", PatronID: "patron-1", ItemType: internal.ItemDVD}},
+			},
+			expected{
+				responseCode: http.StatusForbidden,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to check out the copy: the patron already has 1 of the 1 loans allowed",
+				},
+			},
+		},
+		"The copy is on the hold shelf for another patron": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-2","patron_type":"public"}`,
+					RequestContext: librarianContext,
+				},
+				dbCopy:  internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.OnHoldShelf},
+				dbHolds: []internal.Hold{readyHold},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to check out the copy: the copy is on the hold shelf for another patron",
+				},
+			},
+		},
+		"The patron checks out the copy reserved for them": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: patronContext,
+				},
+				dbCopy:           internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.OnHoldShelf},
+				dbHolds:          []internal.Hold{readyHold},
+				dbCheckOutResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedOut},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: circulationResponse{Copy: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedOut}, Loan: &loan},
+				from:         internal.OnHoldShelf,
+				fulfilled:    true,
+			},
+		},
+		"The hold was closed in the meantime": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: patronContext,
+				},
+				dbCopy:          internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.OnHoldShelf},
+				dbHolds:         []internal.Hold{readyHold},
+				dbCheckOutError: internal.ErrHoldNotFound{HoldID: "hold-1"},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to check out the copy in the database: " + internal.ErrHoldNotFound{HoldID: "hold-1"}.Error(),
+				},
+			},
+		},
+		"db.CheckOutCopy returns an unexpected error": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: patronContext,
+				},
+				dbCopy:          internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn},
+				dbCheckOutError: errors.New("db.CheckOutCopy error"),
+			},
+			expected{
+				responseCode: http.StatusInternalServerError,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to check out the copy in the database: db.CheckOutCopy error",
+				},
+			},
+		},
+		"Happy path": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: patronContext,
+				},
+				dbCopy:           internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn},
+				dbCheckOutResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedOut},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: circulationResponse{Copy: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedOut}, Loan: &loan},
+				from:         internal.CheckedIn,
+			},
+		},
+		"A librarian checks out a copy for a patron": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1","patron_type":"student"}`,
+					RequestContext: librarianContext,
+				},
+				dbCopy:           internal.Copy{Barcode: "31234000012345", BookID: "12345", ItemType: internal.ItemDVD, Status: internal.CheckedIn},
+				dbCheckOutResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", ItemType: internal.ItemDVD, Status: internal.CheckedOut},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: circulationResponse{Copy: internal.Copy{Barcode: "31234000012345", BookID: "12345", ItemType: internal.ItemDVD, Status: internal.CheckedOut}, Loan: &dvdLoan},
+				from:         internal.CheckedIn,
+			},
+		},
+		"The policy decides the loan period": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","patron_id":"patron-1"}`,
+					RequestContext: studentContext,
+				},
+				dbCopy:           internal.Copy{Barcode: "31234000012345", BookID: "12345", ItemType: internal.ItemDVD, Status: internal.CheckedIn},
+				dbCheckOutResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", ItemType: internal.ItemDVD, Status: internal.CheckedOut},
+				dbPatronLoans:    []internal.Loan{{ID: "loan-0", PatronID: "patron-1", ItemType: internal.ItemBook}},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: circulationResponse{Copy: internal.Copy{Barcode: "31234000012345", BookID: "12345", ItemType: internal.ItemDVD, Status: internal.CheckedOut}, Loan: &dvdLoan},
+				from:         internal.CheckedIn,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.GetCopyByBarcodeReturns(tc.state.dbCopy, tc.state.dbGetError)
+			db.GetHoldsReturns(tc.state.dbHolds, nil)
+			db.CheckOutCopyStub = func(ctx context.Context, loan internal.Loan, from internal.BookStatus, hold *internal.Hold) (internal.Copy, internal.Loan, error) {
+				loan.ID = "loan-1"
+				return tc.state.dbCheckOutResult, loan, tc.state.dbCheckOutError
+			}
+			db.GetLedgerReturns(tc.state.dbLedger, nil)
+			db.GetPatronLoansReturns(tc.state.dbPatronLoans, nil)
+
+			s := NewService(db, WithPolicy(testPolicy), WithClock(func() time.Time { return now }))
+
+			result, err := s.CheckOut(context.Background(), tc.state.request)
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := circulationResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+
+				// The copy, the hold and the loan change in a single call
+				assert.So(db.CheckOutCopyCallCount(), should.Equal, 1)
+				_, _, from, hold := db.CheckOutCopyArgsForCall(0)
+				assert.So(from, should.Equal, tc.expected.from)
+				assert.So(hold != nil, should.Equal, tc.expected.fulfilled)
+				if tc.expected.fulfilled {
+					assert.So(hold.ID, should.Equal, readyHold.ID)
+				}
+				assert.So(db.UpdateCopyStatusCallCount(), should.Equal, 0)
+				assert.So(db.CloseHoldCallCount(), should.Equal, 0)
+				assert.So(db.CreateLoanCallCount(), should.Equal, 0)
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_AddCopy(t *testing.T) {
+	type state struct {
+		request  events.APIGatewayProxyRequest
+		dbResult internal.Copy
+		dbError  error
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+		dbCopy       internal.Copy // The copy passed to the database
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The request doesn't have a barcode": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"branch":"Central"}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to add the copy: the barcode is required",
+				},
+			},
+		},
+		"The condition is unknown": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","condition":"pristine"}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to add the copy: unknown condition 'pristine'",
+				},
+			},
+		},
+		"The item type is unknown": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","item_type":"vinyl"}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to add the copy: unknown item type 'vinyl'",
+				},
+			},
+		},
+		"The barcode is already in use": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbError: internal.ErrDuplicateBarcode{Barcode: "31234000012345"},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to add the copy to the database: A copy with barcode '31234000012345' already exists",
+				},
+				dbCopy: internal.Copy{Barcode: "31234000012345", BookID: "12345", Condition: internal.ConditionGood, ItemType: internal.ItemBook},
+			},
+		},
+		"Happy path": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345","branch":"Central","location":"FIC MOR","condition":"new","item_type":"dvd"}`,
+				},
+				dbResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", Branch: "Central", Location: "FIC MOR", Condition: internal.ConditionNew, ItemType: internal.ItemDVD, Status: internal.CheckedIn},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: internal.Copy{Barcode: "31234000012345", BookID: "12345", Branch: "Central", Location: "FIC MOR", Condition: internal.ConditionNew, ItemType: internal.ItemDVD, Status: internal.CheckedIn},
+				dbCopy:       internal.Copy{Barcode: "31234000012345", BookID: "12345", Branch: "Central", Location: "FIC MOR", Condition: internal.ConditionNew, ItemType: internal.ItemDVD},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.AddCopyReturns(tc.state.dbResult, tc.state.dbError)
+
+			s := service{
+				db:     db,
+				logger: logrus.New(),
+			}
+
+			result, err := s.AddCopy(context.Background(), tc.state.request)
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := internal.Copy{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the copy passed to the database
+			if db.AddCopyCallCount() > 0 {
+				_, dbCopy := db.AddCopyArgsForCall(0)
+				assert.So(dbCopy, should.Resemble, tc.expected.dbCopy)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_CheckIn(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+
+	type state struct {
+		request        events.APIGatewayProxyRequest
+		dbUpdateResult internal.Copy
+		dbHolds        []internal.Hold
+		dbReserveError error
+		dbLoan         internal.Loan
+		dbLoanError    error
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+		reservedFor  string // The hold the copy was reserved for
+		loanClosed   bool
+		fine         internal.Money
+	}
+	loan := internal.Loan{ID: "loan-1", Barcode: "31234000012345", BookID: "12345", PatronID: "patron-1", CheckedOutAt: now.Add(-20 * 24 * time.Hour), DueAt: now.Add(24 * time.Hour)}
+	returned := loan
+	returned.ReturnedAt = &now
+	late := loan
+	late.DueAt = now.Add(-3 * 24 * time.Hour)
+	returnedLate := late
+	returnedLate.ReturnedAt = &now
+	fine := internal.LedgerEntry{ID: "entry-1", PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 50, LoanID: "loan-1", Barcode: "31234000012345", Note: "Returned 3 days late"}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"Nobody is waiting for the book": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbUpdateResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn},
+				dbLoan:         loan,
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: circulationResponse{Copy: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn}, Loan: &returned},
+				loanClosed:   true,
+			},
+		},
+		"The copy is returned late": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbUpdateResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn},
+				dbLoan:         late,
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: circulationResponse{Copy: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn}, Loan: &returnedLate, Fine: &fine},
+				loanClosed:   true,
+				fine:         50,
+			},
+		},
+		"The copy was checked out before loans were recorded": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbUpdateResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn},
+				dbLoanError:    internal.ErrLoanNotFound{Barcode: "31234000012345"},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: circulationResponse{Copy: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn}},
+			},
+		},
+		"The copy is reserved for the patron at the head of the queue": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbUpdateResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn},
+				dbLoan:         loan,
+				dbHolds: []internal.Hold{
+					{ID: "hold-1", BookID: "12345", PatronID: "patron-1", Status: internal.HoldReady, Barcode: "31234000099999"},
+					{ID: "hold-2", BookID: "12345", PatronID: "patron-2", Status: internal.HoldWaiting},
+					{ID: "hold-3", BookID: "12345", PatronID: "patron-3", Status: internal.HoldWaiting},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: circulationResponse{Copy: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.OnHoldShelf}, Loan: &returned},
+				reservedFor:  "hold-2",
+				loanClosed:   true,
+			},
+		},
+		"db.ReserveCopy returns an unexpected error": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbUpdateResult: internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedIn},
+				dbLoan:         loan,
+				dbHolds: []internal.Hold{
+					{ID: "hold-2", BookID: "12345", PatronID: "patron-2", Status: internal.HoldWaiting},
+				},
+				dbReserveError: errors.New("db.ReserveCopy error"),
+			},
+			expected{
+				responseCode: http.StatusInternalServerError,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to reserve the copy for the next hold: db.ReserveCopy error",
+				},
+				reservedFor: "hold-2",
+				loanClosed:  true,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.GetCopyByBarcodeReturns(internal.Copy{Barcode: "31234000012345", BookID: "12345", Status: internal.CheckedOut}, nil)
+			db.UpdateCopyStatusReturns(tc.state.dbUpdateResult, nil)
+			db.GetHoldsReturns(tc.state.dbHolds, nil)
+			db.ReserveCopyReturns(internal.Hold{}, tc.state.dbReserveError)
+			db.GetOpenLoanReturns(tc.state.dbLoan, tc.state.dbLoanError)
+			db.CloseLoanStub = func(ctx context.Context, loan internal.Loan, returnedAt time.Time) (internal.Loan, error) {
+				loan.ReturnedAt = &returnedAt
+				return loan, nil
+			}
+			db.AddLedgerEntryStub = func(ctx context.Context, entry internal.LedgerEntry) (internal.LedgerEntry, error) {
+				entry.ID = "entry-1"
+				return entry, nil
+			}
+
+			s := NewService(db, WithHoldShelfTime(72*time.Hour), WithPolicy(testPolicy), WithClock(func() time.Time { return now }))
+
+			result, err := s.CheckIn(context.Background(), tc.state.request)
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := circulationResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the loan was closed when the copy came back
+			assert.So(db.CloseLoanCallCount() == 1, should.Equal, tc.expected.loanClosed)
+			if tc.expected.loanClosed {
+				_, closed, returnedAt := db.CloseLoanArgsForCall(0)
+				assert.So(closed, should.Resemble, tc.state.dbLoan)
+				assert.So(returnedAt, should.Equal, now)
+			}
+
+			// Verify the patron was fined for a late return
+			assert.So(db.AddLedgerEntryCallCount() == 1, should.Equal, tc.expected.fine > 0)
+			if tc.expected.fine > 0 {
+				_, entry := db.AddLedgerEntryArgsForCall(0)
+				assert.So(entry.Amount, should.Equal, tc.expected.fine)
+			}
+
+			// Verify the copy was reserved for the right hold, until the end of the hold shelf time
+			assert.So(db.ReserveCopyCallCount() == 1, should.Equal, tc.expected.reservedFor != "")
+			if tc.expected.reservedFor != "" {
+				_, hold, barcode, from, expiresAt := db.ReserveCopyArgsForCall(0)
+				assert.So(hold.ID, should.Equal, tc.expected.reservedFor)
+				assert.So(barcode, should.Equal, "31234000012345")
+				assert.So(from, should.Equal, internal.CheckedIn)
+				assert.So(expiresAt, should.Equal, now.Add(72*time.Hour))
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_PlaceHold(t *testing.T) {
+	type state struct {
+		request  events.APIGatewayProxyRequest
+		dbResult internal.Hold
+		dbError  error
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The request doesn't have a patron": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to place the hold: the patron_id is required",
+				},
+			},
+		},
+		"db.PlaceHold returns a BookNotFound error": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"patron_id":"patron-1"}`,
+				},
+				dbError: internal.ErrBookNotFound{BookID: "12345"},
+			},
+			expected{
+				responseCode: http.StatusNotFound,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to place the hold in the database: The book with ID '12345' was not found",
+				},
+			},
+		},
+		"The patron already has a hold on the book": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"patron_id":"patron-1"}`,
+				},
+				dbError: internal.ErrDuplicateHold{BookID: "12345", PatronID: "patron-1"},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to place the hold in the database: The patron 'patron-1' already has a hold on the book with ID '12345'",
+				},
+			},
+		},
+		"Happy path": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"patron_id":"patron-1"}`,
+				},
+				dbResult: internal.Hold{ID: "hold-1", BookID: "12345", PatronID: "patron-1", Status: internal.HoldWaiting},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: internal.Hold{ID: "hold-1", BookID: "12345", PatronID: "patron-1", Status: internal.HoldWaiting},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.PlaceHoldReturns(tc.state.dbResult, tc.state.dbError)
+
+			s := service{
+				db:     db,
+				logger: logrus.New(),
+			}
+
+			result, err := s.PlaceHold(context.Background(), tc.state.request)
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := internal.Hold{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_CancelHold(t *testing.T) {
+	type state struct {
+		request events.APIGatewayProxyRequest
+		dbHolds []internal.Hold
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+		reservedFor  string              // The next hold the copy was reserved for
+		released     internal.BookStatus // The status a released copy was put back into circulation from
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The hold isn't in the book's queue": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345", "hold_id": "hold-9"},
+				},
+				dbHolds: []internal.Hold{
+					{ID: "hold-1", BookID: "12345", PatronID: "patron-1", Status: internal.HoldWaiting},
+				},
+			},
+			expected{
+				responseCode: http.StatusNotFound,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to cancel the hold: The hold with ID 'hold-9' was not found",
+				},
+			},
+		},
+		"A waiting hold is cancelled": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345", "hold_id": "hold-1"},
+				},
+				dbHolds: []internal.Hold{
+					{ID: "hold-1", BookID: "12345", PatronID: "patron-1", Status: internal.HoldWaiting},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+			},
+		},
+		"The copy on the hold shelf passes to the next patron": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345", "hold_id": "hold-1"},
+				},
+				dbHolds: []internal.Hold{
+					{ID: "hold-1", BookID: "12345", PatronID: "patron-1", Status: internal.HoldReady, Barcode: "31234000012345"},
+					{ID: "hold-2", BookID: "12345", PatronID: "patron-2", Status: internal.HoldWaiting},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				reservedFor:  "hold-2",
+			},
+		},
+		"The copy on the hold shelf goes back into circulation": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345", "hold_id": "hold-1"},
+				},
+				dbHolds: []internal.Hold{
+					{ID: "hold-1", BookID: "12345", PatronID: "patron-1", Status: internal.HoldReady, Barcode: "31234000012345"},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				released:     internal.OnHoldShelf,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.GetHoldsReturnsOnCall(0, tc.state.dbHolds, nil)
+			db.GetHoldsReturnsOnCall(1, tc.state.dbHolds[1:], nil) // The cancelled hold has left the queue
+
+			s := service{
+				db:     db,
+				logger: logrus.New(),
+			}
+
+			result, err := s.CancelHold(context.Background(), tc.state.request)
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode != http.StatusOK {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify what happened to the copy that was on the hold shelf
+			assert.So(db.ReserveCopyCallCount() == 1, should.Equal, tc.expected.reservedFor != "")
+			if tc.expected.reservedFor != "" {
+				_, hold, barcode, from, _ := db.ReserveCopyArgsForCall(0)
+				assert.So(hold.ID, should.Equal, tc.expected.reservedFor)
+				assert.So(barcode, should.Equal, "31234000012345")
+				assert.So(from, should.Equal, internal.OnHoldShelf)
+			}
+			assert.So(db.UpdateCopyStatusCallCount() == 1, should.Equal, tc.expected.released != "")
+			if tc.expected.released != "" {
+				_, barcode, from, to := db.UpdateCopyStatusArgsForCall(0)
+				assert.So(barcode, should.Equal, "31234000012345")
+				assert.So(from, should.Equal, tc.expected.released)
+				assert.So(to, should.Equal, internal.CheckedIn)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_RenewLoan(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+	loan := internal.Loan{ID: "loan-1", Barcode: "31234000012345", BookID: "12345", PatronID: "patron-1", CheckedOutAt: now.Add(-10 * 24 * time.Hour), DueAt: now.Add(4 * 24 * time.Hour)}
+	renewed := loan
+	renewed.DueAt, renewed.Renewals = now.Add(14*24*time.Hour), 1
+
+	type state struct {
+		request      events.APIGatewayProxyRequest
+		dbLoan       internal.Loan
+		dbGetError   error
+		dbHolds      []internal.Hold
+		dbRenewError error
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The request doesn't have a barcode": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to decode the request body: the barcode is required",
+				},
+			},
+		},
+		"The copy isn't on loan": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbGetError: internal.ErrLoanNotFound{Barcode: "31234000012345"},
+			},
+			expected{
+				responseCode: http.StatusNotFound,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to retrieve the loan from the database: The copy with barcode '31234000012345' isn't on loan",
+				},
+			},
+		},
+		"The policy doesn't allow the loan to be renewed": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbLoan: internal.Loan{ID: "loan-2", Barcode: "31234000012345", BookID: "12345", PatronID: "patron-1", PatronType: internal.PatronStudent, ItemType: internal.ItemDVD},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to renew the loan: the loan can't be renewed more than 0 times",
+				},
+			},
+		},
+		"The loan has been renewed the maximum number of times": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbLoan: renewed,
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to renew the loan: the loan can't be renewed more than 1 times",
+				},
+			},
+		},
+		"Another patron is holding the book": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbLoan:  loan,
+				dbHolds: []internal.Hold{{ID: "hold-1", BookID: "12345", PatronID: "patron-2", Status: internal.HoldWaiting}},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to renew the loan: other patrons are holding the book",
+				},
+			},
+		},
+		"The loan changed in the meantime": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbLoan:       loan,
+				dbRenewError: internal.ErrLoanChanged{LoanID: "loan-1"},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to renew the loan in the database: The loan with ID 'loan-1' was changed by another request",
+				},
+			},
+		},
+		"Happy path": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"book_id": "12345"},
+					Body:           `{"barcode":"31234000012345"}`,
+				},
+				dbLoan: loan,
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: renewed,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.GetOpenLoanReturns(tc.state.dbLoan, tc.state.dbGetError)
+			db.GetHoldsReturns(tc.state.dbHolds, nil)
+			db.RenewLoanReturns(renewed, tc.state.dbRenewError)
+
+			s := NewService(db, WithPolicy(testPolicy), WithClock(func() time.Time { return now }))
+
+			result, err := s.RenewLoan(context.Background(), tc.state.request)
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := internal.Loan{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+
+				// Verify the loan is due a full loan period from now
+				_, dbLoan, dueAt := db.RenewLoanArgsForCall(0)
+				assert.So(dbLoan, should.Resemble, loan)
+				assert.So(dueAt, should.Equal, now.Add(14*24*time.Hour))
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_ExpireHolds(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+	expiredHold := internal.Hold{ID: "hold-1", BookID: "12345", PatronID: "patron-1", Barcode: "31234000012345", Status: internal.HoldReady}
+	closedHold := internal.Hold{ID: "hold-2", BookID: "12345", PatronID: "patron-2", Barcode: "31234000012346", Status: internal.HoldReady}
+
+	type state struct {
+		closeError error // What closing the second hold returns
+	}
+	type expected struct {
+		expired int
+		err     error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"Every expired hold is closed": {
+			state{},
+			expected{expired: 2},
+		},
+		"A hold that was closed in the meantime isn't counted": {
+			state{closeError: internal.ErrHoldNotFound{HoldID: "hold-2"}},
+			expected{expired: 1},
+		},
+		"db.CloseHold returns an unexpected error": {
+			state{closeError: errors.New("db.CloseHold error")},
+			expected{err: errors.New("db.CloseHold error")},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			logger, hook := test.NewNullLogger()
+
+			db := &mocks.MockBooksDB{}
+			db.GetExpiredHoldsReturns([]internal.Hold{expiredHold, closedHold}, nil)
+			db.CloseHoldReturnsOnCall(1, tc.state.closeError)
+
+			s := NewService(db, WithLogger(logger), WithClock(func() time.Time { return now }))
+
+			err := s.ExpireHolds(context.Background(), events.CloudWatchEvent{ID: "event-1"})
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			assert.So(db.CloseHoldCallCount(), should.Equal, 2)
+			if tc.expected.err == nil {
+				assert.So(hook.LastEntry().Data["expired"], should.Equal, tc.expected.expired)
+			}
+		})
+	}
+}
+
+func Test_service_GetOverdueLoans(t *testing.T) {
+	now := time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+
+	type state struct {
+		dbResult []internal.Loan
+		dbError  error
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"db.GetOverdueLoans returns an unexpected error": {
+			state{
+				dbError: errors.New("db.GetOverdueLoans error"),
+			},
+			expected{
+				responseCode: http.StatusInternalServerError,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to retrieve the overdue loans from the database: db.GetOverdueLoans error",
+				},
+			},
+		},
+		"Happy path": {
+			state{
+				dbResult: []internal.Loan{
+					{ID: "loan-1", Barcode: "31234000012345", BookID: "12345", PatronID: "patron-1", DueAt: now.Add(-72 * time.Hour)},
+					{ID: "loan-2", Barcode: "31234000067890", BookID: "67890", PatronID: "patron-2", DueAt: now.Add(-time.Hour)},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: []overdueLoan{
+					{Loan: internal.Loan{ID: "loan-1", Barcode: "31234000012345", BookID: "12345", PatronID: "patron-1", DueAt: now.Add(-72 * time.Hour)}, DaysOverdue: 3},
+					{Loan: internal.Loan{ID: "loan-2", Barcode: "31234000067890", BookID: "67890", PatronID: "patron-2", DueAt: now.Add(-time.Hour)}, DaysOverdue: 1},
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.GetOverdueLoansReturns(tc.state.dbResult, tc.state.dbError)
+
+			s := NewService(db, WithClock(func() time.Time { return now }))
+
+			result, err := s.GetOverdueLoans(context.Background(), events.APIGatewayProxyRequest{})
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := []overdueLoan{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+
+				_, dbNow := db.GetOverdueLoansArgsForCall(0)
+				assert.So(dbNow, should.Equal, now)
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_GetAccount(t *testing.T) {
+	type state struct {
+		dbResult []internal.LedgerEntry
+		dbError  error
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"db.GetLedger returns an unexpected error": {
+			state{
+				dbError: errors.New("db.GetLedger error"),
+			},
+			expected{
+				responseCode: http.StatusInternalServerError,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to retrieve the patron's account from the database: db.GetLedger error",
+				},
+			},
+		},
+		"Happy path": {
+			state{
+				dbResult: []internal.LedgerEntry{
+					{ID: "entry-1", PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 150},
+					{ID: "entry-2", PatronID: "patron-1", Type: internal.LedgerPayment, Amount: 100},
+					{ID: "entry-3", PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 75},
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: internal.Account{
+					PatronID: "patron-1",
+					Balance:  125,
+					Entries: []internal.LedgerEntry{
+						{ID: "entry-1", PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 150},
+						{ID: "entry-2", PatronID: "patron-1", Type: internal.LedgerPayment, Amount: 100},
+						{ID: "entry-3", PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 75},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.GetLedgerReturns(tc.state.dbResult, tc.state.dbError)
+
+			s := service{
+				db:     db,
+				logger: logrus.New(),
+			}
+
+			result, err := s.GetAccount(context.Background(), events.APIGatewayProxyRequest{
+				PathParameters: map[string]string{"patron_id": "patron-1"},
+			})
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := internal.Account{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_AddPayment(t *testing.T) {
+	ledger := []internal.LedgerEntry{{ID: "entry-1", PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 150}}
+	adminRequest := events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"patron_id": "patron-1"},
+		Body:           `{"type":"waiver","amount":150,"note":"First offence"}`,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "librarian-1", "cognito:groups": "admin"}},
+		},
+	}
+
+	type state struct {
+		request events.APIGatewayProxyRequest
+	}
+	type expected struct {
+		responseCode int
+		responseBody interface{}
+		err          error
+		dbEntry      *internal.LedgerEntry // The entry passed to the database
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The amount isn't positive": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"patron_id": "patron-1"},
+					Body:           `{"amount":0}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to record the payment: the amount must be more than zero",
+				},
+			},
+		},
+		"The amount isn't in minor units": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"patron_id": "patron-1"},
+					Body:           `{"amount":1.50}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusBadRequest,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to decode the request body into a payment: json: cannot unmarshal number 1.50 into Go struct field LedgerEntry.amount of type internal.Money",
+				},
+			},
+		},
+		"Only administrators may waive fines": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"patron_id": "patron-1"},
+					Body:           `{"type":"waiver","amount":150}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusForbidden,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to record the payment: only administrators may waive fines",
+				},
+			},
+		},
+		"The payment is more than the patron owes": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"patron_id": "patron-1"},
+					Body:           `{"amount":200}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusConflict,
+				responseBody: errorResponse{
+					ErrorMessage: "failed to record the payment: the patron only owes 1.50",
+				},
+			},
+		},
+		"A patron pays part of their balance": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					PathParameters: map[string]string{"patron_id": "patron-1"},
+					Body:           `{"amount":100}`,
+				},
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: internal.Account{
+					PatronID: "patron-1",
+					Balance:  50,
+					Entries:  append(ledger, internal.LedgerEntry{ID: "entry-2", PatronID: "patron-1", Type: internal.LedgerPayment, Amount: 100}),
+				},
+				dbEntry: &internal.LedgerEntry{PatronID: "patron-1", Type: internal.LedgerPayment, Amount: 100},
+			},
+		},
+		"An administrator waives a fine": {
+			state{
+				request: adminRequest,
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: internal.Account{
+					PatronID: "patron-1",
+					Balance:  0,
+					Entries:  append(ledger, internal.LedgerEntry{ID: "entry-2", PatronID: "patron-1", Type: internal.LedgerWaiver, Amount: 150, Note: "First offence"}),
+				},
+				dbEntry: &internal.LedgerEntry{PatronID: "patron-1", Type: internal.LedgerWaiver, Amount: 150, Note: "First offence"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockBooksDB{}
+			db.GetLedgerReturns(ledger, nil)
+			db.AddLedgerEntryStub = func(ctx context.Context, entry internal.LedgerEntry) (internal.LedgerEntry, error) {
+				entry.ID = "entry-2"
+				return entry, nil
+			}
+
+			s := service{
+				db:     db,
+				logger: logrus.New(),
+			}
+
+			result, err := s.AddPayment(context.Background(), tc.state.request)
+
+			// Verify the response code
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			// Verify the response body
+			if tc.expected.responseCode == http.StatusOK {
+				resp := internal.Account{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			} else {
+				resp := errorResponse{}
+				jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+				assert.So(jsonErr, should.BeNil)
+				assert.So(resp, should.Resemble, tc.expected.responseBody)
+			}
+
+			// Verify the entry passed to the database
+			assert.So(db.AddLedgerEntryCallCount() == 1, should.Equal, tc.expected.dbEntry != nil)
+			if tc.expected.dbEntry != nil {
+				_, entry := db.AddLedgerEntryArgsForCall(0)
+				assert.So(entry, should.Resemble, *tc.expected.dbEntry)
+			}
+
+			// Verify the error
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func Test_service_GetPolicies(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := NewService(&mocks.MockBooksDB{}, WithPolicy(testPolicy))
+
+	result, err := s.GetPolicies(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.So(err, should.BeNil)
+	assert.So(result.StatusCode, should.Equal, http.StatusOK)
+
+	resp := policy.Policy{}
+	jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+	assert.So(jsonErr, should.BeNil)
+	assert.So(resp, should.Resemble, testPolicy)
+}
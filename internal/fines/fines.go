@@ -0,0 +1,34 @@
+package fines
+
+import (
+	"time"
+
+	"github.com/aaron-zeisler/library-api/internal"
+)
+
+// Rate configures how a late item is fined. Nothing is charged for the first GraceDays days overdue, then
+// PerDay for every day after them, up to Cap. A zero Cap means the fine isn't capped.
+type Rate struct {
+	PerDay    internal.Money `json:"per_day" yaml:"per_day"`
+	GraceDays int            `json:"grace_days" yaml:"grace_days"`
+	Cap       internal.Money `json:"cap" yaml:"cap"`
+}
+
+// Fine is the fine for an item returned the given number of days late
+func (r Rate) Fine(daysOverdue int) internal.Money {
+	days := daysOverdue - r.GraceDays
+	if days <= 0 || r.PerDay <= 0 {
+		return 0
+	}
+
+	result := r.PerDay * internal.Money(days)
+	if r.Cap > 0 && result > r.Cap {
+		return r.Cap
+	}
+	return result
+}
+
+// LoanFine is the fine for a loan returned at returnedAt
+func (r Rate) LoanFine(loan internal.Loan, returnedAt time.Time) internal.Money {
+	return r.Fine(loan.DaysOverdue(returnedAt))
+}
@@ -0,0 +1,63 @@
+package fines
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal"
+)
+
+func TestRate_LoanFine(t *testing.T) {
+	due := time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+	loan := internal.Loan{ID: "loan-1", Barcode: "31234000012345", DueAt: due}
+
+	type state struct {
+		rate       Rate
+		returnedAt time.Time
+	}
+	type expected struct {
+		fine internal.Money
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The item is returned on time": {
+			state{rate: Rate{PerDay: 25, GraceDays: 1, Cap: 1000}, returnedAt: due},
+			expected{fine: 0},
+		},
+		"The item is returned within the grace period": {
+			state{rate: Rate{PerDay: 25, GraceDays: 1, Cap: 1000}, returnedAt: due.Add(20 * time.Hour)},
+			expected{fine: 0},
+		},
+		"Part of a day after the grace period counts as a day": {
+			state{rate: Rate{PerDay: 25, GraceDays: 1, Cap: 1000}, returnedAt: due.Add(25 * time.Hour)},
+			expected{fine: 25},
+		},
+		"The fine is capped": {
+			state{rate: Rate{PerDay: 25, GraceDays: 1, Cap: 1000}, returnedAt: due.Add(90 * 24 * time.Hour)},
+			expected{fine: 1000},
+		},
+		"The fine isn't capped": {
+			state{rate: Rate{PerDay: 100}, returnedAt: due.Add(90 * 24 * time.Hour)},
+			expected{fine: 9000},
+		},
+		"The item type isn't fined": {
+			state{rate: Rate{}, returnedAt: due.Add(90 * 24 * time.Hour)},
+			expected{fine: 0},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			result := tc.state.rate.LoanFine(loan, tc.state.returnedAt)
+
+			assert.So(result, should.Equal, tc.expected.fine)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mocks
+
+import (
+	"context"
+	"sync"
+)
+
+type MockProber struct {
+	ProbeStub        func(context.Context) error
+	probeMutex       sync.RWMutex
+	probeArgsForCall []struct {
+		arg1 context.Context
+	}
+	probeReturns struct {
+		result1 error
+	}
+	probeReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *MockProber) Probe(arg1 context.Context) error {
+	fake.probeMutex.Lock()
+	ret, specificReturn := fake.probeReturnsOnCall[len(fake.probeArgsForCall)]
+	fake.probeArgsForCall = append(fake.probeArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	stub := fake.ProbeStub
+	fakeReturns := fake.probeReturns
+	fake.recordInvocation("Probe", []interface{}{arg1})
+	fake.probeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *MockProber) ProbeCallCount() int {
+	fake.probeMutex.RLock()
+	defer fake.probeMutex.RUnlock()
+	return len(fake.probeArgsForCall)
+}
+
+func (fake *MockProber) ProbeCalls(stub func(context.Context) error) {
+	fake.probeMutex.Lock()
+	defer fake.probeMutex.Unlock()
+	fake.ProbeStub = stub
+}
+
+func (fake *MockProber) ProbeArgsForCall(i int) context.Context {
+	fake.probeMutex.RLock()
+	defer fake.probeMutex.RUnlock()
+	argsForCall := fake.probeArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *MockProber) ProbeReturns(result1 error) {
+	fake.probeMutex.Lock()
+	defer fake.probeMutex.Unlock()
+	fake.ProbeStub = nil
+	fake.probeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *MockProber) ProbeReturnsOnCall(i int, result1 error) {
+	fake.probeMutex.Lock()
+	defer fake.probeMutex.Unlock()
+	fake.ProbeStub = nil
+	if fake.probeReturnsOnCall == nil {
+		fake.probeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.probeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *MockProber) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.probeMutex.RLock()
+	defer fake.probeMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *MockProber) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aaron-zeisler/library-api/internal/logging"
+	"github.com/aaron-zeisler/library-api/internal/version"
+)
+
+type service struct {
+	db      prober
+	timeout time.Duration
+	logger  *logrus.Logger
+}
+
+// prober is implemented by every booksDB implementation. Probe must be cheap: it's called by load
+// balancers and monitoring, so it shouldn't read more than a single item. Only Ready probes, so the
+// other endpoints' services are made without one.
+type prober interface {
+	Probe(ctx context.Context) error
+}
+
+func NewService(db prober, opts ...ServiceOption) service {
+	s := service{
+		db:      db,
+		timeout: 2 * time.Second,
+		logger:  logrus.New(),
+	}
+
+	for _, opt := range opts {
+		s = opt(s)
+	}
+
+	return s
+}
+
+type ServiceOption func(s service) service
+
+func WithTimeout(timeout time.Duration) ServiceOption {
+	return func(s service) service {
+		s.timeout = timeout
+		return s
+	}
+}
+
+func WithLogger(logger *logrus.Logger) ServiceOption {
+	return func(s service) service {
+		s.logger = logger
+		return s
+	}
+}
+
+// status is the body of every health response. The checks' errors are only logged, since the endpoints
+// aren't authenticated and the errors name the storage's internals.
+type status struct {
+	Status string `json:"status"`
+	Check  string `json:"check,omitempty"` // What the readiness check probed
+}
+
+// storageCheck is the name of the readiness check that probes the storage
+const storageCheck = "storage"
+
+// Health reports that the function is alive. It doesn't touch the storage.
+func (s service) Health(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return jsonResponse(http.StatusOK, status{Status: "ok"})
+}
+
+// Ready reports whether the storage can be reached within the service's timeout
+func (s service) Ready(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := s.db.Probe(ctx)
+	if err != nil {
+		logging.FromContextOr(ctx, s.logger).WithError(err).WithField("check", storageCheck).Error("the storage probe failed")
+		return jsonResponse(http.StatusServiceUnavailable, status{Status: "unavailable", Check: storageCheck})
+	}
+
+	return jsonResponse(http.StatusOK, status{Status: "ok", Check: storageCheck})
+}
+
+// Version reports the build information injected when the function was compiled
+func (s service) Version(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return jsonResponse(http.StatusOK, version.Get())
+}
+
+func jsonResponse(statusCode int, body interface{}) (events.APIGatewayProxyResponse, error) {
+	responseBody, err := json.Marshal(body)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       fmt.Sprintf(`{"error":"failed to encode the response: %s"}`, err.Error()),
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Body:       string(responseBody),
+	}, nil
+}
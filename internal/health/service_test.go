@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/health/mocks"
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+	"github.com/aaron-zeisler/library-api/internal/version"
+)
+
+func Test_service_Health(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := NewService(nil)
+
+	result, err := s.Health(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.So(err, should.BeNil)
+	assert.So(result.StatusCode, should.Equal, http.StatusOK)
+	assert.So(result.Body, should.Equal, `{"status":"ok"}`)
+}
+
+func Test_service_Ready(t *testing.T) {
+	type state struct {
+		probe func(ctx context.Context) error
+	}
+	type expected struct {
+		responseCode int
+		responseBody status
+		loggedError  string
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The storage is reachable": {
+			state{
+				probe: func(ctx context.Context) error { return nil },
+			},
+			expected{
+				responseCode: http.StatusOK,
+				responseBody: status{Status: "ok", Check: "storage"},
+			},
+		},
+		"The probe returns an error": {
+			state{
+				probe: func(ctx context.Context) error { return errors.New("the books table is CREATING") },
+			},
+			expected{
+				responseCode: http.StatusServiceUnavailable,
+				responseBody: status{Status: "unavailable", Check: "storage"},
+				loggedError:  "the books table is CREATING",
+			},
+		},
+		"The probe takes longer than the timeout": {
+			state{
+				probe: func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+			expected{
+				responseCode: http.StatusServiceUnavailable,
+				responseBody: status{Status: "unavailable", Check: "storage"},
+				loggedError:  "context deadline exceeded",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			db := &mocks.MockProber{}
+			db.ProbeStub = tc.state.probe
+
+			logger, hook := test.NewNullLogger()
+			s := service{
+				db:      db,
+				timeout: 10 * time.Millisecond,
+				logger:  logger,
+			}
+
+			result, err := s.Ready(context.Background(), events.APIGatewayProxyRequest{})
+
+			assert.So(err, should.BeNil)
+			assert.So(result.StatusCode, should.Equal, tc.expected.responseCode)
+
+			resp := status{}
+			jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+			assert.So(jsonErr, should.BeNil)
+			assert.So(resp, should.Resemble, tc.expected.responseBody)
+
+			// The probe's error is only logged
+			if tc.expected.loggedError == "" {
+				assert.So(hook.AllEntries(), should.BeEmpty)
+			} else {
+				assert.So(hook.LastEntry().Data[logrus.ErrorKey], testutils.ShouldEqualError, errors.New(tc.expected.loggedError))
+				assert.So(result.Body, should.NotContainSubstring, tc.expected.loggedError)
+			}
+		})
+	}
+}
+
+func Test_service_Version(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := NewService(nil)
+
+	result, err := s.Version(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.So(err, should.BeNil)
+	assert.So(result.StatusCode, should.Equal, http.StatusOK)
+
+	resp := version.Info{}
+	jsonErr := json.Unmarshal([]byte(result.Body), &resp)
+	assert.So(jsonErr, should.BeNil)
+	assert.So(resp, should.Resemble, version.Get())
+}
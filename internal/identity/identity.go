@@ -0,0 +1,102 @@
+package identity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Kind describes how a client was identified
+type Kind string
+
+const (
+	APIKey   Kind = "api_key"
+	Subject  Kind = "sub"
+	SourceIP Kind = "ip"
+)
+
+// Identity is the best available identifier for the client that sent a request
+type Identity struct {
+	Kind  Kind
+	Value string
+}
+
+func (i Identity) String() string {
+	return fmt.Sprintf("%s:%s", i.Kind, i.Value)
+}
+
+// FromRequest identifies the client that sent the request. Only values that API Gateway has already
+// verified are used: the ID of the API key it validated, the subject of a JWT accepted by an authorizer,
+// and finally the source IP address. The identity ends up in logs, rate limit buckets and the audit log,
+// so the API key itself, which is a credential, is never used.
+func FromRequest(request events.APIGatewayProxyRequest) Identity {
+	if apiKeyID := request.RequestContext.Identity.APIKeyID; apiKeyID != "" {
+		return Identity{Kind: APIKey, Value: apiKeyID}
+	}
+
+	if subject := subjectFromAuthorizer(request.RequestContext.Authorizer); subject != "" {
+		return Identity{Kind: Subject, Value: subject}
+	}
+
+	return Identity{Kind: SourceIP, Value: request.RequestContext.Identity.SourceIP}
+}
+
+func subjectFromAuthorizer(authorizer map[string]interface{}) string {
+	// JWT and Cognito authorizers put the token's claims under "claims"
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		if sub, ok := claims["sub"].(string); ok {
+			return sub
+		}
+	}
+
+	// Lambda authorizers return the subject as the principal ID
+	if principalID, ok := authorizer["principalId"].(string); ok {
+		return principalID
+	}
+
+	return ""
+}
+
+const (
+	// AdminGroup is the group, or authorizer role, whose members may perform administrative actions
+	AdminGroup = "admin"
+	// LibrarianGroup is the group, or authorizer role, of the staff at the circulation desk
+	LibrarianGroup = "librarian"
+)
+
+// IsAdmin reports whether the authorizer placed the client in the admin group
+func IsAdmin(request events.APIGatewayProxyRequest) bool {
+	return InGroup(request, AdminGroup)
+}
+
+// InGroup reports whether the authorizer placed the client in the group
+func InGroup(request events.APIGatewayProxyRequest, group string) bool {
+	for _, g := range Groups(request) {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Groups returns the groups the authorizer placed the client in: Cognito lists them in the "cognito:groups"
+// claim and Lambda authorizers return one in the "role" context value.
+func Groups(request events.APIGatewayProxyRequest) []string {
+	authorizer := request.RequestContext.Authorizer
+
+	var result []string
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok && claims["cognito:groups"] != nil {
+		result = strings.FieldsFunc(fmt.Sprint(claims["cognito:groups"]), isGroupSeparator)
+	}
+	if role, _ := authorizer["role"].(string); role != "" {
+		result = append(result, role)
+	}
+	return result
+}
+
+// isGroupSeparator splits the groups claim, which API Gateway passes either as a comma separated list or
+// as the string form of an array, e.g. "[admin staff]"
+func isGroupSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '[' || r == ']'
+}
@@ -0,0 +1,184 @@
+package identity
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+)
+
+func TestFromRequest(t *testing.T) {
+	type state struct {
+		request events.APIGatewayProxyRequest
+	}
+	type expected struct {
+		result Identity
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The API key takes precedence": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					RequestContext: events.APIGatewayProxyRequestContext{
+						Identity:   events.APIGatewayRequestIdentity{APIKey: "kiosk-key", APIKeyID: "a1b2c3", SourceIP: "10.0.0.1"},
+						Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "patron-1"}},
+					},
+				},
+			},
+			expected{
+				result: Identity{Kind: APIKey, Value: "a1b2c3"},
+			},
+		},
+		"The API key itself is never used": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					RequestContext: events.APIGatewayProxyRequestContext{
+						Identity: events.APIGatewayRequestIdentity{APIKey: "kiosk-key", SourceIP: "10.0.0.1"},
+					},
+				},
+			},
+			expected{
+				result: Identity{Kind: SourceIP, Value: "10.0.0.1"},
+			},
+		},
+		"The JWT subject is used when there's no API key": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					RequestContext: events.APIGatewayProxyRequestContext{
+						Identity:   events.APIGatewayRequestIdentity{SourceIP: "10.0.0.1"},
+						Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "patron-1"}},
+					},
+				},
+			},
+			expected{
+				result: Identity{Kind: Subject, Value: "patron-1"},
+			},
+		},
+		"A lambda authorizer's principal ID is used as the subject": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					RequestContext: events.APIGatewayProxyRequestContext{
+						Identity:   events.APIGatewayRequestIdentity{SourceIP: "10.0.0.1"},
+						Authorizer: map[string]interface{}{"principalId": "patron-2"},
+					},
+				},
+			},
+			expected{
+				result: Identity{Kind: Subject, Value: "patron-2"},
+			},
+		},
+		"The source IP is the fallback": {
+			state{
+				request: events.APIGatewayProxyRequest{
+					RequestContext: events.APIGatewayProxyRequestContext{
+						Identity: events.APIGatewayRequestIdentity{SourceIP: "10.0.0.1"},
+					},
+				},
+			},
+			expected{
+				result: Identity{Kind: SourceIP, Value: "10.0.0.1"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			result := FromRequest(tc.state.request)
+
+			assert.So(result, should.Resemble, tc.expected.result)
+		})
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	type state struct {
+		authorizer map[string]interface{}
+	}
+	type expected struct {
+		result bool
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"A Cognito user in the admin group": {
+			state{authorizer: map[string]interface{}{"claims": map[string]interface{}{"cognito:groups": "staff,admin"}}},
+			expected{result: true},
+		},
+		"A Cognito user whose groups are passed as an array": {
+			state{authorizer: map[string]interface{}{"claims": map[string]interface{}{"cognito:groups": "[staff admin]"}}},
+			expected{result: true},
+		},
+		"A Cognito user outside the admin group": {
+			state{authorizer: map[string]interface{}{"claims": map[string]interface{}{"cognito:groups": "staff,administrators"}}},
+			expected{result: false},
+		},
+		"A lambda authorizer's admin role": {
+			state{authorizer: map[string]interface{}{"principalId": "librarian-1", "role": "admin"}},
+			expected{result: true},
+		},
+		"An anonymous client": {
+			state{},
+			expected{result: false},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{
+				RequestContext: events.APIGatewayProxyRequestContext{Authorizer: tc.state.authorizer},
+			}
+
+			result := IsAdmin(request)
+
+			assertions.New(t).So(result, should.Equal, tc.expected.result)
+		})
+	}
+}
+
+func TestGroups(t *testing.T) {
+	type state struct {
+		authorizer map[string]interface{}
+	}
+	type expected struct {
+		result []string
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"A Cognito user's groups": {
+			state{authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "patron-1", "cognito:groups": "staff,admin"}}},
+			expected{result: []string{"staff", "admin"}},
+		},
+		"A Cognito user in no groups": {
+			state{authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "patron-1"}}},
+			expected{result: nil},
+		},
+		"A lambda authorizer's role": {
+			state{authorizer: map[string]interface{}{"principalId": "patron-1", "role": "student"}},
+			expected{result: []string{"student"}},
+		},
+		"An anonymous client": {
+			state{},
+			expected{result: nil},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{
+				RequestContext: events.APIGatewayProxyRequestContext{Authorizer: tc.state.authorizer},
+			}
+
+			result := Groups(request)
+
+			assertions.New(t).So(result, should.Resemble, tc.expected.result)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx that carries the logger. Handlers and storage implementations
+// retrieve it with FromContext so that every line they log is tagged with the request's fields.
+func NewContext(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx by NewContext, or false if there isn't one
+func FromContext(ctx context.Context) (*logrus.Entry, bool) {
+	logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry)
+	return logger, ok
+}
+
+// FromContextOr returns the logger stored in ctx, falling back to the given logger if there isn't one
+func FromContextOr(ctx context.Context, fallback *logrus.Logger) *logrus.Entry {
+	if logger, ok := FromContext(ctx); ok {
+		return logger
+	}
+	return logrus.NewEntry(fallback)
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+type Unit string
+
+const (
+	Count        Unit = "Count"
+	Milliseconds Unit = "Milliseconds"
+	None         Unit = "None"
+)
+
+// Datum is a single measurement
+type Datum struct {
+	Name       string
+	Unit       Unit
+	Value      float64
+	Dimensions map[string]string
+	Timestamp  time.Time
+}
+
+// Sink receives measurements. Implementations must be safe for concurrent use.
+type Sink interface {
+	Emit(data ...Datum)
+}
+
+// Counter returns a datum that counts one occurrence of an event
+func Counter(name string, dimensions map[string]string) Datum {
+	return Datum{Name: name, Unit: Count, Value: 1, Dimensions: dimensions, Timestamp: time.Now()}
+}
+
+// Duration returns a datum for the time elapsed since start
+func Duration(name string, start time.Time, dimensions map[string]string) Datum {
+	now := time.Now()
+	return Datum{Name: name, Unit: Milliseconds, Value: float64(now.Sub(start).Microseconds()) / 1000, Dimensions: dimensions, Timestamp: now}
+}
+
+// Value returns a datum for an arbitrary measurement
+func Value(name string, unit Unit, value float64, dimensions map[string]string) Datum {
+	return Datum{Name: name, Unit: unit, Value: value, Dimensions: dimensions, Timestamp: time.Now()}
+}
+
+type noopSink struct{}
+
+// NewNoopSink returns a sink that discards everything, for code that isn't configured with a real sink
+func NewNoopSink() Sink {
+	return noopSink{}
+}
+
+func (noopSink) Emit(data ...Datum) {}
+
+type multiSink []Sink
+
+// NewMultiSink returns a sink that forwards every datum to each of the given sinks
+func NewMultiSink(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+func (m multiSink) Emit(data ...Datum) {
+	for _, sink := range m {
+		sink.Emit(data...)
+	}
+}
+
+// dimensionKeys returns the datum's dimension names in a stable order
+func dimensionKeys(dimensions map[string]string) []string {
+	keys := make([]string, 0, len(dimensions))
+	for k := range dimensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// seriesKey identifies a metric and its dimension values, e.g. "RequestCount|Route=/books"
+func seriesKey(name string, dimensions map[string]string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range dimensionKeys(dimensions) {
+		b.WriteString("|")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(dimensions[k])
+	}
+	return b.String()
+}
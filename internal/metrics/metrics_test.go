@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+)
+
+func Test_emfSink_Emit(t *testing.T) {
+	timestamp := time.Date(2021, 2, 14, 12, 0, 0, 0, time.UTC)
+
+	type state struct {
+		data []Datum
+	}
+	type expected struct {
+		lines []string
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"Data with the same dimensions share a document": {
+			state{
+				data: []Datum{
+					{Name: "RequestCount", Unit: Count, Value: 1, Dimensions: map[string]string{"Route": "GET /books"}, Timestamp: timestamp},
+					{Name: "RequestLatency", Unit: Milliseconds, Value: 12.5, Dimensions: map[string]string{"Route": "GET /books"}, Timestamp: timestamp},
+				},
+			},
+			expected{
+				lines: []string{
+					`{"RequestCount":1,"RequestLatency":12.5,"Route":"GET /books","_aws":{"Timestamp":1613304000000,"CloudWatchMetrics":[{"Namespace":"LibraryAPI","Dimensions":[["Route"]],"Metrics":[{"Name":"RequestCount","Unit":"Count"},{"Name":"RequestLatency","Unit":"Milliseconds"}]}]}}`,
+				},
+			},
+		},
+		"Data with different dimensions are written on separate lines": {
+			state{
+				data: []Datum{
+					{Name: "RequestCount", Unit: Count, Value: 1, Dimensions: map[string]string{"Route": "GET /books"}, Timestamp: timestamp},
+					{Name: "Errors", Unit: Count, Value: 1, Dimensions: map[string]string{"Route": "GET /books", "StatusClass": "5xx"}, Timestamp: timestamp},
+				},
+			},
+			expected{
+				lines: []string{
+					`{"RequestCount":1,"Route":"GET /books","_aws":{"Timestamp":1613304000000,"CloudWatchMetrics":[{"Namespace":"LibraryAPI","Dimensions":[["Route"]],"Metrics":[{"Name":"RequestCount","Unit":"Count"}]}]}}`,
+					`{"Errors":1,"Route":"GET /books","StatusClass":"5xx","_aws":{"Timestamp":1613304000000,"CloudWatchMetrics":[{"Namespace":"LibraryAPI","Dimensions":[["Route","StatusClass"]],"Metrics":[{"Name":"Errors","Unit":"Count"}]}]}}`,
+				},
+			},
+		},
+		"Repeated metrics become an array of values": {
+			state{
+				data: []Datum{
+					{Name: "DynamoDBLatency", Unit: Milliseconds, Value: 3, Timestamp: timestamp},
+					{Name: "DynamoDBLatency", Unit: Milliseconds, Value: 4, Timestamp: timestamp},
+				},
+			},
+			expected{
+				lines: []string{
+					`{"DynamoDBLatency":[3,4],"_aws":{"Timestamp":1613304000000,"CloudWatchMetrics":[{"Namespace":"LibraryAPI","Dimensions":[[]],"Metrics":[{"Name":"DynamoDBLatency","Unit":"Milliseconds"}]}]}}`,
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			out := &bytes.Buffer{}
+			s := NewEMFSink(out, "LibraryAPI")
+
+			s.Emit(tc.state.data...)
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			assert.So(len(lines), should.Equal, len(tc.expected.lines))
+			for i, line := range lines {
+				assert.So(json.Valid([]byte(line)), should.BeTrue)
+				assert.So(line, should.Equal, tc.expected.lines[i])
+			}
+		})
+	}
+}
+
+func Test_memorySink_Sum(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := NewMemorySink()
+	s.Emit(
+		Counter("CheckOuts", nil),
+		Counter("CheckOuts", nil),
+		Counter("RequestCount", map[string]string{"Route": "GET /books"}),
+		Counter("RequestCount", map[string]string{"Route": "GET /book/{book_id}"}),
+	)
+
+	assert.So(len(s.Data()), should.Equal, 4)
+	assert.So(s.Sum("CheckOuts", nil), should.Equal, 2)
+	assert.So(s.Sum("RequestCount", map[string]string{"Route": "GET /books"}), should.Equal, 1)
+	assert.So(s.Sum("CheckIns", nil), should.Equal, 0)
+}
+
+func Test_prometheusSink_Write(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := NewPrometheusSink()
+	s.Emit(
+		Datum{Name: "RequestCount", Unit: Count, Value: 1, Dimensions: map[string]string{"Route": "GET /books"}},
+		Datum{Name: "RequestCount", Unit: Count, Value: 1, Dimensions: map[string]string{"Route": "GET /books"}},
+		Datum{Name: "RequestLatency", Unit: Milliseconds, Value: 10, Dimensions: map[string]string{"Route": "GET /books"}},
+		Datum{Name: "RequestLatency", Unit: Milliseconds, Value: 20, Dimensions: map[string]string{"Route": "GET /books"}},
+		Datum{Name: "DynamoDBConsumedCapacity", Unit: None, Value: 0.5, Dimensions: map[string]string{"Table": "library-api-books"}},
+	)
+
+	out := &bytes.Buffer{}
+	err := s.Write(out)
+
+	assert.So(err, should.BeNil)
+	assert.So(out.String(), should.Equal, strings.Join([]string{
+		`# TYPE dynamo_db_consumed_capacity summary`,
+		`dynamo_db_consumed_capacity_sum{table="library-api-books"} 0.5`,
+		`dynamo_db_consumed_capacity_count{table="library-api-books"} 1`,
+		`# TYPE request_count_total counter`,
+		`request_count_total{route="GET /books"} 2`,
+		`# TYPE request_latency_milliseconds summary`,
+		`request_latency_milliseconds_sum{route="GET /books"} 30`,
+		`request_latency_milliseconds_count{route="GET /books"} 2`,
+		``,
+	}, "\n"))
+}
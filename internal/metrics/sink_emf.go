@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// emfSink writes data in CloudWatch's Embedded Metric Format. Lambda sends every line written to stdout
+// to CloudWatch Logs, which extracts the metrics from it without any API calls from the function.
+type emfSink struct {
+	mu        sync.Mutex
+	out       io.Writer
+	namespace string
+}
+
+func NewEMFSink(out io.Writer, namespace string) *emfSink {
+	return &emfSink{
+		out:       out,
+		namespace: namespace,
+	}
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+type emfDirective struct {
+	Namespace  string                `json:"Namespace"`
+	Dimensions [][]string            `json:"Dimensions"`
+	Metrics    []emfMetricDefinition `json:"Metrics"`
+}
+
+type emfMetricDefinition struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+// Emit writes one line per set of dimensions, since an EMF document's values all share the same dimensions
+func (s *emfSink) Emit(data ...Datum) {
+	groups := make(map[string][]Datum)
+	order := make([]string, 0)
+	for _, d := range data {
+		key := seriesKey("", d.Dimensions)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], d)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range order {
+		line, err := s.document(groups[key])
+		if err != nil {
+			continue
+		}
+		_, _ = s.out.Write(append(line, '\n'))
+	}
+}
+
+func (s *emfSink) document(data []Datum) ([]byte, error) {
+	dimensions := data[0].Dimensions
+	timestamp := data[0].Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	doc := make(map[string]interface{})
+	for k, v := range dimensions {
+		doc[k] = v
+	}
+
+	definitions := make([]emfMetricDefinition, 0, len(data))
+	for _, d := range data {
+		if _, ok := doc[d.Name]; !ok {
+			definitions = append(definitions, emfMetricDefinition{Name: d.Name, Unit: d.Unit})
+			doc[d.Name] = d.Value
+			continue
+		}
+
+		// A metric that appears more than once in the same document is written as an array of values
+		switch v := doc[d.Name].(type) {
+		case float64:
+			doc[d.Name] = []float64{v, d.Value}
+		case []float64:
+			doc[d.Name] = append(v, d.Value)
+		}
+	}
+
+	doc["_aws"] = emfMetadata{
+		Timestamp: timestamp.UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []emfDirective{{
+			Namespace:  s.namespace,
+			Dimensions: [][]string{dimensionKeys(dimensions)},
+			Metrics:    definitions,
+		}},
+	}
+
+	return json.Marshal(doc)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import "sync"
+
+// memorySink keeps every datum it receives, so tests can verify what was emitted
+type memorySink struct {
+	mu   sync.Mutex
+	data []Datum
+}
+
+func NewMemorySink() *memorySink {
+	return &memorySink{}
+}
+
+func (s *memorySink) Emit(data ...Datum) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = append(s.data, data...)
+}
+
+// Data returns everything emitted so far
+func (s *memorySink) Data() []Datum {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Datum, len(s.data))
+	copy(result, s.data)
+	return result
+}
+
+// Sum adds up the values of the metric with exactly the given dimensions
+func (s *memorySink) Sum(name string, dimensions map[string]string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := seriesKey(name, dimensions)
+	total := 0.0
+	for _, d := range s.data {
+		if seriesKey(d.Name, d.Dimensions) == key {
+			total += d.Value
+		}
+	}
+	return total
+}
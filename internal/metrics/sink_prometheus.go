@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// prometheusSink aggregates data in memory and serves it in Prometheus' text exposition format. Counts
+// become counters; every other unit becomes a summary with a _sum and a _count.
+type prometheusSink struct {
+	mu     sync.Mutex
+	series map[string]*prometheusSeries
+}
+
+type prometheusSeries struct {
+	name       string
+	unit       Unit
+	dimensions map[string]string
+	sum        float64
+	count      int
+}
+
+func NewPrometheusSink() *prometheusSink {
+	return &prometheusSink{
+		series: make(map[string]*prometheusSeries),
+	}
+}
+
+func (s *prometheusSink) Emit(data ...Datum) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range data {
+		key := seriesKey(d.Name, d.Dimensions)
+		series, ok := s.series[key]
+		if !ok {
+			series = &prometheusSeries{name: d.Name, unit: d.Unit, dimensions: d.Dimensions}
+			s.series[key] = series
+		}
+		series.sum += d.Value
+		series.count++
+	}
+}
+
+// ServeHTTP lets the sink be mounted as a /metrics endpoint
+func (s *prometheusSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = s.Write(w)
+}
+
+// Write writes every series in the text exposition format
+func (s *prometheusSink) Write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Group the series by metric name, since the TYPE line must come once before all of a metric's series
+	byName := make(map[string][]*prometheusSeries)
+	for _, series := range s.series {
+		name := prometheusName(series.name, series.unit)
+		byName[name] = append(byName[name], series)
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		series := byName[name]
+		sort.Slice(series, func(i, j int) bool {
+			return seriesKey("", series[i].dimensions) < seriesKey("", series[j].dimensions)
+		})
+
+		if series[0].unit == Count {
+			if _, err := fmt.Fprintf(w, "# TYPE %s counter\n", name); err != nil {
+				return err
+			}
+			for _, s := range series {
+				if _, err := fmt.Fprintf(w, "%s%s %g\n", name, prometheusLabels(s.dimensions), s.sum); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if _, err := fmt.Fprintf(w, "# TYPE %s summary\n", name); err != nil {
+			return err
+		}
+		for _, s := range series {
+			labels := prometheusLabels(s.dimensions)
+			if _, err := fmt.Fprintf(w, "%s_sum%s %g\n%s_count%s %d\n", name, labels, s.sum, name, labels, s.count); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+var (
+	acronymBoundary   = regexp.MustCompile(`([A-Z]+)([A-Z][a-z])`)
+	camelCaseBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	invalidNameChars  = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// snakeCase converts a name like "DynamoDBLatency" into "dynamo_db_latency"
+func snakeCase(name string) string {
+	result := acronymBoundary.ReplaceAllString(name, "${1}_${2}")
+	result = camelCaseBoundary.ReplaceAllString(result, "${1}_${2}")
+	return invalidNameChars.ReplaceAllString(strings.ToLower(result), "_")
+}
+
+// prometheusName converts a name like "RequestLatency" into "request_latency_milliseconds"
+func prometheusName(name string, unit Unit) string {
+	result := snakeCase(name)
+
+	switch unit {
+	case Count:
+		result += "_total"
+	case Milliseconds:
+		result += "_milliseconds"
+	}
+	return result
+}
+
+func prometheusLabels(dimensions map[string]string) string {
+	if len(dimensions) == 0 {
+		return ""
+	}
+
+	labels := make([]string, 0, len(dimensions))
+	for _, k := range dimensionKeys(dimensions) {
+		labels = append(labels, fmt.Sprintf("%s=%q", snakeCase(k), dimensions[k]))
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
@@ -1,6 +1,12 @@
 package internal
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Book struct {
 	ID          string     `json:"id"`
@@ -9,15 +15,150 @@ type Book struct {
 	ISBN        string     `json:"isbn"`
 	Description string     `json:"description"`
 	Status      BookStatus `json:"book_status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CreatedBy   string     `json:"created_by"`
+	UpdatedAt   time.Time  `json:"updated_at"` // Also set when the book is moved in or out of the trash
+	UpdatedBy   string     `json:"updated_by"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // Set when the book is moved to the trash
+}
+
+func (b Book) IsDeleted() bool {
+	return b.DeletedAt != nil
+}
+
+// BookFilter narrows down a listing of the catalog. Books in the trash are only listed when asking for
+// the changes since a point in time, so that a downstream system syncing the catalog sees the deletion.
+// An empty ISBN, Author or Status matches any book.
+type BookFilter struct {
+	UpdatedSince time.Time
+	ISBN         string
+	Author       string
+	Status       BookStatus
+}
+
+func (f BookFilter) Matches(book Book) bool {
+	switch {
+	case f.ISBN != "" && book.ISBN != f.ISBN:
+		return false
+	case f.Author != "" && book.Author != f.Author:
+		return false
+	case f.Status != "" && book.Status != f.Status:
+		return false
+	case f.UpdatedSince.IsZero():
+		return !book.IsDeleted()
+	}
+	return !book.UpdatedAt.Before(f.UpdatedSince)
 }
 
 type BookStatus string
 
 const (
-	CheckedIn  BookStatus = "in"
-	CheckedOut BookStatus = "out"
+	CheckedIn   BookStatus = "in"
+	CheckedOut  BookStatus = "out"
+	OnHoldShelf BookStatus = "on_hold_shelf" // Reserved for the patron at the head of the holds queue
+)
+
+// Copy is a physical item that a branch owns of a book. Circulation operates on copies, and the book's
+// Status summarizes them: it's checked in while any copy is available.
+type Copy struct {
+	Barcode   string        `json:"barcode"`
+	BookID    string        `json:"book_id"`
+	Branch    string        `json:"branch"`
+	Location  string        `json:"location"` // Where the copy is shelved within the branch
+	Condition CopyCondition `json:"condition"`
+	ItemType  ItemType      `json:"item_type"`
+	Status    BookStatus    `json:"status"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type CopyCondition string
+
+const (
+	ConditionNew     CopyCondition = "new"
+	ConditionGood    CopyCondition = "good"
+	ConditionWorn    CopyCondition = "worn"
+	ConditionDamaged CopyCondition = "damaged"
+)
+
+// ItemType is the kind of item a copy is, which decides how it's fined when it's returned late
+type ItemType string
+
+const (
+	ItemBook      ItemType = "book"
+	ItemDVD       ItemType = "dvd"
+	ItemReference ItemType = "reference"
+)
+
+// PatronType is the kind of patron borrowing a copy, which decides how long they may keep it
+type PatronType string
+
+const (
+	PatronStudent PatronType = "student"
+	PatronStaff   PatronType = "staff"
+	PatronPublic  PatronType = "public"
 )
 
+// Availability counts a book's copies
+type Availability struct {
+	Available   int `json:"available"`
+	OnHoldShelf int `json:"on_hold_shelf"`
+	Total       int `json:"total"`
+}
+
+func NewAvailability(copies []Copy) Availability {
+	result := Availability{Total: len(copies)}
+	for _, c := range copies {
+		switch c.Status {
+		case CheckedIn:
+			result.Available++
+		case OnHoldShelf:
+			result.OnHoldShelf++
+		}
+	}
+	return result
+}
+
+// Status summarizes the availability as a book status. A book without any copies keeps its own status.
+func (a Availability) Status(current BookStatus) BookStatus {
+	switch {
+	case a.Total == 0:
+		return current
+	case a.Available > 0:
+		return CheckedIn
+	case a.OnHoldShelf > 0:
+		return OnHoldShelf
+	default:
+		return CheckedOut
+	}
+}
+
+// Hold is a patron's place in the queue for a book. When a copy is checked in it's reserved for the
+// patron at the head of the queue, who has until ExpiresAt to check it out.
+type Hold struct {
+	ID        string     `json:"id"`
+	BookID    string     `json:"book_id"`
+	PatronID  string     `json:"patron_id"`
+	Status    HoldStatus `json:"status"`
+	PlacedAt  time.Time  `json:"placed_at"`
+	Barcode   string     `json:"barcode,omitempty"` // The copy reserved on the hold shelf
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type HoldStatus string
+
+const (
+	HoldWaiting   HoldStatus = "waiting"
+	HoldReady     HoldStatus = "ready" // A copy is waiting on the hold shelf
+	HoldFulfilled HoldStatus = "fulfilled"
+	HoldCancelled HoldStatus = "cancelled"
+	HoldExpired   HoldStatus = "expired"
+)
+
+// IsActive reports whether the hold is still in the queue
+func (h Hold) IsActive() bool {
+	return h.Status == HoldWaiting || h.Status == HoldReady
+}
+
 type ErrBookNotFound struct {
 	BookID string
 }
@@ -25,3 +166,319 @@ type ErrBookNotFound struct {
 func (e ErrBookNotFound) Error() string {
 	return fmt.Sprintf("The book with ID '%s' was not found", e.BookID)
 }
+
+// ErrDuplicateISBN is returned when a book is given an ISBN that another book already has
+type ErrDuplicateISBN struct {
+	ISBN string
+}
+
+func (e ErrDuplicateISBN) Error() string {
+	return fmt.Sprintf("A book with ISBN '%s' already exists", e.ISBN)
+}
+
+// ErrRetryable is returned when the database failed in a way that may pass if it's tried again later, such
+// as throttling or a server error, and was still failing when the storage stopped retrying
+type ErrRetryable struct {
+	Err        error
+	Throttled  bool          // Whether the database was throttling the requests
+	RetryAfter time.Duration // How long to wait before trying again
+}
+
+func (e ErrRetryable) Error() string {
+	return e.Err.Error()
+}
+
+func (e ErrRetryable) Unwrap() error {
+	return e.Err
+}
+
+// ErrNonRetryable is returned when the database failed in a way that will fail again if it's retried, such
+// as a request it rejected as invalid
+type ErrNonRetryable struct {
+	Err error
+}
+
+func (e ErrNonRetryable) Error() string {
+	return e.Err.Error()
+}
+
+func (e ErrNonRetryable) Unwrap() error {
+	return e.Err
+}
+
+// Loan records a copy checked out to a patron. It's open until the copy is returned.
+type Loan struct {
+	ID           string     `json:"id"`
+	Barcode      string     `json:"barcode"`
+	BookID       string     `json:"book_id"`
+	PatronID     string     `json:"patron_id"`
+	PatronType   PatronType `json:"patron_type,omitempty"`
+	ItemType     ItemType   `json:"item_type,omitempty"`
+	CheckedOutAt time.Time  `json:"checked_out_at"`
+	DueAt        time.Time  `json:"due_at"`
+	Renewals     int        `json:"renewals"`
+	ReturnedAt   *time.Time `json:"returned_at,omitempty"`
+}
+
+func (l Loan) IsOpen() bool {
+	return l.ReturnedAt == nil
+}
+
+// DaysOverdue counts the days, or part days, between the due date and either the return or now
+func (l Loan) DaysOverdue(now time.Time) int {
+	if l.ReturnedAt != nil {
+		now = *l.ReturnedAt
+	}
+	late := now.Sub(l.DueAt)
+	if late <= 0 {
+		return 0
+	}
+	return int((late + 24*time.Hour - 1) / (24 * time.Hour))
+}
+
+type ErrLoanNotFound struct {
+	Barcode string
+}
+
+func (e ErrLoanNotFound) Error() string {
+	return fmt.Sprintf("The copy with barcode '%s' isn't on loan", e.Barcode)
+}
+
+// ErrLoanChanged is returned when a loan was renewed or returned by someone else in the meantime
+type ErrLoanChanged struct {
+	LoanID string
+}
+
+func (e ErrLoanChanged) Error() string {
+	return fmt.Sprintf("The loan with ID '%s' was changed by another request", e.LoanID)
+}
+
+// Money is an amount in minor units, e.g. cents, so that sums are exact
+type Money int64
+
+// String formats the amount in major units, e.g. "12.05"
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign, m = "-", -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/100, m%100)
+}
+
+// LedgerEntry is a line in a patron's account. Amounts are always positive: the entry's type says whether
+// it adds to what the patron owes or takes away from it.
+type LedgerEntry struct {
+	ID        string          `json:"id"`
+	PatronID  string          `json:"patron_id"`
+	Type      LedgerEntryType `json:"type"`
+	Amount    Money           `json:"amount"`
+	LoanID    string          `json:"loan_id,omitempty"` // The late loan that a charge is for
+	Barcode   string          `json:"barcode,omitempty"`
+	Note      string          `json:"note,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	CreatedBy string          `json:"created_by"`
+}
+
+type LedgerEntryType string
+
+const (
+	LedgerCharge  LedgerEntryType = "charge"
+	LedgerPayment LedgerEntryType = "payment"
+	LedgerWaiver  LedgerEntryType = "waiver" // Forgiven by staff
+)
+
+// Balance is the entry's effect on what the patron owes
+func (e LedgerEntry) Balance() Money {
+	if e.Type == LedgerCharge {
+		return e.Amount
+	}
+	return -e.Amount
+}
+
+// Account is a patron's ledger, and the balance that they owe
+type Account struct {
+	PatronID string        `json:"patron_id"`
+	Balance  Money         `json:"balance"`
+	Entries  []LedgerEntry `json:"entries"`
+}
+
+func NewAccount(patronID string, entries []LedgerEntry) Account {
+	result := Account{PatronID: patronID, Entries: entries}
+	for _, entry := range entries {
+		result.Balance += entry.Balance()
+	}
+	return result
+}
+
+type ErrHoldNotFound struct {
+	HoldID string
+}
+
+func (e ErrHoldNotFound) Error() string {
+	return fmt.Sprintf("The hold with ID '%s' was not found", e.HoldID)
+}
+
+// ErrDuplicateHold is returned when a patron places a second hold on the same book
+type ErrDuplicateHold struct {
+	BookID   string
+	PatronID string
+}
+
+func (e ErrDuplicateHold) Error() string {
+	return fmt.Sprintf("The patron '%s' already has a hold on the book with ID '%s'", e.PatronID, e.BookID)
+}
+
+type ErrCopyNotFound struct {
+	Barcode string
+}
+
+func (e ErrCopyNotFound) Error() string {
+	return fmt.Sprintf("The copy with barcode '%s' was not found", e.Barcode)
+}
+
+// ErrDuplicateBarcode is returned when adding a copy whose barcode is already in use
+type ErrDuplicateBarcode struct {
+	Barcode string
+}
+
+func (e ErrDuplicateBarcode) Error() string {
+	return fmt.Sprintf("A copy with barcode '%s' already exists", e.Barcode)
+}
+
+// ErrCopyStatusConflict is returned when a copy's status isn't the one a change expects, e.g. checking
+// out a copy that's already checked out
+type ErrCopyStatusConflict struct {
+	Barcode string
+	Status  BookStatus
+}
+
+func (e ErrCopyStatusConflict) Error() string {
+	return fmt.Sprintf("The copy with barcode '%s' is not checked %s", e.Barcode, e.Status)
+}
+
+type AuditAction string
+
+const (
+	AuditCreateBook  AuditAction = "create_book"
+	AuditUpdateBook  AuditAction = "update_book"
+	AuditDeleteBook  AuditAction = "delete_book"
+	AuditRestoreBook AuditAction = "restore_book"
+	AuditPurgeBook   AuditAction = "purge_book"
+	AuditCheckOut    AuditAction = "check_out"
+	AuditCheckIn     AuditAction = "check_in"
+	AuditAddCopy     AuditAction = "add_copy"
+	AuditHoldShelf   AuditAction = "hold_shelf"   // A copy was reserved for a hold
+	AuditReleaseCopy AuditAction = "release_copy" // A copy on the hold shelf went back into circulation
+)
+
+// CopyAuditAction names a change of a copy's status
+func CopyAuditAction(from, to BookStatus) AuditAction {
+	switch {
+	case to == CheckedOut:
+		return AuditCheckOut
+	case to == OnHoldShelf:
+		return AuditHoldShelf
+	case from == CheckedOut:
+		return AuditCheckIn
+	default:
+		return AuditReleaseCopy
+	}
+}
+
+// AuditEvent records a single change to the catalog. Before is nil for a created book, and After is nil
+// for a purged one. Changes to a copy carry its barcode.
+type AuditEvent struct {
+	ID        string      `json:"id"`
+	BookID    string      `json:"book_id"`
+	Barcode   string      `json:"barcode,omitempty"`
+	Action    AuditAction `json:"action"`
+	Actor     string      `json:"actor"`
+	Timestamp time.Time   `json:"timestamp"`
+	Before    *Book       `json:"before,omitempty"`
+	After     *Book       `json:"after,omitempty"`
+}
+
+// Precedes reports whether the event comes before other in the audit log, which is ordered by time and then
+// by ID
+func (e AuditEvent) Precedes(other AuditEvent) bool {
+	if !e.Timestamp.Equal(other.Timestamp) {
+		return e.Timestamp.Before(other.Timestamp)
+	}
+	return e.ID < other.ID
+}
+
+// AuditFilter narrows down a search of the audit log. Zero values match every event. The log is read a page
+// at a time by passing the last event of each page as the After of the next.
+type AuditFilter struct {
+	Actor string
+	Since time.Time
+	After *AuditEvent // Only the events that come after it
+	Limit int         // The most events to return; zero returns every match
+}
+
+func (f AuditFilter) Matches(event AuditEvent) bool {
+	if f.Actor != "" && event.Actor != f.Actor {
+		return false
+	}
+	if f.After != nil && !f.After.Precedes(event) {
+		return false
+	}
+	return !event.Timestamp.Before(f.Since)
+}
+
+// NewAuditEvent describes the change from before to after. Moving a book in or out of the trash is
+// recorded as a delete or restore, a change of status as a check-out or check-in, and any other change
+// as an update.
+func NewAuditEvent(ctx context.Context, bookID string, before, after *Book, timestamp time.Time) AuditEvent {
+	event := AuditEvent{
+		ID:        uuid.New().String(),
+		BookID:    bookID,
+		Actor:     ActorFromContext(ctx),
+		Timestamp: timestamp,
+		Before:    before,
+		After:     after,
+	}
+
+	switch {
+	case before == nil:
+		event.Action = AuditCreateBook
+	case after == nil:
+		event.Action = AuditPurgeBook
+	case !before.IsDeleted() && after.IsDeleted():
+		event.Action = AuditDeleteBook
+	case before.IsDeleted() && !after.IsDeleted():
+		event.Action = AuditRestoreBook
+	case before.Status != after.Status && after.Status == CheckedOut:
+		event.Action = AuditCheckOut
+	case before.Status != after.Status && after.Status == CheckedIn:
+		event.Action = AuditCheckIn
+	default:
+		event.Action = AuditUpdateBook
+	}
+
+	return event
+}
+
+// NewCopyAuditEvent records a change to one of a book's copies, along with the change it made to the book
+func NewCopyAuditEvent(ctx context.Context, action AuditAction, bookCopy Copy, before, after *Book, timestamp time.Time) AuditEvent {
+	event := NewAuditEvent(ctx, bookCopy.BookID, before, after, timestamp)
+	event.Action = action
+	event.Barcode = bookCopy.Barcode
+	return event
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns a copy of ctx that records who is making the request, so the storage can
+// attribute the changes it makes
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by ContextWithActor, or "unknown"
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return "unknown"
+}
@@ -0,0 +1,159 @@
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/fines"
+)
+
+// Rule is how items of one type are lent to patrons of one type
+type Rule struct {
+	LoanDays    int        `json:"loan_days" yaml:"loan_days"`
+	MaxRenewals int        `json:"max_renewals" yaml:"max_renewals"`
+	MaxLoans    int        `json:"max_loans" yaml:"max_loans"` // How many items of the type a patron may have out at once
+	Fine        fines.Rate `json:"fine" yaml:"fine"`
+}
+
+// LoanPeriod is how long a copy is lent for, on check-out and on each renewal
+func (r Rule) LoanPeriod() time.Duration {
+	return time.Duration(r.LoanDays) * 24 * time.Hour
+}
+
+// Default is the key of the rules that apply to patron or item types without rules of their own
+const Default = "default"
+
+// Policy holds the circulation rules, keyed by patron type and then item type
+type Policy struct {
+	Rules map[string]map[string]Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule finds the rule for lending an item of the given type to a patron of the given type. A rule for the
+// item type is preferred over a default one, so the restrictions on an item type apply to every patron type
+// that doesn't have its own rule for it: the lookup is <patron>/<item>, default/<item>, <patron>/default
+// and then default/default.
+func (p Policy) Rule(patronType internal.PatronType, itemType internal.ItemType) Rule {
+	lookups := [][2]string{
+		{string(patronType), string(itemType)},
+		{Default, string(itemType)},
+		{string(patronType), Default},
+		{Default, Default},
+	}
+	for _, lookup := range lookups {
+		if rule, ok := p.Rules[lookup[0]][lookup[1]]; ok {
+			return rule
+		}
+	}
+	return Rule{}
+}
+
+var patronTypes = []internal.PatronType{internal.PatronStudent, internal.PatronStaff, internal.PatronPublic}
+
+var itemTypes = []internal.ItemType{internal.ItemBook, internal.ItemDVD, internal.ItemReference}
+
+// Validate checks that the policy only has rules for known patron and item types, that none of the rules
+// is negative or lends items for no time at all, and that there's a default rule for every lookup to fall
+// back on
+func (p Policy) Validate() error {
+	if _, ok := p.Rules[Default][Default]; !ok {
+		return errors.New("the policy must have a default rule for the default patron type")
+	}
+
+	problems := make([]string, 0)
+	for patron, rules := range p.Rules {
+		if patron != Default && !KnownPatronType(internal.PatronType(patron)) {
+			problems = append(problems, fmt.Sprintf("unknown patron type '%s'", patron))
+		}
+		for item, rule := range rules {
+			if item != Default && !KnownItemType(internal.ItemType(item)) {
+				problems = append(problems, fmt.Sprintf("unknown item type '%s' for patron type '%s'", item, patron))
+			}
+			if rule.LoanDays <= 0 {
+				problems = append(problems, fmt.Sprintf("the loan_days of %s/%s must be more than zero", patron, item))
+			}
+			if rule.MaxRenewals < 0 || rule.MaxLoans < 0 || rule.Fine.PerDay < 0 || rule.Fine.GraceDays < 0 || rule.Fine.Cap < 0 {
+				problems = append(problems, fmt.Sprintf("the rule for %s/%s must not be negative", patron, item))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems) // The rules are in a map, so sort the problems for a stable message
+		return fmt.Errorf("the policy is invalid: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// KnownPatronType reports whether the policy can have rules for the patron type
+func KnownPatronType(patronType internal.PatronType) bool {
+	for _, t := range patronTypes {
+		if t == patronType {
+			return true
+		}
+	}
+	return false
+}
+
+// KnownItemType reports whether the policy can have rules for the item type
+func KnownItemType(itemType internal.ItemType) bool {
+	for _, t := range itemTypes {
+		if t == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse decodes and validates a policy. YAML is a superset of JSON, so the data may be either.
+func Parse(data []byte) (Policy, error) {
+	var result Policy
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&result)
+	if err != nil {
+		return Policy{}, fmt.Errorf("failed to decode the policy: %w", err)
+	}
+
+	err = result.Validate()
+	if err != nil {
+		return Policy{}, err
+	}
+	return result, nil
+}
+
+// Load reads and validates the policy file at path, which may be JSON or YAML
+func Load(path string) (Policy, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Policy{}, fmt.Errorf("failed to read the policy file: %w", err)
+	}
+
+	result, err := Parse(data)
+	if err != nil {
+		return Policy{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return result, nil
+}
+
+//go:embed default.yaml
+var defaultPolicy []byte
+
+// DefaultPolicy returns the policy that's used when no policy file is configured
+func DefaultPolicy() Policy {
+	result, err := Parse(defaultPolicy)
+	if err != nil {
+		panic(fmt.Sprintf("the default policy is invalid: %v", err))
+	}
+	return result
+}
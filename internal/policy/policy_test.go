@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/fines"
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+func TestPolicy_Rule(t *testing.T) {
+	p := Policy{Rules: map[string]map[string]Rule{
+		Default: {
+			Default: {LoanDays: 21},
+			"dvd":   {LoanDays: 7},
+		},
+		"staff": {
+			Default: {LoanDays: 56},
+		},
+		"student": {
+			"reference": {LoanDays: 2},
+		},
+	}}
+
+	type state struct {
+		patronType internal.PatronType
+		itemType   internal.ItemType
+	}
+	type expected struct {
+		loanDays int
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"Neither type has rules of its own": {
+			state{patronType: internal.PatronPublic, itemType: internal.ItemBook},
+			expected{loanDays: 21},
+		},
+		"The item type has a default rule": {
+			state{patronType: internal.PatronPublic, itemType: internal.ItemDVD},
+			expected{loanDays: 7},
+		},
+		"The item type's default rule is preferred over the patron type's default rule": {
+			state{patronType: internal.PatronStaff, itemType: internal.ItemDVD},
+			expected{loanDays: 7},
+		},
+		"The patron type's default rule applies to item types without rules": {
+			state{patronType: internal.PatronStaff, itemType: internal.ItemBook},
+			expected{loanDays: 56},
+		},
+		"The patron type has a rule for the item type": {
+			state{patronType: internal.PatronStudent, itemType: internal.ItemReference},
+			expected{loanDays: 2},
+		},
+		"The patron type has no rule for the item type": {
+			state{patronType: internal.PatronStudent, itemType: internal.ItemDVD},
+			expected{loanDays: 7},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			result := p.Rule(tc.state.patronType, tc.state.itemType)
+
+			assert.So(result.LoanDays, should.Equal, tc.expected.loanDays)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	type state struct {
+		data string
+	}
+	type expected struct {
+		policy Policy
+		err    error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"A JSON policy": {
+			state{data: `{"rules":{"default":{"default":{"loan_days":21,"max_renewals":2,"max_loans":10,"fine":{"per_day":25,"grace_days":1,"cap":1000}}}}}`},
+			expected{policy: Policy{Rules: map[string]map[string]Rule{
+				Default: {Default: {LoanDays: 21, MaxRenewals: 2, MaxLoans: 10, Fine: fines.Rate{PerDay: 25, GraceDays: 1, Cap: 1000}}},
+			}}},
+		},
+		"A YAML policy": {
+			state{data: "rules:\n  default:\n    default: {loan_days: 21}\n  student:\n    dvd: {loan_days: 7, max_loans: 1}\n"},
+			expected{policy: Policy{Rules: map[string]map[string]Rule{
+				Default:   {Default: {LoanDays: 21}},
+				"student": {"dvd": {LoanDays: 7, MaxLoans: 1}},
+			}}},
+		},
+		"A field is misspelled": {
+			state{data: "rules:\n  default:\n    default: {loan_day: 21}\n"},
+			expected{err: errors.New("failed to decode the policy: yaml: unmarshal errors:\n  line 3: field loan_day not found in type policy.Rule")},
+		},
+		"There's no default rule": {
+			state{data: "rules:\n  student:\n    default: {loan_days: 14}\n"},
+			expected{err: errors.New("the policy must have a default rule for the default patron type")},
+		},
+		"The rules aren't valid": {
+			state{data: "rules:\n  default:\n    default: {loan_days: 21}\n    vinyl: {loan_days: 0}\n  alumni:\n    default: {loan_days: 7, max_loans: -1}\n"},
+			expected{err: errors.New("the policy is invalid: the loan_days of default/vinyl must be more than zero; the rule for alumni/default must not be negative; unknown item type 'vinyl' for patron type 'default'; unknown patron type 'alumni'")},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			result, err := Parse([]byte(tc.state.data))
+
+			assert.So(result, should.Resemble, tc.expected.policy)
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	type state struct {
+		patronType internal.PatronType
+		itemType   internal.ItemType
+	}
+	type expected struct {
+		loanDays int
+		maxLoans int
+		perDay   internal.Money
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"A public patron borrows a book":      {state{internal.PatronPublic, internal.ItemBook}, expected{loanDays: 21, maxLoans: 20, perDay: 25}},
+		"A public patron borrows a DVD":       {state{internal.PatronPublic, internal.ItemDVD}, expected{loanDays: 7, maxLoans: 5, perDay: 100}},
+		"A public patron borrows a reference": {state{internal.PatronPublic, internal.ItemReference}, expected{loanDays: 2, maxLoans: 2, perDay: 100}},
+		"A student borrows a book":            {state{internal.PatronStudent, internal.ItemBook}, expected{loanDays: 14, maxLoans: 10, perDay: 10}},
+		"A student borrows a DVD":             {state{internal.PatronStudent, internal.ItemDVD}, expected{loanDays: 7, maxLoans: 5, perDay: 100}},
+		"A student borrows a reference":       {state{internal.PatronStudent, internal.ItemReference}, expected{loanDays: 2, maxLoans: 2, perDay: 100}},
+		"A staff member borrows a book":       {state{internal.PatronStaff, internal.ItemBook}, expected{loanDays: 56, maxLoans: 50, perDay: 0}},
+		"A staff member borrows a DVD":        {state{internal.PatronStaff, internal.ItemDVD}, expected{loanDays: 7, maxLoans: 5, perDay: 100}},
+		"A staff member borrows a reference":  {state{internal.PatronStaff, internal.ItemReference}, expected{loanDays: 7, maxLoans: 5, perDay: 0}},
+	}
+
+	assert := assertions.New(t)
+	assert.So(DefaultPolicy().Validate(), should.BeNil)
+	assert.So(len(testCases), should.Equal, len(patronTypes)*len(itemTypes)) // Every pair of known types is covered
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			result := DefaultPolicy().Rule(tc.state.patronType, tc.state.itemType)
+
+			assert.So(result.LoanDays, should.Equal, tc.expected.loanDays)
+			assert.So(result.MaxLoans, should.Equal, tc.expected.maxLoans)
+			assert.So(result.Fine.PerDay, should.Equal, tc.expected.perDay)
+		})
+	}
+}
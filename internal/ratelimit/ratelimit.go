@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit configures a token bucket. The bucket holds at most Burst tokens and is refilled at Rate tokens
+// per second. A zero Limit means the route is not rate limited.
+type Limit struct {
+	Burst int
+	Rate  float64
+}
+
+func (l Limit) unlimited() bool {
+	return l.Burst <= 0 || l.Rate <= 0
+}
+
+// Result describes the outcome of taking a token from a bucket
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // How long until a token is available, if the request wasn't allowed
+	Reset      time.Duration // How long until the bucket is full again
+}
+
+// Store holds the state of every client's buckets. Implementations must make Take atomic so that
+// concurrent requests from the same client can't spend the same token.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+type bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// take refills the bucket for the time elapsed since it was last updated and then tries to spend a token
+func (b bucket) take(limit Limit, now time.Time) (bucket, Result) {
+	if b.UpdatedAt.IsZero() {
+		b = bucket{Tokens: float64(limit.Burst), UpdatedAt: now}
+	}
+
+	elapsed := now.Sub(b.UpdatedAt).Seconds()
+	if elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed*limit.Rate)
+		b.UpdatedAt = now
+	}
+
+	result := Result{Limit: limit.Burst}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.Tokens) / limit.Rate)
+	}
+	result.Remaining = int(math.Floor(b.Tokens))
+	result.Reset = secondsToDuration((float64(limit.Burst) - b.Tokens) / limit.Rate)
+
+	return b, result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
+
+type Limiter struct {
+	store        Store
+	defaultLimit Limit
+	routeLimits  map[string]Limit
+	now          func() time.Time
+}
+
+func NewLimiter(store Store, opts ...LimiterOption) Limiter {
+	l := Limiter{
+		store:       store,
+		routeLimits: make(map[string]Limit),
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		l = opt(l)
+	}
+
+	return l
+}
+
+type LimiterOption func(l Limiter) Limiter
+
+// WithDefaultLimit sets the limit for routes that don't have their own
+func WithDefaultLimit(limit Limit) LimiterOption {
+	return func(l Limiter) Limiter {
+		l.defaultLimit = limit
+		return l
+	}
+}
+
+// WithRouteLimit sets the limit for a single route, e.g. "GET /books"
+func WithRouteLimit(route string, limit Limit) LimiterOption {
+	return func(l Limiter) Limiter {
+		l.routeLimits[route] = limit
+		return l
+	}
+}
+
+// WithLimits sets the limits returned by ParseLimits
+func WithLimits(limits map[string]Limit) LimiterOption {
+	return func(l Limiter) Limiter {
+		for route, limit := range limits {
+			if route == DefaultRoute {
+				l.defaultLimit = limit
+				continue
+			}
+			l.routeLimits[route] = limit
+		}
+		return l
+	}
+}
+
+func WithClock(now func() time.Time) LimiterOption {
+	return func(l Limiter) Limiter {
+		l.now = now
+		return l
+	}
+}
+
+// Allow takes a token from the client's bucket for the given route
+func (l Limiter) Allow(ctx context.Context, route, clientID string) (Result, error) {
+	limit, ok := l.routeLimits[route]
+	if !ok {
+		limit = l.defaultLimit
+	}
+
+	if limit.unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	result, err := l.store.Take(ctx, route+"|"+clientID, limit, l.now())
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to take a token from the bucket: %w", err)
+	}
+
+	return result, nil
+}
+
+// DefaultRoute is the route name used by ParseLimits for the limit that applies to every other route
+const DefaultRoute = "default"
+
+// ParseLimits parses limits in the form "GET /books=5:0.5;default=50:10", where each limit is the
+// bucket's burst followed by its refill rate per second
+func ParseLimits(value string) (map[string]Limit, error) {
+	result := make(map[string]Limit)
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(entry, "=")
+		if separator < 0 {
+			return nil, fmt.Errorf("the rate limit '%s' must be in the form 'route=burst:rate'", entry)
+		}
+		route, spec := strings.TrimSpace(entry[:separator]), entry[separator+1:]
+
+		parts := strings.Split(spec, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("the rate limit '%s' must be in the form 'route=burst:rate'", entry)
+		}
+
+		burst, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("the burst of the rate limit '%s' is not an integer: %w", entry, err)
+		}
+		rate, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("the rate of the rate limit '%s' is not a number: %w", entry, err)
+		}
+
+		result[route] = Limit{Burst: burst, Rate: rate}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,212 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+func Test_bucket_take(t *testing.T) {
+	now := time.Date(2021, 2, 14, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Burst: 2, Rate: 0.5}
+
+	type state struct {
+		bucket bucket
+		now    time.Time
+	}
+	type expected struct {
+		bucket bucket
+		result Result
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"A new bucket starts full": {
+			state{
+				bucket: bucket{},
+				now:    now,
+			},
+			expected{
+				bucket: bucket{Tokens: 1, UpdatedAt: now},
+				result: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 2 * time.Second},
+			},
+		},
+		"An empty bucket rejects the request": {
+			state{
+				bucket: bucket{Tokens: 0, UpdatedAt: now},
+				now:    now,
+			},
+			expected{
+				bucket: bucket{Tokens: 0, UpdatedAt: now},
+				result: Result{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: 2 * time.Second, Reset: 4 * time.Second},
+			},
+		},
+		"The bucket is refilled for the time that has passed": {
+			state{
+				bucket: bucket{Tokens: 0, UpdatedAt: now},
+				now:    now.Add(3 * time.Second),
+			},
+			expected{
+				bucket: bucket{Tokens: 0.5, UpdatedAt: now.Add(3 * time.Second)},
+				result: Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 3 * time.Second},
+			},
+		},
+		"The bucket never holds more than the burst": {
+			state{
+				bucket: bucket{Tokens: 1, UpdatedAt: now},
+				now:    now.Add(time.Hour),
+			},
+			expected{
+				bucket: bucket{Tokens: 1, UpdatedAt: now.Add(time.Hour)},
+				result: Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 2 * time.Second},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			b, result := tc.state.bucket.take(limit, tc.state.now)
+
+			assert.So(b, should.Resemble, tc.expected.bucket)
+			assert.So(result, should.Resemble, tc.expected.result)
+		})
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2021, 2, 14, 12, 0, 0, 0, time.UTC)
+
+	type state struct {
+		route    string
+		requests int // The number of requests made before the one being verified
+	}
+	type expected struct {
+		allowed bool
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The route's own limit is applied": {
+			state{
+				route:    "GET /books",
+				requests: 1,
+			},
+			expected{
+				allowed: false,
+			},
+		},
+		"The default limit is applied to other routes": {
+			state{
+				route:    "GET /book/{book_id}",
+				requests: 1,
+			},
+			expected{
+				allowed: true,
+			},
+		},
+		"The default limit is eventually reached": {
+			state{
+				route:    "GET /book/{book_id}",
+				requests: 3,
+			},
+			expected{
+				allowed: false,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			l := NewLimiter(NewMemoryStore(),
+				WithRouteLimit("GET /books", Limit{Burst: 1, Rate: 0.1}),
+				WithDefaultLimit(Limit{Burst: 3, Rate: 0.1}),
+				WithClock(func() time.Time { return now }),
+			)
+
+			for i := 0; i < tc.state.requests; i++ {
+				_, err := l.Allow(context.Background(), tc.state.route, "ip:10.0.0.1")
+				assert.So(err, should.BeNil)
+			}
+
+			result, err := l.Allow(context.Background(), tc.state.route, "ip:10.0.0.1")
+
+			assert.So(result.Allowed, should.Equal, tc.expected.allowed)
+			assert.So(err, should.BeNil)
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	type state struct {
+		value string
+	}
+	type expected struct {
+		result map[string]Limit
+		err    error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"An empty value has no limits": {
+			state{
+				value: "",
+			},
+			expected{
+				result: map[string]Limit{},
+			},
+		},
+		"Route and default limits are parsed": {
+			state{
+				value: "GET /books=10:0.2; default=50:5",
+			},
+			expected{
+				result: map[string]Limit{
+					"GET /books": {Burst: 10, Rate: 0.2},
+					DefaultRoute: {Burst: 50, Rate: 5},
+				},
+			},
+		},
+		"A limit without a rate returns an error": {
+			state{
+				value: "GET /books=10",
+			},
+			expected{
+				err: errors.New("the rate limit 'GET /books=10' must be in the form 'route=burst:rate'"),
+			},
+		},
+		"A burst that isn't an integer returns an error": {
+			state{
+				value: "GET /books=ten:1",
+			},
+			expected{
+				err: errors.New("the burst of the rate limit 'GET /books=ten:1' is not an integer"),
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			result, err := ParseLimits(tc.state.value)
+
+			if tc.expected.err == nil {
+				assert.So(result, should.Resemble, tc.expected.result)
+			}
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
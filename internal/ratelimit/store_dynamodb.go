@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// dynamodbStore keeps buckets in a DynamoDB table so that limits hold across Lambda containers. The table
+// needs a string partition key named "bucket_key"; enabling TTL on "expires_at" cleans up idle buckets.
+type dynamodbStore struct {
+	awsRegion  string
+	endpoint   string
+	tableName  string
+	maxRetries int
+	db         *dynamodb.Client
+}
+
+// NewDynamoDBStore fails if the AWS configuration, such as the shared config file, can't be loaded
+func NewDynamoDBStore(tableName string, opts ...DynamoDBStoreOption) (*dynamodbStore, error) {
+	result := &dynamodbStore{
+		awsRegion:  "us-west-1", // Default region is us-west-1
+		tableName:  tableName,
+		maxRetries: 5,
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(result.awsRegion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the AWS configuration: %w", err)
+	}
+
+	result.db = dynamodb.NewFromConfig(awsConfig, func(o *dynamodb.Options) {
+		if result.endpoint != "" {
+			o.BaseEndpoint = aws.String(result.endpoint)
+		}
+	})
+
+	return result, nil
+}
+
+type DynamoDBStoreOption func(*dynamodbStore)
+
+func WithAWSRegion(awsRegion string) DynamoDBStoreOption {
+	return func(s *dynamodbStore) {
+		s.awsRegion = awsRegion
+	}
+}
+
+// WithEndpoint points the store at a DynamoDB other than AWS's, such as DynamoDB Local
+func WithEndpoint(endpoint string) DynamoDBStoreOption {
+	return func(s *dynamodbStore) {
+		s.endpoint = endpoint
+	}
+}
+
+// Take reads the bucket and writes it back with a condition on the previous update time. If another
+// container updated the bucket in the meantime the condition fails and the read is retried.
+func (s *dynamodbStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	for attempt := 0; attempt < s.maxRetries; attempt++ {
+		current, err := s.getBucket(ctx, key)
+		if err != nil {
+			return Result{}, err
+		}
+
+		updated, result := current.take(limit, now)
+
+		err = s.putBucket(ctx, key, current, updated, now.Add(result.Reset))
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			continue
+		}
+		if err != nil {
+			return Result{}, err
+		}
+
+		return result, nil
+	}
+
+	return Result{}, fmt.Errorf("failed to update the bucket '%s' after %d attempts", key, s.maxRetries)
+}
+
+func (s *dynamodbStore) getBucket(ctx context.Context, key string) (bucket, error) {
+	dbResult, err := s.db.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            map[string]types.AttributeValue{"bucket_key": &types.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return bucket{}, fmt.Errorf("failed to retrieve the bucket from the database: %w", err)
+	}
+
+	if len(dbResult.Item) == 0 {
+		return bucket{}, nil
+	}
+
+	tokens, err := strconv.ParseFloat(numberAttribute(dbResult.Item, "tokens"), 64)
+	if err != nil {
+		return bucket{}, fmt.Errorf("failed to parse the bucket's tokens: %w", err)
+	}
+	updatedAt, err := strconv.ParseInt(numberAttribute(dbResult.Item, "updated_at"), 10, 64)
+	if err != nil {
+		return bucket{}, fmt.Errorf("failed to parse the bucket's update time: %w", err)
+	}
+
+	return bucket{Tokens: tokens, UpdatedAt: time.Unix(0, updatedAt)}, nil
+}
+
+// numberAttribute is the item's number attribute, or empty if the item doesn't have it
+func numberAttribute(item map[string]types.AttributeValue, name string) string {
+	if value, ok := item[name].(*types.AttributeValueMemberN); ok {
+		return value.Value
+	}
+	return ""
+}
+
+func (s *dynamodbStore) putBucket(ctx context.Context, key string, previous, updated bucket, expiresAt time.Time) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]types.AttributeValue{
+			"bucket_key": &types.AttributeValueMemberS{Value: key},
+			"tokens":     &types.AttributeValueMemberN{Value: strconv.FormatFloat(updated.Tokens, 'f', -1, 64)},
+			"updated_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(updated.UpdatedAt.UnixNano(), 10)},
+			"expires_at": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Add(time.Minute).Unix(), 10)},
+		},
+	}
+
+	if previous.UpdatedAt.IsZero() {
+		input.ConditionExpression = aws.String("attribute_not_exists(bucket_key)")
+	} else {
+		input.ConditionExpression = aws.String("updated_at = :u")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":u": &types.AttributeValueMemberN{Value: strconv.FormatInt(previous.UpdatedAt.UnixNano(), 10)},
+		}
+	}
+
+	_, err := s.db.PutItem(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to save the bucket in the database: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/storage/dynamodbtest"
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+// serveTestTable serves the fake with the rate limit table, and returns its endpoint
+func serveTestTable(t *testing.T, fake *dynamodbtest.Fake) string {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s := newTestDynamoDBStore(t, server.URL)
+	_, err := s.db.CreateTable(context.Background(), &dynamodb.CreateTableInput{
+		TableName:            aws.String("rate-limits"),
+		BillingMode:          types.BillingModePayPerRequest,
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String("bucket_key"), KeyType: types.KeyTypeHash}},
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String("bucket_key"), AttributeType: types.ScalarAttributeTypeS}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the rate limit table: %v", err)
+	}
+	return server.URL
+}
+
+// newTestDynamoDBStore returns a store of the rate limit table at the endpoint
+func newTestDynamoDBStore(t *testing.T, endpoint string) *dynamodbStore {
+	t.Helper()
+
+	// The fake accepts any credentials, but the SDK needs some to sign its requests
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	s, err := NewDynamoDBStore("rate-limits", WithEndpoint(endpoint))
+	if err != nil {
+		t.Fatalf("failed to create the store: %v", err)
+	}
+	return s
+}
+
+func TestDynamoDBStore_Take(t *testing.T) {
+	now := time.Date(2021, 2, 14, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Burst: 2, Rate: 0.5}
+	conflict := dynamodbtest.Fault{Status: http.StatusBadRequest, Code: "ConditionalCheckFailedException", Message: "The conditional request failed"}
+
+	type state struct {
+		operation string // The operation whose requests fail, if any
+		fault     dynamodbtest.Fault
+		count     int
+	}
+	type expected struct {
+		result Result
+		puts   int // How many times the bucket is written, counting the two writes before
+		err    error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"A token is taken from the client's bucket": {
+			state{},
+			expected{
+				result: Result{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: 2 * time.Second, Reset: 4 * time.Second},
+				puts:   3,
+			},
+		},
+		"A bucket another container updated in the meantime is read again": {
+			state{operation: "PutItem", fault: conflict, count: 1},
+			expected{
+				result: Result{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: 2 * time.Second, Reset: 4 * time.Second},
+				puts:   4,
+			},
+		},
+		"The bucket keeps changing under the store": {
+			state{operation: "PutItem", fault: conflict, count: -1},
+			expected{puts: 7, err: errors.New("failed to update the bucket 'GET /books|ip:10.0.0.1' after 5 attempts")},
+		},
+		"The bucket can't be read": {
+			state{operation: "GetItem", fault: dynamodbtest.Fault{Status: http.StatusBadRequest, Code: "ResourceNotFoundException", Message: "Requested resource not found"}, count: -1},
+			expected{err: errors.New("failed to retrieve the bucket from the database: ")},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			fake := dynamodbtest.NewFake()
+			s := newTestDynamoDBStore(t, serveTestTable(t, fake))
+			ctx := context.Background()
+
+			// The first two requests spend the bucket, and the injected faults only apply to the last one
+			for i := 0; i < 2; i++ {
+				_, err := s.Take(ctx, "GET /books|ip:10.0.0.1", limit, now)
+				assert.So(err, should.BeNil)
+			}
+			if tc.state.operation != "" {
+				fake.InjectFault(tc.state.operation, tc.state.fault, tc.state.count)
+			}
+
+			result, err := s.Take(ctx, "GET /books|ip:10.0.0.1", limit, now)
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			assert.So(result, should.Resemble, tc.expected.result)
+			if tc.expected.puts > 0 {
+				assert.So(fake.Requests("PutItem"), should.Equal, tc.expected.puts)
+			}
+		})
+	}
+}
+
+// The bucket is shared by every container that uses the table
+func TestDynamoDBStore_shared(t *testing.T) {
+	assert := assertions.New(t)
+	endpoint := serveTestTable(t, dynamodbtest.NewFake())
+	first := newTestDynamoDBStore(t, endpoint)
+	second := newTestDynamoDBStore(t, endpoint)
+	ctx := context.Background()
+	now := time.Date(2021, 2, 14, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Burst: 1, Rate: 0.5}
+
+	result, err := first.Take(ctx, "GET /books|ip:10.0.0.1", limit, now)
+	assert.So(err, should.BeNil)
+	assert.So(result.Allowed, should.BeTrue)
+
+	result, err = second.Take(ctx, "GET /books|ip:10.0.0.1", limit, now)
+	assert.So(err, should.BeNil)
+	assert.So(result.Allowed, should.BeFalse)
+
+	result, err = second.Take(ctx, "GET /books|ip:10.0.0.1", limit, now.Add(2*time.Second))
+	assert.So(err, should.BeNil)
+	assert.So(result.Allowed, should.BeTrue)
+}
@@ -0,0 +1,29 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps buckets in the Lambda container's memory, so limits only hold within a single container
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+}
+
+func NewMemoryStore() *memoryStore {
+	return &memoryStore{
+		buckets: make(map[string]bucket),
+	}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, result := s.buckets[key].take(limit, now)
+	s.buckets[key] = b
+
+	return result, nil
+}
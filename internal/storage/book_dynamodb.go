@@ -2,42 +2,109 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/metrics"
+	"github.com/aaron-zeisler/library-api/internal/tracing"
 )
 
+// dynamodbBooksStorage keeps everything in a single table, so that a change to a book, its copies, a loan
+// and the patron can be written in one transaction. The items are keyed generically, by PK and SK, and
+// each kind of item puts something different in its keys:
+//
+//	Item               PK              SK                     GSI1PK          GSI1SK          GSI2PK       GSI2SK
+//	Book               BOOK#<id>       BOOK                   BOOKS#<shard>   <id>
+//	ISBN reservation   ISBN#<isbn>     ISBN
+//	Copy               BOOK#<book_id>  COPY#<barcode>         COPY#<barcode>  COPY
+//	Hold               BOOK#<book_id>  HOLD#<placed>#<id>                                     HOLDS#ready  <expires_at>
+//	Audit event        BOOK#<book_id>  AUDIT#<time>#<id>      ACTOR#<actor>   <time>#<id>     AUDIT#<date> <time>#<id>
+//	Loan               COPY#<barcode>  LOAN#<checked>#<id>    PATRON#<id>     LOAN#<checked>#<id>   LOANS#open   <due_at>
+//	Patron             PATRON#<id>     PATRON
+//	Ledger entry       PATRON#<id>     LEDGER#<created>#<id>
+//
+// So a book's copies, holds and history are read from its partition, a copy's loans from the copy's, and
+// a patron's account from the patron's. GSI1 lists the books, finds a copy by its barcode, a patron's loans
+// and an actor's changes. The books are spread over a few catalog shards, by a hash of their ID, so that
+// adding and updating them doesn't all fall on one partition of GSI1. GSI2 is sparse: only the holds on
+// the hold shelf and the open loans have its keys, which are removed when they close, and the audit
+// events, which it lists a day at a time.
 type dynamodbBooksStorage struct {
-	awsRegion string
-	tableName string
-	sess      *session.Session
-	db        *dynamodb.DynamoDB
+	awsRegion   string
+	endpoint    string
+	tablePrefix string
+	tableName   string
+	db          dynamodbAPI
+	metrics     metrics.Sink
+	now         func() time.Time
+	retryPolicy RetryPolicy
 }
 
-func NewDynamoDBBooksStorage(opts ...DynamoBooksStorageOption) *dynamodbBooksStorage {
+// dynamodbAPI is the part of the DynamoDB client that the storage calls
+type dynamodbAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+	DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error)
+}
+
+// DefaultTablePrefix prefixes the name of the storage's table unless WithTablePrefix says otherwise
+const DefaultTablePrefix = "library-api"
+
+// NewDynamoDBBooksStorage fails if the AWS configuration, such as the shared config file, can't be loaded
+func NewDynamoDBBooksStorage(opts ...DynamoBooksStorageOption) (*dynamodbBooksStorage, error) {
 	result := &dynamodbBooksStorage{
-		awsRegion: "us-west-1", // Default region is us-west-1
-		tableName: "library-api-books",
+		awsRegion:   "us-west-1", // Default region is us-west-1
+		metrics:     metrics.NewNoopSink(),
+		now:         time.Now,
+		retryPolicy: DefaultRetryPolicy,
 	}
+	WithTablePrefix(DefaultTablePrefix)(result)
 
 	for _, opt := range opts {
 		opt(result)
 	}
+	if result.db != nil {
+		return result, nil
+	}
 
-	awsConfig := &aws.Config{
-		Region: aws.String(result.awsRegion),
+	awsConfig, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(result.awsRegion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the AWS configuration: %w", err)
 	}
 
-	result.sess = session.Must(session.NewSession(awsConfig))
-	result.db = dynamodb.New(result.sess)
+	result.db = dynamodb.NewFromConfig(awsConfig, func(o *dynamodb.Options) {
+		// The policy alone decides how many attempts a call makes
+		o.Retryer = retryer{policy: result.retryPolicy}
+		o.RetryMaxAttempts = 0
+		if result.endpoint != "" {
+			o.BaseEndpoint = aws.String(result.endpoint)
+		}
+	})
 
-	return result
+	return result, nil
 }
 
 type DynamoBooksStorageOption func(*dynamodbBooksStorage)
@@ -48,42 +115,383 @@ func WithAWSRegion(awsRegion string) DynamoBooksStorageOption {
 	}
 }
 
-func (s *dynamodbBooksStorage) GetBooks(ctx context.Context) ([]internal.Book, error) {
-	result := make([]internal.Book, 0)
+// WithEndpoint points the storage at a DynamoDB other than AWS's, such as DynamoDB Local
+func WithEndpoint(endpoint string) DynamoBooksStorageOption {
+	return func(db *dynamodbBooksStorage) {
+		db.endpoint = endpoint
+	}
+}
+
+// WithClient makes the storage call DynamoDB through the client instead of one of its own, such as a fake
+// in a unit test. The client is used as it is: the storage's region, endpoint and retry policy don't apply
+// to it.
+func WithClient(client dynamodbAPI) DynamoBooksStorageOption {
+	return func(db *dynamodbBooksStorage) {
+		db.db = client
+	}
+}
+
+// WithTablePrefix names the table <prefix>-library, so that more than one library, or test, can share an
+// account
+func WithTablePrefix(prefix string) DynamoBooksStorageOption {
+	return func(db *dynamodbBooksStorage) {
+		db.tablePrefix = prefix
+		db.tableName = prefix + "-library"
+	}
+}
+
+func WithMetrics(sink metrics.Sink) DynamoBooksStorageOption {
+	return func(db *dynamodbBooksStorage) {
+		db.metrics = sink
+	}
+}
+
+// WithClock sets the clock used to timestamp changes to the books
+func WithClock(now func() time.Time) DynamoBooksStorageOption {
+	return func(db *dynamodbBooksStorage) {
+		db.now = now
+	}
+}
+
+// instrument starts a span for a DynamoDB call. The returned function ends the span, emits the call's
+// latency and the capacity it consumed, and returns the call's error classified by whether it may pass if
+// it's retried later.
+func (s *dynamodbBooksStorage) instrument(ctx context.Context, operation, bookID string) (context.Context, func(*types.ConsumedCapacity, error) error) {
+	start := time.Now()
+
+	attributes := []attribute.KeyValue{
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", operation),
+		attribute.String("aws.dynamodb.table_names", s.tableName),
+	}
+	if bookID != "" {
+		attributes = append(attributes, attribute.String("book_id", bookID))
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "dynamodb."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+
+	return ctx, func(capacity *types.ConsumedCapacity, err error) error {
+		err = s.classifyError(err)
+
+		dimensions := map[string]string{"Table": s.tableName, "Operation": operation}
+		data := []metrics.Datum{metrics.Duration("DynamoDBLatency", start, dimensions)}
+		if capacity != nil {
+			data = append(data, metrics.Value("DynamoDBConsumedCapacity", metrics.None, aws.ToFloat64(capacity.CapacityUnits), dimensions))
+			span.SetAttributes(attribute.Float64("aws.dynamodb.consumed_capacity", aws.ToFloat64(capacity.CapacityUnits)))
+		}
+		var retryable internal.ErrRetryable
+		if errors.As(err, &retryable) && retryable.Throttled {
+			data = append(data, metrics.Counter("DynamoDBThrottled", dimensions))
+		}
+		s.metrics.Emit(data...)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		return err
+	}
+}
+
+// Probe verifies that the table can be reached and is ready to serve requests. DescribeTable doesn't
+// consume any read capacity.
+func (s *dynamodbBooksStorage) Probe(ctx context.Context) error {
+	callCtx, done := s.instrument(ctx, "DescribeTable", "")
+	dbResult, err := s.db.DescribeTable(callCtx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(s.tableName),
+	}, retryUntil(callCtx))
+	err = done(nil, err)
+	if err != nil {
+		return fmt.Errorf("failed to describe the library table: %w", err)
+	}
+
+	if tableStatus := dbResult.Table.TableStatus; tableStatus != types.TableStatusActive && tableStatus != types.TableStatusUpdating {
+		return fmt.Errorf("the library table is %s", tableStatus)
+	}
+
+	return nil
+}
+
+// The keys of the table's items. See dynamodbBooksStorage for how each kind of item uses them.
+const (
+	bookPrefix   = "BOOK#"
+	isbnPrefix   = "ISBN#"
+	copyPrefix   = "COPY#"
+	holdPrefix   = "HOLD#"
+	auditPrefix  = "AUDIT#"
+	loanPrefix   = "LOAN#"
+	patronPrefix = "PATRON#"
+	ledgerPrefix = "LEDGER#"
+	actorPrefix  = "ACTOR#"
+
+	bookSortKey   = "BOOK"
+	isbnSortKey   = "ISBN"
+	copySortKey   = "COPY"
+	patronSortKey = "PATRON"
+
+	catalogPrefix       = "BOOKS#"
+	readyHoldsPartition = "HOLDS#ready"
+	openLoansPartition  = "LOANS#open"
+)
+
+// catalogShards is how many partitions of GSI1 the books are spread over. Changing it moves books between
+// shards, so their items have to be rewritten.
+const catalogShards = 8
+
+// catalogShard is the partition of GSI1 that lists the book
+func catalogShard(bookID string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(bookID))
+	return fmt.Sprintf("%s%d", catalogPrefix, hash.Sum32()%catalogShards)
+}
+
+// catalogKeys are the key conditions of every catalog shard
+func catalogKeys() []expression.KeyConditionBuilder {
+	result := make([]expression.KeyConditionBuilder, 0, catalogShards)
+	for i := 0; i < catalogShards; i++ {
+		result = append(result, expression.Key("GSI1PK").Equal(expression.Value(fmt.Sprintf("%s%d", catalogPrefix, i))))
+	}
+	return result
+}
+
+// itemKeys are the keys an item is stored under, added to the attributes of what it stores
+type itemKeys struct {
+	PK     string `json:"PK"`
+	SK     string `json:"SK"`
+	GSI1PK string `json:"GSI1PK,omitempty"`
+	GSI1SK string `json:"GSI1SK,omitempty"`
+	GSI2PK string `json:"GSI2PK,omitempty"`
+	GSI2SK string `json:"GSI2SK,omitempty"`
+}
+
+// marshalItem stores the value's fields under the names of their json tags, like the models are served
+func marshalItem(value interface{}) (map[string]types.AttributeValue, error) {
+	return attributevalue.MarshalMapWithOptions(value, func(o *attributevalue.EncoderOptions) {
+		o.TagKey = "json"
+	})
+}
+
+func unmarshalItem(item map[string]types.AttributeValue, value interface{}) error {
+	return attributevalue.UnmarshalMapWithOptions(item, value, func(o *attributevalue.DecoderOptions) {
+		o.TagKey = "json"
+	})
+}
+
+func unmarshalItems(items []map[string]types.AttributeValue, value interface{}) error {
+	return attributevalue.UnmarshalListOfMapsWithOptions(items, value, func(o *attributevalue.DecoderOptions) {
+		o.TagKey = "json"
+	})
+}
+
+func itemKey(pk, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: pk},
+		"SK": &types.AttributeValueMemberS{Value: sk},
+	}
+}
 
-	dbResult, err := s.db.ScanWithContext(ctx, &dynamodb.ScanInput{
-		TableName: aws.String("library-api-books"),
+func bookKey(bookID string) map[string]types.AttributeValue {
+	return itemKey(bookPrefix+bookID, bookSortKey)
+}
+
+// The conditions that most writes are made on
+var (
+	// itemNotExists is met by a write that creates an item, rather than overwrites one
+	itemNotExists = expression.AttributeNotExists(expression.Name("PK"))
+	// bookInCatalog is met by a book that exists and isn't in the trash
+	bookInCatalog = expression.AttributeExists(expression.Name("PK")).And(expression.AttributeNotExists(expression.Name("deleted_at")))
+)
+
+// bookItem is how a book is stored. Every book is in its catalog shard of GSI1, in the trash or not, so
+// the listings are a query of each shard, merged in ID order.
+func bookItem(book internal.Book) (map[string]types.AttributeValue, error) {
+	item, err := marshalItem(struct {
+		internal.Book
+		itemKeys
+	}{
+		Book:     book,
+		itemKeys: itemKeys{PK: bookPrefix + book.ID, SK: bookSortKey, GSI1PK: catalogShard(book.ID), GSI1SK: book.ID},
 	})
 	if err != nil {
-		return result, fmt.Errorf("failed to retrieve all the books from the database: %w", err)
+		return nil, fmt.Errorf("failed to marshal the book: %w", err)
 	}
+	return withoutEmptyIndexKeys(item), nil
+}
 
-	err = dynamodbattribute.UnmarshalListOfMaps(dbResult.Items, &result)
+// queryInput builds a query of the table, or of the index if one is named, with the builder's key
+// condition, filter and projection
+func (s *dynamodbBooksStorage) queryInput(index string, builder expression.Builder) (*dynamodb.QueryInput, error) {
+	expr, err := builder.Build()
 	if err != nil {
-		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", err)
+		return nil, fmt.Errorf("failed to build the query: %w", err)
 	}
 
+	result := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ProjectionExpression:      expr.Projection(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+	if index != "" {
+		result.IndexName = aws.String(index)
+	}
 	return result, nil
 }
 
-func (s *dynamodbBooksStorage) GetBookByID(ctx context.Context, bookID string) (internal.Book, error) {
-	result := internal.Book{}
+// queryPages calls fn with each page of the query's results, until there are no more or it returns false
+func (s *dynamodbBooksStorage) queryPages(ctx context.Context, input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput) bool) error {
+	paginator := dynamodb.NewQueryPaginator(s.db, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, retryUntil(ctx))
+		if err != nil {
+			return err
+		}
+		if !fn(page) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// scanPages calls fn with each page of the scan's results, until there are no more or it returns false
+func (s *dynamodbBooksStorage) scanPages(ctx context.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput) bool) error {
+	paginator := dynamodb.NewScanPaginator(s.db, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, retryUntil(ctx))
+		if err != nil {
+			return err
+		}
+		if !fn(page) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// GetBooks returns the books that match the filter, ordered by ID
+func (s *dynamodbBooksStorage) GetBooks(ctx context.Context, filter internal.BookFilter) ([]internal.Book, error) {
+	condition := expression.AttributeNotExists(expression.Name("deleted_at"))
+	if !filter.UpdatedSince.IsZero() {
+		// The timestamps are stored as RFC 3339 strings, whose fractional seconds don't sort lexically, so
+		// DynamoDB only filters to the second before and the exact comparison is made below
+		since := filter.UpdatedSince.UTC().Truncate(time.Second).Add(-time.Second).Format(time.RFC3339)
+		condition = expression.Name("updated_at").GreaterThanEqual(expression.Value(since))
+	}
+	index, keys := bookListingKeys(filter)
+	books, err := s.queryCatalog(ctx, index, keys, condition)
+	if err != nil {
+		return books, fmt.Errorf("failed to retrieve all the books from the database: %w", err)
+	}
+
+	result := make([]internal.Book, 0, len(books))
+	for _, book := range books {
+		if filter.Matches(book) {
+			result = append(result, book)
+		}
+	}
+	return result, nil
+}
+
+// bookListingKeys picks the index that narrows the filter's listing down the most: a book by its ISBN, an
+// author's books, or the books with a status. Any other listing is of every catalog shard.
+func bookListingKeys(filter internal.BookFilter) (string, []expression.KeyConditionBuilder) {
+	switch {
+	case filter.ISBN != "":
+		return isbnIndex, []expression.KeyConditionBuilder{expression.Key("isbn").Equal(expression.Value(filter.ISBN))}
+	case filter.Author != "":
+		return authorIndex, []expression.KeyConditionBuilder{expression.Key("author").Equal(expression.Value(filter.Author))}
+	case filter.Status != "":
+		return statusIndex, []expression.KeyConditionBuilder{expression.Key("book_status").Equal(expression.Value(string(filter.Status)))}
+	}
+	return gsi1Index, catalogKeys()
+}
+
+// queryCatalog reads the books in the index's partitions with the keys that meet the condition, in ID order
+func (s *dynamodbBooksStorage) queryCatalog(ctx context.Context, index string, keys []expression.KeyConditionBuilder, condition expression.ConditionBuilder) ([]internal.Book, error) {
+	result := make([]internal.Book, 0)
+	for _, key := range keys {
+		input, err := s.queryInput(index, expression.NewBuilder().WithKeyCondition(key).WithFilter(condition))
+		if err != nil {
+			return result, err
+		}
+		input.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
+
+		books, err := s.queryBooks(ctx, input)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, books...)
+	}
+
+	sortBooks(result)
+	return result, nil
+}
+
+// queryBooks reads every page of a query of the catalog
+func (s *dynamodbBooksStorage) queryBooks(ctx context.Context, input *dynamodb.QueryInput) ([]internal.Book, error) {
+	result := make([]internal.Book, 0)
+
+	var (
+		capacities   []types.ConsumedCapacity
+		unmarshalErr error
+	)
+	callCtx, done := s.instrument(ctx, "Query", "")
+	err := s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		if page.ConsumedCapacity != nil {
+			capacities = append(capacities, *page.ConsumedCapacity)
+		}
 
-	key, err := dynamodbattribute.MarshalMap(map[string]string{"id": bookID})
+		books := make([]internal.Book, 0)
+		unmarshalErr = unmarshalItems(page.Items, &books)
+		result = append(result, books...)
+		return unmarshalErr == nil
+	})
+	err = done(totalCapacity(capacities), err)
+	if err != nil {
+		return result, err
+	}
+	if unmarshalErr != nil {
+		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", unmarshalErr)
+	}
+
+	return result, nil
+}
+
+func (s *dynamodbBooksStorage) GetBookByID(ctx context.Context, bookID string) (internal.Book, error) {
+	result, err := s.getBook(ctx, bookID)
 	if err != nil {
-		return result, fmt.Errorf("failed to marshal the bookID into a dynamo key: %w", err)
+		return internal.Book{}, err
+	}
+
+	if result.IsDeleted() {
+		return internal.Book{}, internal.ErrBookNotFound{BookID: bookID}
 	}
 
-	dbResult, err := s.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{TableName: aws.String(s.tableName), Key: key})
+	return result, nil
+}
+
+// getBook retrieves the book whether or not it's in the trash
+func (s *dynamodbBooksStorage) getBook(ctx context.Context, bookID string) (internal.Book, error) {
+	result := internal.Book{}
+
+	callCtx, done := s.instrument(ctx, "GetItem", bookID)
+	dbResult, err := s.db.GetItem(callCtx, &dynamodb.GetItemInput{
+		TableName:              aws.String(s.tableName),
+		Key:                    bookKey(bookID),
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}, retryUntil(callCtx))
 	if err != nil {
-		return result, fmt.Errorf("failed to retrieve the book from the database: %w", err)
+		return result, fmt.Errorf("failed to retrieve the book from the database: %w", done(nil, err))
 	}
+	done(dbResult.ConsumedCapacity, nil)
 
 	if len(dbResult.Item) == 0 {
 		return result, internal.ErrBookNotFound{BookID: bookID}
 	}
 
-	err = dynamodbattribute.UnmarshalMap(dbResult.Item, &result)
+	err = unmarshalItem(dbResult.Item, &result)
 	if err != nil {
 		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", err)
 	}
@@ -94,7 +502,7 @@ func (s *dynamodbBooksStorage) GetBookByID(ctx context.Context, bookID string) (
 func (s *dynamodbBooksStorage) CreateBook(ctx context.Context, title, author, isbn, description string) (internal.Book, error) {
 	result := internal.Book{}
 
-	//TODO: Don't INSERT a book if the ISBN alreaedy exists in the database!
+	now, actor := s.timestamp(), internal.ActorFromContext(ctx)
 	newBook := internal.Book{
 		ID:          uuid.New().String(),
 		ISBN:        isbn,
@@ -102,16 +510,40 @@ func (s *dynamodbBooksStorage) CreateBook(ctx context.Context, title, author, is
 		Author:      author,
 		Description: description,
 		Status:      internal.CheckedIn,
+		CreatedAt:   now,
+		CreatedBy:   actor,
+		UpdatedAt:   now,
+		UpdatedBy:   actor,
+	}
+	item, err := bookItem(newBook)
+	if err != nil {
+		return result, err
 	}
-	item, err := dynamodbattribute.MarshalMap(newBook)
+	put, err := s.put(item, itemNotExists)
 	if err != nil {
-		return result, fmt.Errorf("failed to marshal the bookID into a dynamo key: %w", err)
+		return result, err
 	}
 
-	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(s.tableName),
-		Item:      item,
-	})
+	auditItem, err := s.auditItem(ctx, newBook.ID, nil, &newBook)
+	if err != nil {
+		return result, err
+	}
+
+	items := []types.TransactWriteItem{put, auditItem}
+	reservation := -1
+	if isbn != "" {
+		reserve, err := s.reserveISBN(newBook.ID, isbn)
+		if err != nil {
+			return result, err
+		}
+		reservation = len(items)
+		items = append(items, reserve)
+	}
+
+	err = s.transactWrite(ctx, newBook.ID, items)
+	if failedCondition(err, reservation) {
+		return result, internal.ErrDuplicateISBN{ISBN: isbn}
+	}
 	if err != nil {
 		return result, fmt.Errorf("failed to create the new book in the database: %w", err)
 	}
@@ -122,77 +554,1495 @@ func (s *dynamodbBooksStorage) CreateBook(ctx context.Context, title, author, is
 func (s *dynamodbBooksStorage) UpdateBook(ctx context.Context, bookID string, book internal.Book) (internal.Book, error) {
 	result := internal.Book{}
 
-	// Attempt to retrieve the book to verify that it exists before updating
-	_, err := s.GetBookByID(ctx, bookID)
+	// Retrieve the book to verify that it exists, and to record its previous state in the audit log
+	before, err := s.GetBookByID(ctx, bookID)
 	if err != nil {
 		return result, err
 	}
 
-	key, err := dynamodbattribute.MarshalMap(map[string]string{"id": bookID})
+	now, actor := s.timestamp(), internal.ActorFromContext(ctx)
+	changes := expression.Set(expression.Name("description"), expression.Value(book.Description)).
+		Set(expression.Name("updated_at"), expression.Value(now.Format(time.RFC3339Nano))).
+		Set(expression.Name("updated_by"), expression.Value(actor))
+
+	// An empty index key is removed rather than set to an empty string
+	for _, attribute := range []struct{ name, value string }{
+		{"isbn", book.ISBN}, {"title", book.Title}, {"author", book.Author}, {"book_status", string(book.Status)},
+	} {
+		if attribute.value == "" {
+			changes = changes.Remove(expression.Name(attribute.name))
+		} else {
+			changes = changes.Set(expression.Name(attribute.name), expression.Value(attribute.value))
+		}
+	}
+
+	update, err := s.update(bookKey(bookID), expression.NewBuilder().WithUpdate(changes).WithCondition(bookInCatalog))
 	if err != nil {
-		return result, fmt.Errorf("failed to marshal the bookID into a dynamo key: %w", err)
+		return result, err
 	}
 
-	bookUpdates := struct {
-		Title       string `json:":t"`
-		Author      string `json:":a"`
-		ISBN        string `json:":i"`
-		Description string `json:":d"`
-		Status      string `json:":s"`
-	}{
+	after := internal.Book{
+		ID:          bookID,
 		Title:       book.Title,
 		Author:      book.Author,
 		ISBN:        book.ISBN,
 		Description: book.Description,
-		Status:      string(book.Status),
+		Status:      book.Status,
+		CreatedAt:   before.CreatedAt,
+		CreatedBy:   before.CreatedBy,
+		UpdatedAt:   now,
+		UpdatedBy:   actor,
 	}
-	updates, err := dynamodbattribute.MarshalMap(bookUpdates)
+	auditItem, err := s.auditItem(ctx, bookID, &before, &after)
 	if err != nil {
-		return result, fmt.Errorf("failed to marshal the bookID into a dynamo key: %w", err)
+		return result, err
 	}
 
-	dbResult, err := s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(s.tableName),
-		Key:                       key,
-		UpdateExpression:          aws.String("SET isbn=:i, title=:t, author=:a, description=:d, book_status=:s"),
-		ExpressionAttributeValues: updates,
-		ReturnValues:              aws.String("ALL_NEW"),
-	})
+	items := []types.TransactWriteItem{{Update: update}, auditItem}
+	reservation := -1
+	if book.ISBN != before.ISBN {
+		if before.ISBN != "" {
+			items = append(items, s.releaseISBN(before.ISBN))
+		}
+		if book.ISBN != "" {
+			reserve, err := s.reserveISBN(bookID, book.ISBN)
+			if err != nil {
+				return result, err
+			}
+			reservation = len(items)
+			items = append(items, reserve)
+		}
+	}
+
+	err = s.transactWrite(ctx, bookID, items)
+	if failedCondition(err, reservation) {
+		return result, internal.ErrDuplicateISBN{ISBN: book.ISBN}
+	}
+	if isConditionalCheckFailure(err) { // The book was deleted since it was retrieved
+		return result, internal.ErrBookNotFound{BookID: bookID}
+	}
 	if err != nil {
 		return result, fmt.Errorf("failed to update the book in the database: %w", err)
 	}
 
-	if len(dbResult.Attributes) == 0 {
-		return result, internal.ErrBookNotFound{BookID: bookID}
+	return after, nil
+}
+
+// DeleteBook moves the book to the trash by setting its deleted_at attribute. It stays there until it's
+// restored or purged.
+func (s *dynamodbBooksStorage) DeleteBook(ctx context.Context, bookID string) error {
+	// Retrieve the book to verify that it exists, and to record its last state in the audit log
+	before, err := s.GetBookByID(ctx, bookID)
+	if err != nil {
+		return err
 	}
 
-	err = dynamodbattribute.UnmarshalMap(dbResult.Attributes, &result)
+	after := before
+	deletedAt := s.timestamp()
+	after.DeletedAt = &deletedAt
+	after.UpdatedAt = deletedAt
+	after.UpdatedBy = internal.ActorFromContext(ctx)
+
+	deleted := expression.Value(deletedAt.Format(time.RFC3339Nano))
+	err = s.transactUpdate(ctx, before, after, expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("deleted_at"), deleted).
+			Set(expression.Name("updated_at"), deleted).
+			Set(expression.Name("updated_by"), expression.Value(after.UpdatedBy))).
+		WithCondition(bookInCatalog))
+	if isConditionalCheckFailure(err) { // The book was deleted since it was retrieved
+		return internal.ErrBookNotFound{BookID: bookID}
+	}
 	if err != nil {
-		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", err)
+		return fmt.Errorf("failed to delete the book from the database: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeletedBooks returns the books in the trash, ordered by ID
+func (s *dynamodbBooksStorage) GetDeletedBooks(ctx context.Context) ([]internal.Book, error) {
+	result, err := s.queryCatalog(ctx, gsi1Index, catalogKeys(), expression.AttributeExists(expression.Name("deleted_at")))
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve the deleted books from the database: %w", err)
 	}
 
 	return result, nil
 }
 
-func (s *dynamodbBooksStorage) DeleteBook(ctx context.Context, bookID string) error {
-	// Attempt to retrieve the book to verify that it exists before deleting
-	_, err := s.GetBookByID(ctx, bookID)
+func (s *dynamodbBooksStorage) RestoreBook(ctx context.Context, bookID string) (internal.Book, error) {
+	before, err := s.getBook(ctx, bookID)
+	if err != nil {
+		return internal.Book{}, err
+	}
+	if !before.IsDeleted() {
+		return internal.Book{}, internal.ErrBookNotFound{BookID: bookID}
+	}
+
+	after := before
+	after.DeletedAt = nil
+	after.UpdatedAt = s.timestamp()
+	after.UpdatedBy = internal.ActorFromContext(ctx)
+
+	err = s.transactUpdate(ctx, before, after, expression.NewBuilder().
+		WithUpdate(expression.Remove(expression.Name("deleted_at")).
+			Set(expression.Name("updated_at"), expression.Value(after.UpdatedAt.Format(time.RFC3339Nano))).
+			Set(expression.Name("updated_by"), expression.Value(after.UpdatedBy))).
+		WithCondition(expression.AttributeExists(expression.Name("deleted_at"))))
+	if isConditionalCheckFailure(err) { // The book was restored or purged since it was retrieved
+		return internal.Book{}, internal.ErrBookNotFound{BookID: bookID}
+	}
+	if err != nil {
+		return internal.Book{}, fmt.Errorf("failed to restore the book in the database: %w", err)
+	}
+
+	return after, nil
+}
+
+// PurgeBook permanently removes the book, whether or not it's in the trash, with its copies, holds and loans
+func (s *dynamodbBooksStorage) PurgeBook(ctx context.Context, bookID string) error {
+	before, err := s.getBook(ctx, bookID)
 	if err != nil {
 		return err
 	}
 
-	key, err := dynamodbattribute.MarshalMap(map[string]string{"id": bookID})
+	// The book's copies, holds and loans are deleted first, as many at a time as DynamoDB accepts, since
+	// there may be more of them than a transaction can hold. A purge that's interrupted leaves the book in
+	// the trash, and purging it again deletes the rest.
+	keys, openLoans, err := s.bookItemKeys(ctx, bookID)
+	if err != nil {
+		return err
+	}
+	for _, loan := range openLoans {
+		err = s.deleteOpenLoan(ctx, loan)
+		if err != nil {
+			return fmt.Errorf("failed to purge the open loan from the database: %w", err)
+		}
+	}
+	err = s.batchDelete(ctx, keys)
 	if err != nil {
-		return fmt.Errorf("failed to marshal the bookID into a dynamo key: %w", err)
+		return fmt.Errorf("failed to purge the book's copies, holds and loans from the database: %w", err)
 	}
 
-	_, err = s.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String(s.tableName),
-		Key:       key,
-	})
+	auditItem, err := s.auditItem(ctx, bookID, &before, nil)
 	if err != nil {
-		return fmt.Errorf("failed to delete the book from the database: %w", err)
+		return err
+	}
+
+	exists, err := expression.NewBuilder().WithCondition(expression.AttributeExists(expression.Name("PK"))).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build the condition: %w", err)
+	}
+	items := []types.TransactWriteItem{
+		{Delete: &types.Delete{
+			TableName:                aws.String(s.tableName),
+			Key:                      bookKey(bookID),
+			ConditionExpression:      exists.Condition(),
+			ExpressionAttributeNames: exists.Names(),
+		}},
+		auditItem,
+	}
+	if before.ISBN != "" {
+		items = append(items, s.releaseISBN(before.ISBN))
+	}
+
+	err = s.transactWrite(ctx, bookID, items)
+	if isConditionalCheckFailure(err) { // The book was purged since it was retrieved
+		return internal.ErrBookNotFound{BookID: bookID}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to purge the book from the database: %w", err)
 	}
 
 	return nil
 }
+
+// bookItemKeys returns the keys of the book's copies and holds, and of its copies' closed loans, with the
+// copies' open loans. The book's audit events are kept.
+func (s *dynamodbBooksStorage) bookItemKeys(ctx context.Context, bookID string) ([]map[string]types.AttributeValue, []internal.Loan, error) {
+	copies, err := s.GetCopies(ctx, bookID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys, err := s.queryKeys(ctx, bookID, expression.Key("PK").Equal(expression.Value(bookPrefix+bookID)).
+		And(expression.Key("SK").BeginsWith(holdPrefix)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve the book's holds from the database: %w", err)
+	}
+
+	open := make([]internal.Loan, 0)
+	for _, bookCopy := range copies {
+		keys = append(keys, copyKey(bookCopy))
+
+		input, err := s.queryInput("", expression.NewBuilder().
+			WithKeyCondition(expression.Key("PK").Equal(expression.Value(copyPrefix+bookCopy.Barcode)).And(expression.Key("SK").BeginsWith(loanPrefix))))
+		if err != nil {
+			return nil, nil, err
+		}
+		input.ConsistentRead = aws.Bool(true)
+		loans, err := s.queryLoans(ctx, bookID, input)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to retrieve the copy's loans from the database: %w", err)
+		}
+		for _, loan := range loans {
+			if loan.IsOpen() {
+				open = append(open, loan)
+			} else {
+				keys = append(keys, loanItemKey(loan))
+			}
+		}
+	}
+	return keys, open, nil
+}
+
+// deleteOpenLoan deletes the loan and takes it off the patron's count of open loans
+func (s *dynamodbBooksStorage) deleteOpenLoan(ctx context.Context, loan internal.Loan) error {
+	count, err := s.countLoan(loan.PatronID, -1)
+	if err != nil {
+		return err
+	}
+	return s.transactWrite(ctx, loan.BookID, []types.TransactWriteItem{
+		{Delete: &types.Delete{TableName: aws.String(s.tableName), Key: loanItemKey(loan)}},
+		count,
+	})
+}
+
+// queryKeys returns the keys of the items in the table that the key condition matches
+func (s *dynamodbBooksStorage) queryKeys(ctx context.Context, bookID string, key expression.KeyConditionBuilder) ([]map[string]types.AttributeValue, error) {
+	input, err := s.queryInput("", expression.NewBuilder().
+		WithKeyCondition(key).
+		WithProjection(expression.NamesList(expression.Name("PK"), expression.Name("SK"))))
+	if err != nil {
+		return nil, err
+	}
+	input.ConsistentRead = aws.Bool(true)
+
+	result := make([]map[string]types.AttributeValue, 0)
+	callCtx, done := s.instrument(ctx, "Query", bookID)
+	err = s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		result = append(result, page.Items...)
+		return true
+	})
+	return result, done(nil, err)
+}
+
+// PurgeDeletedBooks permanently removes the books that were moved to the trash before the cutoff
+func (s *dynamodbBooksStorage) PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int, error) {
+	deletedBooks, err := s.GetDeletedBooks(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, book := range deletedBooks {
+		if !book.DeletedAt.Before(cutoff) {
+			continue
+		}
+
+		err = s.PurgeBook(ctx, book.ID)
+		if err != nil && !errors.As(err, &internal.ErrBookNotFound{}) {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// timestamp reads the storage's clock in UTC
+func (s *dynamodbBooksStorage) timestamp() time.Time {
+	return s.now().UTC()
+}
+
+// put builds the write that puts the item in the table, as long as the condition is met
+func (s *dynamodbBooksStorage) put(item map[string]types.AttributeValue, condition expression.ConditionBuilder) (types.TransactWriteItem, error) {
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		return types.TransactWriteItem{}, fmt.Errorf("failed to build the condition: %w", err)
+	}
+
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName:                 aws.String(s.tableName),
+		Item:                      item,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}}, nil
+}
+
+// update builds the write that applies the builder's update to the item, as long as the builder's
+// condition, if any, is met
+func (s *dynamodbBooksStorage) update(key map[string]types.AttributeValue, builder expression.Builder) (*types.Update, error) {
+	expr, err := builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the update: %w", err)
+	}
+
+	return &types.Update{
+		TableName:                 aws.String(s.tableName),
+		Key:                       key,
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}, nil
+}
+
+// A book's ISBN is reserved by an item keyed by the ISBN, which is written in the same transaction as the
+// book. The reservation's condition fails if another book already has the ISBN. A book in the trash keeps
+// its ISBN, since it may be restored, until it's purged.
+func isbnReservation(bookID, isbn string) map[string]types.AttributeValue {
+	item := itemKey(isbnPrefix+isbn, isbnSortKey)
+	item["book_id"] = &types.AttributeValueMemberS{Value: bookID}
+	return item
+}
+
+// reserveISBN builds the write that reserves the ISBN for the book
+func (s *dynamodbBooksStorage) reserveISBN(bookID, isbn string) (types.TransactWriteItem, error) {
+	return s.put(isbnReservation(bookID, isbn), itemNotExists)
+}
+
+// releaseISBN builds the write that frees the ISBN for another book
+func (s *dynamodbBooksStorage) releaseISBN(isbn string) types.TransactWriteItem {
+	return types.TransactWriteItem{Delete: &types.Delete{
+		TableName: aws.String(s.tableName),
+		Key:       itemKey(isbnPrefix+isbn, isbnSortKey),
+	}}
+}
+
+// transactUpdate applies the builder's update to the book, on its condition, and records the change from
+// before to after in the audit log, in a single transaction
+func (s *dynamodbBooksStorage) transactUpdate(ctx context.Context, before, after internal.Book, builder expression.Builder) error {
+	update, err := s.update(bookKey(before.ID), builder)
+	if err != nil {
+		return err
+	}
+
+	auditItem, err := s.auditItem(ctx, before.ID, &before, &after)
+	if err != nil {
+		return err
+	}
+
+	return s.transactWrite(ctx, before.ID, []types.TransactWriteItem{{Update: update}, auditItem})
+}
+
+// copyItem is how a copy is stored: in its book's partition, so a book's holdings are read with a single
+// query, and under its barcode in GSI1, which finds the copy scanned at the desk
+func copyItem(bookCopy internal.Copy) (map[string]types.AttributeValue, error) {
+	item, err := marshalItem(struct {
+		internal.Copy
+		itemKeys
+	}{
+		Copy: bookCopy,
+		itemKeys: itemKeys{
+			PK:     bookPrefix + bookCopy.BookID,
+			SK:     copyPrefix + bookCopy.Barcode,
+			GSI1PK: copyPrefix + bookCopy.Barcode,
+			GSI1SK: copySortKey,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the copy: %w", err)
+	}
+	return item, nil
+}
+
+func copyKey(bookCopy internal.Copy) map[string]types.AttributeValue {
+	return itemKey(bookPrefix+bookCopy.BookID, copyPrefix+bookCopy.Barcode)
+}
+
+func (s *dynamodbBooksStorage) GetCopies(ctx context.Context, bookID string) ([]internal.Copy, error) {
+	result := make([]internal.Copy, 0)
+
+	input, err := s.queryInput("", expression.NewBuilder().
+		WithKeyCondition(expression.Key("PK").Equal(expression.Value(bookPrefix+bookID)).And(expression.Key("SK").BeginsWith(copyPrefix))))
+	if err != nil {
+		return result, err
+	}
+	input.ConsistentRead = aws.Bool(true)
+
+	var unmarshalErr error
+	callCtx, done := s.instrument(ctx, "Query", bookID)
+	err = s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		copies := make([]internal.Copy, 0)
+		unmarshalErr = unmarshalItems(page.Items, &copies)
+		result = append(result, copies...)
+		return unmarshalErr == nil
+	})
+	err = done(nil, err)
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve the book's copies from the database: %w", err)
+	}
+	if unmarshalErr != nil {
+		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", unmarshalErr)
+	}
+
+	return result, nil
+}
+
+func (s *dynamodbBooksStorage) GetCopyByBarcode(ctx context.Context, barcode string) (internal.Copy, error) {
+	result := internal.Copy{}
+
+	input, err := s.queryInput(gsi1Index, expression.NewBuilder().
+		WithKeyCondition(expression.Key("GSI1PK").Equal(expression.Value(copyPrefix+barcode)).And(expression.Key("GSI1SK").Equal(expression.Value(copySortKey)))))
+	if err != nil {
+		return result, err
+	}
+	input.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
+
+	callCtx, done := s.instrument(ctx, "Query", "")
+	dbResult, err := s.db.Query(callCtx, input, retryUntil(callCtx))
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve the copy from the database: %w", done(nil, err))
+	}
+	done(dbResult.ConsumedCapacity, nil)
+
+	if len(dbResult.Items) == 0 {
+		return result, internal.ErrCopyNotFound{Barcode: barcode}
+	}
+
+	err = unmarshalItem(dbResult.Items[0], &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", err)
+	}
+
+	return result, nil
+}
+
+// AddCopy adds a checked in copy to the book's holdings. GSI1 is only eventually consistent, so two copies
+// added at the same moment with the same barcode aren't detected.
+func (s *dynamodbBooksStorage) AddCopy(ctx context.Context, bookCopy internal.Copy) (internal.Copy, error) {
+	_, err := s.GetCopyByBarcode(ctx, bookCopy.Barcode)
+	if err == nil {
+		return internal.Copy{}, internal.ErrDuplicateBarcode{Barcode: bookCopy.Barcode}
+	}
+	if !errors.As(err, &internal.ErrCopyNotFound{}) {
+		return internal.Copy{}, err
+	}
+
+	bookCopy.Status = internal.CheckedIn
+	bookCopy.UpdatedAt = s.timestamp()
+
+	item, err := copyItem(bookCopy)
+	if err != nil {
+		return internal.Copy{}, err
+	}
+	put, err := s.put(item, itemNotExists)
+	if err != nil {
+		return internal.Copy{}, err
+	}
+
+	err = s.saveCopy(ctx, internal.AuditAddCopy, bookCopy, put)
+	if isConditionalCheckFailure(err) { // The book was deleted, or the barcode was added, since they were read
+		return internal.Copy{}, internal.ErrBookNotFound{BookID: bookCopy.BookID}
+	}
+	if err != nil {
+		return internal.Copy{}, fmt.Errorf("failed to add the copy to the database: %w", err)
+	}
+
+	return bookCopy, nil
+}
+
+// UpdateCopyStatus changes the copy's status from one status to another, and fails with
+// ErrCopyStatusConflict if the copy's status isn't the expected one
+func (s *dynamodbBooksStorage) UpdateCopyStatus(ctx context.Context, barcode string, from, to internal.BookStatus) (internal.Copy, error) {
+	bookCopy, err := s.GetCopyByBarcode(ctx, barcode)
+	if err != nil {
+		return internal.Copy{}, err
+	}
+	if bookCopy.Status != from {
+		return internal.Copy{}, internal.ErrCopyStatusConflict{Barcode: barcode, Status: from}
+	}
+
+	bookCopy.Status = to
+	bookCopy.UpdatedAt = s.timestamp()
+
+	statusUpdate, err := s.copyStatusUpdate(bookCopy, from)
+	if err != nil {
+		return internal.Copy{}, err
+	}
+
+	err = s.saveCopy(ctx, internal.CopyAuditAction(from, to), bookCopy, statusUpdate)
+	if isConditionalCheckFailure(err) { // Someone else changed the copy, or deleted the book, in the meantime
+		return internal.Copy{}, internal.ErrCopyStatusConflict{Barcode: barcode, Status: from}
+	}
+	if err != nil {
+		return internal.Copy{}, fmt.Errorf("failed to update the copy in the database: %w", err)
+	}
+
+	return bookCopy, nil
+}
+
+// copyStatusUpdate builds the write that changes the copy to its new status, as long as it's still in the
+// expected one
+func (s *dynamodbBooksStorage) copyStatusUpdate(bookCopy internal.Copy, from internal.BookStatus) (types.TransactWriteItem, error) {
+	update, err := s.update(copyKey(bookCopy), expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("status"), expression.Value(string(bookCopy.Status))).
+			Set(expression.Name("updated_at"), expression.Value(bookCopy.UpdatedAt.Format(time.RFC3339Nano)))).
+		WithCondition(expression.Name("status").Equal(expression.Value(string(from)))))
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	return types.TransactWriteItem{Update: update}, nil
+}
+
+// saveCopy writes the change to the copy in a transaction that also brings the book's status in line with
+// its copies and records the change in the audit log
+func (s *dynamodbBooksStorage) saveCopy(ctx context.Context, action internal.AuditAction, bookCopy internal.Copy, writes ...types.TransactWriteItem) error {
+	before, err := s.GetBookByID(ctx, bookCopy.BookID)
+	if err != nil {
+		return err
+	}
+
+	copies, err := s.GetCopies(ctx, bookCopy.BookID)
+	if err != nil {
+		return err
+	}
+	copies = replaceCopy(copies, bookCopy)
+
+	after := before
+	after.Status = internal.NewAvailability(copies).Status(before.Status)
+	if after.Status != before.Status {
+		after.UpdatedAt = bookCopy.UpdatedAt
+		after.UpdatedBy = internal.ActorFromContext(ctx)
+	}
+
+	bookUpdate, err := s.update(bookKey(before.ID), expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("book_status"), expression.Value(string(after.Status))).
+			Set(expression.Name("updated_at"), expression.Value(after.UpdatedAt.Format(time.RFC3339Nano))).
+			Set(expression.Name("updated_by"), expression.Value(after.UpdatedBy))).
+		WithCondition(bookInCatalog))
+	if err != nil {
+		return err
+	}
+
+	auditItem, err := s.auditEventItem(internal.NewCopyAuditEvent(ctx, action, bookCopy, &before, &after, bookCopy.UpdatedAt))
+	if err != nil {
+		return err
+	}
+
+	return s.transactWrite(ctx, before.ID, append(writes, types.TransactWriteItem{Update: bookUpdate}, auditItem))
+}
+
+// replaceCopy returns the copies with the one that has the same barcode replaced, or added if it's new
+func replaceCopy(copies []internal.Copy, bookCopy internal.Copy) []internal.Copy {
+	for i := range copies {
+		if copies[i].Barcode == bookCopy.Barcode {
+			copies[i] = bookCopy
+			return copies
+		}
+	}
+	return append(copies, bookCopy)
+}
+
+// holdItem is how a hold is stored: in its book's partition, with a sort key of the time it was placed
+// followed by its ID, so the book's queue is read in order with a single query. A hold whose copy is on
+// the hold shelf is in GSI2's ready partition, by its expiry, until it's closed.
+func holdItem(hold internal.Hold) (map[string]types.AttributeValue, error) {
+	keys := itemKeys{PK: bookPrefix + hold.BookID, SK: holdPrefix + holdKey(hold)}
+	if hold.Status == internal.HoldReady && hold.ExpiresAt != nil {
+		keys.GSI2PK = readyHoldsPartition
+		keys.GSI2SK = hold.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	item, err := marshalItem(struct {
+		internal.Hold
+		itemKeys
+	}{
+		Hold:     hold,
+		itemKeys: keys,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the hold: %w", err)
+	}
+	return item, nil
+}
+
+func holdKey(hold internal.Hold) string {
+	return hold.PlacedAt.UTC().Format(time.RFC3339Nano) + "#" + hold.ID
+}
+
+func holdItemKey(hold internal.Hold) map[string]types.AttributeValue {
+	return itemKey(bookPrefix+hold.BookID, holdPrefix+holdKey(hold))
+}
+
+// holdActive is met by a hold that's in the queue, waiting or on the hold shelf
+var holdActive = expression.Name("status").In(expression.Value(string(internal.HoldWaiting)), expression.Value(string(internal.HoldReady)))
+
+// PlaceHold adds the patron to the end of the book's holds queue
+func (s *dynamodbBooksStorage) PlaceHold(ctx context.Context, hold internal.Hold) (internal.Hold, error) {
+	if _, err := s.GetBookByID(ctx, hold.BookID); err != nil {
+		return internal.Hold{}, err
+	}
+
+	holds, err := s.GetHolds(ctx, hold.BookID)
+	if err != nil {
+		return internal.Hold{}, err
+	}
+	for _, existing := range holds {
+		if existing.PatronID == hold.PatronID {
+			return internal.Hold{}, internal.ErrDuplicateHold{BookID: hold.BookID, PatronID: hold.PatronID}
+		}
+	}
+
+	hold.ID = uuid.New().String()
+	hold.Status = internal.HoldWaiting
+	hold.PlacedAt = s.timestamp()
+	hold.Barcode = ""
+	hold.ExpiresAt = nil
+
+	item, err := holdItem(hold)
+	if err != nil {
+		return internal.Hold{}, err
+	}
+	put, err := s.put(item, itemNotExists)
+	if err != nil {
+		return internal.Hold{}, err
+	}
+
+	callCtx, done := s.instrument(ctx, "PutItem", hold.BookID)
+	dbResult, err := s.db.PutItem(callCtx, &dynamodb.PutItemInput{
+		TableName:                 put.Put.TableName,
+		Item:                      put.Put.Item,
+		ConditionExpression:       put.Put.ConditionExpression,
+		ExpressionAttributeNames:  put.Put.ExpressionAttributeNames,
+		ExpressionAttributeValues: put.Put.ExpressionAttributeValues,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}, retryUntil(callCtx))
+	if err != nil {
+		return internal.Hold{}, fmt.Errorf("failed to place the hold in the database: %w", done(nil, err))
+	}
+	done(dbResult.ConsumedCapacity, nil)
+
+	return hold, nil
+}
+
+// GetHolds returns the book's active holds, in the order they were placed
+func (s *dynamodbBooksStorage) GetHolds(ctx context.Context, bookID string) ([]internal.Hold, error) {
+	result := make([]internal.Hold, 0)
+
+	input, err := s.queryInput("", expression.NewBuilder().
+		WithKeyCondition(expression.Key("PK").Equal(expression.Value(bookPrefix+bookID)).And(expression.Key("SK").BeginsWith(holdPrefix))).
+		WithFilter(holdActive))
+	if err != nil {
+		return result, err
+	}
+	input.ConsistentRead = aws.Bool(true)
+
+	var unmarshalErr error
+	callCtx, done := s.instrument(ctx, "Query", bookID)
+	err = s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		holds := make([]internal.Hold, 0)
+		unmarshalErr = unmarshalItems(page.Items, &holds)
+		result = append(result, holds...)
+		return unmarshalErr == nil
+	})
+	err = done(nil, err)
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve the book's holds from the database: %w", err)
+	}
+	if unmarshalErr != nil {
+		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", unmarshalErr)
+	}
+
+	return result, nil
+}
+
+// GetExpiredHolds returns the holds whose copy has been on the hold shelf past its expiry
+func (s *dynamodbBooksStorage) GetExpiredHolds(ctx context.Context, now time.Time) ([]internal.Hold, error) {
+	result := make([]internal.Hold, 0)
+
+	// The expiry is stored as an RFC 3339 string, whose fractional seconds don't sort lexically, so the
+	// index is queried up to the next second and the exact comparison is made below
+	bound := now.UTC().Truncate(time.Second).Add(time.Second).Format(time.RFC3339)
+	input, err := s.queryInput(gsi2Index, expression.NewBuilder().
+		WithKeyCondition(expression.Key("GSI2PK").Equal(expression.Value(readyHoldsPartition)).And(expression.Key("GSI2SK").LessThan(expression.Value(bound)))))
+	if err != nil {
+		return result, err
+	}
+
+	var unmarshalErr error
+	callCtx, done := s.instrument(ctx, "Query", "")
+	err = s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		holds := make([]internal.Hold, 0)
+		unmarshalErr = unmarshalItems(page.Items, &holds)
+		for _, hold := range holds {
+			if hold.ExpiresAt != nil && hold.ExpiresAt.Before(now) {
+				result = append(result, hold)
+			}
+		}
+		return unmarshalErr == nil
+	})
+	err = done(nil, err)
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve the expired holds from the database: %w", err)
+	}
+	if unmarshalErr != nil {
+		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", unmarshalErr)
+	}
+
+	return result, nil
+}
+
+// ReserveCopy puts the copy on the hold shelf for a waiting hold, until the expiry. The copy, the book's
+// status and the hold change in a single transaction.
+func (s *dynamodbBooksStorage) ReserveCopy(ctx context.Context, hold internal.Hold, barcode string, from internal.BookStatus, expiresAt time.Time) (internal.Hold, error) {
+	bookCopy, err := s.GetCopyByBarcode(ctx, barcode)
+	if err != nil {
+		return internal.Hold{}, err
+	}
+	if bookCopy.Status != from {
+		return internal.Hold{}, internal.ErrCopyStatusConflict{Barcode: barcode, Status: from}
+	}
+
+	bookCopy.Status = internal.OnHoldShelf
+	bookCopy.UpdatedAt = s.timestamp()
+
+	hold.Status = internal.HoldReady
+	hold.Barcode = barcode
+	hold.ExpiresAt = &expiresAt
+
+	expiry := expression.Value(expiresAt.UTC().Format(time.RFC3339Nano))
+	holdUpdate, err := s.update(holdItemKey(hold), expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("status"), expression.Value(string(internal.HoldReady))).
+			Set(expression.Name("barcode"), expression.Value(barcode)).
+			Set(expression.Name("expires_at"), expiry).
+			Set(expression.Name("GSI2PK"), expression.Value(readyHoldsPartition)).
+			Set(expression.Name("GSI2SK"), expiry)).
+		WithCondition(expression.Name("status").Equal(expression.Value(string(internal.HoldWaiting)))))
+	if err != nil {
+		return internal.Hold{}, err
+	}
+	statusUpdate, err := s.copyStatusUpdate(bookCopy, from)
+	if err != nil {
+		return internal.Hold{}, err
+	}
+
+	err = s.saveCopy(ctx, internal.AuditHoldShelf, bookCopy, statusUpdate, types.TransactWriteItem{Update: holdUpdate})
+	if failedCondition(err, 1) { // The hold isn't waiting, or doesn't exist
+		return internal.Hold{}, internal.ErrHoldNotFound{HoldID: hold.ID}
+	}
+	if isConditionalCheckFailure(err) { // The copy changed in the meantime
+		return internal.Hold{}, internal.ErrCopyStatusConflict{Barcode: barcode, Status: from}
+	}
+	if err != nil {
+		return internal.Hold{}, fmt.Errorf("failed to reserve the copy in the database: %w", err)
+	}
+
+	return hold, nil
+}
+
+// CloseHold takes an active hold out of the queue with its final status
+func (s *dynamodbBooksStorage) CloseHold(ctx context.Context, hold internal.Hold, status internal.HoldStatus) error {
+	update, err := s.holdClose(hold, status)
+	if err != nil {
+		return err
+	}
+
+	callCtx, done := s.instrument(ctx, "UpdateItem", hold.BookID)
+	dbResult, err := s.db.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName:                 update.TableName,
+		Key:                       update.Key,
+		UpdateExpression:          update.UpdateExpression,
+		ConditionExpression:       update.ConditionExpression,
+		ExpressionAttributeNames:  update.ExpressionAttributeNames,
+		ExpressionAttributeValues: update.ExpressionAttributeValues,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}, retryUntil(callCtx))
+	if err != nil {
+		err = done(nil, err)
+		if isConditionalCheckFailure(err) {
+			return internal.ErrHoldNotFound{HoldID: hold.ID}
+		}
+		return fmt.Errorf("failed to close the hold in the database: %w", err)
+	}
+	done(dbResult.ConsumedCapacity, nil)
+
+	return nil
+}
+
+// holdClose builds the write that closes an active hold, and takes it off the hold shelf
+func (s *dynamodbBooksStorage) holdClose(hold internal.Hold, status internal.HoldStatus) (*types.Update, error) {
+	return s.update(holdItemKey(hold), expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("status"), expression.Value(string(status))).
+			Remove(expression.Name("GSI2PK")).
+			Remove(expression.Name("GSI2SK"))).
+		WithCondition(holdActive))
+}
+
+// loanItem is how a loan is stored: in its copy's partition, with a sort key of the time the copy was
+// checked out followed by the loan's ID, so the copy's latest loan is the last item in its partition. It's
+// in its patron's partition of GSI1 the same way. An open loan is in GSI2's open partition, by its due
+// date, so the overdue query reads nothing else; the keys are removed on return.
+func loanItem(loan internal.Loan) (map[string]types.AttributeValue, error) {
+	keys := itemKeys{
+		PK:     copyPrefix + loan.Barcode,
+		SK:     loanPrefix + loanKey(loan),
+		GSI1PK: patronPrefix + loan.PatronID,
+		GSI1SK: loanPrefix + loanKey(loan),
+	}
+	if loan.IsOpen() {
+		keys.GSI2PK = openLoansPartition
+		keys.GSI2SK = loan.DueAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	item, err := marshalItem(struct {
+		internal.Loan
+		itemKeys
+	}{
+		Loan:     loan,
+		itemKeys: keys,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the loan: %w", err)
+	}
+	return item, nil
+}
+
+func loanKey(loan internal.Loan) string {
+	return loan.CheckedOutAt.UTC().Format(time.RFC3339Nano) + "#" + loan.ID
+}
+
+func loanItemKey(loan internal.Loan) map[string]types.AttributeValue {
+	return itemKey(copyPrefix+loan.Barcode, loanPrefix+loanKey(loan))
+}
+
+// newLoan builds the writes that open the loan and count it against the patron
+func (s *dynamodbBooksStorage) newLoan(loan internal.Loan) ([]types.TransactWriteItem, error) {
+	item, err := loanItem(loan)
+	if err != nil {
+		return nil, err
+	}
+	put, err := s.put(item, itemNotExists)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.countLoan(loan.PatronID, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return []types.TransactWriteItem{put, count}, nil
+}
+
+// The patron's item counts their open loans, which every write that opens or closes one changes in the
+// same transaction
+func (s *dynamodbBooksStorage) countLoan(patronID string, n int) (types.TransactWriteItem, error) {
+	update, err := s.update(itemKey(patronPrefix+patronID, patronSortKey), expression.NewBuilder().
+		WithUpdate(expression.Set(expression.Name("patron_id"), expression.Value(patronID)).
+			Add(expression.Name("loan_count"), expression.Value(n))))
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+	return types.TransactWriteItem{Update: update}, nil
+}
+
+// CreateLoan opens a loan of a copy. A copy can only be on one open loan at a time.
+func (s *dynamodbBooksStorage) CreateLoan(ctx context.Context, loan internal.Loan) (internal.Loan, error) {
+	if _, err := s.GetOpenLoan(ctx, loan.Barcode); err == nil {
+		return internal.Loan{}, internal.ErrCopyStatusConflict{Barcode: loan.Barcode, Status: internal.CheckedIn}
+	} else if !errors.As(err, &internal.ErrLoanNotFound{}) {
+		return internal.Loan{}, err
+	}
+
+	loan.ID = uuid.New().String()
+	loan.Renewals = 0
+	loan.ReturnedAt = nil
+
+	items, err := s.newLoan(loan)
+	if err != nil {
+		return internal.Loan{}, err
+	}
+
+	err = s.transactWrite(ctx, loan.BookID, items)
+	if err != nil {
+		return internal.Loan{}, fmt.Errorf("failed to create the loan in the database: %w", err)
+	}
+
+	return loan, nil
+}
+
+// CheckOutCopy lends the copy to the loan's patron, fulfilling the hold it was on the hold shelf for, if
+// any. The copy, the book's status, the hold, the new loan and the patron's count of loans change in a
+// single transaction.
+func (s *dynamodbBooksStorage) CheckOutCopy(ctx context.Context, loan internal.Loan, from internal.BookStatus, hold *internal.Hold) (internal.Copy, internal.Loan, error) {
+	bookCopy, err := s.GetCopyByBarcode(ctx, loan.Barcode)
+	if err != nil {
+		return internal.Copy{}, internal.Loan{}, err
+	}
+	if bookCopy.Status != from {
+		return internal.Copy{}, internal.Loan{}, internal.ErrCopyStatusConflict{Barcode: loan.Barcode, Status: from}
+	}
+
+	bookCopy.Status = internal.CheckedOut
+	bookCopy.UpdatedAt = s.timestamp()
+
+	loan.ID = uuid.New().String()
+	loan.Renewals = 0
+	loan.ReturnedAt = nil
+
+	statusUpdate, err := s.copyStatusUpdate(bookCopy, from)
+	if err != nil {
+		return internal.Copy{}, internal.Loan{}, err
+	}
+	loanWrites, err := s.newLoan(loan)
+	if err != nil {
+		return internal.Copy{}, internal.Loan{}, err
+	}
+	writes := append([]types.TransactWriteItem{statusUpdate}, loanWrites...)
+	fulfilled := -1
+	if hold != nil {
+		holdUpdate, err := s.holdClose(*hold, internal.HoldFulfilled)
+		if err != nil {
+			return internal.Copy{}, internal.Loan{}, err
+		}
+		fulfilled = len(writes)
+		writes = append(writes, types.TransactWriteItem{Update: holdUpdate})
+	}
+
+	err = s.saveCopy(ctx, internal.CopyAuditAction(from, internal.CheckedOut), bookCopy, writes...)
+	if failedCondition(err, fulfilled) { // The hold was cancelled or expired in the meantime
+		return internal.Copy{}, internal.Loan{}, internal.ErrHoldNotFound{HoldID: hold.ID}
+	}
+	if isConditionalCheckFailure(err) { // Someone else changed the copy, or deleted the book, in the meantime
+		return internal.Copy{}, internal.Loan{}, internal.ErrCopyStatusConflict{Barcode: loan.Barcode, Status: from}
+	}
+	if err != nil {
+		return internal.Copy{}, internal.Loan{}, fmt.Errorf("failed to check out the copy in the database: %w", err)
+	}
+
+	return bookCopy, loan, nil
+}
+
+func (s *dynamodbBooksStorage) GetOpenLoan(ctx context.Context, barcode string) (internal.Loan, error) {
+	input, err := s.queryInput("", expression.NewBuilder().
+		WithKeyCondition(expression.Key("PK").Equal(expression.Value(copyPrefix+barcode)).And(expression.Key("SK").BeginsWith(loanPrefix))))
+	if err != nil {
+		return internal.Loan{}, err
+	}
+	input.ScanIndexForward = aws.Bool(false) // The latest loan first
+	input.Limit = aws.Int32(1)
+	input.ConsistentRead = aws.Bool(true)
+	input.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
+
+	callCtx, done := s.instrument(ctx, "Query", "")
+	dbResult, err := s.db.Query(callCtx, input, retryUntil(callCtx))
+	if err != nil {
+		return internal.Loan{}, fmt.Errorf("failed to retrieve the loan from the database: %w", done(nil, err))
+	}
+	done(dbResult.ConsumedCapacity, nil)
+
+	if len(dbResult.Items) == 0 {
+		return internal.Loan{}, internal.ErrLoanNotFound{Barcode: barcode}
+	}
+
+	result := internal.Loan{}
+	err = unmarshalItem(dbResult.Items[0], &result)
+	if err != nil {
+		return internal.Loan{}, fmt.Errorf("failed to unmarshal the result from the database: %w", err)
+	}
+	if !result.IsOpen() {
+		return internal.Loan{}, internal.ErrLoanNotFound{Barcode: barcode}
+	}
+
+	return result, nil
+}
+
+// GetPatronLoans returns the patron's open loans
+func (s *dynamodbBooksStorage) GetPatronLoans(ctx context.Context, patronID string) ([]internal.Loan, error) {
+	result := make([]internal.Loan, 0)
+
+	input, err := s.queryInput(gsi1Index, expression.NewBuilder().
+		WithKeyCondition(expression.Key("GSI1PK").Equal(expression.Value(patronPrefix+patronID)).And(expression.Key("GSI1SK").BeginsWith(loanPrefix))).
+		WithFilter(expression.AttributeNotExists(expression.Name("returned_at"))))
+	if err != nil {
+		return result, err
+	}
+
+	result, err = s.queryLoans(ctx, "", input)
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve the patron's loans from the database: %w", err)
+	}
+	return result, nil
+}
+
+// queryLoans reads every page of a query of loans
+func (s *dynamodbBooksStorage) queryLoans(ctx context.Context, bookID string, input *dynamodb.QueryInput) ([]internal.Loan, error) {
+	result := make([]internal.Loan, 0)
+
+	var unmarshalErr error
+	callCtx, done := s.instrument(ctx, "Query", bookID)
+	err := s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		loans := make([]internal.Loan, 0)
+		unmarshalErr = unmarshalItems(page.Items, &loans)
+		result = append(result, loans...)
+		return unmarshalErr == nil
+	})
+	err = done(nil, err)
+	if err != nil {
+		return result, err
+	}
+	if unmarshalErr != nil {
+		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", unmarshalErr)
+	}
+
+	return result, nil
+}
+
+// GetOverdueLoans returns the open loans that were due before now, the most overdue first
+func (s *dynamodbBooksStorage) GetOverdueLoans(ctx context.Context, now time.Time) ([]internal.Loan, error) {
+	result := make([]internal.Loan, 0)
+
+	// The due date is stored as an RFC 3339 string, whose fractional seconds don't sort lexically, so the
+	// index is queried up to the next second and the exact comparison is made below
+	bound := now.UTC().Truncate(time.Second).Add(time.Second).Format(time.RFC3339)
+	input, err := s.queryInput(gsi2Index, expression.NewBuilder().
+		WithKeyCondition(expression.Key("GSI2PK").Equal(expression.Value(openLoansPartition)).And(expression.Key("GSI2SK").LessThan(expression.Value(bound)))))
+	if err != nil {
+		return result, err
+	}
+
+	var unmarshalErr error
+	callCtx, done := s.instrument(ctx, "Query", "")
+	err = s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		loans := make([]internal.Loan, 0)
+		unmarshalErr = unmarshalItems(page.Items, &loans)
+		for _, loan := range loans {
+			if loan.DueAt.Before(now) {
+				result = append(result, loan)
+			}
+		}
+		return unmarshalErr == nil
+	})
+	err = done(nil, err)
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve the overdue loans from the database: %w", err)
+	}
+	if unmarshalErr != nil {
+		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", unmarshalErr)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].DueAt.Before(result[j].DueAt) })
+
+	return result, nil
+}
+
+// RenewLoan moves the loan's due date and counts the renewal, as long as the loan hasn't changed since it
+// was read, and returns the loan as it's stored after the renewal
+func (s *dynamodbBooksStorage) RenewLoan(ctx context.Context, loan internal.Loan, dueAt time.Time) (internal.Loan, error) {
+	due := expression.Value(dueAt.UTC().Format(time.RFC3339Nano))
+	update, err := s.loanUpdate(loan, expression.Set(expression.Name("due_at"), due).
+		Set(expression.Name("GSI2SK"), due).
+		Set(expression.Name("renewals"), expression.Name("renewals").Plus(expression.Value(1))))
+	if err != nil {
+		return internal.Loan{}, err
+	}
+
+	callCtx, done := s.instrument(ctx, "UpdateItem", loan.BookID)
+	dbResult, err := s.db.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+		TableName:                 update.TableName,
+		Key:                       update.Key,
+		UpdateExpression:          update.UpdateExpression,
+		ConditionExpression:       update.ConditionExpression,
+		ExpressionAttributeNames:  update.ExpressionAttributeNames,
+		ExpressionAttributeValues: update.ExpressionAttributeValues,
+		ReturnValues:              types.ReturnValueAllNew,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}, retryUntil(callCtx))
+	if err != nil {
+		err = done(nil, err)
+		if isConditionalCheckFailure(err) {
+			return internal.Loan{}, internal.ErrLoanChanged{LoanID: loan.ID}
+		}
+		return internal.Loan{}, fmt.Errorf("failed to update the loan in the database: %w", err)
+	}
+	done(dbResult.ConsumedCapacity, nil)
+
+	if len(dbResult.Attributes) == 0 {
+		return internal.Loan{}, errors.New("the database didn't return the renewed loan")
+	}
+	result := internal.Loan{}
+	err = unmarshalItem(dbResult.Attributes, &result)
+	if err != nil {
+		return internal.Loan{}, fmt.Errorf("failed to unmarshal the result from the database: %w", err)
+	}
+
+	return result, nil
+}
+
+// CloseLoan returns the loan, and takes it off the patron's count, in a single transaction
+func (s *dynamodbBooksStorage) CloseLoan(ctx context.Context, loan internal.Loan, returnedAt time.Time) (internal.Loan, error) {
+	update, err := s.loanUpdate(loan, expression.Set(expression.Name("returned_at"), expression.Value(returnedAt.UTC().Format(time.RFC3339Nano))).
+		Remove(expression.Name("GSI2PK")).
+		Remove(expression.Name("GSI2SK")))
+	if err != nil {
+		return internal.Loan{}, err
+	}
+	count, err := s.countLoan(loan.PatronID, -1)
+	if err != nil {
+		return internal.Loan{}, err
+	}
+
+	err = s.transactWrite(ctx, loan.BookID, []types.TransactWriteItem{{Update: update}, count})
+	if isConditionalCheckFailure(err) {
+		return internal.Loan{}, internal.ErrLoanChanged{LoanID: loan.ID}
+	}
+	if err != nil {
+		return internal.Loan{}, fmt.Errorf("failed to update the loan in the database: %w", err)
+	}
+
+	loan.ReturnedAt = &returnedAt
+	return loan, nil
+}
+
+// loanUpdate builds the write that applies the changes to the loan, as long as it's still open and hasn't
+// been renewed since it was read
+func (s *dynamodbBooksStorage) loanUpdate(loan internal.Loan, changes expression.UpdateBuilder) (*types.Update, error) {
+	return s.update(loanItemKey(loan), expression.NewBuilder().
+		WithUpdate(changes).
+		WithCondition(expression.AttributeNotExists(expression.Name("returned_at")).
+			And(expression.Name("renewals").Equal(expression.Value(loan.Renewals)))))
+}
+
+// ledgerItem is how a ledger entry is stored: in its patron's partition, with a sort key of the time the
+// entry was recorded followed by its ID
+func ledgerItem(entry internal.LedgerEntry) (map[string]types.AttributeValue, error) {
+	item, err := marshalItem(struct {
+		internal.LedgerEntry
+		itemKeys
+	}{
+		LedgerEntry: entry,
+		itemKeys:    itemKeys{PK: patronPrefix + entry.PatronID, SK: ledgerPrefix + entry.CreatedAt.UTC().Format(time.RFC3339Nano) + "#" + entry.ID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the ledger entry: %w", err)
+	}
+	return item, nil
+}
+
+// AddLedgerEntry records a charge, payment or waiver on the patron's account
+func (s *dynamodbBooksStorage) AddLedgerEntry(ctx context.Context, entry internal.LedgerEntry) (internal.LedgerEntry, error) {
+	entry.ID = uuid.New().String()
+	entry.CreatedAt = s.timestamp()
+	entry.CreatedBy = internal.ActorFromContext(ctx)
+
+	item, err := ledgerItem(entry)
+	if err != nil {
+		return internal.LedgerEntry{}, err
+	}
+	put, err := s.put(item, itemNotExists)
+	if err != nil {
+		return internal.LedgerEntry{}, err
+	}
+
+	callCtx, done := s.instrument(ctx, "PutItem", "")
+	dbResult, err := s.db.PutItem(callCtx, &dynamodb.PutItemInput{
+		TableName:                 put.Put.TableName,
+		Item:                      put.Put.Item,
+		ConditionExpression:       put.Put.ConditionExpression,
+		ExpressionAttributeNames:  put.Put.ExpressionAttributeNames,
+		ExpressionAttributeValues: put.Put.ExpressionAttributeValues,
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	}, retryUntil(callCtx))
+	if err != nil {
+		return internal.LedgerEntry{}, fmt.Errorf("failed to record the ledger entry in the database: %w", done(nil, err))
+	}
+	done(dbResult.ConsumedCapacity, nil)
+
+	return entry, nil
+}
+
+// GetLedger returns the patron's ledger entries, oldest first
+func (s *dynamodbBooksStorage) GetLedger(ctx context.Context, patronID string) ([]internal.LedgerEntry, error) {
+	result := make([]internal.LedgerEntry, 0)
+
+	input, err := s.queryInput("", expression.NewBuilder().
+		WithKeyCondition(expression.Key("PK").Equal(expression.Value(patronPrefix+patronID)).And(expression.Key("SK").BeginsWith(ledgerPrefix))))
+	if err != nil {
+		return result, err
+	}
+	input.ConsistentRead = aws.Bool(true)
+
+	var unmarshalErr error
+	callCtx, done := s.instrument(ctx, "Query", "")
+	err = s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		entries := make([]internal.LedgerEntry, 0)
+		unmarshalErr = unmarshalItems(page.Items, &entries)
+		result = append(result, entries...)
+		return unmarshalErr == nil
+	})
+	err = done(nil, err)
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve the patron's ledger from the database: %w", err)
+	}
+	if unmarshalErr != nil {
+		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", unmarshalErr)
+	}
+
+	// The sort key's fractional seconds don't sort lexically
+	sort.SliceStable(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+
+	return result, nil
+}
+
+func (s *dynamodbBooksStorage) GetBookHistory(ctx context.Context, bookID string) ([]internal.AuditEvent, error) {
+	result := make([]internal.AuditEvent, 0)
+
+	input, err := s.queryInput("", expression.NewBuilder().
+		WithKeyCondition(expression.Key("PK").Equal(expression.Value(bookPrefix+bookID)).And(expression.Key("SK").BeginsWith(auditPrefix))))
+	if err != nil {
+		return result, err
+	}
+	input.ReturnConsumedCapacity = types.ReturnConsumedCapacityTotal
+
+	var unmarshalErr error
+	callCtx, done := s.instrument(ctx, "Query", bookID)
+	err = s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		events := make([]internal.AuditEvent, 0)
+		unmarshalErr = unmarshalItems(page.Items, &events)
+		result = append(result, events...)
+		return unmarshalErr == nil
+	})
+	err = done(nil, err)
+	if err != nil {
+		return result, fmt.Errorf("failed to retrieve the book's history from the database: %w", err)
+	}
+	if unmarshalErr != nil {
+		return result, fmt.Errorf("failed to unmarshal the result from the database: %w", unmarshalErr)
+	}
+
+	return result, nil
+}
+
+// GetAuditEvents queries the actor's partition of GSI1 when the filter has an actor, and otherwise the
+// partitions of GSI2 that hold each day's events, from the day the search starts on to today. A search
+// without an actor has to start somewhere, so it needs a Since or an After.
+func (s *dynamodbBooksStorage) GetAuditEvents(ctx context.Context, filter internal.AuditFilter) ([]internal.AuditEvent, error) {
+	result := make([]internal.AuditEvent, 0)
+
+	type partition struct{ index, partitionKey, sortKey, value string }
+	var partitions []partition
+	if filter.Actor != "" {
+		partitions = append(partitions, partition{gsi1Index, "GSI1PK", "GSI1SK", actorPrefix + filter.Actor})
+	} else {
+		start := filter.Since
+		if filter.After != nil && filter.After.Timestamp.After(start) {
+			start = filter.After.Timestamp
+		}
+		if start.IsZero() {
+			return result, errors.New("the audit events can only be searched by actor or from a time")
+		}
+		for day := start.UTC().Truncate(24 * time.Hour); !day.After(s.timestamp()); day = day.Add(24 * time.Hour) {
+			partitions = append(partitions, partition{gsi2Index, "GSI2PK", "GSI2SK", auditDayPartition(day)})
+		}
+	}
+
+	// A partition is sorted by the events' keys, so the search starts at the key of Since, or just after
+	// the key of the event it continues from
+	since := filter.Since.UTC().Format(time.RFC3339Nano)
+	bound := func(sortKey string) expression.KeyConditionBuilder {
+		if filter.After != nil {
+			if after := auditEventKey(*filter.After); after >= since {
+				return expression.Key(sortKey).GreaterThan(expression.Value(after))
+			}
+		}
+		return expression.Key(sortKey).GreaterThanEqual(expression.Value(since))
+	}
+
+	var unmarshalErr error
+	full := func() bool { return filter.Limit > 0 && len(result) >= filter.Limit }
+	for _, p := range partitions {
+		input, err := s.queryInput(p.index, expression.NewBuilder().
+			WithKeyCondition(expression.Key(p.partitionKey).Equal(expression.Value(p.value)).And(bound(p.sortKey))))
+		if err != nil {
+			return result, err
+		}
+		if filter.Limit > 0 {
+			input.Limit = aws.Int32(int32(filter.Limit - len(result)))
+		}
+
+		callCtx, done := s.instrument(ctx, "Query", "")
+		err = s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+			events := make([]internal.AuditEvent, 0)
+			unmarshalErr = unmarshalItems(page.Items, &events)
+			result = append(result, events...)
+			return unmarshalErr == nil && !full()
+		})
+		if err = done(nil, err); err != nil {
+			return result, fmt.Errorf("failed to retrieve the audit events from the database: %w", err)
+		}
+		if unmarshalErr != nil {
+			return result, fmt.Errorf("failed to unmarshal the result from the database: %w", unmarshalErr)
+		}
+		if full() {
+			return result[:filter.Limit], nil
+		}
+	}
+
+	return result, nil
+}
+
+// auditItem builds the write that records a change in the audit log
+func (s *dynamodbBooksStorage) auditItem(ctx context.Context, bookID string, before, after *internal.Book) (types.TransactWriteItem, error) {
+	return s.auditEventItem(internal.NewAuditEvent(ctx, bookID, before, after, s.timestamp()))
+}
+
+func (s *dynamodbBooksStorage) auditEventItem(event internal.AuditEvent) (types.TransactWriteItem, error) {
+	item, err := auditEventItem(event)
+	if err != nil {
+		return types.TransactWriteItem{}, err
+	}
+
+	return types.TransactWriteItem{Put: &types.Put{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	}}, nil
+}
+
+// auditEventItem is how an audit event is stored: in its book's partition, which outlives the book, with a
+// sort key that orders the book's events by time, and the same way in its actor's partition of GSI1 and
+// its day's partition of GSI2
+func auditEventItem(event internal.AuditEvent) (map[string]types.AttributeValue, error) {
+	eventKey := auditEventKey(event)
+	item, err := marshalItem(struct {
+		internal.AuditEvent
+		itemKeys
+	}{
+		AuditEvent: event,
+		itemKeys: itemKeys{
+			PK:     bookPrefix + event.BookID,
+			SK:     auditPrefix + eventKey,
+			GSI1PK: actorPrefix + event.Actor,
+			GSI1SK: eventKey,
+			GSI2PK: auditDayPartition(event.Timestamp),
+			GSI2SK: eventKey,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the audit event: %w", err)
+	}
+	return item, nil
+}
+
+func auditEventKey(event internal.AuditEvent) string {
+	return event.Timestamp.UTC().Format(time.RFC3339Nano) + "#" + event.ID
+}
+
+// auditDayPartition is the partition of GSI2 that holds the audit events of the day
+func auditDayPartition(t time.Time) string {
+	return auditPrefix + t.UTC().Format("2006-01-02")
+}
+
+// transactWrite writes the items atomically, so a change to a book and its audit event are saved together
+func (s *dynamodbBooksStorage) transactWrite(ctx context.Context, bookID string, items []types.TransactWriteItem) error {
+	callCtx, done := s.instrument(ctx, "TransactWriteItems", bookID)
+	dbResult, err := s.db.TransactWriteItems(callCtx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:          items,
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}, retryUntil(callCtx))
+	if err != nil {
+		return done(nil, err)
+	}
+	return done(totalCapacity(dbResult.ConsumedCapacity), nil)
+}
+
+// batchWriteLimit is the most writes DynamoDB accepts in one BatchWriteItem request
+const batchWriteLimit = 25
+
+// batchPut writes the items into the library table, as many at a time as DynamoDB accepts
+func (s *dynamodbBooksStorage) batchPut(ctx context.Context, items []map[string]types.AttributeValue) error {
+	writes := make([]types.WriteRequest, 0, len(items))
+	for _, item := range items {
+		writes = append(writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+	return s.batchWrite(ctx, writes)
+}
+
+// batchDelete deletes the items with the keys from the library table, as many at a time as DynamoDB accepts
+func (s *dynamodbBooksStorage) batchDelete(ctx context.Context, keys []map[string]types.AttributeValue) error {
+	writes := make([]types.WriteRequest, 0, len(keys))
+	for _, key := range keys {
+		writes = append(writes, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+	}
+	return s.batchWrite(ctx, writes)
+}
+
+// batchWrite makes the writes in batches of as many as DynamoDB accepts. The writes DynamoDB leaves
+// unprocessed, because the table's throughput was exceeded, are retried after a delay that doubles each
+// time.
+func (s *dynamodbBooksStorage) batchWrite(ctx context.Context, requests []types.WriteRequest) error {
+	for start := 0; start < len(requests); start += batchWriteLimit {
+		end := start + batchWriteLimit
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		writes := requests[start:end]
+		delay := 50 * time.Millisecond
+		for len(writes) > 0 {
+			dbResult, err := s.db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{s.tableName: writes},
+			}, retryUntil(ctx))
+			if err != nil {
+				return err
+			}
+
+			writes = dbResult.UnprocessedItems[s.tableName]
+			if len(writes) == 0 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			if delay < 5*time.Second {
+				delay *= 2
+			}
+		}
+	}
+
+	return nil
+}
+
+// totalCapacity adds up the capacity a transaction consumed in each of its tables
+func totalCapacity(capacities []types.ConsumedCapacity) *types.ConsumedCapacity {
+	if len(capacities) == 0 {
+		return nil
+	}
+
+	total := 0.0
+	for _, c := range capacities {
+		total += aws.ToFloat64(c.CapacityUnits)
+	}
+	return &types.ConsumedCapacity{CapacityUnits: aws.Float64(total)}
+}
+
+// failedCondition reports whether a transaction was cancelled because the condition of its i'th write
+// wasn't met
+func failedCondition(err error, i int) bool {
+	var canceled *types.TransactionCanceledException
+	if i < 0 || !errors.As(err, &canceled) || i >= len(canceled.CancellationReasons) {
+		return false
+	}
+	return aws.ToString(canceled.CancellationReasons[i].Code) == "ConditionalCheckFailed"
+}
+
+// isConditionalCheckFailure reports whether a write failed because its condition expression wasn't met
+func isConditionalCheckFailure(err error) bool {
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return true
+	}
+
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for _, reason := range canceled.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+	return false
+}
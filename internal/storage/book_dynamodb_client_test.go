@@ -0,0 +1,1289 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/storage/mocks"
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+// The unit tests of the DynamoDB storage call a mock client, which answers every call the way the test
+// says, so they can cover the answers that DynamoDB doesn't give on demand
+
+var clientTestNow = time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+
+var (
+	clientTestBook = internal.Book{
+		ID: "book-1", Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Status: internal.CheckedIn,
+		CreatedAt: clientTestNow.Add(-time.Hour), CreatedBy: "librarian", UpdatedAt: clientTestNow.Add(-time.Hour), UpdatedBy: "librarian",
+	}
+	clientTestCopy = internal.Copy{Barcode: "copy-1", BookID: "book-1", Branch: "main", Status: internal.CheckedIn, UpdatedAt: clientTestNow.Add(-time.Hour)}
+	clientTestHold = internal.Hold{ID: "hold-1", BookID: "book-1", PatronID: "patron-1", Status: internal.HoldWaiting, PlacedAt: clientTestNow.Add(-time.Hour)}
+	clientTestLoan = internal.Loan{
+		ID: "loan-1", Barcode: "copy-1", BookID: "book-1", PatronID: "patron-1",
+		CheckedOutAt: clientTestNow.Add(-time.Hour), DueAt: clientTestNow.Add(-time.Minute),
+	}
+	clientTestEntry = internal.LedgerEntry{ID: "entry-1", PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 25, CreatedAt: clientTestNow}
+	clientTestEvent = internal.AuditEvent{ID: "event-1", BookID: "book-1", Action: internal.AuditCreateBook, Actor: "librarian", Timestamp: clientTestNow}
+)
+
+var (
+	errClientTest      = &smithy.GenericAPIError{Code: "ValidationException", Message: "One or more parameter values were invalid"}
+	errConditionFailed = &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	errNotFound        = &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+)
+
+// newClientTestStorage returns a storage that calls the mock instead of DynamoDB
+func newClientTestStorage(db *mocks.MockDynamoDBAPI) *dynamodbBooksStorage {
+	// It only fails to load the AWS configuration, which it doesn't need with a client
+	s, _ := NewDynamoDBBooksStorage(WithClient(db), WithTablePrefix("test"), WithClock(func() time.Time { return clientTestNow }))
+	return s
+}
+
+// clientTestItem is how the storage stores the value
+func clientTestItem(t *testing.T, value interface{}) map[string]types.AttributeValue {
+	t.Helper()
+
+	var (
+		item map[string]types.AttributeValue
+		err  error
+	)
+	switch v := value.(type) {
+	case internal.Book:
+		item, err = bookItem(v)
+	case internal.Copy:
+		item, err = copyItem(v)
+	case internal.Hold:
+		item, err = holdItem(v)
+	case internal.Loan:
+		item, err = loanItem(v)
+	case internal.LedgerEntry:
+		item, err = ledgerItem(v)
+	case internal.AuditEvent:
+		item, err = auditEventItem(v)
+	default:
+		t.Fatalf("the storage doesn't store a %T", value)
+	}
+	if err != nil {
+		t.Fatalf("failed to build the item: %v", err)
+	}
+	return item
+}
+
+// malformedItem is an item whose timestamps can't be unmarshalled
+func malformedItem() map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{}
+	for _, name := range []string{"created_at", "updated_at", "placed_at", "checked_out_at", "due_at", "timestamp"} {
+		item[name] = &types.AttributeValueMemberS{Value: "yesterday"}
+	}
+	return item
+}
+
+// transactionCanceled is the error of a transaction of the writes that was cancelled because the condition
+// of the failed one wasn't met
+func transactionCanceled(writes, failed int) error {
+	reasons := make([]types.CancellationReason, writes)
+	for i := range reasons {
+		reasons[i] = types.CancellationReason{Code: aws.String("None")}
+	}
+	reasons[failed].Code = aws.String("ConditionalCheckFailed")
+	return &types.TransactionCanceledException{Message: aws.String("Transaction cancelled"), CancellationReasons: reasons}
+}
+
+// hasValue is whether the expression's values include the value, whatever its placeholder
+func hasValue(values map[string]types.AttributeValue, value types.AttributeValue) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// page is the page a query or scan that starts at the key reads, and the key the page after it starts at
+func page(start map[string]types.AttributeValue, pages [][]map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue) {
+	i := 0
+	if n, ok := start["page"].(*types.AttributeValueMemberN); ok {
+		i, _ = strconv.Atoi(n.Value)
+	}
+	if i >= len(pages) {
+		return nil, nil
+	}
+	if i == len(pages)-1 {
+		return pages[i], nil
+	}
+	return pages[i], map[string]types.AttributeValue{"page": &types.AttributeValueMemberN{Value: strconv.Itoa(i + 1)}}
+}
+
+// serveQueryPages makes the mock answer every query with the pages
+func serveQueryPages(db *mocks.MockDynamoDBAPI, pages ...[]map[string]types.AttributeValue) {
+	db.QueryStub = func(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		if otherShard(input) {
+			return &dynamodb.QueryOutput{}, nil
+		}
+		items, next := page(input.ExclusiveStartKey, pages)
+		return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: next}, nil
+	}
+}
+
+// otherShard is whether the query is of a catalog shard other than the first, which the mocks leave empty
+// so that a listing of every shard returns their items once
+func otherShard(input *dynamodb.QueryInput) bool {
+	for _, value := range input.ExpressionAttributeValues {
+		if shard, ok := value.(*types.AttributeValueMemberS); ok && strings.HasPrefix(shard.Value, catalogPrefix) {
+			return shard.Value != catalogPrefix+"0"
+		}
+	}
+	return false
+}
+
+// serveBook makes the mock answer GetItem with the book, and every query, including the lookup of a copy
+// by its barcode, with its copies
+func serveBook(t *testing.T, db *mocks.MockDynamoDBAPI, book internal.Book, copies ...internal.Copy) {
+	t.Helper()
+
+	db.GetItemReturns(&dynamodb.GetItemOutput{Item: clientTestItem(t, book)}, nil)
+	items := make([]map[string]types.AttributeValue, 0, len(copies))
+	for _, bookCopy := range copies {
+		items = append(items, clientTestItem(t, bookCopy))
+	}
+	serveQueryPages(db, items)
+}
+
+func TestDynamoDBBooksStorage_WithClient(t *testing.T) {
+	assert := assertions.New(t)
+	db := &mocks.MockDynamoDBAPI{}
+
+	s, err := NewDynamoDBBooksStorage(WithClient(db), WithEndpoint("http://localhost:1"))
+
+	assert.So(err, should.BeNil)
+	assert.So(s.db, should.Equal, db)
+}
+
+func TestDynamoDBBooksStorage_Probe(t *testing.T) {
+	type state struct {
+		dbResponse *dynamodb.DescribeTableOutput
+		dbError    error
+	}
+	type expected struct {
+		err   error
+		cause error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The table can't be described": {
+			state{dbError: errClientTest},
+			expected{err: errors.New("failed to describe the library table: api error ValidationException: One or more parameter values were invalid"), cause: errClientTest},
+		},
+		"The table is being created": {
+			state{dbResponse: &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusCreating}}},
+			expected{err: errors.New("the library table is CREATING")},
+		},
+		"The table is being updated": {
+			state{dbResponse: &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusUpdating}}},
+			expected{},
+		},
+		"Happy path": {
+			state{dbResponse: &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusActive}}},
+			expected{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			db.DescribeTableReturns(tc.state.dbResponse, tc.state.dbError)
+			s := newClientTestStorage(db)
+
+			err := s.Probe(context.Background())
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			if tc.expected.cause != nil {
+				assert.So(errors.Is(err, tc.expected.cause), should.BeTrue)
+			}
+			_, input, _ := db.DescribeTableArgsForCall(0)
+			assert.So(aws.ToString(input.TableName), should.Equal, "test-library")
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_GetBookByID(t *testing.T) {
+	deletedAt := clientTestNow
+	deletedBook := clientTestBook
+	deletedBook.DeletedAt = &deletedAt
+
+	type state struct {
+		item    func(t *testing.T) map[string]types.AttributeValue
+		dbError error
+	}
+	type expected struct {
+		book  internal.Book
+		err   error
+		cause error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The call to GetItem returns an error": {
+			state{dbError: errClientTest},
+			expected{err: errors.New("failed to retrieve the book from the database: api error ValidationException"), cause: errClientTest},
+		},
+		"The item is empty": {
+			state{item: func(t *testing.T) map[string]types.AttributeValue { return map[string]types.AttributeValue{} }},
+			expected{err: internal.ErrBookNotFound{BookID: "book-1"}},
+		},
+		"The item can't be unmarshalled": {
+			state{item: func(t *testing.T) map[string]types.AttributeValue { return malformedItem() }},
+			expected{err: errors.New("failed to unmarshal the result from the database: unmarshal failed, cannot unmarshal \"yesterday\" into time.Time")},
+		},
+		"The book is in the trash": {
+			state{item: func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, deletedBook) }},
+			expected{err: internal.ErrBookNotFound{BookID: "book-1"}},
+		},
+		"Happy path": {
+			state{item: func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestBook) }},
+			expected{book: clientTestBook},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			if tc.state.item != nil {
+				db.GetItemReturns(&dynamodb.GetItemOutput{Item: tc.state.item(t)}, nil)
+			} else {
+				db.GetItemReturns(nil, tc.state.dbError)
+			}
+			s := newClientTestStorage(db)
+
+			book, err := s.GetBookByID(context.Background(), "book-1")
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			if tc.expected.cause != nil {
+				assert.So(errors.Is(err, tc.expected.cause), should.BeTrue)
+				assert.So(errors.As(err, &internal.ErrNonRetryable{}), should.BeTrue)
+			}
+			assert.So(book, should.Resemble, tc.expected.book)
+
+			_, input, _ := db.GetItemArgsForCall(0)
+			assert.So(aws.ToString(input.TableName), should.Equal, "test-library")
+			assert.So(input.Key, should.Resemble, bookKey("book-1"))
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_GetCopyByBarcode(t *testing.T) {
+	type state struct {
+		items   func(t *testing.T) []map[string]types.AttributeValue
+		dbError error
+	}
+	type expected struct {
+		bookCopy internal.Copy
+		err      error
+		cause    error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The call to Query returns an error": {
+			state{dbError: errClientTest},
+			expected{err: errors.New("failed to retrieve the copy from the database: api error ValidationException"), cause: errClientTest},
+		},
+		"There are no items": {
+			state{items: func(t *testing.T) []map[string]types.AttributeValue { return nil }},
+			expected{err: internal.ErrCopyNotFound{Barcode: "copy-1"}},
+		},
+		"The item can't be unmarshalled": {
+			state{items: func(t *testing.T) []map[string]types.AttributeValue {
+				return []map[string]types.AttributeValue{malformedItem()}
+			}},
+			expected{err: errors.New("failed to unmarshal the result from the database: unmarshal failed, cannot unmarshal \"yesterday\" into time.Time")},
+		},
+		"Happy path": {
+			state{items: func(t *testing.T) []map[string]types.AttributeValue {
+				return []map[string]types.AttributeValue{clientTestItem(t, clientTestCopy)}
+			}},
+			expected{bookCopy: clientTestCopy},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			if tc.state.items != nil {
+				db.QueryReturns(&dynamodb.QueryOutput{Items: tc.state.items(t)}, nil)
+			} else {
+				db.QueryReturns(nil, tc.state.dbError)
+			}
+			s := newClientTestStorage(db)
+
+			bookCopy, err := s.GetCopyByBarcode(context.Background(), "copy-1")
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			if tc.expected.cause != nil {
+				assert.So(errors.Is(err, tc.expected.cause), should.BeTrue)
+			}
+			assert.So(bookCopy, should.Resemble, tc.expected.bookCopy)
+
+			_, input, _ := db.QueryArgsForCall(0)
+			assert.So(aws.ToString(input.IndexName), should.Equal, gsi1Index)
+			assert.So(hasValue(input.ExpressionAttributeValues, &types.AttributeValueMemberS{Value: "COPY#copy-1"}), should.BeTrue)
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_GetOpenLoan(t *testing.T) {
+	returnedAt := clientTestNow
+	returnedLoan := clientTestLoan
+	returnedLoan.ReturnedAt = &returnedAt
+
+	type state struct {
+		items   func(t *testing.T) []map[string]types.AttributeValue
+		dbError error
+	}
+	type expected struct {
+		loan  internal.Loan
+		err   error
+		cause error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The call to Query returns an error": {
+			state{dbError: errClientTest},
+			expected{err: errors.New("failed to retrieve the loan from the database: api error ValidationException"), cause: errClientTest},
+		},
+		"The copy has never been lent": {
+			state{items: func(t *testing.T) []map[string]types.AttributeValue { return nil }},
+			expected{err: internal.ErrLoanNotFound{Barcode: "copy-1"}},
+		},
+		"The item can't be unmarshalled": {
+			state{items: func(t *testing.T) []map[string]types.AttributeValue {
+				return []map[string]types.AttributeValue{malformedItem()}
+			}},
+			expected{err: errors.New("failed to unmarshal the result from the database: unmarshal failed, cannot unmarshal \"yesterday\" into time.Time")},
+		},
+		"The copy's latest loan was returned": {
+			state{items: func(t *testing.T) []map[string]types.AttributeValue {
+				return []map[string]types.AttributeValue{clientTestItem(t, returnedLoan)}
+			}},
+			expected{err: internal.ErrLoanNotFound{Barcode: "copy-1"}},
+		},
+		"Happy path": {
+			state{items: func(t *testing.T) []map[string]types.AttributeValue {
+				return []map[string]types.AttributeValue{clientTestItem(t, clientTestLoan)}
+			}},
+			expected{loan: clientTestLoan},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			if tc.state.items != nil {
+				db.QueryReturns(&dynamodb.QueryOutput{Items: tc.state.items(t)}, nil)
+			} else {
+				db.QueryReturns(nil, tc.state.dbError)
+			}
+			s := newClientTestStorage(db)
+
+			loan, err := s.GetOpenLoan(context.Background(), "copy-1")
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			if tc.expected.cause != nil {
+				assert.So(errors.Is(err, tc.expected.cause), should.BeTrue)
+			}
+			assert.So(loan, should.Resemble, tc.expected.loan)
+
+			_, input, _ := db.QueryArgsForCall(0)
+			assert.So(aws.ToBool(input.ScanIndexForward), should.BeFalse)
+			assert.So(aws.ToInt32(input.Limit), should.Equal, 1)
+		})
+	}
+}
+
+// The storage's listings all read every page of a query or scan the same way
+func TestDynamoDBBooksStorage_listings(t *testing.T) {
+	deletedAt := clientTestNow.Add(-time.Minute)
+	deletedBook := clientTestBook
+	deletedBook.DeletedAt = &deletedAt
+	readyAt := clientTestNow.Add(-time.Minute)
+	readyHold := clientTestHold
+	readyHold.Status, readyHold.Barcode, readyHold.ExpiresAt = internal.HoldReady, "copy-1", &readyAt
+
+	type listing struct {
+		call   func(s *dynamodbBooksStorage) (interface{}, error)
+		item   func(t *testing.T) map[string]types.AttributeValue
+		failed string // How the listing wraps the database's error
+		shards int    // How many more partitions than the first the listing queries
+	}
+	listings := map[string]listing{
+		"GetBooks": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetBooks(context.Background(), internal.BookFilter{})
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestBook) },
+			failed: "failed to retrieve all the books from the database",
+			shards: catalogShards - 1,
+		},
+		"GetDeletedBooks": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetDeletedBooks(context.Background())
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, deletedBook) },
+			failed: "failed to retrieve the deleted books from the database",
+			shards: catalogShards - 1,
+		},
+		"GetCopies": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetCopies(context.Background(), "book-1")
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestCopy) },
+			failed: "failed to retrieve the book's copies from the database",
+		},
+		"GetHolds": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetHolds(context.Background(), "book-1")
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestHold) },
+			failed: "failed to retrieve the book's holds from the database",
+		},
+		"GetExpiredHolds": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetExpiredHolds(context.Background(), clientTestNow)
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, readyHold) },
+			failed: "failed to retrieve the expired holds from the database",
+		},
+		"GetPatronLoans": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetPatronLoans(context.Background(), "patron-1")
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestLoan) },
+			failed: "failed to retrieve the patron's loans from the database",
+		},
+		"GetOverdueLoans": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetOverdueLoans(context.Background(), clientTestNow)
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestLoan) },
+			failed: "failed to retrieve the overdue loans from the database",
+		},
+		"GetLedger": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetLedger(context.Background(), "patron-1")
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestEntry) },
+			failed: "failed to retrieve the patron's ledger from the database",
+		},
+		"GetBookHistory": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetBookHistory(context.Background(), "book-1")
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestEvent) },
+			failed: "failed to retrieve the book's history from the database",
+		},
+		"GetAuditEvents by an actor": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetAuditEvents(context.Background(), internal.AuditFilter{Actor: "librarian"})
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestEvent) },
+			failed: "failed to retrieve the audit events from the database",
+		},
+		"GetAuditEvents of today": {
+			call: func(s *dynamodbBooksStorage) (interface{}, error) {
+				return s.GetAuditEvents(context.Background(), internal.AuditFilter{Since: clientTestNow})
+			},
+			item:   func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, clientTestEvent) },
+			failed: "failed to retrieve the audit events from the database",
+		},
+	}
+
+	for name, l := range listings {
+		t.Run(name, func(t *testing.T) {
+			type state struct {
+				pages   [][]map[string]types.AttributeValue
+				dbError error
+			}
+			type expected struct {
+				count int // How many results the listing returns
+				pages int // How many pages it asks for
+				err   error
+			}
+			testCases := map[string]struct {
+				state    state
+				expected expected
+			}{
+				"The call to the database returns an error": {
+					state{dbError: errClientTest},
+					expected{pages: 1, err: errors.New(l.failed + ": api error ValidationException")},
+				},
+				"There are no items": {
+					state{pages: [][]map[string]types.AttributeValue{{}}},
+					expected{pages: 1},
+				},
+				"An item can't be unmarshalled": {
+					state{pages: [][]map[string]types.AttributeValue{{malformedItem()}, {l.item(t)}}},
+					expected{pages: 1, err: errors.New("failed to unmarshal the result from the database: unmarshal failed, cannot unmarshal \"yesterday\" into time.Time")},
+				},
+				"Every page is read": {
+					state{pages: [][]map[string]types.AttributeValue{{l.item(t)}, {}, {l.item(t)}}},
+					expected{count: 2, pages: 3},
+				},
+			}
+
+			for name, tc := range testCases {
+				t.Run(name, func(t *testing.T) {
+					assert := assertions.New(t)
+					db := &mocks.MockDynamoDBAPI{}
+					db.QueryStub = func(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+						assert.So(aws.ToString(input.TableName), should.Equal, "test-library")
+						if tc.state.dbError != nil {
+							return nil, tc.state.dbError
+						}
+						if otherShard(input) {
+							return &dynamodb.QueryOutput{}, nil
+						}
+						items, next := page(input.ExclusiveStartKey, tc.state.pages)
+						return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: next}, nil
+					}
+					s := newClientTestStorage(db)
+
+					result, err := l.call(s)
+
+					assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+					if tc.state.dbError != nil {
+						assert.So(errors.Is(err, tc.state.dbError), should.BeTrue)
+					}
+					pages := tc.expected.pages
+					if tc.expected.err == nil {
+						assert.So(result, should.HaveLength, tc.expected.count)
+						pages += l.shards // A listing stops at the first shard that fails
+					}
+					assert.So(db.QueryCallCount(), should.Equal, pages)
+					assert.So(db.ScanCallCount(), should.Equal, 0)
+				})
+			}
+		})
+	}
+}
+
+// Every change to more than one item is written in a transaction, whose failed conditions the storage
+// reports as the domain's errors
+func TestDynamoDBBooksStorage_transactions(t *testing.T) {
+	deletedAt := clientTestNow.Add(-time.Minute)
+	deletedBook := clientTestBook
+	deletedBook.DeletedAt = &deletedAt
+	updatedBook := clientTestBook
+	updatedBook.ISBN = "9780441172696"
+	expiresAt := clientTestNow.Add(72 * time.Hour)
+
+	type transaction struct {
+		serve      func(t *testing.T, db *mocks.MockDynamoDBAPI) // The reads before the transaction
+		call       func(s *dynamodbBooksStorage) error
+		writes     int    // How many writes the transaction makes
+		failed     string // How the database's error is wrapped
+		conflict   error  // What a failed condition of the first write is reported as
+		condition  int    // The write whose failed condition is reported otherwise, if any
+		conditionE error  // What it's reported as
+	}
+	transactions := map[string]transaction{
+		"CreateBook": {
+			call: func(s *dynamodbBooksStorage) error {
+				_, err := s.CreateBook(context.Background(), "Dune", "Frank Herbert", "9780441013593", "")
+				return err
+			},
+			writes:     3,
+			failed:     "failed to create the new book in the database",
+			conflict:   errors.New("failed to create the new book in the database: TransactionCanceledException"),
+			condition:  2,
+			conditionE: internal.ErrDuplicateISBN{ISBN: "9780441013593"},
+		},
+		"UpdateBook": {
+			serve: func(t *testing.T, db *mocks.MockDynamoDBAPI) { serveBook(t, db, clientTestBook) },
+			call: func(s *dynamodbBooksStorage) error {
+				_, err := s.UpdateBook(context.Background(), "book-1", updatedBook)
+				return err
+			},
+			writes:     4,
+			failed:     "failed to update the book in the database",
+			conflict:   internal.ErrBookNotFound{BookID: "book-1"},
+			condition:  3,
+			conditionE: internal.ErrDuplicateISBN{ISBN: updatedBook.ISBN},
+		},
+		"DeleteBook": {
+			serve:     func(t *testing.T, db *mocks.MockDynamoDBAPI) { serveBook(t, db, clientTestBook) },
+			call:      func(s *dynamodbBooksStorage) error { return s.DeleteBook(context.Background(), "book-1") },
+			writes:    2,
+			failed:    "failed to delete the book from the database",
+			conflict:  internal.ErrBookNotFound{BookID: "book-1"},
+			condition: -1,
+		},
+		"RestoreBook": {
+			serve: func(t *testing.T, db *mocks.MockDynamoDBAPI) { serveBook(t, db, deletedBook) },
+			call: func(s *dynamodbBooksStorage) error {
+				_, err := s.RestoreBook(context.Background(), "book-1")
+				return err
+			},
+			writes:    2,
+			failed:    "failed to restore the book in the database",
+			conflict:  internal.ErrBookNotFound{BookID: "book-1"},
+			condition: -1,
+		},
+		"PurgeBook": {
+			serve: func(t *testing.T, db *mocks.MockDynamoDBAPI) {
+				serveBook(t, db, deletedBook)
+				db.BatchWriteItemReturns(&dynamodb.BatchWriteItemOutput{}, nil)
+			},
+			call:      func(s *dynamodbBooksStorage) error { return s.PurgeBook(context.Background(), "book-1") },
+			writes:    3, // The book, its audit event and its ISBN, once its copies, holds and loans are deleted
+			failed:    "failed to purge the book from the database",
+			conflict:  internal.ErrBookNotFound{BookID: "book-1"},
+			condition: -1,
+		},
+		"AddCopy": {
+			serve: func(t *testing.T, db *mocks.MockDynamoDBAPI) { serveBook(t, db, clientTestBook) },
+			call: func(s *dynamodbBooksStorage) error {
+				_, err := s.AddCopy(context.Background(), clientTestCopy)
+				return err
+			},
+			writes:    3,
+			failed:    "failed to add the copy to the database",
+			conflict:  internal.ErrBookNotFound{BookID: "book-1"},
+			condition: -1,
+		},
+		"UpdateCopyStatus": {
+			serve: func(t *testing.T, db *mocks.MockDynamoDBAPI) { serveBook(t, db, clientTestBook, clientTestCopy) },
+			call: func(s *dynamodbBooksStorage) error {
+				_, err := s.UpdateCopyStatus(context.Background(), "copy-1", internal.CheckedIn, internal.CheckedOut)
+				return err
+			},
+			writes:    3,
+			failed:    "failed to update the copy in the database",
+			conflict:  internal.ErrCopyStatusConflict{Barcode: "copy-1", Status: internal.CheckedIn},
+			condition: -1,
+		},
+		"ReserveCopy": {
+			serve: func(t *testing.T, db *mocks.MockDynamoDBAPI) { serveBook(t, db, clientTestBook, clientTestCopy) },
+			call: func(s *dynamodbBooksStorage) error {
+				_, err := s.ReserveCopy(context.Background(), clientTestHold, "copy-1", internal.CheckedIn, expiresAt)
+				return err
+			},
+			writes:     4,
+			failed:     "failed to reserve the copy in the database",
+			conflict:   internal.ErrCopyStatusConflict{Barcode: "copy-1", Status: internal.CheckedIn},
+			condition:  1,
+			conditionE: internal.ErrHoldNotFound{HoldID: "hold-1"},
+		},
+		"CreateLoan": {
+			serve: func(t *testing.T, db *mocks.MockDynamoDBAPI) {
+				db.QueryReturns(&dynamodb.QueryOutput{}, nil)
+			},
+			call: func(s *dynamodbBooksStorage) error {
+				_, err := s.CreateLoan(context.Background(), clientTestLoan)
+				return err
+			},
+			writes:    2,
+			failed:    "failed to create the loan in the database",
+			conflict:  errors.New("failed to create the loan in the database: TransactionCanceledException"),
+			condition: -1,
+		},
+		"CheckOutCopy": {
+			serve: func(t *testing.T, db *mocks.MockDynamoDBAPI) { serveBook(t, db, clientTestBook, clientTestCopy) },
+			call: func(s *dynamodbBooksStorage) error {
+				hold := clientTestHold
+				_, _, err := s.CheckOutCopy(context.Background(), clientTestLoan, internal.CheckedIn, &hold)
+				return err
+			},
+			writes:     6, // The copy, the loan, the patron's count, the hold, the book and its audit event
+			failed:     "failed to check out the copy in the database",
+			conflict:   internal.ErrCopyStatusConflict{Barcode: "copy-1", Status: internal.CheckedIn},
+			condition:  3,
+			conditionE: internal.ErrHoldNotFound{HoldID: "hold-1"},
+		},
+		"CloseLoan": {
+			call: func(s *dynamodbBooksStorage) error {
+				_, err := s.CloseLoan(context.Background(), clientTestLoan, clientTestNow)
+				return err
+			},
+			writes:    2,
+			failed:    "failed to update the loan in the database",
+			conflict:  internal.ErrLoanChanged{LoanID: "loan-1"},
+			condition: -1,
+		},
+	}
+
+	for name, tx := range transactions {
+		t.Run(name, func(t *testing.T) {
+			type state struct {
+				dbError error
+			}
+			type expected struct {
+				err   error
+				cause error
+			}
+			testCases := map[string]struct {
+				state    state
+				expected expected
+			}{
+				"The call to TransactWriteItems returns an error": {
+					state{dbError: errClientTest},
+					expected{err: errors.New(tx.failed + ": api error ValidationException"), cause: errClientTest},
+				},
+				"The first write's condition fails": {
+					state{dbError: transactionCanceled(tx.writes, 0)},
+					expected{err: tx.conflict},
+				},
+				"Happy path": {
+					state{},
+					expected{},
+				},
+			}
+			if tx.condition >= 0 {
+				testCases["Another write's condition fails"] = struct {
+					state    state
+					expected expected
+				}{
+					state{dbError: transactionCanceled(tx.writes, tx.condition)},
+					expected{err: tx.conditionE},
+				}
+			}
+
+			for name, tc := range testCases {
+				t.Run(name, func(t *testing.T) {
+					assert := assertions.New(t)
+					db := &mocks.MockDynamoDBAPI{}
+					if tx.serve != nil {
+						tx.serve(t, db)
+					}
+					if tc.state.dbError != nil {
+						db.TransactWriteItemsReturns(nil, tc.state.dbError)
+					} else {
+						db.TransactWriteItemsReturns(&dynamodb.TransactWriteItemsOutput{}, nil)
+					}
+					s := newClientTestStorage(db)
+
+					err := tx.call(s)
+
+					assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+					if tc.expected.cause != nil {
+						assert.So(errors.Is(err, tc.expected.cause), should.BeTrue)
+					}
+					assert.So(db.TransactWriteItemsCallCount(), should.Equal, 1)
+					_, input, _ := db.TransactWriteItemsArgsForCall(0)
+					assert.So(input.TransactItems, should.HaveLength, tx.writes)
+				})
+			}
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_PlaceHold(t *testing.T) {
+	type state struct {
+		holds   []internal.Hold
+		dbError error
+	}
+	type expected struct {
+		hold  internal.Hold
+		err   error
+		cause error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The call to PutItem returns an error": {
+			state{dbError: errClientTest},
+			expected{err: errors.New("failed to place the hold in the database: api error ValidationException"), cause: errClientTest},
+		},
+		"The patron already has a hold on the book": {
+			state{holds: []internal.Hold{clientTestHold}},
+			expected{err: internal.ErrDuplicateHold{BookID: "book-1", PatronID: "patron-1"}},
+		},
+		"Happy path": {
+			state{},
+			expected{hold: internal.Hold{BookID: "book-1", PatronID: "patron-1", Status: internal.HoldWaiting, PlacedAt: clientTestNow}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			db.GetItemReturns(&dynamodb.GetItemOutput{Item: clientTestItem(t, clientTestBook)}, nil)
+			items := make([]map[string]types.AttributeValue, 0, len(tc.state.holds))
+			for _, hold := range tc.state.holds {
+				items = append(items, clientTestItem(t, hold))
+			}
+			serveQueryPages(db, items)
+			if tc.state.dbError != nil {
+				db.PutItemReturns(nil, tc.state.dbError)
+			} else {
+				db.PutItemReturns(&dynamodb.PutItemOutput{}, nil)
+			}
+			s := newClientTestStorage(db)
+
+			hold, err := s.PlaceHold(context.Background(), internal.Hold{BookID: "book-1", PatronID: "patron-1"})
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			if tc.expected.cause != nil {
+				assert.So(errors.Is(err, tc.expected.cause), should.BeTrue)
+			}
+			if tc.expected.err != nil {
+				return
+			}
+			assert.So(hold.ID, should.NotBeEmpty)
+			tc.expected.hold.ID = hold.ID
+			assert.So(hold, should.Resemble, tc.expected.hold)
+
+			_, input, _ := db.PutItemArgsForCall(0)
+			assert.So(input.Item, should.Resemble, clientTestItem(t, hold))
+			assert.So(aws.ToString(input.ConditionExpression), should.Equal, "attribute_not_exists (#0)")
+			assert.So(input.ExpressionAttributeNames["#0"], should.Equal, "PK")
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_CloseHold(t *testing.T) {
+	type state struct {
+		dbError error
+	}
+	type expected struct {
+		err   error
+		cause error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The call to UpdateItem returns an error": {
+			state{dbError: errClientTest},
+			expected{err: errors.New("failed to close the hold in the database: api error ValidationException"), cause: errClientTest},
+		},
+		"The hold isn't active": {
+			state{dbError: errConditionFailed},
+			expected{err: internal.ErrHoldNotFound{HoldID: "hold-1"}},
+		},
+		"Happy path": {
+			state{},
+			expected{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			if tc.state.dbError != nil {
+				db.UpdateItemReturns(nil, tc.state.dbError)
+			} else {
+				db.UpdateItemReturns(&dynamodb.UpdateItemOutput{}, nil)
+			}
+			s := newClientTestStorage(db)
+
+			err := s.CloseHold(context.Background(), clientTestHold, internal.HoldCancelled)
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			if tc.expected.cause != nil {
+				assert.So(errors.Is(err, tc.expected.cause), should.BeTrue)
+			}
+			_, input, _ := db.UpdateItemArgsForCall(0)
+			assert.So(input.Key, should.Resemble, holdItemKey(clientTestHold))
+			assert.So(hasValue(input.ExpressionAttributeValues, &types.AttributeValueMemberS{Value: string(internal.HoldCancelled)}), should.BeTrue)
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_RenewLoan(t *testing.T) {
+	dueAt := clientTestNow.Add(14 * 24 * time.Hour)
+	renewed := clientTestLoan
+	renewed.DueAt = dueAt
+	renewed.Renewals = 1
+
+	type state struct {
+		attributes func(t *testing.T) map[string]types.AttributeValue
+		dbError    error
+	}
+	type expected struct {
+		loan  internal.Loan
+		err   error
+		cause error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The call to UpdateItem returns an error": {
+			state{dbError: errClientTest},
+			expected{err: errors.New("failed to update the loan in the database: api error ValidationException"), cause: errClientTest},
+		},
+		"The loan was renewed or returned in the meantime": {
+			state{dbError: errConditionFailed},
+			expected{err: internal.ErrLoanChanged{LoanID: "loan-1"}},
+		},
+		"The renewed loan isn't returned": {
+			state{attributes: func(t *testing.T) map[string]types.AttributeValue { return nil }},
+			expected{err: errors.New("the database didn't return the renewed loan")},
+		},
+		"The renewed loan can't be unmarshalled": {
+			state{attributes: func(t *testing.T) map[string]types.AttributeValue { return malformedItem() }},
+			expected{err: errors.New("failed to unmarshal the result from the database: unmarshal failed, cannot unmarshal \"yesterday\" into time.Time")},
+		},
+		"Happy path": {
+			state{attributes: func(t *testing.T) map[string]types.AttributeValue { return clientTestItem(t, renewed) }},
+			expected{loan: renewed},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			if tc.state.attributes != nil {
+				db.UpdateItemReturns(&dynamodb.UpdateItemOutput{Attributes: tc.state.attributes(t)}, nil)
+			} else {
+				db.UpdateItemReturns(nil, tc.state.dbError)
+			}
+			s := newClientTestStorage(db)
+
+			loan, err := s.RenewLoan(context.Background(), clientTestLoan, dueAt)
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			if tc.expected.cause != nil {
+				assert.So(errors.Is(err, tc.expected.cause), should.BeTrue)
+			}
+			assert.So(loan, should.Resemble, tc.expected.loan)
+
+			_, input, _ := db.UpdateItemArgsForCall(0)
+			assert.So(input.Key, should.Resemble, loanItemKey(clientTestLoan))
+			assert.So(input.ReturnValues, should.Equal, types.ReturnValueAllNew)
+			assert.So(hasValue(input.ExpressionAttributeValues, &types.AttributeValueMemberN{Value: "0"}), should.BeTrue)
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_AddLedgerEntry(t *testing.T) {
+	type state struct {
+		dbError error
+	}
+	type expected struct {
+		err   error
+		cause error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The call to PutItem returns an error": {
+			state{dbError: errClientTest},
+			expected{err: errors.New("failed to record the ledger entry in the database: api error ValidationException"), cause: errClientTest},
+		},
+		"Happy path": {
+			state{},
+			expected{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			if tc.state.dbError != nil {
+				db.PutItemReturns(nil, tc.state.dbError)
+			} else {
+				db.PutItemReturns(&dynamodb.PutItemOutput{}, nil)
+			}
+			s := newClientTestStorage(db)
+			ctx := internal.ContextWithActor(context.Background(), "librarian")
+
+			entry, err := s.AddLedgerEntry(ctx, internal.LedgerEntry{PatronID: "patron-1", Type: internal.LedgerPayment, Amount: 100})
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			if tc.expected.cause != nil {
+				assert.So(errors.Is(err, tc.expected.cause), should.BeTrue)
+				assert.So(entry, should.Resemble, internal.LedgerEntry{})
+				return
+			}
+			assert.So(entry.ID, should.NotBeEmpty)
+			assert.So(entry.CreatedAt, should.Equal, clientTestNow)
+			assert.So(entry.CreatedBy, should.Equal, "librarian")
+
+			_, input, _ := db.PutItemArgsForCall(0)
+			assert.So(input.Item, should.Resemble, clientTestItem(t, entry))
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_PurgeDeletedBooks(t *testing.T) {
+	deletedAt := clientTestNow.Add(-31 * 24 * time.Hour)
+	oldBook := clientTestBook
+	oldBook.DeletedAt = &deletedAt
+	recentAt := clientTestNow.Add(-time.Hour)
+	recentBook := clientTestBook
+	recentBook.ID = "book-2"
+	recentBook.DeletedAt = &recentAt
+
+	type state struct {
+		queryError    error
+		book          map[string]types.AttributeValue // What GetItem answers with
+		transactError error
+	}
+	type expected struct {
+		purged int
+		err    error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The deleted books can't be listed": {
+			state{queryError: errClientTest},
+			expected{err: errors.New("failed to retrieve the deleted books from the database: api error ValidationException")},
+		},
+		"The purge fails": {
+			state{transactError: errClientTest},
+			expected{err: errors.New("failed to purge the book from the database: api error ValidationException")},
+		},
+		"The book was purged in the meantime": {
+			state{book: map[string]types.AttributeValue{}},
+			expected{purged: 1},
+		},
+		"Happy path": {
+			state{},
+			expected{purged: 1},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			db.QueryStub = func(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+				if tc.state.queryError != nil {
+					return nil, tc.state.queryError
+				}
+				if aws.ToString(input.IndexName) == gsi1Index && !otherShard(input) { // The trash
+					return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{clientTestItem(t, oldBook), clientTestItem(t, recentBook)}}, nil
+				}
+				return &dynamodb.QueryOutput{}, nil
+			}
+			book := tc.state.book
+			if book == nil {
+				book = clientTestItem(t, oldBook)
+			}
+			db.GetItemReturns(&dynamodb.GetItemOutput{Item: book}, nil)
+			db.TransactWriteItemsReturns(&dynamodb.TransactWriteItemsOutput{}, tc.state.transactError)
+			s := newClientTestStorage(db)
+
+			purged, err := s.PurgeDeletedBooks(context.Background(), clientTestNow.Add(-30*24*time.Hour))
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			assert.So(purged, should.Equal, tc.expected.purged)
+			if db.GetItemCallCount() > 0 {
+				_, input, _ := db.GetItemArgsForCall(0)
+				assert.So(input.Key, should.Resemble, bookKey(oldBook.ID))
+			}
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_ApplySchema_client(t *testing.T) {
+	described := func(keys []types.KeySchemaElement, indexes ...types.GlobalSecondaryIndex) *dynamodb.DescribeTableOutput {
+		table := &types.TableDescription{TableName: aws.String("test-library"), KeySchema: keys, TableStatus: types.TableStatusActive}
+		for _, index := range indexes {
+			table.GlobalSecondaryIndexes = append(table.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+				IndexName:   index.IndexName,
+				KeySchema:   index.KeySchema,
+				IndexStatus: types.IndexStatusActive,
+			})
+		}
+		return &dynamodb.DescribeTableOutput{Table: table}
+	}
+	definition := newClientTestStorage(&mocks.MockDynamoDBAPI{}).schema()[0]
+
+	type state struct {
+		described   *dynamodb.DescribeTableOutput
+		describeErr error
+		createErr   error
+		created     bool // Whether the table is active once it's created
+		updateErr   error
+	}
+	type expected struct {
+		changes []SchemaChange
+		created bool
+		updated bool
+		err     error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The table can't be described": {
+			state{describeErr: errClientTest},
+			expected{err: errors.New("failed to describe the test-library table: api error ValidationException")},
+		},
+		"The table can't be created": {
+			state{describeErr: errNotFound, createErr: errClientTest},
+			expected{created: true, err: errors.New("failed to create the test-library table: api error ValidationException")},
+		},
+		"The table isn't created in time": {
+			state{describeErr: errNotFound},
+			expected{created: true, err: errors.New("failed to wait for the test-library table to be created: request cancelled while waiting")},
+		},
+		"The table is created": {
+			state{describeErr: errNotFound, created: true},
+			expected{created: true, changes: []SchemaChange{{Table: "test-library"}}},
+		},
+		"The table's key doesn't match": {
+			state{described: described(keySchema("PK", ""))},
+			expected{err: errors.New("the test-library table's key doesn't match the schema")},
+		},
+		"An index's key doesn't match": {
+			state{described: described(definition.KeySchema, globalIndex(gsi1Index, "GSI1PK", ""))},
+			expected{err: errors.New("the key of the test-library table's GSI1 index doesn't match the schema")},
+		},
+		"An index can't be added": {
+			state{described: described(definition.KeySchema), updateErr: errClientTest},
+			expected{updated: true, err: errors.New("failed to add the GSI1 index to the test-library table: api error ValidationException")},
+		},
+		"The table is up to date": {
+			state{described: described(definition.KeySchema, definition.GlobalSecondaryIndexes...)},
+			expected{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			db.DescribeTableReturns(tc.state.described, tc.state.describeErr)
+			if tc.state.created {
+				db.DescribeTableReturnsOnCall(1, described(definition.KeySchema, definition.GlobalSecondaryIndexes...), nil)
+			}
+			db.CreateTableReturns(&dynamodb.CreateTableOutput{}, tc.state.createErr)
+			db.UpdateTableReturns(&dynamodb.UpdateTableOutput{}, tc.state.updateErr)
+			s := newClientTestStorage(db)
+			// The waiter checks on a new table every 20 seconds at first, which the test doesn't wait for
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			changes, err := s.ApplySchema(ctx)
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			assert.So(changes, should.Resemble, tc.expected.changes)
+			assert.So(db.CreateTableCallCount() == 1, should.Equal, tc.expected.created)
+			assert.So(db.UpdateTableCallCount() == 1, should.Equal, tc.expected.updated)
+			if tc.expected.created {
+				_, input, _ := db.CreateTableArgsForCall(0)
+				assert.So(input, should.Resemble, definition)
+			}
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_MigrateLegacyTables_client(t *testing.T) {
+	legacyBook := func(t *testing.T) map[string]types.AttributeValue {
+		return legacyTestItem(t, clientTestBook, nil)
+	}
+
+	type state struct {
+		books       func(t *testing.T) map[string]types.AttributeValue // The legacy books table's only item
+		scanErr     error                                              // The error of scanning the books table
+		batchErrs   []error                                            // The errors of each BatchWriteItem call
+		unprocessed int                                                // How many BatchWriteItem calls leave the write unprocessed
+	}
+	type expected struct {
+		migrations []TableMigration
+		batches    int
+		err        error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"There are no legacy tables": {
+			state{scanErr: errNotFound},
+			expected{},
+		},
+		"A legacy table can't be read": {
+			state{scanErr: errClientTest},
+			expected{err: errors.New("failed to read the test-books table: api error ValidationException")},
+		},
+		"An item can't be converted": {
+			state{books: func(t *testing.T) map[string]types.AttributeValue { return malformedItem() }},
+			expected{err: errors.New("failed to convert an item of the test-books table: ")},
+		},
+		"The items can't be copied": {
+			state{books: legacyBook, batchErrs: []error{errClientTest}},
+			expected{batches: 1, err: errors.New("failed to copy the test-books table: api error ValidationException")},
+		},
+		"The unprocessed items are written again": {
+			state{books: legacyBook, unprocessed: 2},
+			expected{batches: 3, migrations: []TableMigration{{Table: "test-books", Items: 1}}},
+		},
+		"Happy path": {
+			state{books: legacyBook},
+			expected{batches: 1, migrations: []TableMigration{{Table: "test-books", Items: 1}}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := &mocks.MockDynamoDBAPI{}
+			db.ScanStub = func(_ context.Context, input *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+				if aws.ToString(input.TableName) != "test-books" {
+					return nil, errNotFound
+				}
+				if tc.state.scanErr != nil {
+					return nil, tc.state.scanErr
+				}
+				return &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{tc.state.books(t)}}, nil
+			}
+			db.BatchWriteItemStub = func(_ context.Context, input *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+				call := db.BatchWriteItemCallCount() - 1
+				if call < len(tc.state.batchErrs) {
+					return nil, tc.state.batchErrs[call]
+				}
+				if call < tc.state.unprocessed {
+					return &dynamodb.BatchWriteItemOutput{UnprocessedItems: input.RequestItems}, nil
+				}
+				return &dynamodb.BatchWriteItemOutput{}, nil
+			}
+			s := newClientTestStorage(db)
+
+			migrations, err := s.MigrateLegacyTables(context.Background())
+
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			assert.So(migrations, should.Resemble, tc.expected.migrations)
+			assert.So(db.BatchWriteItemCallCount(), should.Equal, tc.expected.batches)
+			for i := 0; i < db.BatchWriteItemCallCount(); i++ {
+				_, input, _ := db.BatchWriteItemArgsForCall(i)
+				assert.So(input.RequestItems["test-library"], should.HaveLength, 1)
+			}
+		})
+	}
+}
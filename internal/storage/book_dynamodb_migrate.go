@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/aaron-zeisler/library-api/internal"
+)
+
+// The storage used to keep each kind of item in a table of its own, named <prefix>-<kind>. Their items are
+// copied into the library table by MigrateLegacyTables.
+var legacyTables = []string{"books", "copies", "holds", "loans", "ledger", "audit"}
+
+// TableMigration is how many items MigrateLegacyTables copied from a legacy table
+type TableMigration struct {
+	Table string
+	Items int
+}
+
+func (m TableMigration) String() string {
+	return fmt.Sprintf("%d items from %s", m.Items, m.Table)
+}
+
+// MigrateLegacyTables copies the items of the tables the storage used before the library table into it,
+// keyed the way the storage keys them now, and counts each patron's open loans. A legacy table that doesn't
+// exist is skipped. The legacy tables are left as they are, and copying them again overwrites the copies,
+// so the migration can be run again if it's interrupted. The library table is expected to exist, and not to
+// be written to by anything else while the migration runs.
+func (s *dynamodbBooksStorage) MigrateLegacyTables(ctx context.Context) ([]TableMigration, error) {
+	var result []TableMigration
+	openLoans := make(map[string]int)
+
+	for _, kind := range legacyTables {
+		table := s.tablePrefix + "-" + kind
+
+		items, err := s.scanLegacyTable(ctx, table)
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read the %s table: %w", table, err)
+		}
+
+		converted := make([]map[string]types.AttributeValue, 0, len(items))
+		for _, item := range items {
+			item, err := legacyItem(kind, item, openLoans)
+			if err != nil {
+				return result, fmt.Errorf("failed to convert an item of the %s table: %w", table, err)
+			}
+			converted = append(converted, item)
+		}
+
+		if err := s.batchPut(ctx, converted); err != nil {
+			return result, fmt.Errorf("failed to copy the %s table: %w", table, err)
+		}
+		result = append(result, TableMigration{Table: table, Items: len(items)})
+	}
+
+	patrons := make([]string, 0, len(openLoans))
+	for patronID := range openLoans {
+		patrons = append(patrons, patronID)
+	}
+	sort.Strings(patrons)
+
+	items := make([]map[string]types.AttributeValue, 0, len(patrons))
+	for _, patronID := range patrons {
+		item := itemKey(patronPrefix+patronID, patronSortKey)
+		item["patron_id"] = &types.AttributeValueMemberS{Value: patronID}
+		item["loan_count"] = &types.AttributeValueMemberN{Value: strconv.Itoa(openLoans[patronID])}
+		items = append(items, item)
+	}
+	if err := s.batchPut(ctx, items); err != nil {
+		return result, fmt.Errorf("failed to count the patrons' loans: %w", err)
+	}
+
+	return result, nil
+}
+
+// scanLegacyTable reads every item of the table
+func (s *dynamodbBooksStorage) scanLegacyTable(ctx context.Context, table string) ([]map[string]types.AttributeValue, error) {
+	var result []map[string]types.AttributeValue
+	err := s.scanPages(ctx, &dynamodb.ScanInput{
+		TableName:      aws.String(table),
+		ConsistentRead: aws.Bool(true),
+	}, func(page *dynamodb.ScanOutput) bool {
+		result = append(result, page.Items...)
+		return true
+	})
+	return result, err
+}
+
+// legacyItem converts an item of the legacy table of the kind into the library table's item, and counts
+// the patron's loan if it's an open one
+func legacyItem(kind string, item map[string]types.AttributeValue, openLoans map[string]int) (map[string]types.AttributeValue, error) {
+	switch kind {
+	case "books":
+		// The books table held the ISBN reservations too, keyed by the ISBN after a prefix
+		if id := stringAttribute(item, "id"); strings.HasPrefix(id, "isbn#") {
+			return isbnReservation(stringAttribute(item, "book_id"), strings.TrimPrefix(id, "isbn#")), nil
+		}
+		var book internal.Book
+		if err := unmarshalItem(item, &book); err != nil {
+			return nil, err
+		}
+		return bookItem(book)
+	case "copies":
+		var bookCopy internal.Copy
+		if err := unmarshalItem(item, &bookCopy); err != nil {
+			return nil, err
+		}
+		return copyItem(bookCopy)
+	case "holds":
+		var hold internal.Hold
+		if err := unmarshalItem(item, &hold); err != nil {
+			return nil, err
+		}
+		return holdItem(hold)
+	case "loans":
+		var loan internal.Loan
+		if err := unmarshalItem(item, &loan); err != nil {
+			return nil, err
+		}
+		if loan.IsOpen() {
+			openLoans[loan.PatronID]++
+		}
+		return loanItem(loan)
+	case "ledger":
+		var entry internal.LedgerEntry
+		if err := unmarshalItem(item, &entry); err != nil {
+			return nil, err
+		}
+		return ledgerItem(entry)
+	case "audit":
+		var event internal.AuditEvent
+		if err := unmarshalItem(item, &event); err != nil {
+			return nil, err
+		}
+		return auditEventItem(event)
+	default:
+		return nil, fmt.Errorf("unknown legacy table '%s'", kind)
+	}
+}
+
+// stringAttribute is the item's string attribute, or empty if the item doesn't have it
+func stringAttribute(item map[string]types.AttributeValue, name string) string {
+	if value, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return value.Value
+	}
+	return ""
+}
+
+// unshardedCatalog is the one partition of GSI1 that every book was listed in before the catalog was
+// sharded
+const unshardedCatalog = "BOOKS"
+
+// ShardCatalog moves the books still listed in the unsharded catalog partition of GSI1 into their shards,
+// and returns how many it moved. A book that's rewritten in the meantime is moved by the rewrite, so the
+// migration can run while the table is in use, and again if it's interrupted.
+func (s *dynamodbBooksStorage) ShardCatalog(ctx context.Context) (int, error) {
+	input, err := s.queryInput(gsi1Index, expression.NewBuilder().
+		WithKeyCondition(expression.Key("GSI1PK").Equal(expression.Value(unshardedCatalog))).
+		WithProjection(expression.NamesList(expression.Name("PK"), expression.Name("SK"), expression.Name("GSI1SK"))))
+	if err != nil {
+		return 0, err
+	}
+
+	var items []map[string]types.AttributeValue
+	callCtx, done := s.instrument(ctx, "Query", "")
+	err = s.queryPages(callCtx, input, func(page *dynamodb.QueryOutput) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err = done(nil, err); err != nil {
+		return 0, fmt.Errorf("failed to read the unsharded catalog: %w", err)
+	}
+
+	moved := 0
+	for _, item := range items {
+		bookID := stringAttribute(item, "GSI1SK")
+		update, err := expression.NewBuilder().
+			WithUpdate(expression.Set(expression.Name("GSI1PK"), expression.Value(catalogShard(bookID)))).
+			WithCondition(expression.Name("GSI1PK").Equal(expression.Value(unshardedCatalog))).
+			Build()
+		if err != nil {
+			return moved, fmt.Errorf("failed to build the update: %w", err)
+		}
+
+		callCtx, done := s.instrument(ctx, "UpdateItem", bookID)
+		_, err = s.db.UpdateItem(callCtx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(s.tableName),
+			Key:                       map[string]types.AttributeValue{"PK": item["PK"], "SK": item["SK"]},
+			UpdateExpression:          update.Update(),
+			ConditionExpression:       update.Condition(),
+			ExpressionAttributeNames:  update.Names(),
+			ExpressionAttributeValues: update.Values(),
+		}, retryUntil(callCtx))
+		err = done(nil, err)
+		if isConditionalCheckFailure(err) {
+			continue
+		}
+		if err != nil {
+			return moved, fmt.Errorf("failed to move the book %s into its catalog shard: %w", bookID, err)
+		}
+		moved++
+	}
+	return moved, nil
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/aaron-zeisler/library-api/internal"
+)
+
+// RetryPolicy decides how the DynamoDB storage retries a call that failed in a way that may pass, such as
+// throttling or a server error. It makes up to MaxAttempts attempts in all, and waits between them for a
+// random time of up to BaseDelay, doubled for each attempt so far and capped at MaxDelay. It stops early
+// rather than wait past the context's deadline.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+
+	random func() float64 // The jitter, in [0, 1)
+}
+
+// DefaultRetryPolicy is the storage's retry policy unless WithRetryPolicy says otherwise. It gives up within
+// about a second and a half, well inside a Lambda's timeout.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: 50 * time.Millisecond, MaxDelay: time.Second}
+
+// WithRetryPolicy sets how the storage retries the calls that DynamoDB throttled or failed
+func WithRetryPolicy(policy RetryPolicy) DynamoBooksStorageOption {
+	return func(db *dynamodbBooksStorage) {
+		db.retryPolicy = policy
+	}
+}
+
+// backoff is the longest the policy waits after the attempt, counted from 0
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	result := p.BaseDelay
+	for i := 0; i < attempt && result < p.MaxDelay; i++ {
+		result *= 2
+	}
+	if result > p.MaxDelay {
+		result = p.MaxDelay
+	}
+	return result
+}
+
+// delay is how long the policy waits after the attempt: a random time up to its backoff, so that the
+// clients DynamoDB throttled together don't retry together
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	random := p.random
+	if random == nil {
+		random = rand.Float64
+	}
+	return time.Duration(random() * float64(p.backoff(attempt)))
+}
+
+// retryer applies the policy to the SDK's requests, so every call the storage makes, including each page
+// of a query, is retried the same way. The SDK doesn't give the retryer the call's context when it decides
+// how long to wait, so each call sets the deadline it's bound by, if any, with retryUntil.
+type retryer struct {
+	policy   RetryPolicy
+	deadline time.Time
+}
+
+// retryUntil binds the storage's retryer to the context's deadline for a single call. A client given to
+// WithClient keeps its own retryer.
+func retryUntil(ctx context.Context) func(*dynamodb.Options) {
+	return func(o *dynamodb.Options) {
+		r, ok := o.Retryer.(retryer)
+		if !ok {
+			return
+		}
+		r.deadline, _ = ctx.Deadline()
+		o.Retryer = r
+	}
+}
+
+func (r retryer) IsErrorRetryable(err error) bool {
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary ||
+		retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary
+}
+
+// MaxAttempts is at least one, since the SDK takes zero to mean that it retries forever
+func (r retryer) MaxAttempts() int {
+	if r.policy.MaxAttempts < 1 {
+		return 1
+	}
+	return r.policy.MaxAttempts
+}
+
+// RetryDelay is given the attempt counted from 1. Returning the error gives up on the call.
+func (r retryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	// Waiting past the deadline would only turn the failure into a cancellation
+	if !r.deadline.IsZero() && time.Until(r.deadline) <= r.policy.backoff(attempt-1) {
+		return 0, err
+	}
+	return r.policy.delay(attempt - 1), nil
+}
+
+// The policy doesn't limit how many retries a client makes in all, so its tokens are free
+
+func (r retryer) GetRetryToken(context.Context, error) (func(error) error, error) {
+	return releaseToken, nil
+}
+
+func (r retryer) GetInitialToken() func(error) error {
+	return releaseToken
+}
+
+func (r retryer) GetAttemptToken(context.Context) (func(error) error, error) {
+	return releaseToken, nil
+}
+
+func releaseToken(error) error {
+	return nil
+}
+
+// classifyError wraps an error that the SDK gave up retrying in ErrRetryable if it may pass later, and
+// any other error in ErrNonRetryable. The errors are unwrapped to the SDK's, so the storage can still
+// check their types.
+func (s *dynamodbBooksStorage) classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	throttled := retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary
+	retryable := throttled || retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+
+	var failure *awshttp.ResponseError
+	if errors.As(err, &failure) {
+		switch failure.HTTPStatusCode() {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			throttled, retryable = true, true
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+			retryable = true
+		}
+	}
+
+	if !retryable {
+		return internal.ErrNonRetryable{Err: err}
+	}
+	return internal.ErrRetryable{Err: err, Throttled: throttled, RetryAfter: s.retryPolicy.backoff(s.retryPolicy.MaxAttempts)}
+}
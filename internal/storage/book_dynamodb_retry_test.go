@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/metrics"
+	"github.com/aaron-zeisler/library-api/internal/storage/dynamodbtest"
+)
+
+func TestRetryPolicy_delay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	type state struct {
+		attempt int
+		random  float64
+	}
+	type expected struct {
+		backoff time.Duration
+		delay   time.Duration
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The first attempt waits up to the base delay": {
+			state{attempt: 0, random: 0.5},
+			expected{backoff: 100 * time.Millisecond, delay: 50 * time.Millisecond},
+		},
+		"Each attempt doubles the backoff": {
+			state{attempt: 3, random: 0.5},
+			expected{backoff: 800 * time.Millisecond, delay: 400 * time.Millisecond},
+		},
+		"The backoff is capped": {
+			state{attempt: 4, random: 0.5},
+			expected{backoff: time.Second, delay: 500 * time.Millisecond},
+		},
+		"A late attempt doesn't overflow": {
+			state{attempt: 100, random: 0.5},
+			expected{backoff: time.Second, delay: 500 * time.Millisecond},
+		},
+		"The jitter can be none": {
+			state{attempt: 2, random: 0},
+			expected{backoff: 400 * time.Millisecond, delay: 0},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			p := policy
+			p.random = func() float64 { return tc.state.random }
+
+			assert.So(p.backoff(tc.state.attempt), should.Equal, tc.expected.backoff)
+			assert.So(p.delay(tc.state.attempt), should.Equal, tc.expected.delay)
+		})
+	}
+}
+
+func TestDynamoDBBooksStorage_retries(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond}
+
+	type state struct {
+		fault   dynamodbtest.Fault
+		count   int           // How many requests fail, or every one if negative
+		timeout time.Duration // The context's, if any
+		policy  RetryPolicy
+	}
+	type expected struct {
+		attempts  int
+		retryable bool
+		throttled bool
+		err       bool
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The call passes once it's no longer throttled": {
+			state{fault: dynamodbtest.ThrottlingFault, count: 2, policy: policy},
+			expected{attempts: 3},
+		},
+		"The call is throttled on every attempt": {
+			state{fault: dynamodbtest.ThrottlingFault, count: -1, policy: policy},
+			expected{attempts: 3, err: true, retryable: true, throttled: true},
+		},
+		"DynamoDB is unavailable on every attempt": {
+			state{fault: dynamodbtest.ServiceUnavailableFault, count: -1, policy: policy},
+			expected{attempts: 3, err: true, retryable: true, throttled: true},
+		},
+		"DynamoDB fails on every attempt": {
+			state{fault: dynamodbtest.InternalServerErrorFault, count: -1, policy: policy},
+			expected{attempts: 3, err: true, retryable: true},
+		},
+		"A rejected request isn't retried": {
+			state{fault: dynamodbtest.Fault{Status: http.StatusBadRequest, Code: "ValidationException", Message: "One or more parameter values were invalid"}, count: -1, policy: policy},
+			expected{attempts: 1, err: true},
+		},
+		"The policy doesn't retry": {
+			state{fault: dynamodbtest.ThrottlingFault, count: -1, policy: RetryPolicy{MaxAttempts: 1}},
+			expected{attempts: 1, err: true, retryable: true, throttled: true},
+		},
+		"Retrying would wait past the deadline": {
+			state{fault: dynamodbtest.ThrottlingFault, count: -1, timeout: time.Second, policy: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Minute}},
+			expected{attempts: 1, err: true, retryable: true, throttled: true},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			ctx := context.Background()
+			if tc.state.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.state.timeout)
+				defer cancel()
+			}
+
+			fake := dynamodbtest.NewFake()
+			server := httptest.NewServer(fake)
+			defer server.Close()
+			t.Setenv("AWS_ACCESS_KEY_ID", "test")
+			t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+			t.Setenv("AWS_SESSION_TOKEN", "")
+			sink := metrics.NewMemorySink()
+			s, err := NewDynamoDBBooksStorage(WithEndpoint(server.URL), WithRetryPolicy(tc.state.policy), WithMetrics(sink))
+			assert.So(err, should.BeNil)
+			_, err = s.ApplySchema(ctx)
+			assert.So(err, should.BeNil)
+			book, err := s.CreateBook(ctx, "Dune", "Frank Herbert", "", "")
+			assert.So(err, should.BeNil)
+
+			fake.InjectFault("GetItem", tc.state.fault, tc.state.count)
+			actual, err := s.GetBookByID(ctx, book.ID)
+
+			assert.So(fake.Requests("GetItem"), should.Equal, tc.expected.attempts)
+			if !tc.expected.err {
+				assert.So(err, should.BeNil)
+				assert.So(actual, should.Resemble, book)
+				return
+			}
+
+			var retryable internal.ErrRetryable
+			assert.So(errors.As(err, &retryable), should.Equal, tc.expected.retryable)
+			assert.So(errors.As(err, &internal.ErrNonRetryable{}), should.Equal, !tc.expected.retryable)
+			assert.So(retryable.Throttled, should.Equal, tc.expected.throttled)
+			if tc.expected.retryable {
+				assert.So(retryable.RetryAfter, should.Equal, tc.state.policy.backoff(tc.state.policy.MaxAttempts))
+			}
+
+			throttled := 0.0
+			if tc.expected.throttled {
+				throttled = 1
+			}
+			assert.So(sink.Sum("DynamoDBThrottled", map[string]string{"Table": s.tableName, "Operation": "GetItem"}), should.Equal, throttled)
+		})
+	}
+}
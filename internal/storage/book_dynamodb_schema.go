@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// The library table's overloaded indexes are keyed by the generic GSI1PK/GSI1SK and GSI2PK/GSI2SK
+// attributes that each kind of item sets as its access patterns need. The book indexes find a book by its
+// ISBN, list an author's books by title, and list the books with a status, e.g. the ones that are checked
+// out, by ID, for the listings that filter on them. Every index is sparse: an item without the index's key isn't in it.
+const (
+	gsi1Index   = "GSI1"
+	gsi2Index   = "GSI2"
+	isbnIndex   = "isbn-index"
+	authorIndex = "author-index"
+	statusIndex = "status-index"
+)
+
+// bookIndexKeys are the attributes of a book that key the book indexes. DynamoDB rejects an
+// index key that's null or an empty string, so an empty one is left out of the book's item instead.
+var bookIndexKeys = []string{"isbn", "author", "title", "book_status"}
+
+// withoutEmptyIndexKeys removes the index keys that were empty, which the marshaller made empty strings
+func withoutEmptyIndexKeys(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	for _, name := range bookIndexKeys {
+		switch value := item[name].(type) {
+		case *types.AttributeValueMemberNULL:
+			delete(item, name)
+		case *types.AttributeValueMemberS:
+			if value.Value == "" {
+				delete(item, name)
+			}
+		}
+	}
+	return item
+}
+
+// schema describes the storage's table and the indexes its queries read. It's the one definition the table
+// is created from, so a new index or key is added here and nowhere else. Every key is a string, and the
+// table is billed per request.
+func (s *dynamodbBooksStorage) schema() []*dynamodb.CreateTableInput {
+	return []*dynamodb.CreateTableInput{
+		tableSchema(s.tableName, "PK", "SK",
+			globalIndex(gsi1Index, "GSI1PK", "GSI1SK"),
+			globalIndex(gsi2Index, "GSI2PK", "GSI2SK"),
+			globalIndex(isbnIndex, "isbn", ""),
+			globalIndex(authorIndex, "author", "title"),
+			globalIndex(statusIndex, "book_status", "id")),
+	}
+}
+
+func tableSchema(name, partitionKey, sortKey string, indexes ...types.GlobalSecondaryIndex) *dynamodb.CreateTableInput {
+	result := &dynamodb.CreateTableInput{
+		TableName:   aws.String(name),
+		BillingMode: types.BillingModePayPerRequest,
+		KeySchema:   keySchema(partitionKey, sortKey),
+	}
+	if len(indexes) > 0 {
+		result.GlobalSecondaryIndexes = indexes
+	}
+
+	// Only the attributes in a key are defined
+	defined := make(map[string]bool)
+	keys := append([]types.KeySchemaElement{}, result.KeySchema...)
+	for _, index := range indexes {
+		keys = append(keys, index.KeySchema...)
+	}
+	for _, key := range keys {
+		name := aws.ToString(key.AttributeName)
+		if !defined[name] {
+			defined[name] = true
+			result.AttributeDefinitions = append(result.AttributeDefinitions, types.AttributeDefinition{
+				AttributeName: key.AttributeName,
+				AttributeType: types.ScalarAttributeTypeS,
+			})
+		}
+	}
+
+	return result
+}
+
+// globalIndex projects every attribute, so a query of the index reads whole items
+func globalIndex(name, partitionKey, sortKey string) types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName:  aws.String(name),
+		KeySchema:  keySchema(partitionKey, sortKey),
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+	}
+}
+
+func keySchema(partitionKey, sortKey string) []types.KeySchemaElement {
+	result := []types.KeySchemaElement{
+		{AttributeName: aws.String(partitionKey), KeyType: types.KeyTypeHash},
+	}
+	if sortKey != "" {
+		result = append(result, types.KeySchemaElement{AttributeName: aws.String(sortKey), KeyType: types.KeyTypeRange})
+	}
+	return result
+}
+
+// SchemaChange is a table, or an index of a table, that ApplySchema created
+type SchemaChange struct {
+	Table string
+	Index string
+}
+
+func (c SchemaChange) String() string {
+	if c.Index == "" {
+		return "table " + c.Table
+	}
+	return fmt.Sprintf("index %s of table %s", c.Index, c.Table)
+}
+
+// ApplySchema creates the tables in the schema that don't exist yet, and adds the indexes the existing ones
+// are missing, and returns what it created. DynamoDB builds one index of a table at a time, so it waits for
+// each to be active before adding the next. An index that isn't in the schema is left alone. A table or
+// index whose key differs from the schema's is an error, since its items would have to be copied.
+func (s *dynamodbBooksStorage) ApplySchema(ctx context.Context) ([]SchemaChange, error) {
+	var result []SchemaChange
+
+	for _, definition := range s.schema() {
+		name := aws.ToString(definition.TableName)
+		describeInput := &dynamodb.DescribeTableInput{TableName: definition.TableName}
+
+		described, err := s.db.DescribeTable(ctx, describeInput, retryUntil(ctx))
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			if _, err := s.db.CreateTable(ctx, definition, retryUntil(ctx)); err != nil {
+				return result, fmt.Errorf("failed to create the %s table: %w", name, err)
+			}
+			if err := dynamodb.NewTableExistsWaiter(s.db).Wait(ctx, describeInput, tableCreationTimeout); err != nil {
+				return result, fmt.Errorf("failed to wait for the %s table to be created: %w", name, err)
+			}
+			result = append(result, SchemaChange{Table: name})
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to describe the %s table: %w", name, err)
+		}
+
+		if !sameKeySchema(described.Table.KeySchema, definition.KeySchema) {
+			return result, fmt.Errorf("the %s table's key doesn't match the schema", name)
+		}
+		existing := make(map[string]types.GlobalSecondaryIndexDescription)
+		for _, index := range described.Table.GlobalSecondaryIndexes {
+			existing[aws.ToString(index.IndexName)] = index
+		}
+
+		for _, index := range definition.GlobalSecondaryIndexes {
+			indexName := aws.ToString(index.IndexName)
+			if found, ok := existing[indexName]; ok {
+				if !sameKeySchema(found.KeySchema, index.KeySchema) {
+					return result, fmt.Errorf("the key of the %s table's %s index doesn't match the schema", name, indexName)
+				}
+				continue
+			}
+
+			_, err := s.db.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+				TableName:            definition.TableName,
+				AttributeDefinitions: indexAttributes(definition, index),
+				GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{
+					Create: &types.CreateGlobalSecondaryIndexAction{
+						IndexName:  index.IndexName,
+						KeySchema:  index.KeySchema,
+						Projection: index.Projection,
+					},
+				}},
+			}, retryUntil(ctx))
+			if err != nil {
+				return result, fmt.Errorf("failed to add the %s index to the %s table: %w", indexName, name, err)
+			}
+			if err := s.waitUntilIndexesActive(ctx, describeInput); err != nil {
+				return result, fmt.Errorf("failed to wait for the %s index of the %s table to be built: %w", indexName, name, err)
+			}
+			result = append(result, SchemaChange{Table: name, Index: indexName})
+		}
+	}
+
+	return result, nil
+}
+
+// How long ApplySchema waits for a table it created to be active, and how often, and how many times, it
+// checks that the indexes it added are. Building an index reads the whole table, so it waits for up to an
+// hour.
+const (
+	tableCreationTimeout = 10 * time.Minute
+	indexPollInterval    = 10 * time.Second
+	indexPolls           = 360
+)
+
+// waitUntilIndexesActive waits for every global index of the table to be built
+func (s *dynamodbBooksStorage) waitUntilIndexesActive(ctx context.Context, input *dynamodb.DescribeTableInput) error {
+	for poll := 0; poll < indexPolls; poll++ {
+		described, err := s.db.DescribeTable(ctx, input, retryUntil(ctx))
+		if err != nil {
+			return err
+		}
+		if indexesActive(described.Table) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(indexPollInterval):
+		}
+	}
+	return fmt.Errorf("the indexes weren't active after %s", indexPolls*indexPollInterval)
+}
+
+func indexesActive(table *types.TableDescription) bool {
+	if table == nil {
+		return false
+	}
+	for _, index := range table.GlobalSecondaryIndexes {
+		if index.IndexStatus != types.IndexStatusActive {
+			return false
+		}
+	}
+	return true
+}
+
+// indexAttributes returns the definitions of the attributes in the index's key. DynamoDB rejects the
+// definition of an attribute that no key uses.
+func indexAttributes(definition *dynamodb.CreateTableInput, index types.GlobalSecondaryIndex) []types.AttributeDefinition {
+	var result []types.AttributeDefinition
+	for _, attribute := range definition.AttributeDefinitions {
+		for _, key := range index.KeySchema {
+			if aws.ToString(key.AttributeName) == aws.ToString(attribute.AttributeName) {
+				result = append(result, attribute)
+			}
+		}
+	}
+	return result
+}
+
+func sameKeySchema(a, b []types.KeySchemaElement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if aws.ToString(a[i].AttributeName) != aws.ToString(b[i].AttributeName) || a[i].KeyType != b[i].KeyType {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+	"gopkg.in/yaml.v3"
+)
+
+// templateTable is the part of an AWS::DynamoDB::Table in template.yaml that the schema defines
+type templateTable struct {
+	TableName            string `yaml:"TableName"`
+	BillingMode          string `yaml:"BillingMode"`
+	AttributeDefinitions []struct {
+		AttributeName string `yaml:"AttributeName"`
+		AttributeType string `yaml:"AttributeType"`
+	} `yaml:"AttributeDefinitions"`
+	KeySchema              []templateKey `yaml:"KeySchema"`
+	GlobalSecondaryIndexes []struct {
+		IndexName  string        `yaml:"IndexName"`
+		KeySchema  []templateKey `yaml:"KeySchema"`
+		Projection struct {
+			ProjectionType string `yaml:"ProjectionType"`
+		} `yaml:"Projection"`
+	} `yaml:"GlobalSecondaryIndexes"`
+}
+
+type templateKey struct {
+	AttributeName string `yaml:"AttributeName"`
+	KeyType       string `yaml:"KeyType"`
+}
+
+func TestDynamoDBBooksStorage_schema(t *testing.T) {
+	assert := assertions.New(t)
+
+	contents, err := os.ReadFile("../../template.yaml")
+	if err != nil {
+		t.Fatalf("failed to read the template: %v", err)
+	}
+	var template struct {
+		Resources map[string]struct {
+			Type       string        `yaml:"Type"`
+			Properties templateTable `yaml:"Properties"`
+		} `yaml:"Resources"`
+	}
+	if err := yaml.Unmarshal(contents, &template); err != nil {
+		t.Fatalf("failed to parse the template: %v", err)
+	}
+
+	// The template names the tables with a !Sub of its TablePrefix parameter
+	s := &dynamodbBooksStorage{}
+	WithTablePrefix("${TablePrefix}")(s)
+
+	var actual []*dynamodb.CreateTableInput
+	for name, resource := range template.Resources {
+		// The rate limiters keep their own table, which isn't the storage's
+		if resource.Type == "AWS::DynamoDB::Table" && name != "RateLimitTable" {
+			actual = append(actual, resource.Properties.input())
+		}
+	}
+	sort.Slice(actual, func(i, j int) bool {
+		return aws.ToString(actual[i].TableName) < aws.ToString(actual[j].TableName)
+	})
+
+	expected := s.schema()
+	sort.Slice(expected, func(i, j int) bool {
+		return aws.ToString(expected[i].TableName) < aws.ToString(expected[j].TableName)
+	})
+
+	assert.So(actual, should.Resemble, expected)
+}
+
+// input returns the table's definition in the shape of the schema's
+func (t templateTable) input() *dynamodb.CreateTableInput {
+	result := &dynamodb.CreateTableInput{
+		TableName:   aws.String(t.TableName),
+		BillingMode: types.BillingMode(t.BillingMode),
+		KeySchema:   templateKeySchema(t.KeySchema),
+	}
+	for _, definition := range t.AttributeDefinitions {
+		result.AttributeDefinitions = append(result.AttributeDefinitions, types.AttributeDefinition{
+			AttributeName: aws.String(definition.AttributeName),
+			AttributeType: types.ScalarAttributeType(definition.AttributeType),
+		})
+	}
+	for _, index := range t.GlobalSecondaryIndexes {
+		result.GlobalSecondaryIndexes = append(result.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName:  aws.String(index.IndexName),
+			KeySchema:  templateKeySchema(index.KeySchema),
+			Projection: &types.Projection{ProjectionType: types.ProjectionType(index.Projection.ProjectionType)},
+		})
+	}
+	return result
+}
+
+func templateKeySchema(keys []templateKey) []types.KeySchemaElement {
+	var result []types.KeySchemaElement
+	for _, key := range keys {
+		result = append(result, types.KeySchemaElement{AttributeName: aws.String(key.AttributeName), KeyType: types.KeyType(key.KeyType)})
+	}
+	return result
+}
@@ -2,47 +2,495 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
 	"github.com/smartystreets/assertions"
 	"github.com/smartystreets/assertions/should"
 
 	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/storage/dynamodbtest"
 	"github.com/aaron-zeisler/library-api/internal/testutils"
 )
 
-func Test_dynamodbBooksStorage_GetBooks(t *testing.T) {
+// The DynamoDB tests run against DynamoDB Local when they're told where to find it: the server at
+// DYNAMODB_TEST_ENDPOINT, the jar at DYNAMODB_LOCAL_JAR, run with the java on the PATH, or the image named by
+// DYNAMODB_LOCAL_IMAGE, such as amazon/dynamodb-local, run with docker. Otherwise they run against the fake
+// in dynamodbtest. They're skipped when the DynamoDB Local they're told to use can't be started.
+var testDynamoDB struct {
+	once     sync.Once
+	endpoint string
+	stop     func()
+	err      error
+}
+
+// testDynamoDBEndpoint returns the DynamoDB to test against, starting it the first time it's needed
+func testDynamoDBEndpoint(t *testing.T) string {
+	t.Helper()
+
+	testDynamoDB.once.Do(func() {
+		testDynamoDB.endpoint, testDynamoDB.stop, testDynamoDB.err = startTestDynamoDB()
+	})
+	if testDynamoDB.err != nil {
+		t.Skipf("there's no DynamoDB to test against: %v", testDynamoDB.err)
+	}
+	return testDynamoDB.endpoint
+}
+
+// startTestDynamoDB starts the DynamoDB the environment asks for. The returned function stops it.
+func startTestDynamoDB() (string, func(), error) {
+	if endpoint := os.Getenv("DYNAMODB_TEST_ENDPOINT"); endpoint != "" {
+		return endpoint, nil, nil
+	}
+	if jar := os.Getenv("DYNAMODB_LOCAL_JAR"); jar != "" {
+		return startDynamoDBLocalJar(jar)
+	}
+	if image := os.Getenv("DYNAMODB_LOCAL_IMAGE"); image != "" {
+		return startDynamoDBLocalContainer(image)
+	}
+
+	server := httptest.NewServer(dynamodbtest.NewFake())
+	return server.URL, server.Close, nil
+}
+
+// startDynamoDBLocalJar runs DynamoDB Local in memory, listening on a free port. Its native libraries are
+// expected next to the jar, in DynamoDBLocal_lib, where the download puts them.
+func startDynamoDBLocalJar(jar string) (string, func(), error) {
+	java, err := exec.LookPath("java")
+	if err != nil {
+		return "", nil, errors.New("DYNAMODB_LOCAL_JAR is set but java isn't on the PATH")
+	}
+	if _, err := os.Stat(jar); err != nil {
+		return "", nil, fmt.Errorf("DYNAMODB_LOCAL_JAR isn't readable: %w", err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return "", nil, err
+	}
+
+	libraries := "-Djava.library.path=" + filepath.Join(filepath.Dir(jar), "DynamoDBLocal_lib")
+	cmd := exec.Command(java, libraries, "-jar", jar, "-inMemory", "-port", strconv.Itoa(port))
+	if err := cmd.Start(); err != nil {
+		return "", nil, fmt.Errorf("failed to start DynamoDB Local: %w", err)
+	}
+	stop := func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}
+
+	endpoint := fmt.Sprintf("http://127.0.0.1:%d", port)
+	if err := waitForDynamoDB(endpoint); err != nil {
+		stop()
+		return "", nil, err
+	}
+	return endpoint, stop, nil
+}
+
+// startDynamoDBLocalContainer runs the DynamoDB Local image, published on a port docker picks
+func startDynamoDBLocalContainer(image string) (string, func(), error) {
+	docker, err := exec.LookPath("docker")
+	if err != nil {
+		return "", nil, errors.New("DYNAMODB_LOCAL_IMAGE is set but docker isn't on the PATH")
+	}
+
+	output, err := exec.Command(docker, "run", "--detach", "--rm", "--publish", "127.0.0.1::8000", image).Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to start the %s container: %w", image, commandError(err))
+	}
+	container := strings.TrimSpace(string(output))
+	stop := func() { _ = exec.Command(docker, "rm", "--force", container).Run() }
+
+	output, err = exec.Command(docker, "port", container, "8000/tcp").Output()
+	if err != nil {
+		stop()
+		return "", nil, fmt.Errorf("failed to find the %s container's port: %w", image, commandError(err))
+	}
+	// Docker lists an address for each protocol it publishes on
+	address := strings.Fields(string(output))
+	if len(address) == 0 {
+		stop()
+		return "", nil, fmt.Errorf("the %s container's port isn't published", image)
+	}
+
+	endpoint := "http://" + address[0]
+	if err := waitForDynamoDB(endpoint); err != nil {
+		stop()
+		return "", nil, err
+	}
+	return endpoint, stop, nil
+}
+
+// commandError adds what the command wrote to stderr to its error
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+// waitForDynamoDB waits for DynamoDB Local to answer a request. Any answer will do, since the request isn't
+// signed.
+func waitForDynamoDB(endpoint string) error {
+	client := &http.Client{Timeout: time.Second}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader("{}"))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("X-Amz-Target", "DynamoDB_20120810.ListTables")
+		request.Header.Set("Content-Type", "application/x-amz-json-1.0")
+
+		response, err := client.Do(request)
+		if err == nil {
+			response.Body.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("DynamoDB Local didn't start listening at %s: %w", endpoint, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// newTestDynamoDBStorage creates the storage's tables, under a prefix of their own, from its schema. They're
+// deleted when the test ends.
+func newTestDynamoDBStorage(t *testing.T, opts ...DynamoBooksStorageOption) *dynamodbBooksStorage {
+	t.Helper()
+
+	s := newEmptyTestDynamoDBStorage(t, opts...)
+	if _, err := s.ApplySchema(context.Background()); err != nil {
+		t.Fatalf("failed to apply the schema: %v", err)
+	}
+
+	return s
+}
+
+// newEmptyTestDynamoDBStorage returns a storage whose tables, under a prefix of their own, don't exist yet.
+// Whichever of them the test creates are deleted when it ends.
+func newEmptyTestDynamoDBStorage(t *testing.T, opts ...DynamoBooksStorageOption) *dynamodbBooksStorage {
+	t.Helper()
+	endpoint := testDynamoDBEndpoint(t)
+
+	// DynamoDB Local accepts any credentials, but the SDK needs some to sign its requests
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	prefix := "test-" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	s, err := NewDynamoDBBooksStorage(append([]DynamoBooksStorageOption{WithEndpoint(endpoint), WithTablePrefix(prefix)}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create the storage: %v", err)
+	}
+
+	for _, input := range s.schema() {
+		name := input.TableName
+		t.Cleanup(func() {
+			_, err := s.db.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: name})
+			var notFound *types.ResourceNotFoundException
+			if err != nil && !errors.As(err, &notFound) {
+				t.Errorf("failed to delete the table %s: %v", aws.ToString(name), err)
+			}
+		})
+	}
+
+	return s
+}
+
+func TestDynamoDBBooksStorage_ApplySchema(t *testing.T) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+	s := newEmptyTestDynamoDBStorage(t)
+
+	// The library table was created before it had any indexes
+	_, err := s.db.CreateTable(ctx, tableSchema(s.tableName, "PK", "SK"))
+	assert.So(err, should.BeNil)
+
+	changes, err := s.ApplySchema(ctx)
+	assert.So(err, should.BeNil)
+	assert.So(changes, should.Resemble, []SchemaChange{
+		{Table: s.tableName, Index: gsi1Index},
+		{Table: s.tableName, Index: gsi2Index},
+		{Table: s.tableName, Index: isbnIndex},
+		{Table: s.tableName, Index: authorIndex},
+		{Table: s.tableName, Index: statusIndex},
+	})
+
+	// Every index can be queried
+	for _, definition := range s.schema() {
+		for _, index := range definition.GlobalSecondaryIndexes {
+			_, err := s.db.Scan(ctx, &dynamodb.ScanInput{TableName: definition.TableName, IndexName: index.IndexName})
+			assert.So(err, should.BeNil)
+		}
+	}
+
+	changes, err = s.ApplySchema(ctx)
+	assert.So(err, should.BeNil)
+	assert.So(changes, should.BeEmpty)
+
+	// A table with another key has to be migrated by hand
+	_, err = s.db.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(s.tableName)})
+	assert.So(err, should.BeNil)
+	_, err = s.db.CreateTable(ctx, tableSchema(s.tableName, "PK", ""))
+	assert.So(err, should.BeNil)
+
+	_, err = s.ApplySchema(ctx)
+	assert.So(err, testutils.ShouldEqualError, fmt.Errorf("the %s table's key doesn't match the schema", s.tableName))
+}
+
+func TestDynamoDBBooksStorage_MigrateLegacyTables(t *testing.T) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+	s := newTestDynamoDBStorage(t)
+
+	// The ledger and audit tables were never created
+	now := time.Date(2021, time.March, 1, 9, 0, 0, 0, time.UTC)
+	returnedAt := now.Add(time.Hour)
+	book := internal.Book{ID: "12345", Title: "Dune", Author: "Frank Herbert", ISBN: "9780441013593", Status: internal.CheckedOut, UpdatedAt: now}
+	bookCopy := internal.Copy{Barcode: "1", BookID: book.ID, Status: internal.CheckedOut, UpdatedAt: now}
+	hold := internal.Hold{ID: "hold-1", BookID: book.ID, PatronID: "patron-2", Status: internal.HoldWaiting, PlacedAt: now}
+	open := internal.Loan{ID: "loan-2", Barcode: "1", BookID: book.ID, PatronID: "patron-1", CheckedOutAt: now.Add(2 * time.Hour), DueAt: now.Add(50 * time.Hour)}
+	returned := internal.Loan{ID: "loan-1", Barcode: "1", BookID: book.ID, PatronID: "patron-1", CheckedOutAt: now, DueAt: now.Add(48 * time.Hour), ReturnedAt: &returnedAt}
+
+	legacy := []struct {
+		table        string
+		partitionKey string
+		sortKey      string
+		items        []map[string]types.AttributeValue
+	}{
+		{"books", "id", "", []map[string]types.AttributeValue{
+			legacyTestItem(t, book, nil),
+			{"id": &types.AttributeValueMemberS{Value: "isbn#" + book.ISBN}, "book_id": &types.AttributeValueMemberS{Value: book.ID}},
+		}},
+		{"copies", "book_id", "barcode", []map[string]types.AttributeValue{legacyTestItem(t, bookCopy, nil)}},
+		{"holds", "book_id", "hold_key", []map[string]types.AttributeValue{legacyTestItem(t, hold, map[string]string{"hold_key": holdKey(hold)})}},
+		{"loans", "barcode", "loan_key", []map[string]types.AttributeValue{
+			legacyTestItem(t, returned, map[string]string{"loan_key": loanKey(returned)}),
+			legacyTestItem(t, open, map[string]string{"loan_key": loanKey(open), "loan_state": "open"}),
+		}},
+	}
+	for _, table := range legacy {
+		name := aws.String(s.tablePrefix + "-" + table.table)
+		_, err := s.db.CreateTable(ctx, tableSchema(aws.ToString(name), table.partitionKey, table.sortKey))
+		assert.So(err, should.BeNil)
+		t.Cleanup(func() {
+			_, _ = s.db.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: name})
+		})
+
+		for _, item := range table.items {
+			_, err := s.db.PutItem(ctx, &dynamodb.PutItemInput{TableName: name, Item: item})
+			assert.So(err, should.BeNil)
+		}
+	}
+
+	// Migrating again copies the same items
+	for i := 0; i < 2; i++ {
+		migrations, err := s.MigrateLegacyTables(ctx)
+		assert.So(err, should.BeNil)
+		assert.So(migrations, should.Resemble, []TableMigration{
+			{Table: s.tablePrefix + "-books", Items: 2},
+			{Table: s.tablePrefix + "-copies", Items: 1},
+			{Table: s.tablePrefix + "-holds", Items: 1},
+			{Table: s.tablePrefix + "-loans", Items: 2},
+		})
+	}
+
+	actualBook, err := s.GetBookByID(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(actualBook, should.Resemble, book)
+	_, err = s.CreateBook(ctx, "Dune Messiah", "Frank Herbert", book.ISBN, "")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrDuplicateISBN{ISBN: book.ISBN})
+
+	actualCopy, err := s.GetCopyByBarcode(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(actualCopy, should.Resemble, bookCopy)
+
+	holds, err := s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(holds, should.Resemble, []internal.Hold{hold})
+
+	openLoan, err := s.GetOpenLoan(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(openLoan, should.Resemble, open)
+	overdue, err := s.GetOverdueLoans(ctx, now.Add(72*time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(overdue, should.Resemble, []internal.Loan{open})
+	assert.So(testPatronLoanCount(t, s, "patron-1"), should.Equal, 1)
+}
+
+func TestDynamoDBBooksStorage_ShardCatalog(t *testing.T) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+	s := newTestDynamoDBStorage(t)
+
+	// One book was added before the catalog was sharded
+	sharded, err := s.CreateBook(ctx, "Dune", "Frank Herbert", "", "")
+	assert.So(err, should.BeNil)
+	unsharded := internal.Book{ID: "12345", Title: "Beloved", Author: "Toni Morrison", Status: internal.CheckedIn, UpdatedAt: sharded.UpdatedAt}
+	item, err := bookItem(unsharded)
+	assert.So(err, should.BeNil)
+	item["GSI1PK"] = &types.AttributeValueMemberS{Value: unshardedCatalog}
+	_, err = s.db.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(s.tableName), Item: item})
+	assert.So(err, should.BeNil)
+
+	books, err := s.GetBooks(ctx, internal.BookFilter{})
+	assert.So(err, should.BeNil)
+	assert.So(books, should.Resemble, []internal.Book{sharded})
+
+	// Sharding again moves nothing
+	for _, expected := range []int{1, 0} {
+		moved, err := s.ShardCatalog(ctx)
+		assert.So(err, should.BeNil)
+		assert.So(moved, should.Equal, expected)
+	}
+
+	books, err = s.GetBooks(ctx, internal.BookFilter{})
+	assert.So(err, should.BeNil)
+	assert.So(books, should.HaveLength, 2)
+	assert.So(books, should.Contain, unsharded)
+}
+
+func TestDynamoDBBooksStorage_loanCount(t *testing.T) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+	s := newTestDynamoDBStorage(t)
+
+	book, err := s.CreateBook(ctx, "Dune", "Frank Herbert", "", "")
+	assert.So(err, should.BeNil)
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: book.ID})
+	assert.So(err, should.BeNil)
+	assert.So(testPatronLoanCount(t, s, "patron-1"), should.Equal, 0)
+
+	// Every loan that's opened or closed changes the patron's count
+	now := s.timestamp()
+	_, first, err := s.CheckOutCopy(ctx, internal.Loan{Barcode: "1", BookID: book.ID, PatronID: "patron-1", CheckedOutAt: now, DueAt: now.Add(time.Hour)}, internal.CheckedIn, nil)
+	assert.So(err, should.BeNil)
+	second, err := s.CreateLoan(ctx, internal.Loan{Barcode: "2", BookID: book.ID, PatronID: "patron-1", CheckedOutAt: now, DueAt: now.Add(time.Hour)})
+	assert.So(err, should.BeNil)
+	assert.So(testPatronLoanCount(t, s, "patron-1"), should.Equal, 2)
+
+	_, err = s.CloseLoan(ctx, first, now)
+	assert.So(err, should.BeNil)
+	assert.So(testPatronLoanCount(t, s, "patron-1"), should.Equal, 1)
+
+	// A loan that's already closed isn't counted twice
+	_, err = s.CloseLoan(ctx, first, now)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanChanged{LoanID: first.ID})
+	_, err = s.CloseLoan(ctx, second, now)
+	assert.So(err, should.BeNil)
+	assert.So(testPatronLoanCount(t, s, "patron-1"), should.Equal, 0)
+}
+
+// legacyTestItem marshals the value the way the legacy tables stored it, with their extra attributes
+func legacyTestItem(t *testing.T, value interface{}, attributes map[string]string) map[string]types.AttributeValue {
+	t.Helper()
+
+	item, err := marshalItem(value)
+	if err != nil {
+		t.Fatalf("failed to marshal the item: %v", err)
+	}
+	for name, value := range attributes {
+		item[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return withoutEmptyIndexKeys(item)
+}
+
+// testPatronLoanCount reads the patron's count of open loans from their item
+func testPatronLoanCount(t *testing.T, s *dynamodbBooksStorage, patronID string) int {
+	t.Helper()
+
+	dbResult, err := s.db.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            itemKey(patronPrefix+patronID, patronSortKey),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		t.Fatalf("failed to read the patron: %v", err)
+	}
+
+	count := 0
+	if value, ok := dbResult.Item["loan_count"].(*types.AttributeValueMemberN); ok {
+		count, err = strconv.Atoi(value.Value)
+		if err != nil {
+			t.Fatalf("failed to parse the patron's loan count: %v", err)
+		}
+	}
+	return count
+}
+
+func Test_failedCondition(t *testing.T) {
+	canceled := &types.TransactionCanceledException{
+		Message: aws.String("Transaction cancelled"),
+		CancellationReasons: []types.CancellationReason{
+			{Code: aws.String("None")},
+			{Code: aws.String("ConditionalCheckFailed")},
+		},
+	}
+
 	type state struct {
+		err error
+		i   int
 	}
 	type expected struct {
-		result []internal.Book
-		err    error
+		failed    bool
+		anyFailed bool
 	}
 	testCases := map[string]struct {
 		state    state
 		expected expected
 	}{
-		/*
-			"Testing Dynamo": {
-				state{},
-				expected{},
-			},
-		*/
+		"The write's condition failed": {
+			state{err: canceled, i: 1},
+			expected{failed: true, anyFailed: true},
+		},
+		"Another write's condition failed": {
+			state{err: canceled, i: 0},
+			expected{failed: false, anyFailed: true},
+		},
+		"The write isn't part of the transaction": {
+			state{err: canceled, i: -1},
+			expected{failed: false, anyFailed: true},
+		},
+		"The index is past the last write": {
+			state{err: canceled, i: 2},
+			expected{failed: false, anyFailed: true},
+		},
+		"The error is wrapped": {
+			state{err: fmt.Errorf("failed to write: %w", canceled), i: 1},
+			expected{failed: true, anyFailed: true},
+		},
+		"The transaction wasn't cancelled": {
+			state{err: errors.New("connection refused"), i: 1},
+			expected{failed: false, anyFailed: false},
+		},
+		"There's no error": {
+			state{err: nil, i: 1},
+			expected{failed: false, anyFailed: false},
+		},
 	}
 
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
 			assert := assertions.New(t)
 
-			s := NewDynamoDBBooksStorage()
-
-			result, err := s.GetBooks(context.Background())
-			fmt.Println(result)
-			fmt.Println(err)
-
-			assert.So(result, should.Resemble, tc.expected.result)
-			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+			assert.So(failedCondition(tc.state.err, tc.state.i), should.Equal, tc.expected.failed)
+			assert.So(isConditionalCheckFailure(tc.state.err), should.Equal, tc.expected.anyFailed)
 		})
 	}
 }
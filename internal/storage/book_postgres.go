@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the "pgx" driver
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresUniqueViolation is the SQLSTATE of a write that would have broken a unique index
+const postgresUniqueViolation = "23505"
+
+// postgresMigrationLock is the key of the advisory lock that a run of the migrations holds, so that the
+// instances sharing the database take turns
+const postgresMigrationLock = 4_715_240_531
+
+// postgresDialect stores times as timestamps, which PostgreSQL keeps to the microsecond
+var postgresDialect = sqlDialect{
+	name:          "Postgres",
+	system:        "postgresql",
+	driver:        "pgx",
+	migrations:    postgresMigrations,
+	migrationsDir: "migrations/postgres",
+
+	numberedPlaceholders: true,
+	nativeTimes:          true,
+	precision:            time.Microsecond,
+	forUpdate:            " FOR UPDATE",
+	insertionOrder:       "seq",
+
+	lockMigrations:   fmt.Sprintf("SELECT pg_advisory_lock(%d)", postgresMigrationLock),
+	unlockMigrations: fmt.Sprintf("SELECT pg_advisory_unlock(%d)", postgresMigrationLock),
+	beginMigration:   "BEGIN",
+
+	isUniqueViolation: func(err error) bool {
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && pgErr.Code == postgresUniqueViolation
+	},
+}
+
+// NewPostgresBooksStorage connects to the PostgreSQL database at the DSN, a URL or keyword/value
+// connection string. Its schema isn't migrated on start, since several instances may share the database,
+// so it fails if there are migrations that haven't been applied with `library migrate up`.
+func NewPostgresBooksStorage(ctx context.Context, dsn string, opts ...SQLBooksStorageOption) (*sqlBooksStorage, error) {
+	result, migrator, err := newSQLBooksStorage("postgres", dsn, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := migrator.pending(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+	if len(pending) > 0 {
+		result.Close()
+		names := make([]string, 0, len(pending))
+		for _, migration := range pending {
+			names = append(names, migration.String())
+		}
+		return nil, fmt.Errorf("the database is missing the migrations %s; run `library migrate up` first", strings.Join(names, ", "))
+	}
+
+	return result, nil
+}
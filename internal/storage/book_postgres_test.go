@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+// The Postgres tests run against the database at POSTGRES_TEST_DSN or, if it isn't set, a throwaway cluster
+// started with the initdb and pg_ctl on the PATH. They're skipped when neither is available.
+var testPostgres struct {
+	once sync.Once
+	dsn  string
+	stop func()
+	err  error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if testPostgres.stop != nil {
+		testPostgres.stop()
+	}
+	if testDynamoDB.stop != nil {
+		testDynamoDB.stop()
+	}
+	os.Exit(code)
+}
+
+// testPostgresDSN returns the database to test against, starting it the first time it's needed
+func testPostgresDSN(t *testing.T) string {
+	t.Helper()
+
+	testPostgres.once.Do(func() {
+		testPostgres.dsn, testPostgres.stop, testPostgres.err = startTestPostgres()
+	})
+	if testPostgres.err != nil {
+		t.Skipf("there's no PostgreSQL to test against: %v", testPostgres.err)
+	}
+	return testPostgres.dsn
+}
+
+// startTestPostgres starts a cluster in a temporary directory, listening on a free port. The returned
+// function stops the cluster and removes the directory.
+func startTestPostgres() (string, func(), error) {
+	if dsn := os.Getenv("POSTGRES_TEST_DSN"); dsn != "" {
+		return dsn, nil, nil
+	}
+
+	initdb, err := exec.LookPath("initdb")
+	if err != nil {
+		return "", nil, errors.New("POSTGRES_TEST_DSN isn't set and initdb isn't on the PATH")
+	}
+	pgCtl, err := exec.LookPath("pg_ctl")
+	if err != nil {
+		return "", nil, errors.New("POSTGRES_TEST_DSN isn't set and pg_ctl isn't on the PATH")
+	}
+
+	dir, err := os.MkdirTemp("", "library-postgres")
+	if err != nil {
+		return "", nil, err
+	}
+	data := filepath.Join(dir, "data")
+	stop := func() {
+		_ = exec.Command(pgCtl, "-D", data, "-m", "immediate", "stop").Run()
+		os.RemoveAll(dir)
+	}
+
+	if output, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "--no-sync").CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("initdb failed: %w: %s", err, output)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -c fsync=off", port, dir)
+	if output, err := exec.Command(pgCtl, "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-o", options, "-w", "start").CombinedOutput(); err != nil {
+		stop()
+		return "", nil, fmt.Errorf("pg_ctl failed to start PostgreSQL: %w: %s", err, output)
+	}
+
+	return fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port), stop, nil
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// newTestPostgresSchema creates an empty schema for the test, which is dropped when the test ends, and
+// returns a DSN that uses it
+func newTestPostgresSchema(t *testing.T) string {
+	t.Helper()
+	dsn := testPostgresDSN(t)
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open the Postgres database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if _, err := db.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("failed to create the schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("failed to drop the schema %s: %v", schema, err)
+		}
+	})
+
+	// The DSN is a URL or a keyword/value connection string
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("POSTGRES_TEST_DSN isn't valid: %v", err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// newTestPostgresStorage opens a storage on a migrated schema of its own, which is dropped when the test
+// ends
+func newTestPostgresStorage(t *testing.T, opts ...SQLBooksStorageOption) *sqlBooksStorage {
+	t.Helper()
+	ctx := context.Background()
+	dsn := newTestPostgresSchema(t)
+
+	m, err := NewMigrator("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open the Postgres database for migrating: %v", err)
+	}
+	defer m.Close()
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("failed to migrate the Postgres database: %v", err)
+	}
+
+	s, err := NewPostgresBooksStorage(ctx, dsn, opts...)
+	if err != nil {
+		t.Fatalf("failed to open the Postgres storage: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestNewPostgresBooksStorage(t *testing.T) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+	dsn := newTestPostgresSchema(t)
+
+	// The storage doesn't migrate the schema itself
+	_, err := NewPostgresBooksStorage(ctx, dsn)
+	assert.So(err, testutils.ShouldEqualError, errors.New("the database is missing the migrations 0001_create_catalog; run `library migrate up` first"))
+
+	m, err := NewMigrator("postgres", dsn)
+	assert.So(err, should.BeNil)
+	defer m.Close()
+	_, err = m.Up(ctx)
+	assert.So(err, should.BeNil)
+
+	s, err := NewPostgresBooksStorage(ctx, dsn)
+	assert.So(err, should.BeNil)
+	defer s.Close()
+	created, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	found, err := s.GetBookByID(ctx, created.ID)
+	assert.So(err, should.BeNil)
+	assert.So(found, should.Resemble, created)
+}
+
+func TestNewPostgresBooksStorage_unreachable(t *testing.T) {
+	assert := assertions.New(t)
+
+	port, err := freePort() // Nothing listens on it
+	assert.So(err, should.BeNil)
+
+	_, err = NewPostgresBooksStorage(context.Background(), fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable&connect_timeout=1", port))
+	assert.So(err, should.NotBeNil)
+	assert.So(err.Error(), should.StartWith, "failed to create the schema_migrations table: ")
+}
+
+func Test_postgresDialect(t *testing.T) {
+	assert := assertions.New(t)
+
+	assert.So(postgresDialect.rebind("SELECT id FROM books WHERE id > ? AND deleted_at IS NULL LIMIT ?"), should.Equal,
+		"SELECT id FROM books WHERE id > $1 AND deleted_at IS NULL LIMIT $2")
+	assert.So(sqliteDialect.rebind("SELECT id FROM books WHERE id > ?"), should.Equal, "SELECT id FROM books WHERE id > ?")
+
+	// A bound that's more precise than the database is rounded up, and the times that are stored aren't
+	s := &sqlBooksStorage{dialect: postgresDialect}
+	assert.So(s.timeArg(testNow), should.Equal, testNow)
+	assert.So(s.timeArg(testNow.Add(time.Nanosecond)), should.Equal, testNow.Add(time.Microsecond))
+	assert.So(s.round(testNow.Add(999*time.Nanosecond)), should.Equal, testNow)
+
+	assert.So(postgresDialect.isUniqueViolation(internal.ErrDuplicateISBN{}), should.BeFalse)
+}
@@ -0,0 +1,1172 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/metrics"
+	"github.com/aaron-zeisler/library-api/internal/tracing"
+)
+
+// sqlDialect is what differs between the SQL databases that can hold the library
+type sqlDialect struct {
+	name          string // The name of the database in metrics and errors, e.g. "SQLite"
+	system        string // The db.system of the spans, e.g. "sqlite"
+	driver        string
+	migrations    fs.FS
+	migrationsDir string
+
+	numberedPlaceholders bool          // Whether placeholders are $1, $2, … rather than ?
+	nativeTimes          bool          // Whether times are stored as timestamps rather than text
+	precision            time.Duration // The precision of the stored times, or zero for nanoseconds
+	forUpdate            string        // The clause that locks the rows a query reads until the transaction ends
+	insertionOrder       string        // The column that orders rows which tie, in the order they were inserted
+	maxOpenConns         int           // Zero for no limit
+
+	// How runs of the migrations take turns: lockMigrations waits for any other run to finish and
+	// unlockMigrations lets the next one go, and beginMigration begins the transaction of each migration
+	lockMigrations   string
+	unlockMigrations string
+	beginMigration   string
+
+	isUniqueViolation func(err error) bool
+}
+
+// rebind rewrites the query's ? placeholders in the dialect's style
+func (d sqlDialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+
+	var result strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			result.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
+
+// openSQL opens the database of the backend, "sqlite" or "postgres"
+func openSQL(backend, dsn string) (*sql.DB, sqlDialect, error) {
+	var dialect sqlDialect
+	switch backend {
+	case "sqlite":
+		dialect = sqliteDialect
+	case "postgres":
+		dialect = postgresDialect
+	default:
+		return nil, sqlDialect{}, fmt.Errorf("the backend must be sqlite or postgres, not '%s'", backend)
+	}
+
+	db, err := sql.Open(dialect.driver, dsn)
+	if err != nil {
+		return nil, sqlDialect{}, fmt.Errorf("failed to open the %s database: %w", dialect.name, err)
+	}
+	db.SetMaxOpenConns(dialect.maxOpenConns)
+
+	return db, dialect, nil
+}
+
+// sqlBooksStorage keeps the library in a SQL database: SQLite, for a branch that runs on a single box, or
+// PostgreSQL, for an on-prem deployment. Every change and its audit event are written in one transaction,
+// and the rows the change depends on stay locked until it's committed.
+type sqlBooksStorage struct {
+	db      *sql.DB
+	dialect sqlDialect
+	metrics metrics.Sink
+	now     func() time.Time
+
+	pageSize int // How many books are read at a time when listing them
+}
+
+// newSQLBooksStorage opens the database of the backend. The caller checks that its schema is up to date.
+func newSQLBooksStorage(backend, dsn string, opts ...SQLBooksStorageOption) (*sqlBooksStorage, *Migrator, error) {
+	db, dialect, err := openSQL(backend, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &sqlBooksStorage{
+		db:      db,
+		dialect: dialect,
+		metrics: metrics.NewNoopSink(),
+		now:     time.Now,
+
+		pageSize: 500,
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	migrations, err := loadMigrations(dialect.migrations, dialect.migrationsDir)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return result, &Migrator{db: db, dialect: dialect, migrations: migrations, now: result.timestamp}, nil
+}
+
+type SQLBooksStorageOption func(*sqlBooksStorage)
+
+func WithSQLMetrics(sink metrics.Sink) SQLBooksStorageOption {
+	return func(s *sqlBooksStorage) {
+		s.metrics = sink
+	}
+}
+
+// WithSQLClock sets the clock used to timestamp changes to the books
+func WithSQLClock(now func() time.Time) SQLBooksStorageOption {
+	return func(s *sqlBooksStorage) {
+		s.now = now
+	}
+}
+
+// Close closes the database
+func (s *sqlBooksStorage) Close() error {
+	return s.db.Close()
+}
+
+// instrument starts a span for a call to the database. The returned function ends the span and emits the
+// call's latency.
+func (s *sqlBooksStorage) instrument(ctx context.Context, operation string) (context.Context, func(error)) {
+	start := time.Now()
+
+	ctx, span := tracing.Tracer().Start(ctx, s.dialect.system+"."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", s.dialect.system),
+		attribute.String("db.operation", operation),
+	))
+
+	return ctx, func(err error) {
+		s.metrics.Emit(metrics.Duration(s.dialect.name+"Latency", start, map[string]string{"Operation": operation}))
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Probe verifies that the database can be reached
+func (s *sqlBooksStorage) Probe(ctx context.Context) error {
+	ctx, done := s.instrument(ctx, "Probe")
+	err := s.db.PingContext(ctx)
+	done(err)
+	if err != nil {
+		return fmt.Errorf("failed to reach the %s database: %w", s.dialect.name, err)
+	}
+	return nil
+}
+
+const bookColumns = "id, title, author, isbn, description, status, created_at, created_by, updated_at, updated_by, deleted_at"
+
+// GetBooks returns the books that match the filter, ordered by ID
+func (s *sqlBooksStorage) GetBooks(ctx context.Context, filter internal.BookFilter) ([]internal.Book, error) {
+	condition, args := "deleted_at IS NULL", []interface{}{}
+	if !filter.UpdatedSince.IsZero() {
+		condition, args = "updated_at >= ?", []interface{}{s.timeArg(filter.UpdatedSince)}
+	}
+	for _, column := range []struct{ name, value string }{
+		{"isbn", filter.ISBN}, {"author", filter.Author}, {"status", string(filter.Status)},
+	} {
+		if column.value != "" {
+			condition += " AND " + column.name + " = ?"
+			args = append(args, column.value)
+		}
+	}
+
+	ctx, done := s.instrument(ctx, "GetBooks")
+	result, err := s.getBookPages(ctx, condition, args...)
+	done(err)
+	if err != nil {
+		return []internal.Book{}, fmt.Errorf("failed to retrieve the books from the database: %w", err)
+	}
+	return result, nil
+}
+
+func (s *sqlBooksStorage) GetBookByID(ctx context.Context, bookID string) (internal.Book, error) {
+	ctx, done := s.instrument(ctx, "GetBookByID")
+	result, err := s.getBook(ctx, s.conn(), bookID, false)
+	done(err)
+	if err != nil {
+		return internal.Book{}, err
+	}
+
+	if result.IsDeleted() {
+		return internal.Book{}, internal.ErrBookNotFound{BookID: bookID}
+	}
+	return result, nil
+}
+
+func (s *sqlBooksStorage) CreateBook(ctx context.Context, title, author, isbn, description string) (internal.Book, error) {
+	now, actor := s.timestamp(), internal.ActorFromContext(ctx)
+	newBook := internal.Book{
+		ID:          uuid.New().String(),
+		Title:       title,
+		Author:      author,
+		ISBN:        isbn,
+		Description: description,
+		Status:      internal.CheckedIn,
+		CreatedAt:   now,
+		CreatedBy:   actor,
+		UpdatedAt:   now,
+		UpdatedBy:   actor,
+	}
+
+	ctx, done := s.instrument(ctx, "CreateBook")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO books ("+bookColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			newBook.ID, newBook.Title, newBook.Author, newBook.ISBN, newBook.Description, newBook.Status,
+			s.timeArg(newBook.CreatedAt), newBook.CreatedBy, s.timeArg(newBook.UpdatedAt), newBook.UpdatedBy, nil)
+		if s.dialect.isUniqueViolation(err) {
+			return internal.ErrDuplicateISBN{ISBN: isbn}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create the new book in the database: %w", err)
+		}
+
+		return s.recordAudit(ctx, tx, newBook.ID, nil, &newBook)
+	})
+	done(err)
+	if err != nil {
+		return internal.Book{}, err
+	}
+
+	return newBook, nil
+}
+
+func (s *sqlBooksStorage) UpdateBook(ctx context.Context, bookID string, book internal.Book) (internal.Book, error) {
+	var after internal.Book
+
+	ctx, done := s.instrument(ctx, "UpdateBook")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		before, err := s.getBook(ctx, tx, bookID, true)
+		if err != nil {
+			return err
+		}
+		if before.IsDeleted() {
+			return internal.ErrBookNotFound{BookID: bookID}
+		}
+
+		after = internal.Book{
+			ID:          bookID,
+			Title:       book.Title,
+			Author:      book.Author,
+			ISBN:        book.ISBN,
+			Description: book.Description,
+			Status:      book.Status,
+			CreatedAt:   before.CreatedAt,
+			CreatedBy:   before.CreatedBy,
+			UpdatedAt:   s.timestamp(),
+			UpdatedBy:   internal.ActorFromContext(ctx),
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE books SET title = ?, author = ?, isbn = ?, description = ?, status = ?, updated_at = ?, updated_by = ? WHERE id = ?",
+			after.Title, after.Author, after.ISBN, after.Description, after.Status, s.timeArg(after.UpdatedAt), after.UpdatedBy, bookID)
+		if s.dialect.isUniqueViolation(err) {
+			return internal.ErrDuplicateISBN{ISBN: book.ISBN}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update the book in the database: %w", err)
+		}
+
+		return s.recordAudit(ctx, tx, bookID, &before, &after)
+	})
+	done(err)
+	if err != nil {
+		return internal.Book{}, err
+	}
+
+	return after, nil
+}
+
+// DeleteBook moves the book to the trash. It stays there until it's restored or purged.
+func (s *sqlBooksStorage) DeleteBook(ctx context.Context, bookID string) error {
+	ctx, done := s.instrument(ctx, "DeleteBook")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		before, err := s.getBook(ctx, tx, bookID, true)
+		if err != nil {
+			return err
+		}
+		if before.IsDeleted() {
+			return internal.ErrBookNotFound{BookID: bookID}
+		}
+
+		after := before
+		deletedAt := s.timestamp()
+		after.DeletedAt = &deletedAt
+		after.UpdatedAt = deletedAt
+		after.UpdatedBy = internal.ActorFromContext(ctx)
+
+		_, err = tx.ExecContext(ctx, "UPDATE books SET deleted_at = ?, updated_at = ?, updated_by = ? WHERE id = ?",
+			s.timeArg(deletedAt), s.timeArg(after.UpdatedAt), after.UpdatedBy, bookID)
+		if err != nil {
+			return fmt.Errorf("failed to delete the book from the database: %w", err)
+		}
+
+		return s.recordAudit(ctx, tx, bookID, &before, &after)
+	})
+	done(err)
+	return err
+}
+
+// GetDeletedBooks returns the books in the trash, ordered by ID
+func (s *sqlBooksStorage) GetDeletedBooks(ctx context.Context) ([]internal.Book, error) {
+	ctx, done := s.instrument(ctx, "GetDeletedBooks")
+	result, err := s.getBookPages(ctx, "deleted_at IS NOT NULL")
+	done(err)
+	if err != nil {
+		return []internal.Book{}, fmt.Errorf("failed to retrieve the deleted books from the database: %w", err)
+	}
+	return result, nil
+}
+
+func (s *sqlBooksStorage) RestoreBook(ctx context.Context, bookID string) (internal.Book, error) {
+	var after internal.Book
+
+	ctx, done := s.instrument(ctx, "RestoreBook")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		before, err := s.getBook(ctx, tx, bookID, true)
+		if err != nil {
+			return err
+		}
+		if !before.IsDeleted() {
+			return internal.ErrBookNotFound{BookID: bookID}
+		}
+
+		after = before
+		after.DeletedAt = nil
+		after.UpdatedAt = s.timestamp()
+		after.UpdatedBy = internal.ActorFromContext(ctx)
+
+		_, err = tx.ExecContext(ctx, "UPDATE books SET deleted_at = NULL, updated_at = ?, updated_by = ? WHERE id = ?",
+			s.timeArg(after.UpdatedAt), after.UpdatedBy, bookID)
+		if err != nil {
+			return fmt.Errorf("failed to restore the book in the database: %w", err)
+		}
+
+		return s.recordAudit(ctx, tx, bookID, &before, &after)
+	})
+	done(err)
+	if err != nil {
+		return internal.Book{}, err
+	}
+
+	return after, nil
+}
+
+// PurgeBook permanently removes the book and its copies, whether or not it's in the trash
+func (s *sqlBooksStorage) PurgeBook(ctx context.Context, bookID string) error {
+	ctx, done := s.instrument(ctx, "PurgeBook")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		before, err := s.getBook(ctx, tx, bookID, true)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range []string{"copies", "holds", "loans"} {
+			_, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE book_id = ?", bookID)
+			if err != nil {
+				return fmt.Errorf("failed to purge the book's %s from the database: %w", table, err)
+			}
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM books WHERE id = ?", bookID)
+		if err != nil {
+			return fmt.Errorf("failed to purge the book from the database: %w", err)
+		}
+
+		return s.recordAudit(ctx, tx, bookID, &before, nil)
+	})
+	done(err)
+	return err
+}
+
+// PurgeDeletedBooks permanently removes the books that were moved to the trash before the cutoff. Each
+// book is purged in its own transaction.
+func (s *sqlBooksStorage) PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int, error) {
+	ctx, done := s.instrument(ctx, "PurgeDeletedBooks")
+	expired, err := queryAll(ctx, s.conn(), scanBook, "SELECT "+bookColumns+" FROM books WHERE deleted_at < ? ORDER BY id", s.timeArg(cutoff))
+	done(err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve the deleted books from the database: %w", err)
+	}
+
+	purged := 0
+	for _, book := range expired {
+		err = s.PurgeBook(ctx, book.ID)
+		if errors.As(err, &internal.ErrBookNotFound{}) { // Purged by someone else in the meantime
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+const copyColumns = "barcode, book_id, branch, location, condition, item_type, status, updated_at"
+
+// GetCopies returns the book's copies, ordered by barcode
+func (s *sqlBooksStorage) GetCopies(ctx context.Context, bookID string) ([]internal.Copy, error) {
+	ctx, done := s.instrument(ctx, "GetCopies")
+	result, err := s.getCopies(ctx, s.conn(), bookID)
+	done(err)
+	if err != nil {
+		return []internal.Copy{}, err
+	}
+	return result, nil
+}
+
+func (s *sqlBooksStorage) GetCopyByBarcode(ctx context.Context, barcode string) (internal.Copy, error) {
+	ctx, done := s.instrument(ctx, "GetCopyByBarcode")
+	result, err := s.getCopy(ctx, s.conn(), barcode, false)
+	done(err)
+	return result, err
+}
+
+// AddCopy adds a checked in copy to the book's holdings
+func (s *sqlBooksStorage) AddCopy(ctx context.Context, bookCopy internal.Copy) (internal.Copy, error) {
+	bookCopy.Status = internal.CheckedIn
+	bookCopy.UpdatedAt = s.timestamp()
+
+	ctx, done := s.instrument(ctx, "AddCopy")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		book, err := s.getBook(ctx, tx, bookCopy.BookID, true)
+		if err != nil {
+			return err
+		}
+		if book.IsDeleted() {
+			return internal.ErrBookNotFound{BookID: bookCopy.BookID}
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO copies ("+copyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			bookCopy.Barcode, bookCopy.BookID, bookCopy.Branch, bookCopy.Location, bookCopy.Condition, bookCopy.ItemType,
+			bookCopy.Status, s.timeArg(bookCopy.UpdatedAt))
+		if s.dialect.isUniqueViolation(err) {
+			return internal.ErrDuplicateBarcode{Barcode: bookCopy.Barcode}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add the copy to the database: %w", err)
+		}
+
+		return s.saveCopy(ctx, tx, internal.AuditAddCopy, bookCopy, book)
+	})
+	done(err)
+	if err != nil {
+		return internal.Copy{}, err
+	}
+
+	return bookCopy, nil
+}
+
+// UpdateCopyStatus changes the copy's status from one status to another, and fails with
+// ErrCopyStatusConflict if the copy's status isn't the expected one
+func (s *sqlBooksStorage) UpdateCopyStatus(ctx context.Context, barcode string, from, to internal.BookStatus) (internal.Copy, error) {
+	var result internal.Copy
+
+	ctx, done := s.instrument(ctx, "UpdateCopyStatus")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		var err error
+		result, err = s.updateCopyStatus(ctx, tx, barcode, from, to)
+		return err
+	})
+	done(err)
+	if err != nil {
+		return internal.Copy{}, err
+	}
+
+	return result, nil
+}
+
+// updateCopyStatus locks the copy's book and then the copy, the same order as every other change that
+// touches both, so that concurrent changes wait for each other rather than deadlock
+func (s *sqlBooksStorage) updateCopyStatus(ctx context.Context, tx sqlQuerier, barcode string, from, to internal.BookStatus) (internal.Copy, error) {
+	bookCopy, err := s.getCopy(ctx, tx, barcode, false)
+	if err != nil {
+		return internal.Copy{}, err
+	}
+	book, err := s.getBook(ctx, tx, bookCopy.BookID, true)
+	if errors.As(err, &internal.ErrBookNotFound{}) || (err == nil && book.IsDeleted()) {
+		return internal.Copy{}, internal.ErrBookNotFound{BookID: bookCopy.BookID}
+	}
+	if err != nil {
+		return internal.Copy{}, err
+	}
+	bookCopy, err = s.getCopy(ctx, tx, barcode, true) // It may have changed while waiting for the book
+	if err != nil {
+		return internal.Copy{}, err
+	}
+	if bookCopy.Status != from {
+		return internal.Copy{}, internal.ErrCopyStatusConflict{Barcode: barcode, Status: from}
+	}
+
+	bookCopy.Status = to
+	bookCopy.UpdatedAt = s.timestamp()
+
+	_, err = tx.ExecContext(ctx, "UPDATE copies SET status = ?, updated_at = ? WHERE barcode = ?", bookCopy.Status, s.timeArg(bookCopy.UpdatedAt), barcode)
+	if err != nil {
+		return internal.Copy{}, fmt.Errorf("failed to update the copy in the database: %w", err)
+	}
+
+	err = s.saveCopy(ctx, tx, internal.CopyAuditAction(from, to), bookCopy, book)
+	if err != nil {
+		return internal.Copy{}, err
+	}
+	return bookCopy, nil
+}
+
+// saveCopy brings the book's status in line with its copies, once the copy has been written, and records
+// the change
+func (s *sqlBooksStorage) saveCopy(ctx context.Context, tx sqlQuerier, action internal.AuditAction, bookCopy internal.Copy, before internal.Book) error {
+	copies, err := s.getCopies(ctx, tx, bookCopy.BookID)
+	if err != nil {
+		return err
+	}
+
+	after := before
+	after.Status = internal.NewAvailability(copies).Status(before.Status)
+	if after.Status != before.Status {
+		after.UpdatedAt = bookCopy.UpdatedAt
+		after.UpdatedBy = internal.ActorFromContext(ctx)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE books SET status = ?, updated_at = ?, updated_by = ? WHERE id = ?",
+		after.Status, s.timeArg(after.UpdatedAt), after.UpdatedBy, after.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update the book's status in the database: %w", err)
+	}
+
+	return s.insertAuditEvent(ctx, tx, internal.NewCopyAuditEvent(ctx, action, bookCopy, &before, &after, bookCopy.UpdatedAt))
+}
+
+const holdColumns = "id, book_id, patron_id, status, placed_at, barcode, expires_at"
+
+// PlaceHold adds the patron to the end of the book's holds queue
+func (s *sqlBooksStorage) PlaceHold(ctx context.Context, hold internal.Hold) (internal.Hold, error) {
+	hold.ID = uuid.New().String()
+	hold.Status = internal.HoldWaiting
+	hold.PlacedAt = s.timestamp()
+	hold.Barcode = ""
+	hold.ExpiresAt = nil
+
+	ctx, done := s.instrument(ctx, "PlaceHold")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		book, err := s.getBook(ctx, tx, hold.BookID, true)
+		if err != nil {
+			return err
+		}
+		if book.IsDeleted() {
+			return internal.ErrBookNotFound{BookID: hold.BookID}
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO holds ("+holdColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+			hold.ID, hold.BookID, hold.PatronID, hold.Status, s.timeArg(hold.PlacedAt), hold.Barcode, nil)
+		if s.dialect.isUniqueViolation(err) {
+			return internal.ErrDuplicateHold{BookID: hold.BookID, PatronID: hold.PatronID}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to place the hold in the database: %w", err)
+		}
+		return nil
+	})
+	done(err)
+	if err != nil {
+		return internal.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// GetHolds returns the book's active holds, in the order they were placed
+func (s *sqlBooksStorage) GetHolds(ctx context.Context, bookID string) ([]internal.Hold, error) {
+	ctx, done := s.instrument(ctx, "GetHolds")
+	result, err := queryAll(ctx, s.conn(), scanHold, "SELECT "+holdColumns+" FROM holds WHERE book_id = ? AND status IN (?, ?) ORDER BY placed_at, "+s.dialect.insertionOrder,
+		bookID, internal.HoldWaiting, internal.HoldReady)
+	done(err)
+	if err != nil {
+		return []internal.Hold{}, fmt.Errorf("failed to retrieve the book's holds from the database: %w", err)
+	}
+	return result, nil
+}
+
+// GetExpiredHolds returns the holds whose copy has been on the hold shelf past its expiry
+func (s *sqlBooksStorage) GetExpiredHolds(ctx context.Context, now time.Time) ([]internal.Hold, error) {
+	ctx, done := s.instrument(ctx, "GetExpiredHolds")
+	result, err := queryAll(ctx, s.conn(), scanHold, "SELECT "+holdColumns+" FROM holds WHERE status = ? AND expires_at < ? ORDER BY expires_at, "+s.dialect.insertionOrder,
+		internal.HoldReady, s.timeArg(now))
+	done(err)
+	if err != nil {
+		return []internal.Hold{}, fmt.Errorf("failed to retrieve the expired holds from the database: %w", err)
+	}
+	return result, nil
+}
+
+// ReserveCopy puts the copy on the hold shelf for a waiting hold, until the expiry. The copy, the book's
+// status and the hold change in a single transaction.
+func (s *sqlBooksStorage) ReserveCopy(ctx context.Context, hold internal.Hold, barcode string, from internal.BookStatus, expiresAt time.Time) (internal.Hold, error) {
+	var result internal.Hold
+	expiresAt = s.round(expiresAt)
+
+	ctx, done := s.instrument(ctx, "ReserveCopy")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		var err error
+		result, err = scanHold(tx.QueryRowContext(ctx, "SELECT "+holdColumns+" FROM holds WHERE id = ?"+s.lockClause(true), hold.ID))
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && result.Status != internal.HoldWaiting) {
+			return internal.ErrHoldNotFound{HoldID: hold.ID}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to retrieve the hold from the database: %w", err)
+		}
+
+		if _, err := s.updateCopyStatus(ctx, tx, barcode, from, internal.OnHoldShelf); err != nil {
+			return err
+		}
+
+		result.Status = internal.HoldReady
+		result.Barcode = barcode
+		result.ExpiresAt = &expiresAt
+
+		_, err = tx.ExecContext(ctx, "UPDATE holds SET status = ?, barcode = ?, expires_at = ? WHERE id = ?",
+			result.Status, result.Barcode, s.timeArg(expiresAt), hold.ID)
+		if err != nil {
+			return fmt.Errorf("failed to reserve the copy in the database: %w", err)
+		}
+		return nil
+	})
+	done(err)
+	if err != nil {
+		return internal.Hold{}, err
+	}
+
+	return result, nil
+}
+
+// CloseHold takes an active hold out of the queue with its final status
+func (s *sqlBooksStorage) CloseHold(ctx context.Context, hold internal.Hold, status internal.HoldStatus) error {
+	ctx, done := s.instrument(ctx, "CloseHold")
+	err := s.closeHold(ctx, s.conn(), hold, status)
+	done(err)
+	return err
+}
+
+func (s *sqlBooksStorage) closeHold(ctx context.Context, q sqlQuerier, hold internal.Hold, status internal.HoldStatus) error {
+	dbResult, err := q.ExecContext(ctx, "UPDATE holds SET status = ? WHERE id = ? AND status IN (?, ?)",
+		status, hold.ID, internal.HoldWaiting, internal.HoldReady)
+	if err != nil {
+		return fmt.Errorf("failed to close the hold in the database: %w", err)
+	}
+
+	if rows, err := dbResult.RowsAffected(); err != nil || rows == 0 {
+		return internal.ErrHoldNotFound{HoldID: hold.ID}
+	}
+	return nil
+}
+
+const loanColumns = "id, barcode, book_id, patron_id, patron_type, item_type, checked_out_at, due_at, renewals, returned_at"
+
+// CreateLoan opens a loan of a copy. A copy can only be on one open loan at a time, which a unique index
+// enforces.
+func (s *sqlBooksStorage) CreateLoan(ctx context.Context, loan internal.Loan) (internal.Loan, error) {
+	ctx, done := s.instrument(ctx, "CreateLoan")
+	result, err := s.createLoan(ctx, s.conn(), loan)
+	done(err)
+	return result, err
+}
+
+func (s *sqlBooksStorage) createLoan(ctx context.Context, q sqlQuerier, loan internal.Loan) (internal.Loan, error) {
+	loan.ID = uuid.New().String()
+	loan.CheckedOutAt = s.round(loan.CheckedOutAt)
+	loan.DueAt = s.round(loan.DueAt)
+	loan.Renewals = 0
+	loan.ReturnedAt = nil
+
+	_, err := q.ExecContext(ctx, "INSERT INTO loans ("+loanColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		loan.ID, loan.Barcode, loan.BookID, loan.PatronID, loan.PatronType, loan.ItemType,
+		s.timeArg(loan.CheckedOutAt), s.timeArg(loan.DueAt), loan.Renewals, nil)
+	if s.dialect.isUniqueViolation(err) {
+		return internal.Loan{}, internal.ErrCopyStatusConflict{Barcode: loan.Barcode, Status: internal.CheckedIn}
+	}
+	if err != nil {
+		return internal.Loan{}, fmt.Errorf("failed to create the loan in the database: %w", err)
+	}
+
+	return loan, nil
+}
+
+// CheckOutCopy lends the copy to the loan's patron, fulfilling the hold it was on the hold shelf for, if
+// any. The copy, the book's status, the hold and the new loan change in a single transaction.
+func (s *sqlBooksStorage) CheckOutCopy(ctx context.Context, loan internal.Loan, from internal.BookStatus, hold *internal.Hold) (internal.Copy, internal.Loan, error) {
+	var (
+		bookCopy internal.Copy
+		result   internal.Loan
+	)
+
+	ctx, done := s.instrument(ctx, "CheckOutCopy")
+	err := s.inTx(ctx, func(tx sqlQuerier) error {
+		var err error
+		bookCopy, err = s.updateCopyStatus(ctx, tx, loan.Barcode, from, internal.CheckedOut)
+		if err != nil {
+			return err
+		}
+		if hold != nil {
+			if err := s.closeHold(ctx, tx, *hold, internal.HoldFulfilled); err != nil {
+				return err
+			}
+		}
+		result, err = s.createLoan(ctx, tx, loan)
+		return err
+	})
+	done(err)
+	if err != nil {
+		return internal.Copy{}, internal.Loan{}, err
+	}
+
+	return bookCopy, result, nil
+}
+
+func (s *sqlBooksStorage) GetOpenLoan(ctx context.Context, barcode string) (internal.Loan, error) {
+	ctx, done := s.instrument(ctx, "GetOpenLoan")
+	result, err := scanLoan(s.conn().QueryRowContext(ctx, "SELECT "+loanColumns+" FROM loans WHERE barcode = ? AND returned_at IS NULL", barcode))
+	done(err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return internal.Loan{}, internal.ErrLoanNotFound{Barcode: barcode}
+	}
+	if err != nil {
+		return internal.Loan{}, fmt.Errorf("failed to retrieve the loan from the database: %w", err)
+	}
+	return result, nil
+}
+
+// GetPatronLoans returns the patron's open loans
+func (s *sqlBooksStorage) GetPatronLoans(ctx context.Context, patronID string) ([]internal.Loan, error) {
+	ctx, done := s.instrument(ctx, "GetPatronLoans")
+	result, err := queryAll(ctx, s.conn(), scanLoan, "SELECT "+loanColumns+" FROM loans WHERE patron_id = ? AND returned_at IS NULL ORDER BY checked_out_at, "+s.dialect.insertionOrder, patronID)
+	done(err)
+	if err != nil {
+		return []internal.Loan{}, fmt.Errorf("failed to retrieve the patron's loans from the database: %w", err)
+	}
+	return result, nil
+}
+
+// GetOverdueLoans returns the open loans that were due before now, the most overdue first
+func (s *sqlBooksStorage) GetOverdueLoans(ctx context.Context, now time.Time) ([]internal.Loan, error) {
+	ctx, done := s.instrument(ctx, "GetOverdueLoans")
+	result, err := queryAll(ctx, s.conn(), scanLoan, "SELECT "+loanColumns+" FROM loans WHERE returned_at IS NULL AND due_at < ? ORDER BY due_at, "+s.dialect.insertionOrder, s.timeArg(now))
+	done(err)
+	if err != nil {
+		return []internal.Loan{}, fmt.Errorf("failed to retrieve the overdue loans from the database: %w", err)
+	}
+	return result, nil
+}
+
+// RenewLoan moves the loan's due date and counts the renewal, as long as the loan hasn't changed since it
+// was read
+func (s *sqlBooksStorage) RenewLoan(ctx context.Context, loan internal.Loan, dueAt time.Time) (internal.Loan, error) {
+	dueAt = s.round(dueAt)
+	err := s.updateLoan(ctx, "RenewLoan", loan, "due_at = ?, renewals = renewals + 1", s.timeArg(dueAt))
+	if err != nil {
+		return internal.Loan{}, err
+	}
+
+	loan.DueAt = dueAt
+	loan.Renewals++
+	return loan, nil
+}
+
+func (s *sqlBooksStorage) CloseLoan(ctx context.Context, loan internal.Loan, returnedAt time.Time) (internal.Loan, error) {
+	returnedAt = s.round(returnedAt)
+	err := s.updateLoan(ctx, "CloseLoan", loan, "returned_at = ?", s.timeArg(returnedAt))
+	if err != nil {
+		return internal.Loan{}, err
+	}
+
+	loan.ReturnedAt = &returnedAt
+	return loan, nil
+}
+
+// updateLoan applies the assignments to the loan, as long as it's still open and hasn't been renewed since
+// it was read
+func (s *sqlBooksStorage) updateLoan(ctx context.Context, operation string, loan internal.Loan, assignments string, args ...interface{}) error {
+	ctx, done := s.instrument(ctx, operation)
+	dbResult, err := s.conn().ExecContext(ctx, "UPDATE loans SET "+assignments+" WHERE id = ? AND returned_at IS NULL AND renewals = ?",
+		append(args, loan.ID, loan.Renewals)...)
+	done(err)
+	if err != nil {
+		return fmt.Errorf("failed to update the loan in the database: %w", err)
+	}
+
+	if rows, err := dbResult.RowsAffected(); err != nil || rows == 0 {
+		return internal.ErrLoanChanged{LoanID: loan.ID}
+	}
+	return nil
+}
+
+const ledgerColumns = "id, patron_id, type, amount, loan_id, barcode, note, created_at, created_by"
+
+// AddLedgerEntry records a charge, payment or waiver on the patron's account
+func (s *sqlBooksStorage) AddLedgerEntry(ctx context.Context, entry internal.LedgerEntry) (internal.LedgerEntry, error) {
+	entry.ID = uuid.New().String()
+	entry.CreatedAt = s.timestamp()
+	entry.CreatedBy = internal.ActorFromContext(ctx)
+
+	ctx, done := s.instrument(ctx, "AddLedgerEntry")
+	_, err := s.conn().ExecContext(ctx, "INSERT INTO ledger ("+ledgerColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		entry.ID, entry.PatronID, entry.Type, entry.Amount, entry.LoanID, entry.Barcode, entry.Note, s.timeArg(entry.CreatedAt), entry.CreatedBy)
+	done(err)
+	if err != nil {
+		return internal.LedgerEntry{}, fmt.Errorf("failed to record the ledger entry in the database: %w", err)
+	}
+
+	return entry, nil
+}
+
+// GetLedger returns the patron's ledger entries, oldest first
+func (s *sqlBooksStorage) GetLedger(ctx context.Context, patronID string) ([]internal.LedgerEntry, error) {
+	ctx, done := s.instrument(ctx, "GetLedger")
+	result, err := queryAll(ctx, s.conn(), scanLedgerEntry, "SELECT "+ledgerColumns+" FROM ledger WHERE patron_id = ? ORDER BY created_at, "+s.dialect.insertionOrder, patronID)
+	done(err)
+	if err != nil {
+		return []internal.LedgerEntry{}, fmt.Errorf("failed to retrieve the patron's ledger from the database: %w", err)
+	}
+	return result, nil
+}
+
+const auditColumns = "id, book_id, barcode, action, actor, \"timestamp\", before, after"
+
+func (s *sqlBooksStorage) GetBookHistory(ctx context.Context, bookID string) ([]internal.AuditEvent, error) {
+	ctx, done := s.instrument(ctx, "GetBookHistory")
+	result, err := queryAll(ctx, s.conn(), scanAuditEvent, "SELECT "+auditColumns+" FROM audit_events WHERE book_id = ? ORDER BY \"timestamp\", "+s.dialect.insertionOrder, bookID)
+	done(err)
+	if err != nil {
+		return []internal.AuditEvent{}, fmt.Errorf("failed to retrieve the book's history from the database: %w", err)
+	}
+	return result, nil
+}
+
+func (s *sqlBooksStorage) GetAuditEvents(ctx context.Context, filter internal.AuditFilter) ([]internal.AuditEvent, error) {
+	query, args := "SELECT "+auditColumns+" FROM audit_events WHERE \"timestamp\" >= ?", []interface{}{s.timeArg(filter.Since)}
+	if filter.Actor != "" {
+		query, args = query+" AND actor = ?", append(args, filter.Actor)
+	}
+	if filter.After != nil {
+		after := s.timeArg(filter.After.Timestamp)
+		query, args = query+" AND (\"timestamp\" > ? OR (\"timestamp\" = ? AND id > ?))", append(args, after, after, filter.After.ID)
+	}
+	query += " ORDER BY \"timestamp\", id"
+	if filter.Limit > 0 {
+		query, args = query+" LIMIT ?", append(args, filter.Limit)
+	}
+
+	ctx, done := s.instrument(ctx, "GetAuditEvents")
+	result, err := queryAll(ctx, s.conn(), scanAuditEvent, query, args...)
+	done(err)
+	if err != nil {
+		return []internal.AuditEvent{}, fmt.Errorf("failed to retrieve the audit events from the database: %w", err)
+	}
+	return result, nil
+}
+
+func (s *sqlBooksStorage) recordAudit(ctx context.Context, tx sqlQuerier, bookID string, before, after *internal.Book) error {
+	return s.insertAuditEvent(ctx, tx, internal.NewAuditEvent(ctx, bookID, before, after, s.timestamp()))
+}
+
+// timestamp reads the storage's clock in UTC, at the precision the database stores
+func (s *sqlBooksStorage) timestamp() time.Time {
+	return s.round(s.now().UTC())
+}
+
+// round drops the precision the database can't store, so a time that's written is the same as the time
+// that's read back
+func (s *sqlBooksStorage) round(t time.Time) time.Time {
+	if s.dialect.precision == 0 {
+		return t
+	}
+	return t.Truncate(s.dialect.precision)
+}
+
+// timeArg is a time as a query argument. The times that are written have already been rounded, so a time
+// that's still too precise is a bound to compare against, and it's rounded up: a stored time is after it,
+// or before it, exactly when it's after, or before, the rounded up time.
+func (s *sqlBooksStorage) timeArg(t time.Time) interface{} {
+	if rounded := s.round(t); !rounded.Equal(t) {
+		t = rounded.Add(s.dialect.precision)
+	}
+	if s.dialect.nativeTimes {
+		return t.UTC()
+	}
+	return formatTime(t)
+}
+
+// lockClause is the clause that locks the rows a query reads, when lock is set
+func (s *sqlBooksStorage) lockClause(lock bool) string {
+	if !lock {
+		return ""
+	}
+	return s.dialect.forUpdate
+}
+
+// sqlQuerier is a database or a transaction
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// dialectQuerier runs queries written with ? placeholders in the style of the database's dialect
+type dialectQuerier struct {
+	q       sqlQuerier
+	dialect sqlDialect
+}
+
+func (d dialectQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return d.q.ExecContext(ctx, d.dialect.rebind(query), args...)
+}
+
+func (d dialectQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return d.q.QueryContext(ctx, d.dialect.rebind(query), args...)
+}
+
+func (d dialectQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return d.q.QueryRowContext(ctx, d.dialect.rebind(query), args...)
+}
+
+// conn is the database, for queries outside a transaction
+func (s *sqlBooksStorage) conn() sqlQuerier {
+	return dialectQuerier{q: s.db, dialect: s.dialect}
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled back if it fails
+func (s *sqlBooksStorage) inTx(ctx context.Context, fn func(tx sqlQuerier) error) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(dialectQuerier{q: tx, dialect: s.dialect})
+	})
+}
+
+// rowScanner is a single row or a row of a result set
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryAll runs the query and scans every row of the result
+func queryAll[T any](ctx context.Context, q sqlQuerier, scan func(rowScanner) (T, error), query string, args ...interface{}) ([]T, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]T, 0)
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
+
+// getBookPages reads the books that match the condition a page at a time, ordered by ID. Each page starts
+// after the last ID of the one before, so the reads stay cheap however far into the catalog they are.
+func (s *sqlBooksStorage) getBookPages(ctx context.Context, condition string, args ...interface{}) ([]internal.Book, error) {
+	result := make([]internal.Book, 0)
+	lastID := ""
+	for {
+		page, err := queryAll(ctx, s.conn(), scanBook, "SELECT "+bookColumns+" FROM books WHERE "+condition+" AND id > ? ORDER BY id LIMIT ?",
+			append(args, lastID, s.pageSize)...)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, page...)
+		if len(page) < s.pageSize {
+			return result, nil
+		}
+		lastID = page[len(page)-1].ID
+	}
+}
+
+// getBook retrieves the book whether or not it's in the trash. With lock set, the book's row stays locked
+// until the transaction ends.
+func (s *sqlBooksStorage) getBook(ctx context.Context, q sqlQuerier, bookID string, lock bool) (internal.Book, error) {
+	result, err := scanBook(q.QueryRowContext(ctx, "SELECT "+bookColumns+" FROM books WHERE id = ?"+s.lockClause(lock), bookID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return internal.Book{}, internal.ErrBookNotFound{BookID: bookID}
+	}
+	if err != nil {
+		return internal.Book{}, fmt.Errorf("failed to retrieve the book from the database: %w", err)
+	}
+	return result, nil
+}
+
+func (s *sqlBooksStorage) getCopies(ctx context.Context, q sqlQuerier, bookID string) ([]internal.Copy, error) {
+	result, err := queryAll(ctx, q, scanCopy, "SELECT "+copyColumns+" FROM copies WHERE book_id = ? ORDER BY barcode", bookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the book's copies from the database: %w", err)
+	}
+	return result, nil
+}
+
+// getCopy retrieves the copy. With lock set, the copy's row stays locked until the transaction ends.
+func (s *sqlBooksStorage) getCopy(ctx context.Context, q sqlQuerier, barcode string, lock bool) (internal.Copy, error) {
+	result, err := scanCopy(q.QueryRowContext(ctx, "SELECT "+copyColumns+" FROM copies WHERE barcode = ?"+s.lockClause(lock), barcode))
+	if errors.Is(err, sql.ErrNoRows) {
+		return internal.Copy{}, internal.ErrCopyNotFound{Barcode: barcode}
+	}
+	if err != nil {
+		return internal.Copy{}, fmt.Errorf("failed to retrieve the copy from the database: %w", err)
+	}
+	return result, nil
+}
+
+func (s *sqlBooksStorage) insertAuditEvent(ctx context.Context, q sqlQuerier, event internal.AuditEvent) error {
+	before, err := marshalNullBook(event.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalNullBook(event.After)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, "INSERT INTO audit_events ("+auditColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		event.ID, event.BookID, event.Barcode, event.Action, event.Actor, s.timeArg(event.Timestamp), before, after)
+	if err != nil {
+		return fmt.Errorf("failed to record the audit event in the database: %w", err)
+	}
+	return nil
+}
+
+func marshalNullBook(book *internal.Book) (sql.NullString, error) {
+	if book == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(book)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal the audit event: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func unmarshalNullBook(value sql.NullString) (*internal.Book, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	var result internal.Book
+	if err := json.Unmarshal([]byte(value.String), &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the audit event: %w", err)
+	}
+	return &result, nil
+}
+
+func scanBook(row rowScanner) (internal.Book, error) {
+	var result internal.Book
+	var createdAt, updatedAt, deletedAt sqlTime
+	err := row.Scan(&result.ID, &result.Title, &result.Author, &result.ISBN, &result.Description, &result.Status,
+		&createdAt, &result.CreatedBy, &updatedAt, &result.UpdatedBy, &deletedAt)
+	if err != nil {
+		return internal.Book{}, err
+	}
+
+	result.CreatedAt = createdAt.Time
+	result.UpdatedAt = updatedAt.Time
+	result.DeletedAt = deletedAt.Ptr()
+	return result, nil
+}
+
+func scanCopy(row rowScanner) (internal.Copy, error) {
+	var result internal.Copy
+	var updatedAt sqlTime
+	err := row.Scan(&result.Barcode, &result.BookID, &result.Branch, &result.Location, &result.Condition, &result.ItemType,
+		&result.Status, &updatedAt)
+	if err != nil {
+		return internal.Copy{}, err
+	}
+
+	result.UpdatedAt = updatedAt.Time
+	return result, nil
+}
+
+func scanHold(row rowScanner) (internal.Hold, error) {
+	var result internal.Hold
+	var placedAt, expiresAt sqlTime
+	err := row.Scan(&result.ID, &result.BookID, &result.PatronID, &result.Status, &placedAt, &result.Barcode, &expiresAt)
+	if err != nil {
+		return internal.Hold{}, err
+	}
+
+	result.PlacedAt = placedAt.Time
+	result.ExpiresAt = expiresAt.Ptr()
+	return result, nil
+}
+
+func scanLoan(row rowScanner) (internal.Loan, error) {
+	var result internal.Loan
+	var checkedOutAt, dueAt, returnedAt sqlTime
+	err := row.Scan(&result.ID, &result.Barcode, &result.BookID, &result.PatronID, &result.PatronType, &result.ItemType,
+		&checkedOutAt, &dueAt, &result.Renewals, &returnedAt)
+	if err != nil {
+		return internal.Loan{}, err
+	}
+
+	result.CheckedOutAt = checkedOutAt.Time
+	result.DueAt = dueAt.Time
+	result.ReturnedAt = returnedAt.Ptr()
+	return result, nil
+}
+
+func scanLedgerEntry(row rowScanner) (internal.LedgerEntry, error) {
+	var result internal.LedgerEntry
+	var createdAt sqlTime
+	err := row.Scan(&result.ID, &result.PatronID, &result.Type, &result.Amount, &result.LoanID, &result.Barcode, &result.Note,
+		&createdAt, &result.CreatedBy)
+	if err != nil {
+		return internal.LedgerEntry{}, err
+	}
+
+	result.CreatedAt = createdAt.Time
+	return result, nil
+}
+
+func scanAuditEvent(row rowScanner) (internal.AuditEvent, error) {
+	var result internal.AuditEvent
+	var timestamp sqlTime
+	var before, after sql.NullString
+	err := row.Scan(&result.ID, &result.BookID, &result.Barcode, &result.Action, &result.Actor, &timestamp, &before, &after)
+	if err != nil {
+		return internal.AuditEvent{}, err
+	}
+
+	result.Timestamp = timestamp.Time
+	result.Before, err = unmarshalNullBook(before)
+	if err != nil {
+		return internal.AuditEvent{}, err
+	}
+	result.After, err = unmarshalNullBook(after)
+	if err != nil {
+		return internal.AuditEvent{}, err
+	}
+	return result, nil
+}
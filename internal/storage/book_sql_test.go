@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal"
+)
+
+// The behavior every backend shares is tested by the conformance suite in conformance_test.go. The tests
+// here cover what's particular to the SQL storage.
+
+// sqlTestOpener opens an empty storage, which is closed when the test ends
+type sqlTestOpener func(opts ...SQLBooksStorageOption) *sqlBooksStorage
+
+// forEachSQLBackend runs the test against an in-memory SQLite database and, when one is available, a
+// PostgreSQL database
+func forEachSQLBackend(t *testing.T, test func(t *testing.T, open sqlTestOpener)) {
+	t.Run("SQLite", func(t *testing.T) {
+		test(t, func(opts ...SQLBooksStorageOption) *sqlBooksStorage {
+			return newTestSQLiteStorage(t, opts...)
+		})
+	})
+	t.Run("Postgres", func(t *testing.T) {
+		test(t, func(opts ...SQLBooksStorageOption) *sqlBooksStorage {
+			return newTestPostgresStorage(t, opts...)
+		})
+	})
+}
+
+// newTestSQLiteStorage opens an in-memory database, which is closed when the test ends
+func newTestSQLiteStorage(t *testing.T, opts ...SQLBooksStorageOption) *sqlBooksStorage {
+	t.Helper()
+
+	s, err := NewSQLiteBooksStorage(context.Background(), ":memory:", opts...)
+	if err != nil {
+		t.Fatalf("failed to open the SQLite storage: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// Test_sqlBooksStorage_pages checks that listing the books reads every page
+func Test_sqlBooksStorage_pages(t *testing.T) {
+	forEachSQLBackend(t, func(t *testing.T, open sqlTestOpener) {
+		assert := assertions.New(t)
+
+		s := open()
+		s.pageSize = 2
+		ctx := context.Background()
+
+		for i := 0; i < 5; i++ {
+			book, err := s.CreateBook(ctx, fmt.Sprintf("Volume %d", i), "Anonymous", "", "")
+			assert.So(err, should.BeNil)
+			if i%2 == 0 {
+				assert.So(s.DeleteBook(ctx, book.ID), should.BeNil)
+			}
+		}
+
+		books, err := s.GetBooks(ctx, internal.BookFilter{})
+		assert.So(err, should.BeNil)
+		assert.So(len(books), should.Equal, 2)
+
+		trash, err := s.GetDeletedBooks(ctx)
+		assert.So(err, should.BeNil)
+		assert.So(len(trash), should.Equal, 3)
+
+		changed, err := s.GetBooks(ctx, internal.BookFilter{UpdatedSince: time.Now().Add(-time.Hour)})
+		assert.So(err, should.BeNil)
+		assert.So(len(changed), should.Equal, 5)
+		for i := 1; i < len(changed); i++ {
+			assert.So(changed[i-1].ID, should.BeLessThan, changed[i].ID)
+		}
+	})
+}
+
+func TestNewSQLiteBooksStorage(t *testing.T) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "library.db")
+
+	// The schema is created when the database is first opened, and the books outlive the storage
+	s, err := NewSQLiteBooksStorage(ctx, path)
+	assert.So(err, should.BeNil)
+	created, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	assert.So(s.Close(), should.BeNil)
+
+	// Reopening it doesn't apply the migrations again
+	s, err = NewSQLiteBooksStorage(ctx, path)
+	assert.So(err, should.BeNil)
+	defer s.Close()
+	found, err := s.GetBookByID(ctx, created.ID)
+	assert.So(err, should.BeNil)
+	assert.So(found, should.Resemble, created)
+
+	var applied int
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&applied)
+	assert.So(err, should.BeNil)
+	assert.So(applied, should.Equal, 1)
+}
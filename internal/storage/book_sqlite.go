@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// sqliteDialect stores times as fixed-width text, which sorts in time order. The driver is pure Go, so the
+// binary doesn't need cgo.
+var sqliteDialect = sqlDialect{
+	name:          "SQLite",
+	system:        "sqlite",
+	driver:        "sqlite",
+	migrations:    sqliteMigrations,
+	migrationsDir: "migrations/sqlite",
+
+	// SQLite only allows one writer at a time, so the connection is shared rather than waiting on locks. It
+	// also keeps an in-memory database alive between calls, and means a transaction has the database to
+	// itself, so rows don't need locking.
+	maxOpenConns:   1,
+	insertionOrder: "rowid",
+
+	// SQLite has no lock of its own for the migrations, but a transaction begun IMMEDIATE holds the
+	// database's write lock, so a run checks whether another applied the migration once it has the lock.
+	// Waiting for the lock rather than failing at once takes a busy timeout.
+	lockMigrations: "PRAGMA busy_timeout = 60000",
+	beginMigration: "BEGIN IMMEDIATE",
+
+	isUniqueViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		if !errors.As(err, &sqliteErr) {
+			return false
+		}
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	},
+}
+
+// NewSQLiteBooksStorage opens the SQLite database, which is a file path or ":memory:", and brings its schema
+// up to date
+func NewSQLiteBooksStorage(ctx context.Context, dsn string, opts ...SQLBooksStorageOption) (*sqlBooksStorage, error) {
+	result, migrator, err := newSQLBooksStorage("sqlite", dsn, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = migrator.Up(ctx)
+	if err != nil {
+		result.Close()
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -2,58 +2,157 @@ package storage
 
 import (
 	"context"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/aaron-zeisler/library-api/internal"
 )
 
+// staticBooksStorage keeps the books in memory. Every instance has its own copy of the seed books, and a
+// lock around all of its data, so it's safe to share between concurrent requests. Exported methods take
+// the lock; the unexported helpers they share expect it to be held already.
 type staticBooksStorage struct {
-	books map[string]internal.Book
+	mu     sync.RWMutex
+	books  map[string]internal.Book
+	copies map[string]internal.Copy // Keyed by barcode
+	holds  []internal.Hold          // In the order they were placed
+	loans  []internal.Loan          // In the order the copies were checked out
+	ledger []internal.LedgerEntry   // In the order the entries were recorded
+	audit  []internal.AuditEvent    // Append-only, in the order the changes were made
+	now    func() time.Time         // Defaults to time.Now
 }
 
-func NewStaticBooksStorage() *staticBooksStorage {
-	return &staticBooksStorage{
-		books: staticBooksData,
+// NewStaticBooksStorage returns an in-memory storage seeded with a handful of classics, or with the books
+// given to WithSeedBooks
+func NewStaticBooksStorage(opts ...StaticBooksStorageOption) *staticBooksStorage {
+	result := &staticBooksStorage{
+		books: cloneBooks(staticBooksData),
+		now:   time.Now,
 	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+type StaticBooksStorageOption func(*staticBooksStorage)
+
+// WithStaticClock sets the clock used to timestamp changes to the books
+func WithStaticClock(now func() time.Time) StaticBooksStorageOption {
+	return func(s *staticBooksStorage) {
+		s.now = now
+	}
+}
+
+// WithSeedBooks replaces the books the storage starts with. The books are copied, so the caller's values
+// are never changed by the storage.
+func WithSeedBooks(books ...internal.Book) StaticBooksStorageOption {
+	return func(s *staticBooksStorage) {
+		seed := make(map[string]internal.Book, len(books))
+		for _, book := range books {
+			seed[book.ID] = book
+		}
+		s.books = cloneBooks(seed)
+	}
+}
+
+// cloneBooks deep copies the books, so no two storages share a book's deletion time
+func cloneBooks(books map[string]internal.Book) map[string]internal.Book {
+	result := make(map[string]internal.Book, len(books))
+	for id, book := range books {
+		if book.DeletedAt != nil {
+			deletedAt := *book.DeletedAt
+			book.DeletedAt = &deletedAt
+		}
+		result[id] = book
+	}
+	return result
 }
 
-func (s *staticBooksStorage) GetBooks(ctx context.Context) ([]internal.Book, error) {
+// sortBooks orders the books by ID, so that listing them doesn't depend on the map's iteration order
+func sortBooks(books []internal.Book) {
+	sort.Slice(books, func(i, j int) bool { return books[i].ID < books[j].ID })
+}
+
+// Probe always succeeds, since the books are in memory
+func (s *staticBooksStorage) Probe(ctx context.Context) error {
+	return nil
+}
+
+// GetBooks returns the books that match the filter, ordered by ID
+func (s *staticBooksStorage) GetBooks(ctx context.Context, filter internal.BookFilter) ([]internal.Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	result := make([]internal.Book, 0, len(s.books))
 	for _, book := range s.books {
-		result = append(result, book)
+		if filter.Matches(book) {
+			result = append(result, book)
+		}
 	}
+	sortBooks(result)
 	return result, nil
 }
 
 func (s *staticBooksStorage) GetBookByID(ctx context.Context, bookID string) (internal.Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	book, ok := s.books[bookID]
-	if !ok {
+	if !ok || book.IsDeleted() {
 		return internal.Book{}, internal.ErrBookNotFound{BookID: bookID}
 	}
 	return book, nil
 }
 
 func (s *staticBooksStorage) CreateBook(ctx context.Context, title, author, isbn, description string) (internal.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isbnTaken(isbn, "") {
+		return internal.Book{}, internal.ErrDuplicateISBN{ISBN: isbn}
+	}
+
 	newBookID := uuid.New().String()
+	now, actor := s.timestamp(), internal.ActorFromContext(ctx)
 	newBook := internal.Book{
 		ID:          newBookID,
 		Title:       title,
 		Author:      author,
 		ISBN:        isbn,
 		Description: description,
+		Status:      internal.CheckedIn,
+		CreatedAt:   now,
+		CreatedBy:   actor,
+		UpdatedAt:   now,
+		UpdatedBy:   actor,
 	}
 
+	if s.books == nil {
+		s.books = make(map[string]internal.Book)
+	}
 	s.books[newBookID] = newBook
+	s.recordAudit(ctx, newBookID, nil, &newBook)
 
 	return newBook, nil
 }
 
 func (s *staticBooksStorage) UpdateBook(ctx context.Context, bookID string, book internal.Book) (internal.Book, error) {
-	_, ok := s.books[bookID]
-	if !ok {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.books[bookID]
+	if !ok || before.IsDeleted() {
 		return internal.Book{}, internal.ErrBookNotFound{BookID: bookID}
 	}
+	if s.isbnTaken(book.ISBN, bookID) {
+		return internal.Book{}, internal.ErrDuplicateISBN{ISBN: book.ISBN}
+	}
 
 	s.books[bookID] = internal.Book{
 		ID:          bookID,
@@ -62,22 +161,543 @@ func (s *staticBooksStorage) UpdateBook(ctx context.Context, bookID string, book
 		ISBN:        book.ISBN,
 		Description: book.Description,
 		Status:      book.Status,
+		CreatedAt:   before.CreatedAt,
+		CreatedBy:   before.CreatedBy,
+		UpdatedAt:   s.timestamp(),
+		UpdatedBy:   internal.ActorFromContext(ctx),
 	}
-	return s.books[bookID], nil
+	after := s.books[bookID]
+	s.recordAudit(ctx, bookID, &before, &after)
+
+	return after, nil
+}
 
+// isbnTaken reports whether a book other than the given one has the ISBN. Books in the trash keep their
+// ISBN, since they may be restored, and any number of books can be without one.
+func (s *staticBooksStorage) isbnTaken(isbn, bookID string) bool {
+	if isbn == "" {
+		return false
+	}
+	for _, book := range s.books {
+		if book.ISBN == isbn && book.ID != bookID {
+			return true
+		}
+	}
+	return false
 }
 
+// DeleteBook moves the book to the trash. It stays there until it's restored or purged.
 func (s *staticBooksStorage) DeleteBook(ctx context.Context, bookID string) error {
-	_, ok := s.books[bookID]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.books[bookID]
+	if !ok || before.IsDeleted() {
+		return internal.ErrBookNotFound{BookID: bookID}
+	}
+
+	after := before
+	deletedAt := s.timestamp()
+	after.DeletedAt = &deletedAt
+	after.UpdatedAt = deletedAt
+	after.UpdatedBy = internal.ActorFromContext(ctx)
+	s.books[bookID] = after
+	s.recordAudit(ctx, bookID, &before, &after)
+
+	return nil
+}
+
+// GetDeletedBooks returns the books in the trash, ordered by ID
+func (s *staticBooksStorage) GetDeletedBooks(ctx context.Context) ([]internal.Book, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]internal.Book, 0)
+	for _, book := range s.books {
+		if book.IsDeleted() {
+			result = append(result, book)
+		}
+	}
+	sortBooks(result)
+	return result, nil
+}
+
+func (s *staticBooksStorage) RestoreBook(ctx context.Context, bookID string) (internal.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := s.books[bookID]
+	if !ok || !before.IsDeleted() {
+		return internal.Book{}, internal.ErrBookNotFound{BookID: bookID}
+	}
+
+	after := before
+	after.DeletedAt = nil
+	after.UpdatedAt = s.timestamp()
+	after.UpdatedBy = internal.ActorFromContext(ctx)
+	s.books[bookID] = after
+	s.recordAudit(ctx, bookID, &before, &after)
+
+	return after, nil
+}
+
+// PurgeBook permanently removes the book, whether or not it's in the trash
+func (s *staticBooksStorage) PurgeBook(ctx context.Context, bookID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.purgeBook(ctx, bookID)
+}
+
+func (s *staticBooksStorage) purgeBook(ctx context.Context, bookID string) error {
+	before, ok := s.books[bookID]
 	if !ok {
 		return internal.ErrBookNotFound{BookID: bookID}
 	}
 
 	delete(s.books, bookID)
+	for barcode, bookCopy := range s.copies {
+		if bookCopy.BookID == bookID {
+			delete(s.copies, barcode)
+		}
+	}
+	holds := s.holds[:0]
+	for _, hold := range s.holds {
+		if hold.BookID != bookID {
+			holds = append(holds, hold)
+		}
+	}
+	s.holds = holds
+	loans := s.loans[:0]
+	for _, loan := range s.loans {
+		if loan.BookID != bookID {
+			loans = append(loans, loan)
+		}
+	}
+	s.loans = loans
+	s.recordAudit(ctx, bookID, &before, nil)
 
 	return nil
 }
 
+// PurgeDeletedBooks permanently removes the books that were moved to the trash before the cutoff
+func (s *staticBooksStorage) PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := make([]internal.Book, 0)
+	for _, book := range s.books {
+		if book.IsDeleted() && book.DeletedAt.Before(cutoff) {
+			expired = append(expired, book)
+		}
+	}
+	sortBooks(expired)
+
+	purged := 0
+	for _, book := range expired {
+		if err := s.purgeBook(ctx, book.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// GetCopies returns the book's copies, ordered by barcode
+func (s *staticBooksStorage) GetCopies(ctx context.Context, bookID string) ([]internal.Copy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.copiesOf(bookID), nil
+}
+
+func (s *staticBooksStorage) copiesOf(bookID string) []internal.Copy {
+	result := make([]internal.Copy, 0)
+	for _, bookCopy := range s.copies {
+		if bookCopy.BookID == bookID {
+			result = append(result, bookCopy)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Barcode < result[j].Barcode })
+	return result
+}
+
+func (s *staticBooksStorage) GetCopyByBarcode(ctx context.Context, barcode string) (internal.Copy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bookCopy, ok := s.copies[barcode]
+	if !ok {
+		return internal.Copy{}, internal.ErrCopyNotFound{Barcode: barcode}
+	}
+	return bookCopy, nil
+}
+
+// AddCopy adds a checked in copy to the book's holdings
+func (s *staticBooksStorage) AddCopy(ctx context.Context, bookCopy internal.Copy) (internal.Copy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if book, ok := s.books[bookCopy.BookID]; !ok || book.IsDeleted() {
+		return internal.Copy{}, internal.ErrBookNotFound{BookID: bookCopy.BookID}
+	}
+	if _, ok := s.copies[bookCopy.Barcode]; ok {
+		return internal.Copy{}, internal.ErrDuplicateBarcode{Barcode: bookCopy.Barcode}
+	}
+
+	bookCopy.Status = internal.CheckedIn
+	bookCopy.UpdatedAt = s.timestamp()
+	s.saveCopy(ctx, internal.AuditAddCopy, bookCopy)
+
+	return bookCopy, nil
+}
+
+// UpdateCopyStatus changes the copy's status from one status to another, and fails with
+// ErrCopyStatusConflict if the copy's status isn't the expected one
+func (s *staticBooksStorage) UpdateCopyStatus(ctx context.Context, barcode string, from, to internal.BookStatus) (internal.Copy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateCopyStatus(ctx, barcode, from, to)
+}
+
+func (s *staticBooksStorage) updateCopyStatus(ctx context.Context, barcode string, from, to internal.BookStatus) (internal.Copy, error) {
+	bookCopy, ok := s.copies[barcode]
+	if !ok {
+		return internal.Copy{}, internal.ErrCopyNotFound{Barcode: barcode}
+	}
+	if book, ok := s.books[bookCopy.BookID]; !ok || book.IsDeleted() {
+		return internal.Copy{}, internal.ErrBookNotFound{BookID: bookCopy.BookID}
+	}
+	if bookCopy.Status != from {
+		return internal.Copy{}, internal.ErrCopyStatusConflict{Barcode: barcode, Status: from}
+	}
+
+	bookCopy.Status = to
+	bookCopy.UpdatedAt = s.timestamp()
+	s.saveCopy(ctx, internal.CopyAuditAction(from, to), bookCopy)
+
+	return bookCopy, nil
+}
+
+// saveCopy stores the copy, brings the book's status in line with its copies, and records the change
+func (s *staticBooksStorage) saveCopy(ctx context.Context, action internal.AuditAction, bookCopy internal.Copy) {
+	if s.copies == nil {
+		s.copies = make(map[string]internal.Copy)
+	}
+	s.copies[bookCopy.Barcode] = bookCopy
+
+	before := s.books[bookCopy.BookID]
+	after := before
+	after.Status = internal.NewAvailability(s.copiesOf(bookCopy.BookID)).Status(before.Status)
+	if after.Status != before.Status {
+		after.UpdatedAt = bookCopy.UpdatedAt
+		after.UpdatedBy = internal.ActorFromContext(ctx)
+	}
+	s.books[bookCopy.BookID] = after
+
+	s.audit = append(s.audit, internal.NewCopyAuditEvent(ctx, action, bookCopy, &before, &after, bookCopy.UpdatedAt))
+}
+
+// PlaceHold adds the patron to the end of the book's holds queue
+func (s *staticBooksStorage) PlaceHold(ctx context.Context, hold internal.Hold) (internal.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if book, ok := s.books[hold.BookID]; !ok || book.IsDeleted() {
+		return internal.Hold{}, internal.ErrBookNotFound{BookID: hold.BookID}
+	}
+	for _, existing := range s.holds {
+		if existing.IsActive() && existing.BookID == hold.BookID && existing.PatronID == hold.PatronID {
+			return internal.Hold{}, internal.ErrDuplicateHold{BookID: hold.BookID, PatronID: hold.PatronID}
+		}
+	}
+
+	hold.ID = uuid.New().String()
+	hold.Status = internal.HoldWaiting
+	hold.PlacedAt = s.timestamp()
+	hold.Barcode = ""
+	hold.ExpiresAt = nil
+	s.holds = append(s.holds, hold)
+
+	return hold, nil
+}
+
+// GetHolds returns the book's active holds, in the order they were placed
+func (s *staticBooksStorage) GetHolds(ctx context.Context, bookID string) ([]internal.Hold, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]internal.Hold, 0)
+	for _, hold := range s.holds {
+		if hold.IsActive() && hold.BookID == bookID {
+			result = append(result, hold)
+		}
+	}
+	return result, nil
+}
+
+// GetExpiredHolds returns the holds whose copy has been on the hold shelf past its expiry
+func (s *staticBooksStorage) GetExpiredHolds(ctx context.Context, now time.Time) ([]internal.Hold, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]internal.Hold, 0)
+	for _, hold := range s.holds {
+		if hold.Status == internal.HoldReady && hold.ExpiresAt.Before(now) {
+			result = append(result, hold)
+		}
+	}
+	return result, nil
+}
+
+// ReserveCopy puts the copy on the hold shelf for a waiting hold, until the expiry
+func (s *staticBooksStorage) ReserveCopy(ctx context.Context, hold internal.Hold, barcode string, from internal.BookStatus, expiresAt time.Time) (internal.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findHold(hold.ID)
+	if i < 0 || s.holds[i].Status != internal.HoldWaiting {
+		return internal.Hold{}, internal.ErrHoldNotFound{HoldID: hold.ID}
+	}
+
+	if _, err := s.updateCopyStatus(ctx, barcode, from, internal.OnHoldShelf); err != nil {
+		return internal.Hold{}, err
+	}
+
+	s.holds[i].Status = internal.HoldReady
+	s.holds[i].Barcode = barcode
+	s.holds[i].ExpiresAt = &expiresAt
+
+	return s.holds[i], nil
+}
+
+// CloseHold takes an active hold out of the queue with its final status
+func (s *staticBooksStorage) CloseHold(ctx context.Context, hold internal.Hold, status internal.HoldStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findHold(hold.ID)
+	if i < 0 || !s.holds[i].IsActive() {
+		return internal.ErrHoldNotFound{HoldID: hold.ID}
+	}
+
+	s.holds[i].Status = status
+	return nil
+}
+
+func (s *staticBooksStorage) findHold(holdID string) int {
+	for i, hold := range s.holds {
+		if hold.ID == holdID {
+			return i
+		}
+	}
+	return -1
+}
+
+// CreateLoan opens a loan of a copy. A copy can only be on one open loan at a time.
+func (s *staticBooksStorage) CreateLoan(ctx context.Context, loan internal.Loan) (internal.Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.openLoan(loan.Barcode); err == nil {
+		return internal.Loan{}, internal.ErrCopyStatusConflict{Barcode: loan.Barcode, Status: internal.CheckedIn}
+	}
+
+	return s.createLoan(loan), nil
+}
+
+func (s *staticBooksStorage) createLoan(loan internal.Loan) internal.Loan {
+	loan.ID = uuid.New().String()
+	loan.Renewals = 0
+	loan.ReturnedAt = nil
+	s.loans = append(s.loans, loan)
+
+	return loan
+}
+
+// CheckOutCopy lends the copy to the loan's patron, fulfilling the hold it was on the hold shelf for, if
+// any. Nothing changes unless everything can.
+func (s *staticBooksStorage) CheckOutCopy(ctx context.Context, loan internal.Loan, from internal.BookStatus, hold *internal.Hold) (internal.Copy, internal.Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := -1
+	if hold != nil {
+		if i = s.findHold(hold.ID); i < 0 || !s.holds[i].IsActive() {
+			return internal.Copy{}, internal.Loan{}, internal.ErrHoldNotFound{HoldID: hold.ID}
+		}
+	}
+	if _, err := s.openLoan(loan.Barcode); err == nil {
+		return internal.Copy{}, internal.Loan{}, internal.ErrCopyStatusConflict{Barcode: loan.Barcode, Status: from}
+	}
+
+	bookCopy, err := s.updateCopyStatus(ctx, loan.Barcode, from, internal.CheckedOut)
+	if err != nil {
+		return internal.Copy{}, internal.Loan{}, err
+	}
+	if i >= 0 {
+		s.holds[i].Status = internal.HoldFulfilled
+	}
+
+	return bookCopy, s.createLoan(loan), nil
+}
+
+func (s *staticBooksStorage) GetOpenLoan(ctx context.Context, barcode string) (internal.Loan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.openLoan(barcode)
+}
+
+func (s *staticBooksStorage) openLoan(barcode string) (internal.Loan, error) {
+	for _, loan := range s.loans {
+		if loan.IsOpen() && loan.Barcode == barcode {
+			return loan, nil
+		}
+	}
+	return internal.Loan{}, internal.ErrLoanNotFound{Barcode: barcode}
+}
+
+// GetPatronLoans returns the patron's open loans
+func (s *staticBooksStorage) GetPatronLoans(ctx context.Context, patronID string) ([]internal.Loan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]internal.Loan, 0)
+	for _, loan := range s.loans {
+		if loan.IsOpen() && loan.PatronID == patronID {
+			result = append(result, loan)
+		}
+	}
+	return result, nil
+}
+
+// GetOverdueLoans returns the open loans that were due before now, the most overdue first
+func (s *staticBooksStorage) GetOverdueLoans(ctx context.Context, now time.Time) ([]internal.Loan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]internal.Loan, 0)
+	for _, loan := range s.loans {
+		if loan.IsOpen() && loan.DueAt.Before(now) {
+			result = append(result, loan)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].DueAt.Before(result[j].DueAt) })
+	return result, nil
+}
+
+// RenewLoan moves the loan's due date and counts the renewal, as long as the loan hasn't changed since it
+// was read
+func (s *staticBooksStorage) RenewLoan(ctx context.Context, loan internal.Loan, dueAt time.Time) (internal.Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findOpenLoan(loan)
+	if i < 0 {
+		return internal.Loan{}, internal.ErrLoanChanged{LoanID: loan.ID}
+	}
+
+	s.loans[i].DueAt = dueAt
+	s.loans[i].Renewals++
+	return s.loans[i], nil
+}
+
+func (s *staticBooksStorage) CloseLoan(ctx context.Context, loan internal.Loan, returnedAt time.Time) (internal.Loan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findOpenLoan(loan)
+	if i < 0 {
+		return internal.Loan{}, internal.ErrLoanChanged{LoanID: loan.ID}
+	}
+
+	s.loans[i].ReturnedAt = &returnedAt
+	return s.loans[i], nil
+}
+
+// findOpenLoan finds the loan, as long as it's still open and hasn't been renewed since it was read
+func (s *staticBooksStorage) findOpenLoan(loan internal.Loan) int {
+	for i, existing := range s.loans {
+		if existing.ID == loan.ID && existing.IsOpen() && existing.Renewals == loan.Renewals {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddLedgerEntry records a charge, payment or waiver on the patron's account
+func (s *staticBooksStorage) AddLedgerEntry(ctx context.Context, entry internal.LedgerEntry) (internal.LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = uuid.New().String()
+	entry.CreatedAt = s.timestamp()
+	entry.CreatedBy = internal.ActorFromContext(ctx)
+	s.ledger = append(s.ledger, entry)
+
+	return entry, nil
+}
+
+// GetLedger returns the patron's ledger entries, oldest first
+func (s *staticBooksStorage) GetLedger(ctx context.Context, patronID string) ([]internal.LedgerEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]internal.LedgerEntry, 0)
+	for _, entry := range s.ledger {
+		if entry.PatronID == patronID {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+func (s *staticBooksStorage) GetBookHistory(ctx context.Context, bookID string) ([]internal.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]internal.AuditEvent, 0)
+	for _, event := range s.audit {
+		if event.BookID == bookID {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+func (s *staticBooksStorage) GetAuditEvents(ctx context.Context, filter internal.AuditFilter) ([]internal.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]internal.AuditEvent, 0)
+	for _, event := range s.audit {
+		if filter.Matches(event) {
+			result = append(result, event)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Precedes(result[j])
+	})
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+func (s *staticBooksStorage) recordAudit(ctx context.Context, bookID string, before, after *internal.Book) {
+	s.audit = append(s.audit, internal.NewAuditEvent(ctx, bookID, before, after, s.timestamp()))
+}
+
+// timestamp reads the storage's clock, falling back to the system clock
+func (s *staticBooksStorage) timestamp() time.Time {
+	if s.now == nil {
+		return time.Now().UTC()
+	}
+	return s.now().UTC()
+}
+
 var staticBooksData = map[string]internal.Book{
 	"448E55A3-E88E-4597-B3CB-11A844EFDA5D": {
 		ID:          "448E55A3-E88E-4597-B3CB-11A844EFDA5D",
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/smartystreets/assertions"
@@ -12,20 +15,40 @@ import (
 	"github.com/aaron-zeisler/library-api/internal/testutils"
 )
 
+// testNow is the time on the clock the tests give to the storage, so that timestamps are predictable
+var testNow = time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+
+func fixedClock(now time.Time) func() time.Time {
+	return func() time.Time { return now }
+}
+
 func TestNewStaticBookStorage(t *testing.T) {
+	deletedAt := testNow.Add(-time.Hour)
+	seedBooks := []internal.Book{
+		{ID: "1", Title: "Book 1", Status: internal.CheckedIn},
+		{ID: "2", Title: "Book 2", Status: internal.CheckedIn, DeletedAt: &deletedAt},
+	}
+
 	type state struct {
+		opts []StaticBooksStorageOption
 	}
 	type expected struct {
-		result staticBooksStorage
+		books map[string]internal.Book
 	}
 	testCases := map[string]struct {
 		state
 		expected
 	}{
-		"Happy path is the only path": {
+		"The storage is seeded with the classics": {
 			state{},
 			expected{
-				result: staticBooksStorage{books: staticBooksData},
+				books: staticBooksData,
+			},
+		},
+		"The storage is seeded with the given books": {
+			state{opts: []StaticBooksStorageOption{WithSeedBooks(seedBooks...)}},
+			expected{
+				books: map[string]internal.Book{"1": seedBooks[0], "2": seedBooks[1]},
 			},
 		},
 	}
@@ -34,24 +57,53 @@ func TestNewStaticBookStorage(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			assert := assertions.New(t)
 
-			result := NewStaticBooksStorage()
+			result := NewStaticBooksStorage(tc.state.opts...)
 
-			assert.So(len(result.books), should.Equal, len(tc.expected.result.books))
-			for id, book := range tc.expected.result.books {
-				assert.So(result.books[id], should.Resemble, book)
-			}
+			assert.So(result.books, should.Resemble, tc.expected.books)
 		})
 	}
 }
 
+func TestNewStaticBookStorage_independent(t *testing.T) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+	deletedAt := testNow.Add(-time.Hour)
+	seed := internal.Book{ID: "1", Title: "Book 1", DeletedAt: &deletedAt}
+
+	first := NewStaticBooksStorage(WithSeedBooks(seed))
+	second := NewStaticBooksStorage(WithSeedBooks(seed))
+
+	_, err := first.RestoreBook(ctx, "1")
+	assert.So(err, should.BeNil)
+	err = first.PurgeBook(ctx, "1")
+	assert.So(err, should.BeNil)
+	*second.books["1"].DeletedAt = testNow
+
+	// Neither the other storage nor the caller's book sees the changes
+	assert.So(second.books["1"].IsDeleted(), should.BeTrue)
+	assert.So(*seed.DeletedAt, should.Equal, deletedAt)
+
+	// Nor does the seed data shared by the default storages
+	defaults := NewStaticBooksStorage()
+	for bookID := range staticBooksData {
+		err = defaults.PurgeBook(ctx, bookID)
+		assert.So(err, should.BeNil)
+	}
+	assert.So(defaults.books, should.BeEmpty)
+	assert.So(NewStaticBooksStorage().books, should.Resemble, staticBooksData)
+}
+
 func Test_staticBookStorage_GetBooks(t *testing.T) {
+	deletedAt := testNow.Add(-time.Hour)
 	testBooks := map[string]internal.Book{
-		"1": {ID: "1", Title: "Book 1"},
-		"2": {ID: "2", Title: "Book 2"},
+		"1": {ID: "1", Title: "Book 1", UpdatedAt: testNow.Add(-48 * time.Hour)},
+		"2": {ID: "2", Title: "Book 2", UpdatedAt: testNow.Add(-2 * time.Hour)},
+		"3": {ID: "3", Title: "Book 3", UpdatedAt: deletedAt, DeletedAt: &deletedAt},
 	}
 
 	type state struct {
-		books map[string]internal.Book
+		books  map[string]internal.Book
+		filter internal.BookFilter
 	}
 	type expected struct {
 		result []internal.Book
@@ -69,6 +121,15 @@ func Test_staticBookStorage_GetBooks(t *testing.T) {
 				result: []internal.Book{testBooks["1"], testBooks["2"]},
 			},
 		},
+		"Books updated since a time are returned, including the deleted ones": {
+			state{
+				books:  testBooks,
+				filter: internal.BookFilter{UpdatedSince: testNow.Add(-24 * time.Hour)},
+			},
+			expected{
+				result: []internal.Book{testBooks["2"], testBooks["3"]},
+			},
+		},
 		"An empty library returns an empty slice": {
 			state{
 				books: map[string]internal.Book{},
@@ -87,7 +148,7 @@ func Test_staticBookStorage_GetBooks(t *testing.T) {
 				books: tc.state.books,
 			}
 
-			result, err := s.GetBooks(context.Background())
+			result, err := s.GetBooks(context.Background(), tc.state.filter)
 
 			assert.So(len(result), should.Equal, len(tc.expected.result))
 			for _, book := range tc.expected.result {
@@ -184,10 +245,50 @@ func Test_staticBookStorage_CreateBook(t *testing.T) {
 					Author:      "Test book author",
 					ISBN:        "Test book isbn",
 					Description: "Tests book description",
+					Status:      internal.CheckedIn,
+					CreatedAt:   testNow,
+					CreatedBy:   "sub:librarian-1",
+					UpdatedAt:   testNow,
+					UpdatedBy:   "sub:librarian-1",
+				},
+				numBooks: 1,
+			},
+		},
+		"A book with the same ISBN returns an error": {
+			state{
+				books: map[string]internal.Book{
+					"3E020259-42AF-4564-BF1F-FC57B0977EE2": {ID: "3E020259-42AF-4564-BF1F-FC57B0977EE2", Title: "Beloved", ISBN: "9781400033416"},
 				},
+				title:  "Beloved",
+				author: "Toni Morrison",
+				isbn:   "9781400033416",
+			},
+			expected{
+				err:      internal.ErrDuplicateISBN{ISBN: "9781400033416"},
 				numBooks: 1,
 			},
 		},
+		"Any number of books can be without an ISBN": {
+			state{
+				books: map[string]internal.Book{
+					"3E020259-42AF-4564-BF1F-FC57B0977EE2": {ID: "3E020259-42AF-4564-BF1F-FC57B0977EE2", Title: "Beloved"},
+				},
+				title:  "The Martian",
+				author: "Andy Weir",
+			},
+			expected{
+				result: internal.Book{
+					Title:     "The Martian",
+					Author:    "Andy Weir",
+					Status:    internal.CheckedIn,
+					CreatedAt: testNow,
+					CreatedBy: "sub:librarian-1",
+					UpdatedAt: testNow,
+					UpdatedBy: "sub:librarian-1",
+				},
+				numBooks: 2,
+			},
+		},
 	}
 
 	for name, tc := range testCases {
@@ -196,18 +297,27 @@ func Test_staticBookStorage_CreateBook(t *testing.T) {
 
 			s := staticBooksStorage{
 				books: tc.state.books,
+				now:   fixedClock(testNow),
 			}
+			ctx := internal.ContextWithActor(context.Background(), "sub:librarian-1")
 
-			result, err := s.CreateBook(context.Background(), tc.state.title, tc.state.author, tc.state.isbn, tc.state.description)
+			result, err := s.CreateBook(ctx, tc.state.title, tc.state.author, tc.state.isbn, tc.state.description)
 
 			// Verify the peropties of the Book object that was returned
-			_, uuidErr := uuid.Parse(result.ID)
-			assert.So(uuidErr, should.BeNil)
+			if tc.expected.err == nil {
+				_, uuidErr := uuid.Parse(result.ID)
+				assert.So(uuidErr, should.BeNil)
+			}
 
 			assert.So(result.Title, should.Equal, tc.expected.result.Title)
 			assert.So(result.Author, should.Equal, tc.expected.result.Author)
 			assert.So(result.ISBN, should.Equal, tc.expected.result.ISBN)
 			assert.So(result.Description, should.Equal, tc.expected.result.Description)
+			assert.So(result.Status, should.Equal, tc.expected.result.Status)
+			assert.So(result.CreatedAt, should.Equal, tc.expected.result.CreatedAt)
+			assert.So(result.CreatedBy, should.Equal, tc.expected.result.CreatedBy)
+			assert.So(result.UpdatedAt, should.Equal, tc.expected.result.UpdatedAt)
+			assert.So(result.UpdatedBy, should.Equal, tc.expected.result.UpdatedBy)
 
 			// Verify that the book was added to the internal books collection
 			assert.So(len(s.books), should.Equal, tc.expected.numBooks)
@@ -247,6 +357,10 @@ func Test_staticBookStorage_UpdateBook(t *testing.T) {
 						Author:      "Ray Bradbury",
 						ISBN:        "9781451673265",
 						Description: "It was a pleasure to burn",
+						CreatedAt:   testNow.Add(-time.Hour),
+						CreatedBy:   "sub:librarian-2",
+						UpdatedAt:   testNow.Add(-time.Hour),
+						UpdatedBy:   "sub:librarian-2",
 					},
 				},
 				bookID:      "448E55A3-E88E-4597-B3CB-11A844EFDA5D",
@@ -262,6 +376,10 @@ func Test_staticBookStorage_UpdateBook(t *testing.T) {
 					Author:      "Someone Else",
 					ISBN:        "9781451673265",
 					Description: "A different story altogether",
+					CreatedAt:   testNow.Add(-time.Hour),
+					CreatedBy:   "sub:librarian-2",
+					UpdatedAt:   testNow,
+					UpdatedBy:   "sub:librarian-1",
 				},
 			},
 		},
@@ -279,6 +397,21 @@ func Test_staticBookStorage_UpdateBook(t *testing.T) {
 				err:    internal.ErrBookNotFound{BookID: "448E55A3-E88E-4597-B3CB-11A844EFDA5D"},
 			},
 		},
+		"Another book's ISBN returns an error": {
+			state{
+				books: map[string]internal.Book{
+					"448E55A3-E88E-4597-B3CB-11A844EFDA5D": {ID: "448E55A3-E88E-4597-B3CB-11A844EFDA5D", Title: "Fahrenheit 451", ISBN: "9781451673265"},
+					"3E020259-42AF-4564-BF1F-FC57B0977EE2": {ID: "3E020259-42AF-4564-BF1F-FC57B0977EE2", Title: "Beloved", ISBN: "9781400033416"},
+				},
+				bookID: "448E55A3-E88E-4597-B3CB-11A844EFDA5D",
+				title:  "Fahrenheit 451",
+				isbn:   "9781400033416",
+			},
+			expected{
+				result: internal.Book{},
+				err:    internal.ErrDuplicateISBN{ISBN: "9781400033416"},
+			},
+		},
 	}
 
 	for name, tc := range testCases {
@@ -287,9 +420,11 @@ func Test_staticBookStorage_UpdateBook(t *testing.T) {
 
 			s := staticBooksStorage{
 				books: tc.state.books,
+				now:   fixedClock(testNow),
 			}
+			ctx := internal.ContextWithActor(context.Background(), "sub:librarian-1")
 
-			result, err := s.UpdateBook(context.Background(), tc.state.bookID, internal.Book{ID: tc.state.bookID, Title: tc.state.title, Author: tc.state.author, ISBN: tc.state.isbn, Description: tc.state.description})
+			result, err := s.UpdateBook(ctx, tc.state.bookID, internal.Book{ID: tc.state.bookID, Title: tc.state.title, Author: tc.state.author, ISBN: tc.state.isbn, Description: tc.state.description})
 
 			// Verify the properties of the book object that was returned
 			assert.So(result, should.Resemble, tc.expected.result)
@@ -311,8 +446,9 @@ func Test_staticBookStorage_DeleteBook(t *testing.T) {
 		bookID string
 	}
 	type expected struct {
-		err      error
-		numBooks int // The number of books that should be in the library after the test is run
+		err        error
+		numBooks   int // The number of books that should be in the library after the test is run
+		numDeleted int // The number of books that should be in the trash after the test is run
 	}
 	testCases := map[string]struct {
 		state    state
@@ -332,7 +468,8 @@ func Test_staticBookStorage_DeleteBook(t *testing.T) {
 				bookID: "6B94AEF7-ABEC-483E-82CB-2B6E2B801997",
 			},
 			expected{
-				numBooks: 0,
+				numBooks:   0,
+				numDeleted: 1,
 			},
 		},
 		"An unknown book id returns an error": {
@@ -349,8 +486,9 @@ func Test_staticBookStorage_DeleteBook(t *testing.T) {
 				bookID: "11112222-3333-4444-5555-666677778888",
 			},
 			expected{
-				err:      internal.ErrBookNotFound{BookID: "11112222-3333-4444-5555-666677778888"},
-				numBooks: 1,
+				err:        internal.ErrBookNotFound{BookID: "11112222-3333-4444-5555-666677778888"},
+				numBooks:   1,
+				numDeleted: 0,
 			},
 		},
 	}
@@ -367,7 +505,416 @@ func Test_staticBookStorage_DeleteBook(t *testing.T) {
 
 			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
 
-			assert.So(len(s.books), should.Equal, tc.expected.numBooks)
+			books, _ := s.GetBooks(context.Background(), internal.BookFilter{})
+			assert.So(len(books), should.Equal, tc.expected.numBooks)
+
+			deletedBooks, _ := s.GetDeletedBooks(context.Background())
+			assert.So(len(deletedBooks), should.Equal, tc.expected.numDeleted)
 		})
 	}
 }
+
+func Test_staticBookStorage_audit(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := staticBooksStorage{
+		books: map[string]internal.Book{},
+	}
+	ctx := internal.ContextWithActor(context.Background(), "sub:librarian-1")
+
+	// Make one of every kind of change to a book
+	created, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	checkedOut := created
+	checkedOut.Status = internal.CheckedOut
+	_, err = s.UpdateBook(ctx, created.ID, checkedOut)
+	assert.So(err, should.BeNil)
+	edited := checkedOut
+	edited.Description = "124 was spiteful. Full of Baby's venom"
+	_, err = s.UpdateBook(internal.ContextWithActor(context.Background(), "sub:librarian-2"), created.ID, edited)
+	assert.So(err, should.BeNil)
+	err = s.DeleteBook(ctx, created.ID)
+	assert.So(err, should.BeNil)
+
+	// Changes to other books aren't part of the history
+	_, err = s.CreateBook(ctx, "The Martian", "Andy Weir", "9781101905005", "I'm pretty much f*cked")
+	assert.So(err, should.BeNil)
+
+	history, err := s.GetBookHistory(context.Background(), created.ID)
+	assert.So(err, should.BeNil)
+	assert.So(len(history), should.Equal, 4)
+
+	actions := make([]internal.AuditAction, 0)
+	for _, event := range history {
+		actions = append(actions, event.Action)
+		assert.So(event.BookID, should.Equal, created.ID)
+	}
+	assert.So(actions, should.Resemble, []internal.AuditAction{internal.AuditCreateBook, internal.AuditCheckOut, internal.AuditUpdateBook, internal.AuditDeleteBook})
+
+	assert.So(history[0].Before, should.BeNil)
+	assert.So(*history[0].After, should.Resemble, created)
+	assert.So(history[2].Before.Description, should.Equal, "124 was spiteful")
+	assert.So(history[2].After.Description, should.Equal, "124 was spiteful. Full of Baby's venom")
+	assert.So(history[3].After.IsDeleted(), should.BeTrue)
+
+	byActor, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Actor: "sub:librarian-2"})
+	assert.So(err, should.BeNil)
+	assert.So(len(byActor), should.Equal, 1)
+	assert.So(byActor[0].Action, should.Equal, internal.AuditUpdateBook)
+
+	inTheFuture, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Since: time.Now().Add(time.Hour)})
+	assert.So(err, should.BeNil)
+	assert.So(inTheFuture, should.BeEmpty)
+}
+
+func Test_staticBookStorage_trash(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := staticBooksStorage{
+		books: map[string]internal.Book{},
+	}
+	ctx := context.Background()
+
+	kept, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	purged, err := s.CreateBook(ctx, "The Martian", "Andy Weir", "9781101905005", "I'm pretty much f*cked")
+	assert.So(err, should.BeNil)
+
+	assert.So(s.DeleteBook(ctx, kept.ID), should.BeNil)
+	assert.So(s.DeleteBook(ctx, purged.ID), should.BeNil)
+
+	// Deleted books are hidden, and can't be deleted or updated again
+	_, err = s.GetBookByID(ctx, kept.ID)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: kept.ID})
+	assert.So(s.DeleteBook(ctx, kept.ID), testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: kept.ID})
+	_, err = s.UpdateBook(ctx, kept.ID, kept)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: kept.ID})
+
+	trash, err := s.GetDeletedBooks(ctx)
+	assert.So(err, should.BeNil)
+	assert.So(len(trash), should.Equal, 2)
+
+	restored, err := s.RestoreBook(ctx, kept.ID)
+	assert.So(err, should.BeNil)
+	assert.So(restored.IsDeleted(), should.BeFalse)
+	_, err = s.RestoreBook(ctx, kept.ID)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: kept.ID})
+
+	// Only books deleted before the cutoff are purged
+	count, err := s.PurgeDeletedBooks(ctx, time.Now().Add(-time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(count, should.Equal, 0)
+
+	count, err = s.PurgeDeletedBooks(ctx, time.Now().Add(time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(count, should.Equal, 1)
+
+	books, err := s.GetBooks(ctx, internal.BookFilter{})
+	assert.So(err, should.BeNil)
+	assert.So(books, should.Resemble, []internal.Book{restored})
+
+	trash, err = s.GetDeletedBooks(ctx)
+	assert.So(err, should.BeNil)
+	assert.So(trash, should.BeEmpty)
+
+	history, err := s.GetBookHistory(ctx, purged.ID)
+	assert.So(err, should.BeNil)
+	assert.So(history[len(history)-1].Action, should.Equal, internal.AuditPurgeBook)
+}
+
+func Test_staticBookStorage_copies(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := staticBooksStorage{
+		books: map[string]internal.Book{},
+		now:   fixedClock(testNow),
+	}
+	ctx := internal.ContextWithActor(context.Background(), "sub:librarian-1")
+
+	book, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: "unknown"})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: "unknown"})
+
+	first, err := s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: book.ID, Branch: "Central", Condition: internal.ConditionNew})
+	assert.So(err, should.BeNil)
+	assert.So(first.Status, should.Equal, internal.CheckedIn)
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "2", BookID: book.ID, Branch: "Eastside", Condition: internal.ConditionWorn})
+	assert.So(err, should.BeNil)
+
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: book.ID})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrDuplicateBarcode{Barcode: "1"})
+
+	// The book stays checked in while any copy is available
+	_, err = s.UpdateCopyStatus(ctx, "1", internal.CheckedIn, internal.CheckedOut)
+	assert.So(err, should.BeNil)
+	book, _ = s.GetBookByID(ctx, book.ID)
+	assert.So(book.Status, should.Equal, internal.CheckedIn)
+
+	_, err = s.UpdateCopyStatus(ctx, "1", internal.CheckedIn, internal.CheckedOut)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyStatusConflict{Barcode: "1", Status: internal.CheckedIn})
+
+	_, err = s.UpdateCopyStatus(ctx, "2", internal.CheckedIn, internal.CheckedOut)
+	assert.So(err, should.BeNil)
+	book, _ = s.GetBookByID(ctx, book.ID)
+	assert.So(book.Status, should.Equal, internal.CheckedOut)
+
+	copies, err := s.GetCopies(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(internal.NewAvailability(copies), should.Resemble, internal.Availability{Available: 0, Total: 2})
+
+	_, err = s.GetCopyByBarcode(ctx, "3")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyNotFound{Barcode: "3"})
+
+	history, err := s.GetBookHistory(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	actions := make([]internal.AuditAction, 0)
+	for _, event := range history {
+		actions = append(actions, event.Action)
+	}
+	assert.So(actions, should.Resemble, []internal.AuditAction{internal.AuditCreateBook, internal.AuditAddCopy, internal.AuditAddCopy, internal.AuditCheckOut, internal.AuditCheckOut})
+	assert.So(history[4].Barcode, should.Equal, "2")
+
+	// Purging the book removes its copies
+	assert.So(s.PurgeBook(ctx, book.ID), should.BeNil)
+	copies, err = s.GetCopies(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(copies, should.BeEmpty)
+}
+
+func Test_staticBookStorage_holds(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := staticBooksStorage{
+		books: map[string]internal.Book{},
+		now:   fixedClock(testNow),
+	}
+	ctx := context.Background()
+
+	book, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: book.ID})
+	assert.So(err, should.BeNil)
+	_, err = s.UpdateCopyStatus(ctx, "1", internal.CheckedIn, internal.CheckedOut)
+	assert.So(err, should.BeNil)
+
+	_, err = s.PlaceHold(ctx, internal.Hold{BookID: "unknown", PatronID: "patron-1"})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: "unknown"})
+
+	first, err := s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: "patron-1"})
+	assert.So(err, should.BeNil)
+	assert.So(first.Status, should.Equal, internal.HoldWaiting)
+	second, err := s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: "patron-2"})
+	assert.So(err, should.BeNil)
+
+	_, err = s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: "patron-1"})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrDuplicateHold{BookID: book.ID, PatronID: "patron-1"})
+
+	// The copy can only be reserved once it's been checked in
+	expiresAt := testNow.Add(72 * time.Hour)
+	_, err = s.ReserveCopy(ctx, first, "1", internal.CheckedIn, expiresAt)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyStatusConflict{Barcode: "1", Status: internal.CheckedIn})
+
+	ready, err := s.ReserveCopy(ctx, first, "1", internal.CheckedOut, expiresAt)
+	assert.So(err, should.BeNil)
+	assert.So(ready.Status, should.Equal, internal.HoldReady)
+	assert.So(ready.Barcode, should.Equal, "1")
+	assert.So(*ready.ExpiresAt, should.Equal, expiresAt)
+
+	book, _ = s.GetBookByID(ctx, book.ID)
+	assert.So(book.Status, should.Equal, internal.OnHoldShelf)
+
+	expired, err := s.GetExpiredHolds(ctx, expiresAt)
+	assert.So(err, should.BeNil)
+	assert.So(expired, should.BeEmpty)
+	expired, err = s.GetExpiredHolds(ctx, expiresAt.Add(time.Minute))
+	assert.So(err, should.BeNil)
+	assert.So(expired, should.Resemble, []internal.Hold{ready})
+
+	// Closed holds leave the queue
+	assert.So(s.CloseHold(ctx, ready, internal.HoldExpired), should.BeNil)
+	assert.So(s.CloseHold(ctx, ready, internal.HoldCancelled), testutils.ShouldEqualError, internal.ErrHoldNotFound{HoldID: first.ID})
+
+	holds, err := s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(holds, should.Resemble, []internal.Hold{second})
+}
+
+func Test_staticBookStorage_loans(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := staticBooksStorage{
+		books: map[string]internal.Book{},
+		now:   fixedClock(testNow),
+	}
+	ctx := context.Background()
+
+	_, err := s.GetOpenLoan(ctx, "1")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanNotFound{Barcode: "1"})
+
+	first, err := s.CreateLoan(ctx, internal.Loan{Barcode: "1", BookID: "12345", PatronID: "patron-1", CheckedOutAt: testNow, DueAt: testNow.Add(48 * time.Hour)})
+	assert.So(err, should.BeNil)
+	assert.So(first.ID, should.NotBeEmpty)
+	second, err := s.CreateLoan(ctx, internal.Loan{Barcode: "2", BookID: "12345", PatronID: "patron-2", CheckedOutAt: testNow, DueAt: testNow.Add(24 * time.Hour)})
+	assert.So(err, should.BeNil)
+
+	// A copy can only be on one loan at a time
+	_, err = s.CreateLoan(ctx, internal.Loan{Barcode: "1", BookID: "12345", PatronID: "patron-3"})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyStatusConflict{Barcode: "1", Status: internal.CheckedIn})
+
+	open, err := s.GetOpenLoan(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(open, should.Resemble, first)
+
+	// The most overdue loan comes first
+	overdue, err := s.GetOverdueLoans(ctx, testNow.Add(24*time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(overdue, should.BeEmpty)
+	overdue, err = s.GetOverdueLoans(ctx, testNow.Add(72*time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(overdue, should.Resemble, []internal.Loan{second, first})
+
+	// A loan can't be renewed or returned with a stale copy of it
+	renewed, err := s.RenewLoan(ctx, first, testNow.Add(96*time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(renewed.Renewals, should.Equal, 1)
+	assert.So(renewed.DueAt, should.Equal, testNow.Add(96*time.Hour))
+	_, err = s.RenewLoan(ctx, first, testNow.Add(96*time.Hour))
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanChanged{LoanID: first.ID})
+	_, err = s.CloseLoan(ctx, first, testNow)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanChanged{LoanID: first.ID})
+
+	returned, err := s.CloseLoan(ctx, renewed, testNow)
+	assert.So(err, should.BeNil)
+	assert.So(*returned.ReturnedAt, should.Equal, testNow)
+
+	_, err = s.GetOpenLoan(ctx, "1")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanNotFound{Barcode: "1"})
+	patronLoans, err := s.GetPatronLoans(ctx, "patron-1")
+	assert.So(err, should.BeNil)
+	assert.So(patronLoans, should.BeEmpty)
+	patronLoans, err = s.GetPatronLoans(ctx, "patron-2")
+	assert.So(err, should.BeNil)
+	assert.So(patronLoans, should.Resemble, []internal.Loan{second})
+	overdue, err = s.GetOverdueLoans(ctx, testNow.Add(72*time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(overdue, should.Resemble, []internal.Loan{second})
+}
+
+func Test_staticBookStorage_ledger(t *testing.T) {
+	assert := assertions.New(t)
+
+	s := staticBooksStorage{
+		books: map[string]internal.Book{},
+		now:   fixedClock(testNow),
+	}
+	ctx := internal.ContextWithActor(context.Background(), "librarian-1")
+
+	charge, err := s.AddLedgerEntry(ctx, internal.LedgerEntry{PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 150, LoanID: "loan-1"})
+	assert.So(err, should.BeNil)
+	assert.So(charge.ID, should.NotBeEmpty)
+	assert.So(charge.CreatedAt, should.Equal, testNow)
+	assert.So(charge.CreatedBy, should.Equal, "librarian-1")
+	_, err = s.AddLedgerEntry(ctx, internal.LedgerEntry{PatronID: "patron-2", Type: internal.LedgerCharge, Amount: 25})
+	assert.So(err, should.BeNil)
+	payment, err := s.AddLedgerEntry(ctx, internal.LedgerEntry{PatronID: "patron-1", Type: internal.LedgerPayment, Amount: 100})
+	assert.So(err, should.BeNil)
+
+	ledger, err := s.GetLedger(ctx, "patron-1")
+	assert.So(err, should.BeNil)
+	assert.So(ledger, should.Resemble, []internal.LedgerEntry{charge, payment})
+	assert.So(internal.NewAccount("patron-1", ledger).Balance, should.Equal, internal.Money(50))
+
+	ledger, err = s.GetLedger(ctx, "patron-3")
+	assert.So(err, should.BeNil)
+	assert.So(ledger, should.BeEmpty)
+}
+
+// Test_staticBookStorage_concurrent hammers one storage from many goroutines. Run it with -race to catch
+// unguarded access; the checks at the end catch lost updates.
+func Test_staticBookStorage_concurrent(t *testing.T) {
+	assert := assertions.New(t)
+
+	const workers = 16
+	s := NewStaticBooksStorage(WithSeedBooks(), WithStaticClock(fixedClock(testNow)))
+	ctx := context.Background()
+
+	book, err := s.CreateBook(ctx, "Shared book", "Author", "", "")
+	assert.So(err, should.BeNil)
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "shared", BookID: book.ID})
+	assert.So(err, should.BeNil)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		checkOuts int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert := assertions.New(t)
+			patronID := fmt.Sprintf("patron-%d", i)
+
+			created, err := s.CreateBook(ctx, fmt.Sprintf("Book %d", i), "Author", fmt.Sprintf("ISBN-%d", i), "")
+			assert.So(err, should.BeNil)
+			_, err = s.UpdateBook(ctx, created.ID, created)
+			assert.So(err, should.BeNil)
+			_, err = s.AddCopy(ctx, internal.Copy{Barcode: fmt.Sprintf("copy-%d", i), BookID: created.ID})
+			assert.So(err, should.BeNil)
+			assert.So(s.DeleteBook(ctx, created.ID), should.BeNil)
+
+			// Only one of the workers can check out the shared copy
+			if _, err := s.UpdateCopyStatus(ctx, "shared", internal.CheckedIn, internal.CheckedOut); err == nil {
+				mu.Lock()
+				checkOuts++
+				mu.Unlock()
+			}
+			_, err = s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: patronID})
+			assert.So(err, should.BeNil)
+			_, err = s.CreateLoan(ctx, internal.Loan{Barcode: fmt.Sprintf("copy-%d", i), BookID: created.ID, PatronID: patronID, DueAt: testNow})
+			assert.So(err, should.BeNil)
+			_, err = s.AddLedgerEntry(ctx, internal.LedgerEntry{PatronID: patronID, Type: internal.LedgerCharge, Amount: 100})
+			assert.So(err, should.BeNil)
+
+			_, err = s.GetBooks(ctx, internal.BookFilter{})
+			assert.So(err, should.BeNil)
+			_, err = s.GetDeletedBooks(ctx)
+			assert.So(err, should.BeNil)
+			_, err = s.GetCopies(ctx, book.ID)
+			assert.So(err, should.BeNil)
+			_, err = s.GetHolds(ctx, book.ID)
+			assert.So(err, should.BeNil)
+			_, err = s.GetOverdueLoans(ctx, testNow.Add(time.Hour))
+			assert.So(err, should.BeNil)
+			_, err = s.GetLedger(ctx, patronID)
+			assert.So(err, should.BeNil)
+			_, err = s.GetAuditEvents(ctx, internal.AuditFilter{})
+			assert.So(err, should.BeNil)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.So(checkOuts, should.Equal, 1)
+
+	deleted, err := s.GetDeletedBooks(ctx)
+	assert.So(err, should.BeNil)
+	assert.So(len(deleted), should.Equal, workers)
+	for i := 1; i < len(deleted); i++ {
+		assert.So(deleted[i-1].ID, should.BeLessThan, deleted[i].ID)
+	}
+	holds, err := s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(len(holds), should.Equal, workers)
+	overdue, err := s.GetOverdueLoans(ctx, testNow.Add(time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(len(overdue), should.Equal, workers)
+
+	purged, err := s.PurgeDeletedBooks(ctx, testNow.Add(time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(purged, should.Equal, workers)
+	books, err := s.GetBooks(ctx, internal.BookFilter{})
+	assert.So(err, should.BeNil)
+	assert.So(books, should.HaveLength, 1)
+}
@@ -0,0 +1,35 @@
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aaron-zeisler/library-api/internal/storage"
+	"github.com/aaron-zeisler/library-api/internal/storage/storagetest"
+)
+
+// Every backend runs the same suite, so they can't drift apart. A new backend adds a test here.
+
+func TestStaticBooksStorage_conformance(t *testing.T) {
+	storagetest.RunBooksDBSuite(t, func(t *testing.T, now func() time.Time) storage.BooksDB {
+		return storage.NewStaticBooksStorage(storage.WithSeedBooks(), storage.WithStaticClock(now))
+	})
+}
+
+func TestSQLiteBooksStorage_conformance(t *testing.T) {
+	storagetest.RunBooksDBSuite(t, func(t *testing.T, now func() time.Time) storage.BooksDB {
+		return storage.NewTestSQLiteStorage(t, storage.WithSQLClock(now))
+	})
+}
+
+func TestPostgresBooksStorage_conformance(t *testing.T) {
+	storagetest.RunBooksDBSuite(t, func(t *testing.T, now func() time.Time) storage.BooksDB {
+		return storage.NewTestPostgresStorage(t, storage.WithSQLClock(now))
+	})
+}
+
+func TestDynamoDBBooksStorage_conformance(t *testing.T) {
+	storagetest.RunBooksDBSuite(t, func(t *testing.T, now func() time.Time) storage.BooksDB {
+		return storage.NewTestDynamoDBStorage(t, storage.WithClock(now))
+	})
+}
@@ -0,0 +1,41 @@
+package dynamodbtest
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// apiError is an error the fake returns to the client, in the shape DynamoDB returns it
+type apiError struct {
+	status  int
+	code    string
+	message string
+	reasons []cancellationReason
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+// cancellationReason explains why a write in a cancelled transaction failed, or is "None" if it didn't
+type cancellationReason struct {
+	Code    string `json:"Code"`
+	Message string `json:"Message,omitempty"`
+	Item    item   `json:"Item,omitempty"`
+}
+
+func validationError(format string, args ...interface{}) *apiError {
+	return &apiError{status: http.StatusBadRequest, code: "ValidationException", message: fmt.Sprintf(format, args...)}
+}
+
+func resourceNotFound(tableName string) *apiError {
+	return &apiError{
+		status:  http.StatusBadRequest,
+		code:    "ResourceNotFoundException",
+		message: fmt.Sprintf("Requested resource not found: Table: %s not found", tableName),
+	}
+}
+
+func conditionalCheckFailed() *apiError {
+	return &apiError{status: http.StatusBadRequest, code: "ConditionalCheckFailedException", message: "The conditional request failed"}
+}
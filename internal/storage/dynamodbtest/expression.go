@@ -0,0 +1,1298 @@
+package dynamodbtest
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The fake parses the same expression language DynamoDB does: condition and filter expressions, key
+// conditions, update expressions and projections. The grammar is DynamoDB's, down to the reserved words
+// and the checks that every placeholder is defined and used, so an expression the fake accepts is one
+// DynamoDB accepts too.
+
+type tokenKind int
+
+const (
+	tokenEOF    tokenKind = iota
+	tokenIdent            // an attribute name, keyword or function
+	tokenName             // an expression attribute name, such as #status
+	tokenValue            // an expression attribute value, such as :status
+	tokenNumber           // a list index
+	tokenSymbol           // punctuation and comparators
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lex splits the expression into its tokens
+func lex(expression string) ([]token, error) {
+	var tokens []token
+	isWord := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#' || r == ':':
+			j := i + 1
+			for j < len(runes) && isWord(runes[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("Syntax error; token: %q", string(r))
+			}
+			kind := tokenName
+			if r == ':' {
+				kind = tokenValue
+			}
+			tokens = append(tokens, token{kind, string(runes[i:j])})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[i:j])})
+			i = j
+		case isWord(r):
+			j := i
+			for j < len(runes) && isWord(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[i:j])})
+			i = j
+		case strings.ContainsRune("<>", r) && i+1 < len(runes) && (runes[i+1] == '=' || string(runes[i:i+2]) == "<>"):
+			tokens = append(tokens, token{tokenSymbol, string(runes[i : i+2])})
+			i += 2
+		case strings.ContainsRune("()[],.=<>+-", r):
+			tokens = append(tokens, token{tokenSymbol, string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("Invalid token; token: %q", string(r))
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, text: "<EOF>"}), nil
+}
+
+// placeholders resolves a request's expression attribute names and values, and remembers which of them the
+// request's expressions used
+type placeholders struct {
+	names      map[string]*string
+	values     map[string]*value
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newPlaceholders(names map[string]*string, values map[string]*value) *placeholders {
+	return &placeholders{names: names, values: values, usedNames: make(map[string]bool), usedValues: make(map[string]bool)}
+}
+
+func (p *placeholders) name(placeholder string) (string, error) {
+	name, ok := p.names[placeholder]
+	if !ok || name == nil {
+		return "", fmt.Errorf("An expression attribute name used in the document path is not defined; attribute name: %s", placeholder)
+	}
+	p.usedNames[placeholder] = true
+	return *name, nil
+}
+
+func (p *placeholders) value(placeholder string) (*value, error) {
+	v, ok := p.values[placeholder]
+	if !ok {
+		return nil, fmt.Errorf("An expression attribute value used in expression is not defined; attribute value: %s", placeholder)
+	}
+	if err := v.validate(); err != nil {
+		return nil, fmt.Errorf("ExpressionAttributeValues contains invalid value: %s for key %s", err.(*apiError).message, placeholder)
+	}
+	p.usedValues[placeholder] = true
+	return v, nil
+}
+
+// checkUnused fails if the request has a name or value that none of its expressions used
+func (p *placeholders) checkUnused() error {
+	unused := func(all map[string]bool, used map[string]bool) string {
+		var keys []string
+		for key := range all {
+			if !used[key] {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return strings.Join(keys, ", ")
+	}
+
+	names := make(map[string]bool)
+	for key := range p.names {
+		names[key] = true
+	}
+	if keys := unused(names, p.usedNames); keys != "" {
+		return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", keys)
+	}
+
+	values := make(map[string]bool)
+	for key := range p.values {
+		values[key] = true
+	}
+	if keys := unused(values, p.usedValues); keys != "" {
+		return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", keys)
+	}
+
+	return nil
+}
+
+// pathElement is an attribute name in a document path, or an index in a list
+type pathElement struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// path locates an attribute, or an element nested in one
+type path []pathElement
+
+func (p path) String() string {
+	var b strings.Builder
+	for i, element := range p {
+		switch {
+		case element.isIndex:
+			fmt.Fprintf(&b, "[%d]", element.index)
+		case i > 0:
+			b.WriteString("." + element.name)
+		default:
+			b.WriteString(element.name)
+		}
+	}
+	return b.String()
+}
+
+// get returns the value at the path, or nil if there isn't one
+func (p path) get(it item) *value {
+	v := it[p[0].name]
+	for _, element := range p[1:] {
+		switch {
+		case v == nil:
+			return nil
+		case element.isIndex:
+			if v.kind() != "L" || element.index >= len(v.L) {
+				return nil
+			}
+			v = v.L[element.index]
+		default:
+			if v.kind() != "M" {
+				return nil
+			}
+			v = v.M[element.name]
+		}
+	}
+	return v
+}
+
+// overlaps reports whether one of the paths is the other, or contains it
+func (p path) overlaps(other path) bool {
+	for i := 0; i < len(p) && i < len(other); i++ {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var errInvalidPath = validationError("The document path provided in the update expression is invalid for update")
+
+// setPath sets the value at the path in the item. The maps and lists along the path must already exist; an
+// index past the end of a list appends to it.
+func setPath(it item, p path, v *value) error {
+	if len(p) == 1 {
+		it[p[0].name] = v
+		return nil
+	}
+
+	updated, err := setIn(it[p[0].name], p[1:], v)
+	if err != nil {
+		return err
+	}
+	it[p[0].name] = updated
+	return nil
+}
+
+func setIn(container *value, p path, v *value) (*value, error) {
+	element := p[0]
+	if element.isIndex {
+		if container.kind() != "L" {
+			return nil, errInvalidPath
+		}
+		list := append([]*value{}, container.L...)
+		switch {
+		case len(p) == 1 && element.index >= len(list):
+			list = append(list, v)
+		case len(p) == 1:
+			list[element.index] = v
+		case element.index >= len(list):
+			return nil, errInvalidPath
+		default:
+			child, err := setIn(list[element.index], p[1:], v)
+			if err != nil {
+				return nil, err
+			}
+			list[element.index] = child
+		}
+		return &value{L: list}, nil
+	}
+
+	if container.kind() != "M" {
+		return nil, errInvalidPath
+	}
+	m := make(map[string]*value, len(container.M))
+	for name, child := range container.M {
+		m[name] = child
+	}
+	if len(p) == 1 {
+		m[element.name] = v
+	} else {
+		child, err := setIn(m[element.name], p[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		m[element.name] = child
+	}
+	return &value{M: m}, nil
+}
+
+// removePath removes the value at the path from the item, if it's there. Removing an element from a list
+// moves the elements after it down.
+func removePath(it item, p path) {
+	if len(p) == 1 {
+		delete(it, p[0].name)
+		return
+	}
+	if current, ok := it[p[0].name]; ok {
+		it[p[0].name] = removeIn(current, p[1:])
+	}
+}
+
+func removeIn(container *value, p path) *value {
+	element := p[0]
+	if element.isIndex {
+		if container.kind() != "L" || element.index >= len(container.L) {
+			return container
+		}
+		list := append([]*value{}, container.L...)
+		if len(p) == 1 {
+			list = append(list[:element.index], list[element.index+1:]...)
+		} else {
+			list[element.index] = removeIn(list[element.index], p[1:])
+		}
+		return &value{L: list}
+	}
+
+	if container.kind() != "M" {
+		return container
+	}
+	m := make(map[string]*value, len(container.M))
+	for name, child := range container.M {
+		m[name] = child
+	}
+	if len(p) == 1 {
+		delete(m, element.name)
+	} else if child, ok := m[element.name]; ok {
+		m[element.name] = removeIn(child, p[1:])
+	}
+	return &value{M: m}
+}
+
+// operand is a value in an expression: an attribute, a placeholder or a function of them. It evaluates to
+// nil if it refers to an attribute the item doesn't have.
+type operand interface {
+	evaluate(it item) (*value, error)
+}
+
+type pathOperand struct{ path path }
+
+func (o pathOperand) evaluate(it item) (*value, error) { return o.path.get(it), nil }
+
+type valueOperand struct{ value *value }
+
+func (o valueOperand) evaluate(item) (*value, error) { return o.value, nil }
+
+// sizeOperand is size(path): the length of a string or binary, or the number of elements in a collection
+type sizeOperand struct{ path path }
+
+func (o sizeOperand) evaluate(it item) (*value, error) {
+	v := o.path.get(it)
+	var size int
+	switch v.kind() {
+	case "S":
+		size = len(*v.S)
+	case "B":
+		size = len(v.B)
+	case "SS", "NS", "BS":
+		size = len(v.members())
+	case "M":
+		size = len(v.M)
+	case "L":
+		size = len(v.L)
+	default:
+		return nil, nil
+	}
+	return numberValue(strconv.Itoa(size)), nil
+}
+
+// ifNotExistsOperand is if_not_exists(path, operand), which is the attribute if it exists and the operand
+// if it doesn't
+type ifNotExistsOperand struct {
+	path     path
+	fallback operand
+}
+
+func (o ifNotExistsOperand) evaluate(it item) (*value, error) {
+	if v := o.path.get(it); v != nil {
+		return v, nil
+	}
+	return o.fallback.evaluate(it)
+}
+
+// listAppendOperand is list_append(list1, list2)
+type listAppendOperand struct{ first, second operand }
+
+func (o listAppendOperand) evaluate(it item) (*value, error) {
+	first, err := o.first.evaluate(it)
+	if err != nil {
+		return nil, err
+	}
+	second, err := o.second.evaluate(it)
+	if err != nil {
+		return nil, err
+	}
+	if first.kind() != "L" || second.kind() != "L" {
+		return nil, validationError("An operand in the update expression has an incorrect data type")
+	}
+	return &value{L: append(append([]*value{}, first.L...), second.L...)}, nil
+}
+
+// arithmeticOperand adds or subtracts two numbers
+type arithmeticOperand struct {
+	operator    string
+	left, right operand
+}
+
+func (o arithmeticOperand) evaluate(it item) (*value, error) {
+	left, err := o.left.evaluate(it)
+	if err != nil {
+		return nil, err
+	}
+	right, err := o.right.evaluate(it)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, validationError("The provided expression refers to an attribute that does not exist in the item")
+	}
+	if left.kind() != "N" || right.kind() != "N" {
+		return nil, validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	x, _ := parseNumber(*left.N)
+	y, _ := parseNumber(*right.N)
+	if o.operator == "+" {
+		return numberValue(formatNumber(new(big.Rat).Add(x, y))), nil
+	}
+	return numberValue(formatNumber(new(big.Rat).Sub(x, y))), nil
+}
+
+// condition is a condition, filter or key condition expression
+type condition interface {
+	evaluate(it item) (bool, error)
+}
+
+type comparison struct {
+	operator    string
+	left, right operand
+}
+
+func (c comparison) evaluate(it item) (bool, error) {
+	left, err := c.left.evaluate(it)
+	if err != nil {
+		return false, err
+	}
+	right, err := c.right.evaluate(it)
+	if err != nil {
+		return false, err
+	}
+
+	switch c.operator {
+	case "=":
+		return equal(left, right), nil
+	case "<>":
+		return !equal(left, right), nil
+	}
+
+	order, ok := compare(left, right)
+	if !ok {
+		return false, nil
+	}
+	switch c.operator {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+type between struct {
+	operand, low, high operand
+}
+
+func (c between) evaluate(it item) (bool, error) {
+	v, err := c.operand.evaluate(it)
+	if err != nil {
+		return false, err
+	}
+	low, err := c.low.evaluate(it)
+	if err != nil {
+		return false, err
+	}
+	high, err := c.high.evaluate(it)
+	if err != nil {
+		return false, err
+	}
+
+	if order, ok := compare(low, high); ok && order > 0 {
+		return false, validationError("The BETWEEN operator requires upper bound to be greater than or equal to lower bound")
+	}
+	above, ok := compare(v, low)
+	if !ok || above < 0 {
+		return false, nil
+	}
+	below, ok := compare(v, high)
+	return ok && below <= 0, nil
+}
+
+type in struct {
+	operand operand
+	list    []operand
+}
+
+func (c in) evaluate(it item) (bool, error) {
+	v, err := c.operand.evaluate(it)
+	if err != nil {
+		return false, err
+	}
+	for _, candidate := range c.list {
+		other, err := candidate.evaluate(it)
+		if err != nil {
+			return false, err
+		}
+		if equal(v, other) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// function is one of the functions that are conditions in their own right
+type function struct {
+	name     string
+	path     path
+	argument operand
+}
+
+func (c function) evaluate(it item) (bool, error) {
+	v := c.path.get(it)
+	switch c.name {
+	case "attribute_exists":
+		return v != nil, nil
+	case "attribute_not_exists":
+		return v == nil, nil
+	}
+
+	argument, err := c.argument.evaluate(it)
+	if err != nil {
+		return false, err
+	}
+	if v == nil || argument == nil {
+		return false, nil
+	}
+
+	switch c.name {
+	case "attribute_type":
+		if argument.kind() != "S" {
+			return false, validationError("Invalid ConditionExpression: Incorrect operand type for operator or function; operator or function: attribute_type, operand type: %s", argument.kind())
+		}
+		return v.kind() == *argument.S, nil
+	case "begins_with":
+		switch {
+		case v.kind() == "S" && argument.kind() == "S":
+			return strings.HasPrefix(*v.S, *argument.S), nil
+		case v.kind() == "B" && argument.kind() == "B":
+			return strings.HasPrefix(string(v.B), string(argument.B)), nil
+		}
+		return false, nil
+	default: // contains
+		switch v.kind() {
+		case "S":
+			return argument.kind() == "S" && strings.Contains(*v.S, *argument.S), nil
+		case "B":
+			return argument.kind() == "B" && strings.Contains(string(v.B), string(argument.B)), nil
+		case "SS", "NS", "BS", "L":
+			elements := v.L
+			if v.kind() != "L" {
+				elements = v.members()
+			}
+			for _, element := range elements {
+				if equal(element, argument) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+}
+
+type and struct{ left, right condition }
+
+func (c and) evaluate(it item) (bool, error) {
+	left, err := c.left.evaluate(it)
+	if err != nil || !left {
+		return false, err
+	}
+	return c.right.evaluate(it)
+}
+
+type or struct{ left, right condition }
+
+func (c or) evaluate(it item) (bool, error) {
+	left, err := c.left.evaluate(it)
+	if err != nil || left {
+		return left, err
+	}
+	return c.right.evaluate(it)
+}
+
+type not struct{ condition condition }
+
+func (c not) evaluate(it item) (bool, error) {
+	result, err := c.condition.evaluate(it)
+	return !result, err
+}
+
+// parser reads one expression of a request
+type parser struct {
+	kind         string // The request parameter the expression came from, such as ConditionExpression
+	tokens       []token
+	pos          int
+	placeholders *placeholders
+}
+
+func newParser(kind, expression string, placeholders *placeholders) (*parser, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, validationError("Invalid %s: The expression can not be empty;", kind)
+	}
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, validationError("Invalid %s: %v", kind, err)
+	}
+	return &parser{kind: kind, tokens: tokens, placeholders: placeholders}, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isSymbol(symbol string) bool {
+	return p.peek().kind == tokenSymbol && p.peek().text == symbol
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	return p.peek().kind == tokenIdent && strings.EqualFold(p.peek().text, keyword)
+}
+
+func (p *parser) expect(symbol string) error {
+	if !p.isSymbol(symbol) {
+		return p.syntaxError()
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) expectEOF() error {
+	if p.peek().kind != tokenEOF {
+		return p.syntaxError()
+	}
+	return nil
+}
+
+func (p *parser) syntaxError() error {
+	return validationError("Invalid %s: Syntax error; token: %q", p.kind, p.peek().text)
+}
+
+func (p *parser) fail(err error) error {
+	return validationError("Invalid %s: %v", p.kind, err)
+}
+
+// parsePath reads a document path, such as a.b[1] or #status
+func (p *parser) parsePath() (path, error) {
+	var result path
+
+	for {
+		t := p.next()
+		switch t.kind {
+		case tokenIdent:
+			if isReserved(t.text) {
+				return nil, validationError("Invalid %s: Attribute name is a reserved keyword; reserved keyword: %s", p.kind, t.text)
+			}
+			result = append(result, pathElement{name: t.text})
+		case tokenName:
+			name, err := p.placeholders.name(t.text)
+			if err != nil {
+				return nil, p.fail(err)
+			}
+			result = append(result, pathElement{name: name})
+		default:
+			p.pos--
+			return nil, p.syntaxError()
+		}
+
+		for p.isSymbol("[") {
+			p.next()
+			index := p.next()
+			if index.kind != tokenNumber {
+				p.pos--
+				return nil, p.syntaxError()
+			}
+			i, err := strconv.Atoi(index.text)
+			if err != nil {
+				return nil, p.syntaxError()
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			result = append(result, pathElement{index: i, isIndex: true})
+		}
+
+		if !p.isSymbol(".") {
+			return result, nil
+		}
+		p.next()
+	}
+}
+
+// parseOperand reads a path, a value or size(path)
+func (p *parser) parseOperand() (operand, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenValue:
+		p.next()
+		v, err := p.placeholders.value(t.text)
+		if err != nil {
+			return nil, p.fail(err)
+		}
+		return valueOperand{v}, nil
+	case t.kind == tokenIdent && t.text == "size" && p.peekAt(1).text == "(":
+		p.next()
+		p.next()
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return sizeOperand{path}, nil
+	case t.kind == tokenIdent || t.kind == tokenName:
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{path}, nil
+	}
+	return nil, p.syntaxError()
+}
+
+// parseCondition reads a whole condition, filter or key condition expression
+func parseCondition(kind, expression string, placeholders *placeholders) (condition, error) {
+	p, err := newParser(kind, expression, placeholders)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return result, p.expectEOF()
+}
+
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		c, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return not{c}, nil
+	}
+	return p.parsePrimary()
+}
+
+var conditionFunctions = map[string]bool{
+	"attribute_exists":     true,
+	"attribute_not_exists": true,
+	"attribute_type":       true,
+	"begins_with":          true,
+	"contains":             true,
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	if p.isSymbol("(") {
+		p.next()
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	}
+
+	if t := p.peek(); t.kind == tokenIdent && p.peekAt(1).text == "(" {
+		if !conditionFunctions[t.text] && t.text != "size" {
+			return nil, validationError("Invalid %s: Invalid function name; function: %s", p.kind, t.text)
+		}
+		if conditionFunctions[t.text] {
+			return p.parseFunction()
+		}
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	switch {
+	case t.kind == tokenSymbol && strings.Contains(" = <> < <= > >= ", " "+t.text+" "):
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{t.text, left, right}, nil
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.syntaxError()
+		}
+		p.next()
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return between{left, low, high}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var list []operand
+		for {
+			candidate, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, candidate)
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+		if len(list) > 100 {
+			return nil, validationError("Invalid %s: The IN operator is provided with too many operands; number of operands: %d", p.kind, len(list))
+		}
+		return in{left, list}, p.expect(")")
+	}
+	return nil, p.syntaxError()
+}
+
+func (p *parser) parseFunction() (condition, error) {
+	name := p.next().text
+	p.next() // (
+
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	result := function{name: name, path: path}
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if result.argument, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+	}
+	return result, p.expect(")")
+}
+
+// keyCondition is a query's key condition: an equality on the partition key, and optionally a condition on
+// the sort key
+type keyCondition struct {
+	condition condition
+	partition *value
+}
+
+// parseKeyCondition reads a key condition expression, and checks that it only constrains the key
+func parseKeyCondition(expression string, placeholders *placeholders, partitionKey, sortKey string) (keyCondition, error) {
+	c, err := parseCondition("KeyConditionExpression", expression, placeholders)
+	if err != nil {
+		return keyCondition{}, err
+	}
+
+	var terms []condition
+	if both, ok := c.(and); ok {
+		terms = []condition{both.left, both.right}
+	} else {
+		terms = []condition{c}
+	}
+
+	result := keyCondition{condition: c}
+	sortKeyConditions := 0
+	for _, term := range terms {
+		attribute, ok := keyConditionAttribute(term)
+		switch {
+		case !ok:
+			return keyCondition{}, validationError("Invalid operator used in KeyConditionExpression")
+		case attribute == partitionKey:
+			comparison, ok := term.(comparison)
+			if !ok || comparison.operator != "=" {
+				return keyCondition{}, validationError("Query key condition not supported")
+			}
+			result.partition = comparison.right.(valueOperand).value
+		case attribute == sortKey:
+			sortKeyConditions++
+		default:
+			return keyCondition{}, validationError("Query condition missed key schema element: %s", partitionKey)
+		}
+	}
+	if result.partition == nil {
+		return keyCondition{}, validationError("Query condition missed key schema element: %s", partitionKey)
+	}
+	if sortKeyConditions > 1 {
+		return keyCondition{}, validationError("KeyConditionExpressions must only contain one condition per key")
+	}
+
+	return result, nil
+}
+
+// keyConditionAttribute returns the attribute a term of a key condition constrains, which must be compared
+// to a value
+func keyConditionAttribute(term condition) (string, bool) {
+	isValue := func(o operand) bool { _, ok := o.(valueOperand); return ok }
+	attribute := func(o operand) (string, bool) {
+		path, ok := o.(pathOperand)
+		if !ok || len(path.path) != 1 {
+			return "", false
+		}
+		return path.path[0].name, true
+	}
+
+	switch c := term.(type) {
+	case comparison:
+		if c.operator == "<>" || !isValue(c.right) {
+			return "", false
+		}
+		return attribute(c.left)
+	case between:
+		if !isValue(c.low) || !isValue(c.high) {
+			return "", false
+		}
+		return attribute(c.operand)
+	case function:
+		if c.name != "begins_with" || len(c.path) != 1 || !isValue(c.argument) {
+			return "", false
+		}
+		return c.path[0].name, true
+	}
+	return "", false
+}
+
+// updateAction is an action in one of an update expression's clauses
+type updateAction struct {
+	path    path
+	operand operand // SET's value, or ADD's and DELETE's
+}
+
+// update is a parsed update expression
+type update struct {
+	set, remove, add, delete []updateAction
+}
+
+// parseUpdate reads an update expression
+func parseUpdate(expression string, placeholders *placeholders) (*update, error) {
+	p, err := newParser("UpdateExpression", expression, placeholders)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &update{}
+	seen := make(map[string]bool)
+	for p.peek().kind != tokenEOF {
+		clause := strings.ToUpper(p.peek().text)
+		if p.peek().kind != tokenIdent || (clause != "SET" && clause != "REMOVE" && clause != "ADD" && clause != "DELETE") {
+			return nil, p.syntaxError()
+		}
+		if seen[clause] {
+			return nil, validationError("Invalid UpdateExpression: The %q section can only be used once in an update expression;", clause)
+		}
+		seen[clause] = true
+		p.next()
+
+		for {
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			action := updateAction{path: path}
+
+			switch clause {
+			case "SET":
+				if err := p.expect("="); err != nil {
+					return nil, err
+				}
+				if action.operand, err = p.parseSetValue(); err != nil {
+					return nil, err
+				}
+				result.set = append(result.set, action)
+			case "REMOVE":
+				result.remove = append(result.remove, action)
+			default:
+				t := p.next()
+				if t.kind != tokenValue {
+					p.pos--
+					return nil, p.syntaxError()
+				}
+				v, err := placeholders.value(t.text)
+				if err != nil {
+					return nil, p.fail(err)
+				}
+				action.operand = valueOperand{v}
+				if clause == "ADD" {
+					result.add = append(result.add, action)
+				} else {
+					result.delete = append(result.delete, action)
+				}
+			}
+
+			if !p.isSymbol(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	actions := result.actions()
+	for i := range actions {
+		for j := i + 1; j < len(actions); j++ {
+			if actions[i].path.overlaps(actions[j].path) {
+				return nil, validationError("Invalid UpdateExpression: Two document paths overlap with each other; must remove or rewrite one of these paths; path one: [%s], path two: [%s]", actions[i].path, actions[j].path)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// actions returns all the update's actions
+func (u *update) actions() []updateAction {
+	var result []updateAction
+	for _, clause := range [][]updateAction{u.set, u.remove, u.add, u.delete} {
+		result = append(result, clause...)
+	}
+	return result
+}
+
+// parseSetValue reads the value a SET action assigns, which may add or subtract two operands
+func (p *parser) parseSetValue() (operand, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.isSymbol("+") || p.isSymbol("-") {
+		operator := p.next().text
+		right, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return arithmeticOperand{operator, left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseSetOperand() (operand, error) {
+	t := p.peek()
+	if t.kind != tokenIdent || p.peekAt(1).text != "(" {
+		return p.parseOperand()
+	}
+
+	p.next()
+	p.next()
+	switch t.text {
+	case "if_not_exists":
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		fallback, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return ifNotExistsOperand{path, fallback}, p.expect(")")
+	case "list_append":
+		first, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		second, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return listAppendOperand{first, second}, p.expect(")")
+	}
+	return nil, validationError("Invalid UpdateExpression: Invalid function name; function: %s", t.text)
+}
+
+// apply returns the item with the update applied. Every value is evaluated against the item as it was
+// before the update.
+func (u *update) apply(old item) (item, error) {
+	values := make([]*value, len(u.set))
+	for i, action := range u.set {
+		v, err := action.operand.evaluate(old)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, validationError("The provided expression refers to an attribute that does not exist in the item")
+		}
+		values[i] = v
+	}
+
+	result := old.clone()
+	for i, action := range u.set {
+		if err := setPath(result, action.path, values[i]); err != nil {
+			return nil, err
+		}
+	}
+	for _, action := range u.remove {
+		removePath(result, action.path)
+	}
+	for _, action := range u.add {
+		v, _ := action.operand.evaluate(old)
+		updated, err := add(action.path.get(result), v)
+		if err != nil {
+			return nil, err
+		}
+		if err := setPath(result, action.path, updated); err != nil {
+			return nil, err
+		}
+	}
+	for _, action := range u.delete {
+		v, _ := action.operand.evaluate(old)
+		remaining, err := subtract(action.path.get(result), v)
+		if err != nil {
+			return nil, err
+		}
+		if remaining == nil {
+			removePath(result, action.path)
+		} else if err := setPath(result, action.path, remaining); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// add is ADD's arithmetic: it adds to a number or to a set, or starts one
+func add(current, v *value) (*value, error) {
+	switch {
+	case v.kind() != "N" && v.members() == nil:
+		return nil, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD, operand type: %s", v.kind())
+	case current == nil:
+		return v, nil
+	case current.kind() != v.kind():
+		return nil, validationError("An operand in the update expression has an incorrect data type")
+	case v.kind() == "N":
+		x, _ := parseNumber(*current.N)
+		y, _ := parseNumber(*v.N)
+		return numberValue(formatNumber(new(big.Rat).Add(x, y))), nil
+	}
+
+	members := current.members()
+	for _, member := range v.members() {
+		found := false
+		for _, existing := range members {
+			found = found || equal(existing, member)
+		}
+		if !found {
+			members = append(members, member)
+		}
+	}
+	return setOf(v.kind(), members), nil
+}
+
+// subtract is DELETE's arithmetic: it takes members out of a set. It returns nil if the set is left empty.
+func subtract(current, v *value) (*value, error) {
+	switch {
+	case v.members() == nil:
+		return nil, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: DELETE, operand type: %s", v.kind())
+	case current == nil:
+		return nil, nil
+	case current.kind() != v.kind():
+		return nil, validationError("An operand in the update expression has an incorrect data type")
+	}
+
+	var remaining []*value
+	for _, existing := range current.members() {
+		found := false
+		for _, member := range v.members() {
+			found = found || equal(existing, member)
+		}
+		if !found {
+			remaining = append(remaining, existing)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil, nil
+	}
+	return setOf(v.kind(), remaining), nil
+}
+
+// parseProjection reads a projection expression, a list of paths
+func parseProjection(expression string, placeholders *placeholders) ([]path, error) {
+	p, err := newParser("ProjectionExpression", expression, placeholders)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []path
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, path)
+		if !p.isSymbol(",") {
+			break
+		}
+		p.next()
+	}
+	return result, p.expectEOF()
+}
+
+// project returns the parts of the item at the paths
+func project(it item, paths []path) item {
+	result := make(item)
+	for _, p := range paths {
+		v := p.get(it)
+		if v == nil {
+			continue
+		}
+		if len(p) == 1 {
+			result[p[0].name] = v
+			continue
+		}
+		result[p[0].name] = projectInto(result[p[0].name], it[p[0].name], p[1:], v)
+	}
+	return result
+}
+
+// projectInto copies the value at the path from the source document into the projected one, creating the
+// maps and lists along the way
+func projectInto(projected, source *value, p path, v *value) *value {
+	if len(p) == 0 {
+		return v
+	}
+
+	element := p[0]
+	if element.isIndex {
+		list := []*value{}
+		if projected != nil {
+			list = append(list, projected.L...)
+		}
+		// A projected list keeps the elements in their original order, without gaps
+		return &value{L: append(list, projectInto(nil, source.L[element.index], p[1:], v))}
+	}
+
+	m := make(map[string]*value)
+	if projected != nil {
+		for name, child := range projected.M {
+			m[name] = child
+		}
+	}
+	m[element.name] = projectInto(m[element.name], source.M[element.name], p[1:], v)
+	return &value{M: m}
+}
@@ -0,0 +1,234 @@
+package dynamodbtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+func Test_parseCondition(t *testing.T) {
+	boolean := true
+	book := item{
+		"id":      stringValue("1"),
+		"title":   stringValue("Beloved"),
+		"status":  stringValue("checked-in"),
+		"copies":  numberValue("3"),
+		"tags":    {SS: []string{"fiction", "classic"}},
+		"loaned":  {BOOL: &boolean},
+		"history": {L: []*value{stringValue("added"), stringValue("loaned")}},
+		"shelf":   {M: map[string]*value{"floor": numberValue("2")}},
+	}
+
+	type state struct {
+		expression string
+		names      map[string]*string
+		values     map[string]*value
+	}
+	type expected struct {
+		result bool
+		err    error
+	}
+	status := "status"
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"An attribute equals a value": {
+			state{expression: "title = :t", values: map[string]*value{":t": stringValue("Beloved")}},
+			expected{result: true},
+		},
+		"Numbers are compared by value": {
+			state{expression: "copies = :n", values: map[string]*value{":n": numberValue("3.0")}},
+			expected{result: true},
+		},
+		"A number is ordered numerically": {
+			state{expression: "copies < :n", values: map[string]*value{":n": numberValue("10")}},
+			expected{result: true},
+		},
+		"Values of different types aren't ordered": {
+			state{expression: "copies < :n", values: map[string]*value{":n": stringValue("10")}},
+			expected{result: false},
+		},
+		"A missing attribute doesn't equal anything": {
+			state{expression: "author = :a", values: map[string]*value{":a": stringValue("Toni Morrison")}},
+			expected{result: false},
+		},
+		"A missing attribute is different from everything": {
+			state{expression: "author <> :a", values: map[string]*value{":a": stringValue("Toni Morrison")}},
+			expected{result: true},
+		},
+		"A reserved word is referred to by a name": {
+			state{expression: "#s IN (:a, :b)", names: map[string]*string{"#s": &status}, values: map[string]*value{":a": stringValue("lost"), ":b": stringValue("checked-in")}},
+			expected{result: true},
+		},
+		"A reserved word can't be used as an attribute name": {
+			state{expression: "status = :s", values: map[string]*value{":s": stringValue("checked-in")}},
+			expected{err: errors.New("ValidationException: Invalid ConditionExpression: Attribute name is a reserved keyword; reserved keyword: status")},
+		},
+		"AND binds tighter than OR": {
+			state{expression: "attribute_exists(author) AND copies = :n OR title = :t", values: map[string]*value{":n": numberValue("1"), ":t": stringValue("Beloved")}},
+			expected{result: true},
+		},
+		"Parentheses group conditions": {
+			state{expression: "attribute_exists(author) AND (copies = :n OR title = :t)", values: map[string]*value{":n": numberValue("1"), ":t": stringValue("Beloved")}},
+			expected{result: false},
+		},
+		"NOT negates a condition": {
+			state{expression: "NOT attribute_exists(deleted_at)"},
+			expected{result: true},
+		},
+		"BETWEEN includes its bounds": {
+			state{expression: "copies BETWEEN :low AND :high", values: map[string]*value{":low": numberValue("1"), ":high": numberValue("3")}},
+			expected{result: true},
+		},
+		"begins_with matches a prefix": {
+			state{expression: "begins_with(title, :p)", values: map[string]*value{":p": stringValue("Bel")}},
+			expected{result: true},
+		},
+		"contains finds a member of a set": {
+			state{expression: "contains(tags, :t)", values: map[string]*value{":t": stringValue("classic")}},
+			expected{result: true},
+		},
+		"contains finds an element of a list": {
+			state{expression: "contains(history, :h)", values: map[string]*value{":h": stringValue("loaned")}},
+			expected{result: true},
+		},
+		"attribute_type checks the type": {
+			state{expression: "attribute_type(loaned, :t)", values: map[string]*value{":t": stringValue("BOOL")}},
+			expected{result: true},
+		},
+		"size counts the elements": {
+			state{expression: "size(history) = :n", values: map[string]*value{":n": numberValue("2")}},
+			expected{result: true},
+		},
+		"A path reaches into a map and a list": {
+			state{expression: "shelf.floor = :f AND history[1] = :h", values: map[string]*value{":f": numberValue("2"), ":h": stringValue("loaned")}},
+			expected{result: true},
+		},
+		"A value must be defined": {
+			state{expression: "title = :t"},
+			expected{err: errors.New("ValidationException: Invalid ConditionExpression: An expression attribute value used in expression is not defined; attribute value: :t")},
+		},
+		"A name must be defined": {
+			state{expression: "attribute_exists(#s)"},
+			expected{err: errors.New("ValidationException: Invalid ConditionExpression: An expression attribute name used in the document path is not defined; attribute name: #s")},
+		},
+		"A function must exist": {
+			state{expression: "starts_with(title, :t)", values: map[string]*value{":t": stringValue("B")}},
+			expected{err: errors.New("ValidationException: Invalid ConditionExpression: Invalid function name; function: starts_with")},
+		},
+		"The expression must be complete": {
+			state{expression: "title = "},
+			expected{err: errors.New(`ValidationException: Invalid ConditionExpression: Syntax error; token: "<EOF>"`)},
+		},
+		"The expression can't be empty": {
+			state{expression: " "},
+			expected{err: errors.New("ValidationException: Invalid ConditionExpression: The expression can not be empty;")},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			c, err := parseCondition("ConditionExpression", tc.state.expression, newPlaceholders(tc.state.names, tc.state.values))
+			if tc.expected.err != nil {
+				assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+				return
+			}
+			assert.So(err, should.BeNil)
+
+			result, err := c.evaluate(book)
+			assert.So(err, should.BeNil)
+			assert.So(result, should.Equal, tc.expected.result)
+		})
+	}
+}
+
+func Test_parseUpdate(t *testing.T) {
+	loan := item{
+		"barcode":    stringValue("0001"),
+		"renewals":   numberValue("1"),
+		"loan_state": stringValue("open"),
+		"tags":       {SS: []string{"fiction"}},
+		"history":    {L: []*value{stringValue("checked-out")}},
+	}
+
+	type state struct {
+		expression string
+		values     map[string]*value
+	}
+	type expected struct {
+		item item
+		err  error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"SET assigns and REMOVE removes": {
+			state{expression: "SET returned_at = :r REMOVE loan_state", values: map[string]*value{":r": stringValue("2021-03-01")}},
+			expected{item: item{"barcode": stringValue("0001"), "renewals": numberValue("1"), "returned_at": stringValue("2021-03-01"), "tags": {SS: []string{"fiction"}}, "history": {L: []*value{stringValue("checked-out")}}}},
+		},
+		"SET adds to a number": {
+			state{expression: "SET renewals = renewals + :one", values: map[string]*value{":one": numberValue("1")}},
+			expected{item: item{"barcode": stringValue("0001"), "renewals": numberValue("2"), "loan_state": stringValue("open"), "tags": {SS: []string{"fiction"}}, "history": {L: []*value{stringValue("checked-out")}}}},
+		},
+		"if_not_exists and list_append build on what's there": {
+			state{expression: "SET fines = if_not_exists(fines, :zero), history = list_append(history, :h)", values: map[string]*value{":zero": numberValue("0"), ":h": {L: []*value{stringValue("renewed")}}}},
+			expected{item: item{"barcode": stringValue("0001"), "renewals": numberValue("1"), "loan_state": stringValue("open"), "tags": {SS: []string{"fiction"}}, "history": {L: []*value{stringValue("checked-out"), stringValue("renewed")}}, "fines": numberValue("0")}},
+		},
+		"ADD adds to a set": {
+			state{expression: "ADD tags :add", values: map[string]*value{":add": {SS: []string{"classic", "fiction"}}}},
+			expected{item: item{"barcode": stringValue("0001"), "renewals": numberValue("1"), "loan_state": stringValue("open"), "tags": {SS: []string{"fiction", "classic"}}, "history": {L: []*value{stringValue("checked-out")}}}},
+		},
+		"DELETE only takes from a set": {
+			state{expression: "DELETE history :h", values: map[string]*value{":h": {SS: []string{"checked-out"}}}},
+			expected{err: errors.New("ValidationException: An operand in the update expression has an incorrect data type")},
+		},
+		"Arithmetic needs numbers": {
+			state{expression: "SET renewals = loan_state + :one", values: map[string]*value{":one": numberValue("1")}},
+			expected{err: errors.New("ValidationException: An operand in the update expression has an incorrect data type")},
+		},
+		"A path can only be changed once": {
+			state{expression: "SET renewals = :one REMOVE renewals", values: map[string]*value{":one": numberValue("1")}},
+			expected{err: errors.New("ValidationException: Invalid UpdateExpression: Two document paths overlap with each other; must remove or rewrite one of these paths; path one: [renewals], path two: [renewals]")},
+		},
+		"A clause can only appear once": {
+			state{expression: "SET a = :one SET b = :one", values: map[string]*value{":one": numberValue("1")}},
+			expected{err: errors.New(`ValidationException: Invalid UpdateExpression: The "SET" section can only be used once in an update expression;`)},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			u, err := parseUpdate(tc.state.expression, newPlaceholders(nil, tc.state.values))
+			var result item
+			if err == nil {
+				result, err = u.apply(loan)
+			}
+			if tc.expected.err != nil {
+				assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+				return
+			}
+			assert.So(err, should.BeNil)
+			assert.So(result, should.Resemble, tc.expected.item)
+		})
+	}
+}
+
+func Test_placeholders_checkUnused(t *testing.T) {
+	assert := assertions.New(t)
+	status := "status"
+
+	p := newPlaceholders(map[string]*string{"#s": &status}, map[string]*value{":s": stringValue("lost"), ":unused": stringValue("x")})
+	_, err := parseCondition("ConditionExpression", "#s = :s", p)
+	assert.So(err, should.BeNil)
+	assert.So(p.checkUnused(), testutils.ShouldEqualError, errors.New("ValidationException: Value provided in ExpressionAttributeValues unused in expressions: keys: {:unused}"))
+}
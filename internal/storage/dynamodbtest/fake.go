@@ -0,0 +1,710 @@
+// Package dynamodbtest provides an in-memory fake of DynamoDB, for tests to run against when there's no
+// DynamoDB Local. The fake speaks DynamoDB's HTTP API, so the storage is tested through the same SDK calls
+// it makes in production.
+//
+// It implements the table and item operations the storage uses, with DynamoDB's validation of keys and
+// expressions, and its condition, transaction and paging semantics. Every table is strongly consistent, and
+// every transaction is isolated from every other request. A test can inject faults, such as throttling, to
+// fail an operation's requests with.
+package dynamodbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake is an in-memory DynamoDB. It's an http.Handler, usually served by an httptest.Server.
+type Fake struct {
+	mu       sync.Mutex
+	tables   map[string]*table
+	pageSize int
+	now      func() time.Time
+	faults   map[string]*injectedFault
+	requests map[string]int
+}
+
+type FakeOption func(*Fake)
+
+// WithPageSize limits the number of items a scan or query reads for a page, so a test can exercise the
+// client's paging without writing a megabyte of items
+func WithPageSize(pageSize int) FakeOption {
+	return func(f *Fake) {
+		f.pageSize = pageSize
+	}
+}
+
+func NewFake(opts ...FakeOption) *Fake {
+	result := &Fake{
+		tables: make(map[string]*table),
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(result)
+	}
+
+	return result
+}
+
+// targetPrefix prefixes the operation in a request's X-Amz-Target header
+const targetPrefix = "DynamoDB_20120810."
+
+var operations = map[string]func(f *Fake, body []byte) (interface{}, error){
+	"CreateTable":        (*Fake).createTable,
+	"UpdateTable":        (*Fake).updateTable,
+	"DeleteTable":        (*Fake).deleteTable,
+	"DescribeTable":      (*Fake).describeTable,
+	"ListTables":         (*Fake).listTables,
+	"GetItem":            (*Fake).getItem,
+	"PutItem":            (*Fake).putItem,
+	"DeleteItem":         (*Fake).deleteItem,
+	"UpdateItem":         (*Fake).updateItem,
+	"Query":              (*Fake).query,
+	"Scan":               (*Fake).scan,
+	"TransactWriteItems": (*Fake).transactWriteItems,
+	"BatchWriteItem":     (*Fake).batchWriteItem,
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	name := strings.TrimPrefix(target, targetPrefix)
+	operation, ok := operations[name]
+	if r.Method != http.MethodPost || !strings.HasPrefix(target, targetPrefix) || !ok {
+		writeError(w, &apiError{status: http.StatusBadRequest, code: "UnknownOperationException", message: fmt.Sprintf("dynamodbtest doesn't support %q", target)})
+		return
+	}
+	if fault := f.receive(name); fault != nil {
+		writeError(w, fault)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, &apiError{status: http.StatusBadRequest, code: "SerializationException", message: err.Error()})
+		return
+	}
+
+	result, err := operation(f, body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, &apiError{status: http.StatusInternalServerError, code: "InternalServerError", message: err.Error()})
+		return
+	}
+	writeResponse(w, http.StatusOK, encoded)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*apiError)
+	if !ok {
+		e = &apiError{status: http.StatusInternalServerError, code: "InternalServerError", message: err.Error()}
+	}
+
+	body := map[string]interface{}{
+		"__type":  "com.amazonaws.dynamodb.v20120810#" + e.code,
+		"message": e.message,
+	}
+	if e.reasons != nil {
+		body["CancellationReasons"] = e.reasons
+	}
+	encoded, _ := json.Marshal(body)
+
+	writeResponse(w, e.status, encoded)
+}
+
+// writeResponse sends the response with the checksum of its body, which the SDK checks the body against
+func writeResponse(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Header().Set("X-Amz-Crc32", strconv.FormatUint(uint64(crc32.ChecksumIEEE(body)), 10))
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// decode reads a request's parameters
+func decode(body []byte, request interface{}) error {
+	if err := json.Unmarshal(body, request); err != nil {
+		return &apiError{status: http.StatusBadRequest, code: "SerializationException", message: err.Error()}
+	}
+	return nil
+}
+
+type keySchemaElement struct {
+	AttributeName string
+	KeyType       string
+}
+
+type attributeDefinition struct {
+	AttributeName string
+	AttributeType string
+}
+
+type projection struct {
+	ProjectionType   string
+	NonKeyAttributes []string `json:",omitempty"`
+}
+
+type provisionedThroughput struct {
+	ReadCapacityUnits  int64
+	WriteCapacityUnits int64
+}
+
+type indexDefinition struct {
+	IndexName             string
+	KeySchema             []keySchemaElement
+	Projection            projection
+	ProvisionedThroughput *provisionedThroughput `json:",omitempty"`
+}
+
+// table is a table's definition and its items, keyed by their primary key
+type table struct {
+	name          string
+	created       time.Time
+	keySchema     []keySchemaElement
+	attributes    []attributeDefinition
+	billingMode   string
+	throughput    provisionedThroughput
+	globalIndexes []indexDefinition
+	localIndexes  []indexDefinition
+	items         map[string]item
+}
+
+// keyNames returns the names of the partition key and, if there is one, the sort key
+func keyNames(schema []keySchemaElement) (partition, sort string) {
+	for _, element := range schema {
+		if element.KeyType == "HASH" {
+			partition = element.AttributeName
+		} else {
+			sort = element.AttributeName
+		}
+	}
+	return partition, sort
+}
+
+func (t *table) attributeType(name string) string {
+	for _, definition := range t.attributes {
+		if definition.AttributeName == name {
+			return definition.AttributeType
+		}
+	}
+	return ""
+}
+
+// keyOf returns the string that identifies the item's primary key
+func (t *table) keyOf(it item) string {
+	partition, sort := keyNames(t.keySchema)
+	result := it[partition].key()
+	if sort != "" {
+		result += "|" + it[sort].key()
+	}
+	return result
+}
+
+// primaryKey returns just the item's primary key attributes
+func (t *table) primaryKey(it item) item {
+	result := make(item)
+	for _, element := range t.keySchema {
+		result[element.AttributeName] = it[element.AttributeName]
+	}
+	return result
+}
+
+// validateKey checks that the key has exactly the table's key attributes, of the right types
+func (t *table) validateKey(key item) error {
+	if len(key) != len(t.keySchema) {
+		return validationError("The provided key element does not match the schema")
+	}
+	for _, element := range t.keySchema {
+		v, ok := key[element.AttributeName]
+		if !ok || v.validate() != nil || v.kind() != t.attributeType(element.AttributeName) {
+			return validationError("The provided key element does not match the schema")
+		}
+		if isEmpty(v) {
+			return validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty %s value. Key: %s", kindName(v), element.AttributeName)
+		}
+	}
+	return nil
+}
+
+// validateItem checks the item's attributes, that it has the table's key attributes, and that the
+// attributes the indexes are keyed by have the right types
+func (t *table) validateItem(it item) error {
+	for _, v := range it {
+		if err := v.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, element := range t.keySchema {
+		v, ok := it[element.AttributeName]
+		if !ok {
+			return validationError("One or more parameter values were invalid: Missing the key %s in the item", element.AttributeName)
+		}
+		if expected := t.attributeType(element.AttributeName); v.kind() != expected {
+			return validationError("One or more parameter values were invalid: Type mismatch for key %s expected: %s actual: %s", element.AttributeName, expected, v.kind())
+		}
+		if isEmpty(v) {
+			return validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty %s value. Key: %s", kindName(v), element.AttributeName)
+		}
+	}
+
+	for _, index := range append(append([]indexDefinition{}, t.globalIndexes...), t.localIndexes...) {
+		for _, element := range index.KeySchema {
+			v, ok := it[element.AttributeName]
+			if !ok {
+				continue
+			}
+			if expected := t.attributeType(element.AttributeName); v.kind() != expected {
+				return validationError("One or more parameter values were invalid: Type mismatch for Index Key %s Expected: %s Actual: %s IndexName: %s", element.AttributeName, expected, v.kind(), index.IndexName)
+			}
+			if isEmpty(v) {
+				return validationError("One or more parameter values are not valid. A value specified for a secondary index key is not supported. The AttributeValue for a key attribute cannot contain an empty %s value. IndexName: %s, IndexKey: %s", kindName(v), index.IndexName, element.AttributeName)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isEmpty(v *value) bool {
+	return (v.kind() == "S" && *v.S == "") || (v.kind() == "B" && len(v.B) == 0)
+}
+
+func kindName(v *value) string {
+	if v.kind() == "B" {
+		return "binary"
+	}
+	return "string"
+}
+
+func (f *Fake) table(name string) (*table, error) {
+	t, ok := f.tables[name]
+	if !ok {
+		return nil, resourceNotFound(name)
+	}
+	return t, nil
+}
+
+var tableNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,255}$`)
+
+type createTableRequest struct {
+	TableName              string
+	KeySchema              []keySchemaElement
+	AttributeDefinitions   []attributeDefinition
+	GlobalSecondaryIndexes []indexDefinition
+	LocalSecondaryIndexes  []indexDefinition
+	BillingMode            string
+	ProvisionedThroughput  *provisionedThroughput
+}
+
+func (f *Fake) createTable(body []byte) (interface{}, error) {
+	request := createTableRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+
+	t, err := newTable(request, f.now())
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.tables[t.name]; ok {
+		return nil, &apiError{status: http.StatusBadRequest, code: "ResourceInUseException", message: "Table already exists: " + t.name}
+	}
+	f.tables[t.name] = t
+
+	return map[string]interface{}{"TableDescription": t.describe("ACTIVE")}, nil
+}
+
+// newTable validates the table's definition the way CreateTable does
+func newTable(request createTableRequest, now time.Time) (*table, error) {
+	if !tableNamePattern.MatchString(request.TableName) {
+		return nil, validationError("TableName must be at least 3 characters long and at most 255 characters long, and contain only a-z, A-Z, 0-9, '_', '-' and '.'")
+	}
+
+	t := &table{
+		name:          request.TableName,
+		created:       now,
+		keySchema:     request.KeySchema,
+		attributes:    request.AttributeDefinitions,
+		billingMode:   request.BillingMode,
+		globalIndexes: request.GlobalSecondaryIndexes,
+		localIndexes:  request.LocalSecondaryIndexes,
+		items:         make(map[string]item),
+	}
+	if t.billingMode == "" {
+		t.billingMode = "PROVISIONED"
+	}
+
+	switch t.billingMode {
+	case "PAY_PER_REQUEST":
+		if request.ProvisionedThroughput != nil {
+			return nil, validationError("One or more parameter values were invalid: Neither ReadCapacityUnits nor WriteCapacityUnits can be specified when BillingMode is PAY_PER_REQUEST")
+		}
+	case "PROVISIONED":
+		if request.ProvisionedThroughput == nil {
+			return nil, validationError("One or more parameter values were invalid: ReadCapacityUnits and WriteCapacityUnits must both be specified when BillingMode is PROVISIONED")
+		}
+		t.throughput = *request.ProvisionedThroughput
+	default:
+		return nil, validationError("1 validation error detected: Value '%s' at 'billingMode' failed to satisfy constraint: Member must satisfy enum value set: [PROVISIONED, PAY_PER_REQUEST]", t.billingMode)
+	}
+
+	used := make(map[string]bool)
+	if err := validateKeySchema(t, t.keySchema, used); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	partition, _ := keyNames(t.keySchema)
+	for i, index := range append(append([]indexDefinition{}, t.globalIndexes...), t.localIndexes...) {
+		local := i >= len(t.globalIndexes)
+		if names[index.IndexName] {
+			return nil, validationError("One or more parameter values were invalid: Duplicate index name: %s", index.IndexName)
+		}
+		names[index.IndexName] = true
+
+		if err := validateKeySchema(t, index.KeySchema, used); err != nil {
+			return nil, err
+		}
+		indexPartition, indexSort := keyNames(index.KeySchema)
+		if local && (indexPartition != partition || indexSort == "") {
+			return nil, validationError("One or more parameter values were invalid: Table KeySchema does not have a range key, which is required when specifying a LocalSecondaryIndex, or the index's hash key doesn't match the table's")
+		}
+
+		switch index.Projection.ProjectionType {
+		case "ALL", "KEYS_ONLY":
+			if len(index.Projection.NonKeyAttributes) > 0 {
+				return nil, validationError("One or more parameter values were invalid: ProjectionType is %s, but NonKeyAttributes is specified", index.Projection.ProjectionType)
+			}
+		case "INCLUDE":
+		default:
+			return nil, validationError("One or more parameter values were invalid: Unknown ProjectionType: %s", index.Projection.ProjectionType)
+		}
+
+		if !local && t.billingMode == "PROVISIONED" && index.ProvisionedThroughput == nil {
+			return nil, validationError("One or more parameter values were invalid: ProvisionedThroughput is not specified for index: %s", index.IndexName)
+		}
+		if !local && t.billingMode == "PAY_PER_REQUEST" && index.ProvisionedThroughput != nil {
+			return nil, validationError("One or more parameter values were invalid: ProvisionedThroughput should not be specified for index: %s when BillingMode is PAY_PER_REQUEST", index.IndexName)
+		}
+	}
+
+	for _, definition := range t.attributes {
+		switch definition.AttributeType {
+		case "S", "N", "B":
+		default:
+			return nil, validationError("One or more parameter values were invalid: Invalid attribute type %s for %s", definition.AttributeType, definition.AttributeName)
+		}
+		if !used[definition.AttributeName] {
+			return nil, validationError("One or more parameter values were invalid: Some AttributeDefinitions are not used. AttributeDefinitions: [%s], keys used: [%s]", definitionNames(t.attributes), usedNames(used))
+		}
+	}
+
+	return t, nil
+}
+
+// validateKeySchema checks that the key has a partition key and at most one sort key, defined in the
+// table's attributes, and records the attributes it uses
+func validateKeySchema(t *table, schema []keySchemaElement, used map[string]bool) error {
+	if len(schema) == 0 || len(schema) > 2 || schema[0].KeyType != "HASH" || (len(schema) == 2 && schema[1].KeyType != "RANGE") {
+		return validationError("Invalid KeySchema: The first KeySchemaElement is not a HASH key type, or there are too many")
+	}
+	for _, element := range schema {
+		if t.attributeType(element.AttributeName) == "" {
+			return validationError("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions. Keys: [%s], AttributeDefinitions: [%s]", element.AttributeName, definitionNames(t.attributes))
+		}
+		used[element.AttributeName] = true
+	}
+	return nil
+}
+
+func definitionNames(definitions []attributeDefinition) string {
+	var names []string
+	for _, definition := range definitions {
+		names = append(names, definition.AttributeName)
+	}
+	return strings.Join(names, ", ")
+}
+
+func usedNames(used map[string]bool) string {
+	var names []string
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+type updateTableRequest struct {
+	TableName                   string
+	AttributeDefinitions        []attributeDefinition
+	GlobalSecondaryIndexUpdates []globalIndexUpdate
+}
+
+// globalIndexUpdate creates or deletes a global index. The fake doesn't change an index's throughput.
+type globalIndexUpdate struct {
+	Create *indexDefinition
+	Delete *struct{ IndexName string }
+}
+
+// updateTable creates or deletes a global index. The index is active as soon as it's created, with the
+// table's items in it.
+func (f *Fake) updateTable(body []byte) (interface{}, error) {
+	request := updateTableRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+	if len(request.GlobalSecondaryIndexUpdates) == 0 {
+		return nil, validationError("At least one of ProvisionedThroughput, BillingMode, UpdateStreamEnabled, GlobalSecondaryIndexUpdates or SSESpecification or ReplicaUpdates is required")
+	}
+	if len(request.GlobalSecondaryIndexUpdates) > 1 {
+		return nil, &apiError{status: http.StatusBadRequest, code: "LimitExceededException", message: "Subscriber limit exceeded: Only 1 online index can be created or deleted simultaneously per table"}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The updated table is validated the way it would be created
+	definition := createTableRequest{
+		TableName:              t.name,
+		KeySchema:              t.keySchema,
+		AttributeDefinitions:   append([]attributeDefinition{}, t.attributes...),
+		GlobalSecondaryIndexes: append([]indexDefinition{}, t.globalIndexes...),
+		LocalSecondaryIndexes:  t.localIndexes,
+		BillingMode:            t.billingMode,
+	}
+	if t.billingMode == "PROVISIONED" {
+		throughput := t.throughput
+		definition.ProvisionedThroughput = &throughput
+	}
+
+	for _, added := range request.AttributeDefinitions {
+		if attributeType := t.attributeType(added.AttributeName); attributeType == "" {
+			definition.AttributeDefinitions = append(definition.AttributeDefinitions, added)
+		} else if attributeType != added.AttributeType {
+			return nil, validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of a key and its type can't be changed", added.AttributeName)
+		}
+	}
+
+	update := request.GlobalSecondaryIndexUpdates[0]
+	switch {
+	case update.Create != nil:
+		for _, index := range t.globalIndexes {
+			if index.IndexName == update.Create.IndexName {
+				return nil, validationError("One or more parameter values were invalid: Index with name: %s already exists", index.IndexName)
+			}
+		}
+		definition.GlobalSecondaryIndexes = append(definition.GlobalSecondaryIndexes, *update.Create)
+
+	case update.Delete != nil:
+		found := false
+		for i, index := range definition.GlobalSecondaryIndexes {
+			if index.IndexName == update.Delete.IndexName {
+				definition.GlobalSecondaryIndexes = append(definition.GlobalSecondaryIndexes[:i], definition.GlobalSecondaryIndexes[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &apiError{status: http.StatusBadRequest, code: "ResourceNotFoundException", message: "Requested resource not found: Index: " + update.Delete.IndexName + " not found"}
+		}
+
+	default:
+		return nil, validationError("dynamodbtest only creates and deletes global secondary indexes")
+	}
+
+	// An attribute is only defined while a key uses it
+	keys := append([]keySchemaElement{}, definition.KeySchema...)
+	for _, index := range append(append([]indexDefinition{}, definition.GlobalSecondaryIndexes...), definition.LocalSecondaryIndexes...) {
+		keys = append(keys, index.KeySchema...)
+	}
+	used := make(map[string]bool)
+	for _, element := range keys {
+		used[element.AttributeName] = true
+	}
+	defined := definition.AttributeDefinitions[:0]
+	for _, attribute := range definition.AttributeDefinitions {
+		if used[attribute.AttributeName] {
+			defined = append(defined, attribute)
+		}
+	}
+	definition.AttributeDefinitions = defined
+
+	updated, err := newTable(definition, t.created)
+	if err != nil {
+		return nil, err
+	}
+	updated.throughput = t.throughput
+	updated.items = t.items
+	f.tables[t.name] = updated
+
+	return map[string]interface{}{"TableDescription": updated.describe("ACTIVE")}, nil
+}
+
+type tableDescription struct {
+	TableName              string
+	TableArn               string
+	TableStatus            string
+	CreationDateTime       float64
+	KeySchema              []keySchemaElement
+	AttributeDefinitions   []attributeDefinition
+	ItemCount              int
+	TableSizeBytes         int
+	BillingModeSummary     map[string]string
+	ProvisionedThroughput  provisionedThroughput
+	GlobalSecondaryIndexes []indexDescription `json:",omitempty"`
+	LocalSecondaryIndexes  []indexDescription `json:",omitempty"`
+}
+
+type indexDescription struct {
+	IndexName             string
+	IndexArn              string
+	IndexStatus           string `json:",omitempty"`
+	KeySchema             []keySchemaElement
+	Projection            projection
+	ItemCount             int
+	IndexSizeBytes        int
+	ProvisionedThroughput *provisionedThroughput `json:",omitempty"`
+}
+
+func (t *table) arn() string {
+	return "arn:aws:dynamodb:ddblocal:000000000000:table/" + t.name
+}
+
+func (t *table) describe(status string) tableDescription {
+	result := tableDescription{
+		TableName:             t.name,
+		TableArn:              t.arn(),
+		TableStatus:           status,
+		CreationDateTime:      float64(t.created.UnixNano()) / 1e9,
+		KeySchema:             t.keySchema,
+		AttributeDefinitions:  t.attributes,
+		ItemCount:             len(t.items),
+		BillingModeSummary:    map[string]string{"BillingMode": t.billingMode},
+		ProvisionedThroughput: t.throughput,
+	}
+	for _, it := range t.items {
+		result.TableSizeBytes += it.size()
+	}
+
+	for _, index := range t.globalIndexes {
+		view := t.mustView(index.IndexName)
+		description := indexDescription{
+			IndexName:             index.IndexName,
+			IndexArn:              t.arn() + "/index/" + index.IndexName,
+			IndexStatus:           "ACTIVE",
+			KeySchema:             index.KeySchema,
+			Projection:            index.Projection,
+			ItemCount:             len(view.items()),
+			ProvisionedThroughput: index.ProvisionedThroughput,
+		}
+		if description.ProvisionedThroughput == nil {
+			description.ProvisionedThroughput = &provisionedThroughput{}
+		}
+		result.GlobalSecondaryIndexes = append(result.GlobalSecondaryIndexes, description)
+	}
+	for _, index := range t.localIndexes {
+		result.LocalSecondaryIndexes = append(result.LocalSecondaryIndexes, indexDescription{
+			IndexName:  index.IndexName,
+			IndexArn:   t.arn() + "/index/" + index.IndexName,
+			KeySchema:  index.KeySchema,
+			Projection: index.Projection,
+			ItemCount:  len(t.mustView(index.IndexName).items()),
+		})
+	}
+
+	return result
+}
+
+type tableRequest struct {
+	TableName string
+}
+
+func (f *Fake) deleteTable(body []byte) (interface{}, error) {
+	request := tableRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	delete(f.tables, t.name)
+
+	return map[string]interface{}{"TableDescription": t.describe("DELETING")}, nil
+}
+
+func (f *Fake) describeTable(body []byte) (interface{}, error) {
+	request := tableRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"Table": t.describe("ACTIVE")}, nil
+}
+
+type listTablesRequest struct {
+	ExclusiveStartTableName string
+	Limit                   int
+}
+
+func (f *Fake) listTables(body []byte) (interface{}, error) {
+	request := listTablesRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+	if request.Limit <= 0 || request.Limit > 100 {
+		request.Limit = 100
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	names := make([]string, 0, len(f.tables))
+	for name := range f.tables {
+		if name > request.ExclusiveStartTableName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := map[string]interface{}{"TableNames": names}
+	if len(names) > request.Limit {
+		result["TableNames"] = names[:request.Limit]
+		result["LastEvaluatedTableName"] = names[request.Limit-1]
+	}
+	return result, nil
+}
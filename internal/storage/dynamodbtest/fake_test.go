@@ -0,0 +1,472 @@
+package dynamodbtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/storage/dynamodbtest"
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+// newTestClient serves a fake with a loans table, partitioned by barcode and sorted by loan_key, with a
+// sparse index of the open loans by due date
+func newTestClient(t *testing.T, opts ...dynamodbtest.FakeOption) *dynamodb.Client {
+	t.Helper()
+	return newTestClientOf(t, dynamodbtest.NewFake(opts...))
+}
+
+// newTestClientOf serves the fake, with the loans table, for a test that changes the fake as it runs
+func newTestClientOf(t *testing.T, fake *dynamodbtest.Fake) *dynamodb.Client {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	db := dynamodb.New(dynamodb.Options{
+		Region:       "us-west-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		Retryer:      aws.NopRetryer{},
+	})
+
+	_, err := db.CreateTable(context.Background(), loansTable())
+	if err != nil {
+		t.Fatalf("failed to create the loans table: %v", err)
+	}
+	return db
+}
+
+func loansTable() *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		TableName:   aws.String("loans"),
+		BillingMode: types.BillingModePayPerRequest,
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("barcode"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("loan_key"), KeyType: types.KeyTypeRange},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("barcode"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("loan_key"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("loan_state"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("due_at"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String("due-index"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("loan_state"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("due_at"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+		}},
+	}
+}
+
+// s and n are a string and a number attribute
+func s(value string) types.AttributeValue { return &types.AttributeValueMemberS{Value: value} }
+func n(value string) types.AttributeValue { return &types.AttributeValueMemberN{Value: value} }
+
+func loan(barcode, loanKey, dueAt string, open bool) map[string]types.AttributeValue {
+	result := map[string]types.AttributeValue{
+		"barcode":  s(barcode),
+		"loan_key": s(loanKey),
+		"due_at":   s(dueAt),
+		"renewals": n("0"),
+	}
+	if open {
+		result["loan_state"] = s("open")
+	}
+	return result
+}
+
+func putLoans(t *testing.T, db *dynamodb.Client, loans ...map[string]types.AttributeValue) {
+	t.Helper()
+	for _, item := range loans {
+		if _, err := db.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String("loans"), Item: item}); err != nil {
+			t.Fatalf("failed to put the loan: %v", err)
+		}
+	}
+}
+
+// errorCode returns the code of the error DynamoDB returned
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+func TestFake_tables(t *testing.T) {
+	assert := assertions.New(t)
+	db := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := db.CreateTable(ctx, loansTable())
+	assert.So(errorCode(err), should.Equal, "ResourceInUseException")
+
+	undefined := loansTable()
+	undefined.TableName = aws.String("undefined")
+	undefined.AttributeDefinitions = undefined.AttributeDefinitions[:3]
+	_, err = db.CreateTable(ctx, undefined)
+	assert.So(err, testutils.ShouldEqualError, errors.New("Some index key attributes are not defined in AttributeDefinitions. Keys: [due_at]"))
+
+	described, err := db.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String("loans")})
+	assert.So(err, should.BeNil)
+	assert.So(described.Table.TableStatus, should.Equal, types.TableStatusActive)
+	assert.So(aws.ToString(described.Table.GlobalSecondaryIndexes[0].IndexName), should.Equal, "due-index")
+
+	listed, err := db.ListTables(ctx, &dynamodb.ListTablesInput{})
+	assert.So(err, should.BeNil)
+	assert.So(listed.TableNames, should.Resemble, []string{"loans"})
+
+	// An index is added with the definition of its key, and deleted along with it
+	updated, err := db.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName:            aws.String("loans"),
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String("patron_id"), AttributeType: types.ScalarAttributeTypeS}},
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Create: &types.CreateGlobalSecondaryIndexAction{
+			IndexName:  aws.String("patron-index"),
+			KeySchema:  []types.KeySchemaElement{{AttributeName: aws.String("patron_id"), KeyType: types.KeyTypeHash}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}}},
+	})
+	assert.So(err, should.BeNil)
+	assert.So(len(updated.TableDescription.GlobalSecondaryIndexes), should.Equal, 2)
+	assert.So(len(updated.TableDescription.AttributeDefinitions), should.Equal, 5)
+
+	updated, err = db.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName:                   aws.String("loans"),
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Delete: &types.DeleteGlobalSecondaryIndexAction{IndexName: aws.String("patron-index")}}},
+	})
+	assert.So(err, should.BeNil)
+	assert.So(len(updated.TableDescription.GlobalSecondaryIndexes), should.Equal, 1)
+	assert.So(len(updated.TableDescription.AttributeDefinitions), should.Equal, 4)
+
+	_, err = db.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String("loans")})
+	assert.So(err, should.BeNil)
+	_, err = db.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String("loans")})
+	assert.So(errorCode(err), should.Equal, "ResourceNotFoundException")
+}
+
+func TestFake_writes(t *testing.T) {
+	type state struct {
+		write func(ctx context.Context, db *dynamodb.Client) error
+	}
+	type expected struct {
+		code string
+		item map[string]types.AttributeValue
+	}
+	key := map[string]types.AttributeValue{
+		"barcode":  s("0001"),
+		"loan_key": s("2021-03-01T09:30:00Z#1"),
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"An update whose condition holds is applied": {
+			state{func(ctx context.Context, db *dynamodb.Client) error {
+				_, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+					TableName:                 aws.String("loans"),
+					Key:                       key,
+					UpdateExpression:          aws.String("SET renewals = renewals + :one"),
+					ConditionExpression:       aws.String("loan_state = :open"),
+					ExpressionAttributeValues: map[string]types.AttributeValue{":one": n("1"), ":open": s("open")},
+				})
+				return err
+			}},
+			expected{item: map[string]types.AttributeValue{
+				"barcode":    s("0001"),
+				"loan_key":   s("2021-03-01T09:30:00Z#1"),
+				"due_at":     s("2021-03-22"),
+				"loan_state": s("open"),
+				"renewals":   n("1"),
+			}},
+		},
+		"A write whose condition fails isn't applied": {
+			state{func(ctx context.Context, db *dynamodb.Client) error {
+				_, err := db.PutItem(ctx, &dynamodb.PutItemInput{
+					TableName:           aws.String("loans"),
+					Item:                loan("0001", "2021-03-01T09:30:00Z#1", "2021-04-01", false),
+					ConditionExpression: aws.String("attribute_not_exists(loan_key)"),
+				})
+				return err
+			}},
+			expected{code: "ConditionalCheckFailedException", item: loan("0001", "2021-03-01T09:30:00Z#1", "2021-03-22", true)},
+		},
+		"An attribute that's part of the key can't be updated": {
+			state{func(ctx context.Context, db *dynamodb.Client) error {
+				_, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+					TableName:                 aws.String("loans"),
+					Key:                       key,
+					UpdateExpression:          aws.String("SET barcode = :b"),
+					ExpressionAttributeValues: map[string]types.AttributeValue{":b": s("0002")},
+				})
+				return err
+			}},
+			expected{code: "ValidationException", item: loan("0001", "2021-03-01T09:30:00Z#1", "2021-03-22", true)},
+		},
+		"An index key must have its defined type": {
+			state{func(ctx context.Context, db *dynamodb.Client) error {
+				_, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+					TableName:                 aws.String("loans"),
+					Key:                       key,
+					UpdateExpression:          aws.String("SET due_at = :d"),
+					ExpressionAttributeValues: map[string]types.AttributeValue{":d": &types.AttributeValueMemberNULL{Value: true}},
+				})
+				return err
+			}},
+			expected{code: "ValidationException", item: loan("0001", "2021-03-01T09:30:00Z#1", "2021-03-22", true)},
+		},
+		"Every value must be used": {
+			state{func(ctx context.Context, db *dynamodb.Client) error {
+				_, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+					TableName:                 aws.String("loans"),
+					Key:                       key,
+					ConditionExpression:       aws.String("attribute_exists(loan_key)"),
+					ExpressionAttributeValues: map[string]types.AttributeValue{":open": s("open")},
+				})
+				return err
+			}},
+			expected{code: "ValidationException", item: loan("0001", "2021-03-01T09:30:00Z#1", "2021-03-22", true)},
+		},
+		"A delete removes the item": {
+			state{func(ctx context.Context, db *dynamodb.Client) error {
+				_, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: aws.String("loans"), Key: key})
+				return err
+			}},
+			expected{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			db := newTestClient(t)
+			ctx := context.Background()
+			putLoans(t, db, loan("0001", "2021-03-01T09:30:00Z#1", "2021-03-22", true))
+
+			err := tc.state.write(ctx, db)
+			assert.So(errorCode(err), should.Equal, tc.expected.code)
+
+			found, err := db.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("loans"), Key: key, ConsistentRead: aws.Bool(true)})
+			assert.So(err, should.BeNil)
+			if tc.expected.item == nil {
+				assert.So(found.Item, should.BeEmpty)
+			} else {
+				assert.So(found.Item, should.Resemble, tc.expected.item)
+			}
+		})
+	}
+}
+
+func TestFake_Query(t *testing.T) {
+	assert := assertions.New(t)
+	db := newTestClient(t, dynamodbtest.WithPageSize(2))
+	ctx := context.Background()
+
+	putLoans(t, db,
+		loan("0001", "2021-03-01T09:30:00Z#1", "2021-03-22", false),
+		loan("0001", "2021-03-03T09:30:00Z#2", "2021-03-24", false),
+		loan("0001", "2021-03-02T09:30:00Z#3", "2021-03-23", true),
+		loan("0002", "2021-03-01T09:30:00Z#4", "2021-03-20", true),
+		loan("0003", "2021-03-01T09:30:00Z#5", "2021-03-25", true),
+	)
+
+	// A partition is read in the order of its sort key, a page at a time
+	var keys []string
+	pages := 0
+	paginator := dynamodb.NewQueryPaginator(db, &dynamodb.QueryInput{
+		TableName:                 aws.String("loans"),
+		KeyConditionExpression:    aws.String("barcode = :b AND loan_key > :k"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":b": s("0001"), ":k": s("2021-03-01T09:30:00Z#1")},
+		ScanIndexForward:          aws.Bool(false),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		assert.So(err, should.BeNil)
+		pages++
+		for _, item := range page.Items {
+			keys = append(keys, item["loan_key"].(*types.AttributeValueMemberS).Value)
+		}
+	}
+	assert.So(keys, should.Resemble, []string{"2021-03-03T09:30:00Z#2", "2021-03-02T09:30:00Z#3"})
+	assert.So(pages, should.Equal, 1)
+
+	// The index only has the open loans, with the attributes it projects
+	var due []map[string]types.AttributeValue
+	paginator = dynamodb.NewQueryPaginator(db, &dynamodb.QueryInput{
+		TableName:                 aws.String("loans"),
+		IndexName:                 aws.String("due-index"),
+		KeyConditionExpression:    aws.String("loan_state = :open AND due_at < :bound"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":open": s("open"), ":bound": s("2021-03-30")},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		assert.So(err, should.BeNil)
+		due = append(due, page.Items...)
+	}
+	assert.So(due, should.Resemble, []map[string]types.AttributeValue{
+		{"barcode": s("0002"), "loan_key": s("2021-03-01T09:30:00Z#4"), "loan_state": s("open"), "due_at": s("2021-03-20")},
+		{"barcode": s("0001"), "loan_key": s("2021-03-02T09:30:00Z#3"), "loan_state": s("open"), "due_at": s("2021-03-23")},
+		{"barcode": s("0003"), "loan_key": s("2021-03-01T09:30:00Z#5"), "loan_state": s("open"), "due_at": s("2021-03-25")},
+	})
+
+	// A global index is only eventually consistent
+	_, err := db.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String("loans"),
+		IndexName:                 aws.String("due-index"),
+		KeyConditionExpression:    aws.String("loan_state = :open"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":open": s("open")},
+		ConsistentRead:            aws.Bool(true),
+	})
+	assert.So(err, testutils.ShouldEqualError, errors.New("Consistent reads are not supported on global secondary indexes"))
+
+	// A key condition only constrains the key
+	_, err = db.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String("loans"),
+		KeyConditionExpression:    aws.String("barcode = :b AND due_at < :bound"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":b": s("0001"), ":bound": s("2021-03-30")},
+	})
+	assert.So(err, testutils.ShouldEqualError, errors.New("Query condition missed key schema element: barcode"))
+
+	// A scan reads every page, and filters each of them
+	var scanned []map[string]types.AttributeValue
+	pages = 0
+	scanner := dynamodb.NewScanPaginator(db, &dynamodb.ScanInput{
+		TableName:        aws.String("loans"),
+		FilterExpression: aws.String("attribute_not_exists(loan_state)"),
+	})
+	for scanner.HasMorePages() {
+		page, err := scanner.NextPage(ctx)
+		assert.So(err, should.BeNil)
+		pages++
+		scanned = append(scanned, page.Items...)
+	}
+	assert.So(len(scanned), should.Equal, 2)
+	assert.So(pages, should.Equal, 3)
+}
+
+func TestFake_TransactWriteItems(t *testing.T) {
+	assert := assertions.New(t)
+	db := newTestClient(t)
+	ctx := context.Background()
+	putLoans(t, db, loan("0001", "2021-03-01T09:30:00Z#1", "2021-03-22", true))
+
+	returned := types.TransactWriteItem{Update: &types.Update{
+		TableName:                 aws.String("loans"),
+		Key:                       map[string]types.AttributeValue{"barcode": s("0001"), "loan_key": s("2021-03-01T09:30:00Z#1")},
+		UpdateExpression:          aws.String("REMOVE loan_state"),
+		ConditionExpression:       aws.String("loan_state = :open"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":open": s("open")},
+	}}
+	checkedOut := types.TransactWriteItem{Put: &types.Put{
+		TableName:           aws.String("loans"),
+		Item:                loan("0001", "2021-03-05T09:30:00Z#2", "2021-03-26", true),
+		ConditionExpression: aws.String("attribute_not_exists(loan_key)"),
+	}}
+
+	// The writes are applied together
+	_, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{returned, checkedOut}})
+	assert.So(err, should.BeNil)
+
+	// Or not at all, with the reason each write failed
+	_, err = db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String("loans"), Item: loan("0002", "2021-03-05T09:30:00Z#3", "2021-03-26", true)}},
+		returned,
+	}})
+	var canceled *types.TransactionCanceledException
+	assert.So(errors.As(err, &canceled), should.BeTrue)
+	assert.So(canceled.CancellationReasons, should.Resemble, []types.CancellationReason{
+		{Code: aws.String("None")},
+		{Code: aws.String("ConditionalCheckFailed"), Message: aws.String("The conditional request failed")},
+	})
+
+	scanned, err := db.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String("loans"), Select: types.SelectCount})
+	assert.So(err, should.BeNil)
+	assert.So(scanned.Count, should.Equal, 2)
+
+	// An item can only be written once in a transaction
+	_, err = db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{checkedOut, checkedOut}})
+	assert.So(err, testutils.ShouldEqualError, errors.New("Transaction request cannot include multiple operations on one item"))
+}
+
+func TestFake_BatchWriteItem(t *testing.T) {
+	assert := assertions.New(t)
+	db := newTestClient(t)
+	ctx := context.Background()
+	putLoans(t, db, loan("0001", "2021-03-01T09:30:00Z#1", "2021-03-22", false))
+
+	// The puts and deletes are all applied, whatever was there before
+	written, err := db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{
+		"loans": {
+			{PutRequest: &types.PutRequest{Item: loan("0002", "2021-03-05T09:30:00Z#2", "2021-03-26", true)}},
+			{PutRequest: &types.PutRequest{Item: loan("0003", "2021-03-05T09:30:00Z#3", "2021-03-26", true)}},
+			{DeleteRequest: &types.DeleteRequest{Key: map[string]types.AttributeValue{"barcode": s("0001"), "loan_key": s("2021-03-01T09:30:00Z#1")}}},
+		},
+	}})
+	assert.So(err, should.BeNil)
+	assert.So(written.UnprocessedItems, should.BeEmpty)
+
+	scanned, err := db.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String("loans"), IndexName: aws.String("due-index")})
+	assert.So(err, should.BeNil)
+	assert.So(scanned.Count, should.Equal, 2)
+
+	// A batch can't write an item twice, or more than 25 items
+	twice := types.WriteRequest{PutRequest: &types.PutRequest{Item: loan("0002", "2021-03-05T09:30:00Z#2", "2021-03-26", false)}}
+	_, err = db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"loans": {twice, twice}}})
+	assert.So(err, testutils.ShouldEqualError, errors.New("Provided list of item keys contains duplicates"))
+
+	var tooMany []types.WriteRequest
+	for i := 0; i < 26; i++ {
+		tooMany = append(tooMany, types.WriteRequest{PutRequest: &types.PutRequest{Item: loan("0004", fmt.Sprintf("2021-03-05T09:30:%02dZ", i), "2021-03-26", false)}})
+	}
+	_, err = db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{"loans": tooMany}})
+	assert.So(errorCode(err), should.Equal, "ValidationException")
+}
+
+func TestFake_InjectFault(t *testing.T) {
+	assert := assertions.New(t)
+	fake := dynamodbtest.NewFake()
+	db := newTestClientOf(t, fake)
+	ctx := context.Background()
+	key := map[string]types.AttributeValue{"barcode": s("0001"), "loan_key": s("2021-03-01T09:30:00Z#1")}
+
+	// The next two requests of the operation fail, and the other operations are served
+	fake.InjectFault("GetItem", dynamodbtest.ThrottlingFault, 2)
+	for i := 0; i < 2; i++ {
+		_, err := db.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("loans"), Key: key})
+		assert.So(errorCode(err), should.Equal, "ProvisionedThroughputExceededException")
+	}
+	_, err := db.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("loans"), Key: key})
+	assert.So(err, should.BeNil)
+	_, err = db.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String("loans")})
+	assert.So(err, should.BeNil)
+	assert.So(fake.Requests("GetItem"), should.Equal, 3)
+
+	// A negative count fails every request until the faults are cleared
+	fake.InjectFault("Scan", dynamodbtest.InternalServerErrorFault, -1)
+	for i := 0; i < 3; i++ {
+		_, err = db.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String("loans")})
+		var responseErr *awshttp.ResponseError
+		if assert.So(errors.As(err, &responseErr), should.BeTrue) {
+			assert.So(responseErr.HTTPStatusCode(), should.Equal, http.StatusInternalServerError)
+		}
+	}
+	fake.ClearFaults()
+	_, err = db.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String("loans")})
+	assert.So(err, should.BeNil)
+	assert.So(fake.Requests("Scan"), should.Equal, 5)
+}
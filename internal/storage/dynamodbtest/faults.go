@@ -0,0 +1,87 @@
+package dynamodbtest
+
+import (
+	"net/http"
+)
+
+// Fault is an error the fake answers a request with instead of serving it, so a test can exercise how the
+// client handles DynamoDB failing
+type Fault struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// The faults DynamoDB answers with when it's overloaded
+var (
+	ThrottlingFault = Fault{
+		Status:  http.StatusBadRequest,
+		Code:    "ProvisionedThroughputExceededException",
+		Message: "The level of configured provisioned throughput for the table was exceeded. Consider increasing your provisioning level with the UpdateTable API.",
+	}
+	InternalServerErrorFault = Fault{
+		Status:  http.StatusInternalServerError,
+		Code:    "InternalServerError",
+		Message: "Internal server error",
+	}
+	ServiceUnavailableFault = Fault{
+		Status:  http.StatusServiceUnavailable,
+		Code:    "ServiceUnavailable",
+		Message: "Service unavailable",
+	}
+)
+
+// injectedFault is a fault waiting for the requests of an operation
+type injectedFault struct {
+	fault     Fault
+	remaining int // Negative if every request fails
+}
+
+// InjectFault makes the next count requests of the operation, e.g. "GetItem", fail with the fault, or every
+// request of it if the count is negative. A fault injected for an operation replaces the previous one.
+func (f *Fake) InjectFault(operation string, fault Fault, count int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.faults == nil {
+		f.faults = make(map[string]*injectedFault)
+	}
+	f.faults[operation] = &injectedFault{fault: fault, remaining: count}
+}
+
+// ClearFaults lets every operation be served again
+func (f *Fake) ClearFaults() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = nil
+}
+
+// Requests returns how many requests of the operation the fake has received, including the ones that
+// failed
+func (f *Fake) Requests(operation string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests[operation]
+}
+
+// receive counts the request of the operation, and returns the fault it should fail with, if any
+func (f *Fake) receive(operation string) *apiError {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.requests == nil {
+		f.requests = make(map[string]int)
+	}
+	f.requests[operation]++
+
+	injected, ok := f.faults[operation]
+	if !ok || injected.remaining == 0 {
+		return nil
+	}
+	if injected.remaining > 0 {
+		injected.remaining--
+	}
+	return &apiError{status: injected.fault.Status, code: injected.fault.Code, message: injected.fault.Message}
+}
@@ -0,0 +1,522 @@
+package dynamodbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type expressionParameters struct {
+	ExpressionAttributeNames  map[string]*string
+	ExpressionAttributeValues map[string]*value
+}
+
+// legacyParameters are the parameters that predate expressions, which the fake doesn't support
+type legacyParameters struct {
+	AttributesToGet     json.RawMessage
+	AttributeUpdates    json.RawMessage
+	ConditionalOperator json.RawMessage
+	Expected            json.RawMessage
+	KeyConditions       json.RawMessage
+	QueryFilter         json.RawMessage
+	ScanFilter          json.RawMessage
+}
+
+func (l legacyParameters) check() error {
+	for name, parameter := range map[string]json.RawMessage{
+		"AttributesToGet":     l.AttributesToGet,
+		"AttributeUpdates":    l.AttributeUpdates,
+		"ConditionalOperator": l.ConditionalOperator,
+		"Expected":            l.Expected,
+		"KeyConditions":       l.KeyConditions,
+		"QueryFilter":         l.QueryFilter,
+		"ScanFilter":          l.ScanFilter,
+	} {
+		if parameter != nil {
+			return validationError("dynamodbtest doesn't support the legacy %s parameter; use expressions instead", name)
+		}
+	}
+	return nil
+}
+
+type consumedCapacity struct {
+	TableName     string
+	CapacityUnits float64
+}
+
+// capacity returns the capacity an operation consumed, if the request asked for it. A read consumes a unit
+// for every 4KB, or half that if it's eventually consistent, and a write a unit for every 1KB.
+func capacity(returnConsumedCapacity, tableName string, units float64) *consumedCapacity {
+	if returnConsumedCapacity != "TOTAL" && returnConsumedCapacity != "INDEXES" {
+		return nil
+	}
+	return &consumedCapacity{TableName: tableName, CapacityUnits: units}
+}
+
+func readUnits(size int, consistent bool) float64 {
+	units := math.Max(1, math.Ceil(float64(size)/4096))
+	if !consistent {
+		units /= 2
+	}
+	return units
+}
+
+func writeUnits(size int) float64 {
+	return math.Max(1, math.Ceil(float64(size)/1024))
+}
+
+type getItemRequest struct {
+	TableName              string
+	Key                    item
+	ConsistentRead         bool
+	ProjectionExpression   *string
+	ReturnConsumedCapacity string
+	expressionParameters
+	legacyParameters
+}
+
+func (f *Fake) getItem(body []byte) (interface{}, error) {
+	request := getItemRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+	if err := request.check(); err != nil {
+		return nil, err
+	}
+
+	placeholders := newPlaceholders(request.ExpressionAttributeNames, request.ExpressionAttributeValues)
+	var projection []path
+	if request.ProjectionExpression != nil {
+		var err error
+		if projection, err = parseProjection(*request.ProjectionExpression, placeholders); err != nil {
+			return nil, err
+		}
+	}
+	if err := placeholders.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, err := f.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.validateKey(request.Key); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{})
+	found, ok := t.items[t.keyOf(request.Key)]
+	if ok {
+		if projection != nil {
+			found = project(found, projection)
+		}
+		result["Item"] = found
+	}
+	if c := capacity(request.ReturnConsumedCapacity, t.name, readUnits(found.size(), request.ConsistentRead)); c != nil {
+		result["ConsumedCapacity"] = c
+	}
+	return result, nil
+}
+
+// writeRequest holds the parameters of PutItem, DeleteItem, UpdateItem and the writes in a transaction
+type writeRequest struct {
+	TableName                           string
+	Key                                 item
+	Item                                item
+	ConditionExpression                 *string
+	UpdateExpression                    *string
+	ReturnValues                        string
+	ReturnValuesOnConditionCheckFailure string
+	ReturnConsumedCapacity              string
+	expressionParameters
+	legacyParameters
+}
+
+// write is a validated put, delete, update or condition check, ready to be applied
+type write struct {
+	table     *table
+	key       string
+	condition condition
+	// change returns the item as it is after the write, or nil if the write deletes it
+	change  func(old item) (item, error)
+	update  *update
+	request *writeRequest
+}
+
+// prepareWrite parses and validates a write. It's called with the lock held.
+func (f *Fake) prepareWrite(operation string, request *writeRequest) (*write, error) {
+	if err := request.check(); err != nil {
+		return nil, err
+	}
+
+	t, err := f.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	result := &write{table: t, request: request}
+
+	placeholders := newPlaceholders(request.ExpressionAttributeNames, request.ExpressionAttributeValues)
+	if request.ConditionExpression != nil {
+		if result.condition, err = parseCondition("ConditionExpression", *request.ConditionExpression, placeholders); err != nil {
+			return nil, err
+		}
+	}
+
+	switch operation {
+	case "Put":
+		if err := t.validateItem(request.Item); err != nil {
+			return nil, err
+		}
+		result.key = t.keyOf(request.Item)
+		result.change = func(item) (item, error) { return request.Item, nil }
+	case "Delete":
+		if err := t.validateKey(request.Key); err != nil {
+			return nil, err
+		}
+		result.key = t.keyOf(request.Key)
+		result.change = func(item) (item, error) { return nil, nil }
+	case "Update":
+		if err := t.validateKey(request.Key); err != nil {
+			return nil, err
+		}
+		result.key = t.keyOf(request.Key)
+
+		result.update = &update{}
+		if request.UpdateExpression != nil {
+			if result.update, err = parseUpdate(*request.UpdateExpression, placeholders); err != nil {
+				return nil, err
+			}
+		}
+		for _, action := range result.update.actions() {
+			if _, ok := request.Key[action.path[0].name]; ok {
+				return nil, validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", action.path[0].name)
+			}
+		}
+		result.change = func(old item) (item, error) {
+			if old == nil {
+				old = request.Key
+			}
+			updated, err := result.update.apply(old)
+			if err != nil {
+				return nil, err
+			}
+			return updated, t.validateItem(updated)
+		}
+	case "ConditionCheck":
+		if err := t.validateKey(request.Key); err != nil {
+			return nil, err
+		}
+		if result.condition == nil {
+			return nil, validationError("1 validation error detected: Value null at 'transactItems.conditionCheck.conditionExpression' failed to satisfy constraint: Member must not be null")
+		}
+		result.key = t.keyOf(request.Key)
+		result.change = func(old item) (item, error) { return old, nil }
+	}
+
+	if err := placeholders.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// check evaluates the write's condition against the item as it is
+func (w *write) check(old item) (bool, error) {
+	if w.condition == nil {
+		return true, nil
+	}
+	return w.condition.evaluate(old)
+}
+
+// commit stores the item as it is after the write
+func (w *write) commit(updated item) {
+	if updated == nil {
+		delete(w.table.items, w.key)
+	} else {
+		w.table.items[w.key] = updated
+	}
+}
+
+// returnValues picks the attributes of the item before or after the write that the request asked for
+func (w *write) returnValues(old, updated item) item {
+	var changed []path
+	if w.update != nil {
+		for _, action := range w.update.actions() {
+			changed = append(changed, action.path[:1])
+		}
+	}
+
+	switch w.request.ReturnValues {
+	case "ALL_OLD":
+		return old
+	case "ALL_NEW":
+		return updated
+	case "UPDATED_OLD":
+		return project(old, changed)
+	case "UPDATED_NEW":
+		return project(updated, changed)
+	}
+	return nil
+}
+
+// writeItem runs a single PutItem, DeleteItem or UpdateItem
+func (f *Fake) writeItem(operation string, body []byte, returnValues ...string) (interface{}, error) {
+	request := writeRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+	if request.ReturnValues != "" && request.ReturnValues != "NONE" && !contains(returnValues, request.ReturnValues) {
+		return nil, validationError("Return values set to invalid value")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w, err := f.prepareWrite(operation, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	old := w.table.items[w.key]
+	ok, err := w.check(old)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, conditionalCheckFailed()
+	}
+	updated, err := w.change(old)
+	if err != nil {
+		return nil, err
+	}
+	w.commit(updated)
+
+	result := make(map[string]interface{})
+	if attributes := w.returnValues(old, updated); len(attributes) > 0 {
+		result["Attributes"] = attributes
+	}
+	size := math.Max(float64(old.size()), float64(updated.size()))
+	if c := capacity(request.ReturnConsumedCapacity, w.table.name, writeUnits(int(size))); c != nil {
+		result["ConsumedCapacity"] = c
+	}
+	return result, nil
+}
+
+func (f *Fake) putItem(body []byte) (interface{}, error) {
+	return f.writeItem("Put", body, "ALL_OLD")
+}
+
+func (f *Fake) deleteItem(body []byte) (interface{}, error) {
+	return f.writeItem("Delete", body, "ALL_OLD")
+}
+
+func (f *Fake) updateItem(body []byte) (interface{}, error) {
+	return f.writeItem("Update", body, "ALL_OLD", "UPDATED_OLD", "ALL_NEW", "UPDATED_NEW")
+}
+
+func contains(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+type transactWriteItemsRequest struct {
+	TransactItems []struct {
+		ConditionCheck *writeRequest
+		Put            *writeRequest
+		Delete         *writeRequest
+		Update         *writeRequest
+	}
+	ReturnConsumedCapacity string
+	ClientRequestToken     string
+}
+
+// transactWriteItems applies all of the writes, or none of them if any of their conditions fail
+func (f *Fake) transactWriteItems(body []byte) (interface{}, error) {
+	request := transactWriteItemsRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+	if len(request.TransactItems) == 0 || len(request.TransactItems) > 100 {
+		return nil, validationError("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: Member must have length less than or equal to 100, Member must have length greater than or equal to 1")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	writes := make([]*write, 0, len(request.TransactItems))
+	seen := make(map[string]bool)
+	for _, transactItem := range request.TransactItems {
+		operations := map[string]*writeRequest{
+			"ConditionCheck": transactItem.ConditionCheck,
+			"Put":            transactItem.Put,
+			"Delete":         transactItem.Delete,
+			"Update":         transactItem.Update,
+		}
+		var (
+			operation    string
+			writeRequest *writeRequest
+		)
+		for name, candidate := range operations {
+			if candidate != nil {
+				if writeRequest != nil {
+					return nil, validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+				}
+				operation, writeRequest = name, candidate
+			}
+		}
+		if writeRequest == nil {
+			return nil, validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+		}
+		if writeRequest.ReturnValues != "" {
+			return nil, validationError("dynamodbtest doesn't support ReturnValues in a transaction")
+		}
+
+		w, err := f.prepareWrite(operation, writeRequest)
+		if err != nil {
+			return nil, err
+		}
+		if id := w.table.name + "/" + w.key; seen[id] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		} else {
+			seen[id] = true
+		}
+		writes = append(writes, w)
+	}
+
+	reasons := make([]cancellationReason, len(writes))
+	updated := make([]item, len(writes))
+	cancelled := false
+	for i, w := range writes {
+		old := w.table.items[w.key]
+		ok, err := w.check(old)
+		if err == nil && ok {
+			updated[i], err = w.change(old)
+		}
+		switch {
+		case err != nil:
+			reasons[i] = cancellationReason{Code: "ValidationError", Message: err.(*apiError).message}
+			cancelled = true
+		case !ok:
+			reasons[i] = cancellationReason{Code: "ConditionalCheckFailed", Message: "The conditional request failed"}
+			if w.request.ReturnValuesOnConditionCheckFailure == "ALL_OLD" {
+				reasons[i].Item = old
+			}
+			cancelled = true
+		default:
+			reasons[i] = cancellationReason{Code: "None"}
+		}
+	}
+	if cancelled {
+		codes := make([]string, len(reasons))
+		for i, reason := range reasons {
+			codes[i] = reason.Code
+		}
+		return nil, &apiError{
+			status:  http.StatusBadRequest,
+			code:    "TransactionCanceledException",
+			message: fmt.Sprintf("Transaction cancelled, please refer cancellation reasons for specific reasons [%s]", strings.Join(codes, ", ")),
+			reasons: reasons,
+		}
+	}
+
+	units := make(map[string]float64)
+	for i, w := range writes {
+		size := math.Max(float64(w.table.items[w.key].size()), float64(updated[i].size()))
+		units[w.table.name] += 2 * writeUnits(int(size))
+		w.commit(updated[i])
+	}
+
+	result := make(map[string]interface{})
+	if capacity(request.ReturnConsumedCapacity, "", 0) != nil {
+		consumed := make([]*consumedCapacity, 0, len(units))
+		for name, total := range units {
+			consumed = append(consumed, capacity(request.ReturnConsumedCapacity, name, total))
+		}
+		sort.Slice(consumed, func(i, j int) bool { return consumed[i].TableName < consumed[j].TableName })
+		result["ConsumedCapacity"] = consumed
+	}
+	return result, nil
+}
+
+type batchWriteItemRequest struct {
+	RequestItems map[string][]struct {
+		PutRequest    *struct{ Item item }
+		DeleteRequest *struct{ Key item }
+	}
+	ReturnConsumedCapacity string
+}
+
+// batchWriteItem applies up to 25 puts and deletes, which are unconditional. Unlike DynamoDB, the fake
+// never throttles a write, so none of them are returned as unprocessed.
+func (f *Fake) batchWriteItem(body []byte) (interface{}, error) {
+	request := batchWriteItemRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var writes []*write
+	seen := make(map[string]bool)
+	for tableName, requests := range request.RequestItems {
+		for _, r := range requests {
+			var (
+				w   *write
+				err error
+			)
+			switch {
+			case r.PutRequest != nil && r.DeleteRequest == nil:
+				w, err = f.prepareWrite("Put", &writeRequest{TableName: tableName, Item: r.PutRequest.Item})
+			case r.DeleteRequest != nil && r.PutRequest == nil:
+				w, err = f.prepareWrite("Delete", &writeRequest{TableName: tableName, Key: r.DeleteRequest.Key})
+			default:
+				err = validationError("Supplied AttributeValue has more than one datatypes set, must contain exactly one of the supported datatypes")
+			}
+			if err != nil {
+				return nil, err
+			}
+			if id := w.table.name + "/" + w.key; seen[id] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			} else {
+				seen[id] = true
+			}
+			writes = append(writes, w)
+		}
+	}
+	if len(writes) == 0 || len(writes) > 25 {
+		return nil, validationError("1 validation error detected: Value at 'requestItems' failed to satisfy constraint: Map value must satisfy constraint: [Member must have length less than or equal to 25, Member must have length greater than or equal to 1]")
+	}
+
+	units := make(map[string]float64)
+	for _, w := range writes {
+		updated, err := w.change(w.table.items[w.key])
+		if err != nil {
+			return nil, err
+		}
+		size := math.Max(float64(w.table.items[w.key].size()), float64(updated.size()))
+		units[w.table.name] += writeUnits(int(size))
+		w.commit(updated)
+	}
+
+	result := map[string]interface{}{"UnprocessedItems": map[string]interface{}{}}
+	if capacity(request.ReturnConsumedCapacity, "", 0) != nil {
+		consumed := make([]*consumedCapacity, 0, len(units))
+		for name, total := range units {
+			consumed = append(consumed, capacity(request.ReturnConsumedCapacity, name, total))
+		}
+		sort.Slice(consumed, func(i, j int) bool { return consumed[i].TableName < consumed[j].TableName })
+		result["ConsumedCapacity"] = consumed
+	}
+	return result, nil
+}
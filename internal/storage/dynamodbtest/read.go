@@ -0,0 +1,361 @@
+package dynamodbtest
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxPageBytes is the most data a scan or query reads for a page
+const maxPageBytes = 1 << 20
+
+// view is a table or one of its indexes, as a query or scan reads it
+type view struct {
+	table      *table
+	index      *indexDefinition
+	global     bool
+	partition  string
+	sort       string
+	projection projection
+}
+
+// view returns the table, or the index if it's named
+func (t *table) view(indexName string) (*view, error) {
+	if indexName == "" {
+		partition, sort := keyNames(t.keySchema)
+		return &view{table: t, partition: partition, sort: sort, projection: projection{ProjectionType: "ALL"}}, nil
+	}
+
+	for i, index := range append(append([]indexDefinition{}, t.globalIndexes...), t.localIndexes...) {
+		if index.IndexName == indexName {
+			partition, sort := keyNames(index.KeySchema)
+			index := index
+			return &view{table: t, index: &index, global: i < len(t.globalIndexes), partition: partition, sort: sort, projection: index.Projection}, nil
+		}
+	}
+	return nil, validationError("The table does not have the specified index: %s", indexName)
+}
+
+// mustView returns an index that's known to exist
+func (t *table) mustView(indexName string) *view {
+	v, err := t.view(indexName)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// items returns the items in the view. An index is sparse: it only has the items with its key attributes.
+func (v *view) items() []item {
+	result := make([]item, 0, len(v.table.items))
+	for _, it := range v.table.items {
+		if it[v.partition] == nil || (v.sort != "" && it[v.sort] == nil) {
+			continue
+		}
+		result = append(result, it)
+	}
+	return result
+}
+
+// key returns the attributes that locate an item in the view: the table's key, and the index's
+func (v *view) key(it item) item {
+	result := v.table.primaryKey(it)
+	result[v.partition] = it[v.partition]
+	if v.sort != "" {
+		result[v.sort] = it[v.sort]
+	}
+	return result
+}
+
+// project returns the attributes of the item the view holds
+func (v *view) project(it item) item {
+	switch v.projection.ProjectionType {
+	case "ALL":
+		return it
+	case "INCLUDE":
+		result := v.key(it)
+		for _, name := range v.projection.NonKeyAttributes {
+			if attribute, ok := it[name]; ok {
+				result[name] = attribute
+			}
+		}
+		return result
+	}
+	return v.key(it)
+}
+
+// compareKeys orders two items by the view's sort key, then by the table's key to break ties in an index
+func (v *view) compareKeys(a, b item) int {
+	names := []string{v.sort}
+	tablePartition, tableSort := keyNames(v.table.keySchema)
+	if v.index != nil {
+		names = append(names, tablePartition, tableSort)
+	}
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if order, ok := compare(a[name], b[name]); ok && order != 0 {
+			return order
+		}
+	}
+	return 0
+}
+
+// scanOrder orders two items the way a scan returns them: by the hash of their partition key, then within
+// the partition
+func (v *view) scanOrder(a, b item) int {
+	ha, hb := hashOf(a[v.partition]), hashOf(b[v.partition])
+	switch {
+	case ha < hb:
+		return -1
+	case ha > hb:
+		return 1
+	}
+	if order := strings.Compare(a[v.partition].key(), b[v.partition].key()); order != 0 {
+		return order
+	}
+	return v.compareKeys(a, b)
+}
+
+// validateStartKey checks that a page's start key is a key of the view
+func (v *view) validateStartKey(startKey item) error {
+	if startKey == nil {
+		return nil
+	}
+	expected := v.key(startKey)
+	if len(startKey) != len(expected) {
+		return validationError("The provided starting key is invalid: The provided key element does not match the schema")
+	}
+	for name := range expected {
+		if startKey[name] == nil || startKey[name].kind() != v.table.attributeType(name) {
+			return validationError("The provided starting key is invalid: The provided key element does not match the schema")
+		}
+	}
+	return nil
+}
+
+type readRequest struct {
+	TableName              string
+	IndexName              string
+	KeyConditionExpression *string
+	FilterExpression       *string
+	ProjectionExpression   *string
+	Select                 string
+	Limit                  *int
+	ExclusiveStartKey      item
+	ScanIndexForward       *bool
+	ConsistentRead         bool
+	Segment                *int
+	TotalSegments          *int
+	ReturnConsumedCapacity string
+	expressionParameters
+	legacyParameters
+}
+
+// page reads the items in order, from after the start key, until it reaches the limit or the page is full.
+// The filter is applied to the items that were read.
+func (f *Fake) page(v *view, request *readRequest, ordered []item, filter condition, projection []path) (map[string]interface{}, error) {
+	if err := v.validateStartKey(request.ExclusiveStartKey); err != nil {
+		return nil, err
+	}
+
+	start := 0
+	if request.ExclusiveStartKey != nil {
+		forward := request.ScanIndexForward == nil || *request.ScanIndexForward
+		for start < len(ordered) {
+			order := v.compareKeys(ordered[start], request.ExclusiveStartKey)
+			if request.KeyConditionExpression == nil {
+				order = v.scanOrder(ordered[start], request.ExclusiveStartKey)
+			}
+			if (forward && order > 0) || (!forward && order < 0) {
+				break
+			}
+			start++
+		}
+	}
+
+	limit := len(ordered)
+	if request.Limit != nil {
+		if *request.Limit <= 0 {
+			return nil, validationError("1 validation error detected: Value '%d' at 'limit' failed to satisfy constraint: Member must have value greater than or equal to 1", *request.Limit)
+		}
+		limit = *request.Limit
+	}
+	if f.pageSize > 0 && f.pageSize < limit {
+		limit = f.pageSize
+	}
+
+	items := make([]item, 0)
+	scanned, size := 0, 0
+	for i := start; i < len(ordered) && scanned < limit && size < maxPageBytes; i++ {
+		it := ordered[i]
+		scanned++
+		size += it.size()
+
+		if filter != nil {
+			matches, err := filter.evaluate(it)
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				continue
+			}
+		}
+
+		it = v.project(it)
+		if projection != nil {
+			it = project(it, projection)
+		}
+		items = append(items, it)
+	}
+
+	result := map[string]interface{}{
+		"Count":        len(items),
+		"ScannedCount": scanned,
+	}
+	if request.Select != "COUNT" {
+		result["Items"] = items
+	}
+	if start+scanned < len(ordered) {
+		result["LastEvaluatedKey"] = v.key(ordered[start+scanned-1])
+	}
+	if c := capacity(request.ReturnConsumedCapacity, v.table.name, readUnits(size, request.ConsistentRead)); c != nil {
+		result["ConsumedCapacity"] = c
+	}
+	return result, nil
+}
+
+// prepareRead finds the view the request reads, and parses its filter and projection
+func (f *Fake) prepareRead(request *readRequest, placeholders *placeholders) (*view, condition, []path, error) {
+	if err := request.check(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	t, err := f.table(request.TableName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	v, err := t.view(request.IndexName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if v.global && request.ConsistentRead {
+		return nil, nil, nil, validationError("Consistent reads are not supported on global secondary indexes")
+	}
+
+	var filter condition
+	if request.FilterExpression != nil {
+		if filter, err = parseCondition("FilterExpression", *request.FilterExpression, placeholders); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	var projection []path
+	if request.ProjectionExpression != nil {
+		if projection, err = parseProjection(*request.ProjectionExpression, placeholders); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	switch request.Select {
+	case "", "ALL_ATTRIBUTES", "ALL_PROJECTED_ATTRIBUTES", "COUNT":
+	case "SPECIFIC_ATTRIBUTES":
+		if projection == nil {
+			return nil, nil, nil, validationError("SPECIFIC_ATTRIBUTES requires a ProjectionExpression")
+		}
+	default:
+		return nil, nil, nil, validationError("1 validation error detected: Value '%s' at 'select' failed to satisfy constraint", request.Select)
+	}
+
+	return v, filter, projection, nil
+}
+
+func (f *Fake) query(body []byte) (interface{}, error) {
+	request := readRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+	if request.KeyConditionExpression == nil {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request.")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	placeholders := newPlaceholders(request.ExpressionAttributeNames, request.ExpressionAttributeValues)
+	v, filter, projection, err := f.prepareRead(&request, placeholders)
+	if err != nil {
+		return nil, err
+	}
+	keyCondition, err := parseKeyCondition(*request.KeyConditionExpression, placeholders, v.partition, v.sort)
+	if err != nil {
+		return nil, err
+	}
+	if err := placeholders.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	var ordered []item
+	for _, it := range v.items() {
+		if !equal(it[v.partition], keyCondition.partition) {
+			continue
+		}
+		matches, err := keyCondition.condition.evaluate(it)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			ordered = append(ordered, it)
+		}
+	}
+
+	forward := request.ScanIndexForward == nil || *request.ScanIndexForward
+	sort.SliceStable(ordered, func(i, j int) bool {
+		order := v.compareKeys(ordered[i], ordered[j])
+		if forward {
+			return order < 0
+		}
+		return order > 0
+	})
+
+	return f.page(v, &request, ordered, filter, projection)
+}
+
+func (f *Fake) scan(body []byte) (interface{}, error) {
+	request := readRequest{}
+	if err := decode(body, &request); err != nil {
+		return nil, err
+	}
+	if request.KeyConditionExpression != nil || request.ScanIndexForward != nil {
+		return nil, validationError("Scan doesn't take a KeyConditionExpression or ScanIndexForward")
+	}
+	if (request.Segment == nil) != (request.TotalSegments == nil) {
+		return nil, validationError("The TotalSegments parameter is required but was not present in the request when Segment parameter is present")
+	}
+	if request.TotalSegments != nil && (*request.TotalSegments < 1 || *request.Segment < 0 || *request.Segment >= *request.TotalSegments) {
+		return nil, validationError("The Segment parameter is zero-based and must be less than parameter TotalSegments")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	placeholders := newPlaceholders(request.ExpressionAttributeNames, request.ExpressionAttributeValues)
+	v, filter, projection, err := f.prepareRead(&request, placeholders)
+	if err != nil {
+		return nil, err
+	}
+	if err := placeholders.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	var ordered []item
+	for _, it := range v.items() {
+		if request.TotalSegments == nil || int(hashOf(it[v.partition])%uint32(*request.TotalSegments)) == *request.Segment {
+			ordered = append(ordered, it)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return v.scanOrder(ordered[i], ordered[j]) < 0 })
+
+	return f.page(v, &request, ordered, filter, projection)
+}
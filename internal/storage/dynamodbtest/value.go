@@ -0,0 +1,338 @@
+package dynamodbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// value is an attribute value as it's written in DynamoDB's JSON protocol. Exactly one of its fields is set.
+type value struct {
+	S    *string           `json:"S,omitempty"`
+	N    *string           `json:"N,omitempty"`
+	B    []byte            `json:"B,omitempty"`
+	SS   []string          `json:"SS,omitempty"`
+	NS   []string          `json:"NS,omitempty"`
+	BS   [][]byte          `json:"BS,omitempty"`
+	M    map[string]*value `json:"M,omitempty"`
+	L    []*value          `json:"L,omitempty"`
+	NULL *bool             `json:"NULL,omitempty"`
+	BOOL *bool             `json:"BOOL,omitempty"`
+}
+
+// item is a set of attributes. The items in a table are never changed in place; a write replaces them.
+type item map[string]*value
+
+func stringValue(s string) *value { return &value{S: &s} }
+func numberValue(n string) *value { return &value{N: &n} }
+
+// MarshalJSON writes the value's only field, which may be an empty map or list
+func (v *value) MarshalJSON() ([]byte, error) {
+	switch v.kind() {
+	case "S":
+		return json.Marshal(map[string]string{"S": *v.S})
+	case "N":
+		return json.Marshal(map[string]string{"N": *v.N})
+	case "B":
+		return json.Marshal(map[string][]byte{"B": v.B})
+	case "SS":
+		return json.Marshal(map[string][]string{"SS": v.SS})
+	case "NS":
+		return json.Marshal(map[string][]string{"NS": v.NS})
+	case "BS":
+		return json.Marshal(map[string][][]byte{"BS": v.BS})
+	case "M":
+		return json.Marshal(map[string]map[string]*value{"M": v.M})
+	case "L":
+		return json.Marshal(map[string][]*value{"L": v.L})
+	case "NULL":
+		return json.Marshal(map[string]bool{"NULL": true})
+	case "BOOL":
+		return json.Marshal(map[string]bool{"BOOL": *v.BOOL})
+	}
+	return nil, fmt.Errorf("the attribute value is empty")
+}
+
+// kind returns the value's data type, in DynamoDB's notation
+func (v *value) kind() string {
+	switch {
+	case v == nil:
+		return ""
+	case v.S != nil:
+		return "S"
+	case v.N != nil:
+		return "N"
+	case v.B != nil:
+		return "B"
+	case v.SS != nil:
+		return "SS"
+	case v.NS != nil:
+		return "NS"
+	case v.BS != nil:
+		return "BS"
+	case v.M != nil:
+		return "M"
+	case v.L != nil:
+		return "L"
+	case v.NULL != nil:
+		return "NULL"
+	case v.BOOL != nil:
+		return "BOOL"
+	}
+	return ""
+}
+
+// validate checks that the value has exactly one data type, and that its numbers and sets are well formed
+func (v *value) validate() error {
+	if v == nil {
+		return validationError("Supplied AttributeValue is empty, must contain exactly one of the supported datatypes")
+	}
+
+	types := 0
+	for _, set := range []bool{v.S != nil, v.N != nil, v.B != nil, v.SS != nil, v.NS != nil, v.BS != nil, v.M != nil, v.L != nil, v.NULL != nil, v.BOOL != nil} {
+		if set {
+			types++
+		}
+	}
+	if types == 0 {
+		return validationError("Supplied AttributeValue is empty, must contain exactly one of the supported datatypes")
+	}
+	if types > 1 {
+		return validationError("Supplied AttributeValue has more than one datatypes set, must contain exactly one of the supported datatypes")
+	}
+
+	switch v.kind() {
+	case "N":
+		if _, ok := parseNumber(*v.N); !ok {
+			return validationError("A value provided cannot be converted into a number")
+		}
+	case "NS":
+		for _, n := range v.NS {
+			if _, ok := parseNumber(n); !ok {
+				return validationError("A value provided cannot be converted into a number")
+			}
+		}
+	case "NULL":
+		if !*v.NULL {
+			return validationError("Null attribute value types must have the value of true")
+		}
+	case "M":
+		for _, element := range v.M {
+			if err := element.validate(); err != nil {
+				return err
+			}
+		}
+	case "L":
+		for _, element := range v.L {
+			if err := element.validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if set := v.members(); set != nil {
+		if len(set) == 0 {
+			return validationError("One or more parameter values were invalid: An %s set may not be empty", v.kind())
+		}
+		seen := make(map[string]bool)
+		for _, member := range set {
+			key := member.key()
+			if seen[key] {
+				return validationError("One or more parameter values were invalid: Input collection contains duplicates")
+			}
+			seen[key] = true
+		}
+	}
+
+	return nil
+}
+
+// members returns a set's members as values, or nil if the value isn't a set
+func (v *value) members() []*value {
+	var result []*value
+	switch v.kind() {
+	case "SS":
+		result = make([]*value, 0, len(v.SS))
+		for _, s := range v.SS {
+			result = append(result, stringValue(s))
+		}
+	case "NS":
+		result = make([]*value, 0, len(v.NS))
+		for _, n := range v.NS {
+			result = append(result, numberValue(n))
+		}
+	case "BS":
+		result = make([]*value, 0, len(v.BS))
+		for _, b := range v.BS {
+			result = append(result, &value{B: b})
+		}
+	}
+	return result
+}
+
+// setOf builds a set of the kind from its members
+func setOf(kind string, members []*value) *value {
+	switch kind {
+	case "SS":
+		result := &value{SS: make([]string, 0, len(members))}
+		for _, member := range members {
+			result.SS = append(result.SS, *member.S)
+		}
+		return result
+	case "NS":
+		result := &value{NS: make([]string, 0, len(members))}
+		for _, member := range members {
+			result.NS = append(result.NS, *member.N)
+		}
+		return result
+	default:
+		result := &value{BS: make([][]byte, 0, len(members))}
+		for _, member := range members {
+			result.BS = append(result.BS, member.B)
+		}
+		return result
+	}
+}
+
+// key is a string that's the same for equal values, and different for values that aren't
+func (v *value) key() string {
+	switch v.kind() {
+	case "N":
+		n, _ := parseNumber(*v.N)
+		return "N:" + n.RatString()
+	case "SS", "NS", "BS":
+		keys := make([]string, 0)
+		for _, member := range v.members() {
+			keys = append(keys, member.key())
+		}
+		sort.Strings(keys)
+		return v.kind() + ":[" + strings.Join(keys, ",") + "]"
+	case "M":
+		names := make([]string, 0, len(v.M))
+		for name := range v.M {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		keys := make([]string, 0, len(names))
+		for _, name := range names {
+			keys = append(keys, fmt.Sprintf("%q=%s", name, v.M[name].key()))
+		}
+		return "M:{" + strings.Join(keys, ",") + "}"
+	case "L":
+		keys := make([]string, 0, len(v.L))
+		for _, element := range v.L {
+			keys = append(keys, element.key())
+		}
+		return "L:[" + strings.Join(keys, ",") + "]"
+	}
+
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
+
+// equal reports whether the values are the same. Numbers are compared by value, and sets regardless of the
+// order of their members.
+func equal(a, b *value) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return a.key() == b.key()
+}
+
+// compare orders two strings, numbers or binaries of the same type. It reports false if they can't be
+// compared.
+func compare(a, b *value) (int, bool) {
+	if a == nil || b == nil || a.kind() != b.kind() {
+		return 0, false
+	}
+
+	switch a.kind() {
+	case "S":
+		return strings.Compare(*a.S, *b.S), true
+	case "N":
+		x, _ := parseNumber(*a.N)
+		y, _ := parseNumber(*b.N)
+		return x.Cmp(y), true
+	case "B":
+		return bytes.Compare(a.B, b.B), true
+	}
+	return 0, false
+}
+
+// parseNumber reads a number in DynamoDB's notation
+func parseNumber(s string) (*big.Rat, bool) {
+	if strings.TrimSpace(s) != s || s == "" || strings.Contains(s, "/") {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// formatNumber writes a number the way DynamoDB returns it, without trailing zeros
+func formatNumber(n *big.Rat) string {
+	if n.IsInt() {
+		return n.Num().String()
+	}
+	s := strings.TrimRight(n.FloatString(38), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// size estimates the number of bytes the value takes up, the way DynamoDB meters it
+func (v *value) size() int {
+	switch v.kind() {
+	case "S":
+		return len(*v.S)
+	case "N":
+		return len(*v.N)/2 + 1
+	case "B":
+		return len(v.B)
+	case "SS", "NS", "BS":
+		total := 0
+		for _, member := range v.members() {
+			total += member.size()
+		}
+		return total
+	case "M":
+		total := 3
+		for name, element := range v.M {
+			total += len(name) + element.size() + 1
+		}
+		return total
+	case "L":
+		total := 3
+		for _, element := range v.L {
+			total += element.size() + 1
+		}
+		return total
+	}
+	return 1
+}
+
+// size estimates the number of bytes the item takes up
+func (it item) size() int {
+	total := 0
+	for name, v := range it {
+		total += len(name) + v.size()
+	}
+	return total
+}
+
+// clone copies the item's attributes, so it can be changed without changing the item in the table. The
+// values themselves are shared, since they're never changed in place.
+func (it item) clone() item {
+	result := make(item, len(it))
+	for name, v := range it {
+		result[name] = v
+	}
+	return result
+}
+
+// hashOf spreads the partition keys, so a scan returns the items in no particular order, as DynamoDB does
+func hashOf(v *value) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(v.key()))
+	return h.Sum32()
+}
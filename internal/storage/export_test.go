@@ -0,0 +1,8 @@
+package storage
+
+// The test harnesses, for the conformance tests in package storage_test
+var (
+	NewTestSQLiteStorage   = newTestSQLiteStorage
+	NewTestPostgresStorage = newTestPostgresStorage
+	NewTestDynamoDBStorage = newTestDynamoDBStorage
+)
@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migration is a numbered change to a SQL schema. Its files are named after it, e.g.
+// 0001_create_catalog.up.sql and 0001_create_catalog.down.sql.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// loadMigrations reads the migrations in the directory, in version order
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(fileName, "."+direction+".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("the migration %s isn't named like 0001_name.%s.sql", fileName, direction)
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the migration %s: %w", fileName, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if m.name != name {
+			return nil, fmt.Errorf("the migrations %s and %s share version %d", m.name, name, version)
+		}
+		if direction == "up" {
+			m.up = string(data)
+		} else {
+			m.down = string(data)
+		}
+	}
+
+	result := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("the migration %04d_%s has no up.sql", m.version, m.name)
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].version < result[j].version })
+
+	return result, nil
+}
+
+// MigrationStatus is whether a migration has been applied to the database, and when
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+func (m MigrationStatus) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Migrator applies and reverts the schema migrations of a SQL database. Each migration runs in its own
+// transaction, and the applied ones are recorded in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	dialect    sqlDialect
+	migrations []migration
+	now        func() time.Time
+}
+
+// NewMigrator opens the database of the backend, "sqlite" or "postgres", for migrating
+func NewMigrator(backend, dsn string) (*Migrator, error) {
+	db, dialect, err := openSQL(backend, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := loadMigrations(dialect.migrations, dialect.migrationsDir)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: migrations, now: time.Now}, nil
+}
+
+// Close closes the database
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Status lists every migration, in version order, with the time it was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.version, Name: migration.name}
+		if appliedAt, ok := applied[migration.version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// Up applies the migrations that haven't been applied yet, and returns them. Runs of Up against the same
+// database, e.g. by instances that start together, take turns, so each migration is only applied once.
+func (m *Migrator) Up(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	defer conn.Close()
+
+	if m.dialect.lockMigrations != "" {
+		if _, err := conn.ExecContext(ctx, m.dialect.lockMigrations); err != nil {
+			return nil, fmt.Errorf("failed to lock the migrations: %w", err)
+		}
+		defer func() {
+			_, _ = conn.ExecContext(context.Background(), m.dialect.unlockMigrations)
+		}()
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0)
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.version]; ok {
+			continue
+		}
+
+		now := m.now().UTC()
+		status := MigrationStatus{Version: migration.version, Name: migration.name, AppliedAt: &now}
+		ok, err := m.apply(ctx, conn, migration, now)
+		if err != nil {
+			return result, fmt.Errorf("failed to apply the migration %s: %w", status, err)
+		}
+		if ok {
+			result = append(result, status)
+		}
+	}
+
+	return result, nil
+}
+
+// apply applies the migration in a transaction that begins with the dialect's beginMigration, unless
+// another run applied it first, and reports whether it did
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration migration, now time.Time) (bool, error) {
+	if _, err := conn.ExecContext(ctx, m.dialect.beginMigration); err != nil {
+		return false, fmt.Errorf("failed to begin a transaction: %w", err)
+	}
+
+	ok, err := func() (bool, error) {
+		var applied int
+		err := conn.QueryRowContext(ctx, m.dialect.rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), migration.version).Scan(&applied)
+		if err != nil || applied > 0 {
+			return false, err
+		}
+		if _, err := conn.ExecContext(ctx, migration.up); err != nil {
+			return false, err
+		}
+		_, err = conn.ExecContext(ctx, m.dialect.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			migration.version, migration.name, formatTime(now))
+		return err == nil, err
+	}()
+	if err != nil || !ok {
+		_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+		return false, err
+	}
+
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return false, fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+	return true, nil
+}
+
+// Down reverts the latest migration that was applied, and returns it. It returns nil if no migration has
+// been applied.
+func (m *Migrator) Down(ctx context.Context) (*MigrationStatus, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.version]; !ok {
+			continue
+		}
+
+		status := MigrationStatus{Version: migration.version, Name: migration.name}
+		if migration.down == "" {
+			return nil, fmt.Errorf("the migration %s can't be reverted", status)
+		}
+
+		err := inTx(ctx, m.db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migration.down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, m.dialect.rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.version)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to revert the migration %s: %w", status, err)
+		}
+		return &status, nil
+	}
+
+	return nil, nil
+}
+
+// pending returns the migrations that haven't been applied yet
+func (m *Migrator) pending(ctx context.Context) ([]MigrationStatus, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0)
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			result = append(result, status)
+		}
+	}
+	return result, nil
+}
+
+// applied reads the versions of the migrations that have been applied, and when
+func (m *Migrator) applied(ctx context.Context, q sqlQuerier) (map[int]time.Time, error) {
+	_, err := q.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the schema_migrations table: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt sqlTime
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+		}
+		result[version] = appliedAt.Time
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %w", err)
+	}
+
+	return result, nil
+}
+
+// inTx runs fn in a transaction, which is committed if fn succeeds and rolled back if it fails
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin a transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+	return nil
+}
+
+// sqlTimeFormat is RFC 3339 in UTC with a fixed number of fractional digits, so that times stored as text
+// sort in time order
+const sqlTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqlTimeFormat)
+}
+
+// sqlTime reads a time that's stored either as text or as a native timestamp
+type sqlTime struct {
+	Time  time.Time
+	Valid bool // False when the time is NULL
+}
+
+func (t *sqlTime) Scan(src interface{}) error {
+	var err error
+	switch value := src.(type) {
+	case nil:
+		t.Time, t.Valid = time.Time{}, false
+		return nil
+	case time.Time:
+		t.Time = value.UTC()
+	case string:
+		t.Time, err = time.Parse(sqlTimeFormat, value)
+	case []byte:
+		t.Time, err = time.Parse(sqlTimeFormat, string(value))
+	default:
+		err = fmt.Errorf("can't read a time from %T", src)
+	}
+	t.Valid = err == nil
+	return err
+}
+
+// Ptr returns the time, or nil if it's NULL
+func (t sqlTime) Ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	result := t.Time
+	return &result
+}
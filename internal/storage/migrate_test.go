@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+func TestMigrator(t *testing.T) {
+	backends := map[string]func(t *testing.T) string{
+		"sqlite":   func(t *testing.T) string { return filepath.Join(t.TempDir(), "library.db") },
+		"postgres": newTestPostgresSchema,
+	}
+
+	for backend, newDSN := range backends {
+		t.Run(backend, func(t *testing.T) {
+			assert := assertions.New(t)
+			ctx := context.Background()
+
+			m, err := NewMigrator(backend, newDSN(t))
+			assert.So(err, should.BeNil)
+			defer m.Close()
+			m.now = fixedClock(testNow)
+
+			pending := []MigrationStatus{{Version: 1, Name: "create_catalog"}}
+			applied := []MigrationStatus{{Version: 1, Name: "create_catalog", AppliedAt: &testNow}}
+
+			statuses, err := m.Status(ctx)
+			assert.So(err, should.BeNil)
+			assert.So(statuses, should.Resemble, pending)
+
+			// Each migration is only applied once
+			result, err := m.Up(ctx)
+			assert.So(err, should.BeNil)
+			assert.So(result, should.Resemble, applied)
+			result, err = m.Up(ctx)
+			assert.So(err, should.BeNil)
+			assert.So(result, should.BeEmpty)
+
+			statuses, err = m.Status(ctx)
+			assert.So(err, should.BeNil)
+			assert.So(statuses, should.Resemble, applied)
+
+			// Down reverts one migration at a time, until there are none left
+			reverted, err := m.Down(ctx)
+			assert.So(err, should.BeNil)
+			assert.So(*reverted, should.Resemble, pending[0])
+			reverted, err = m.Down(ctx)
+			assert.So(err, should.BeNil)
+			assert.So(reverted, should.BeNil)
+
+			statuses, err = m.Status(ctx)
+			assert.So(err, should.BeNil)
+			assert.So(statuses, should.Resemble, pending)
+
+			// The schema can be created again once it's been dropped
+			result, err = m.Up(ctx)
+			assert.So(err, should.BeNil)
+			assert.So(result, should.Resemble, applied)
+		})
+	}
+}
+
+// Instances that start together migrate the database at the same time
+func TestMigrator_Up_concurrently(t *testing.T) {
+	backends := map[string]func(t *testing.T) string{
+		"sqlite":   func(t *testing.T) string { return filepath.Join(t.TempDir(), "library.db") },
+		"postgres": newTestPostgresSchema,
+	}
+
+	for backend, newDSN := range backends {
+		t.Run(backend, func(t *testing.T) {
+			assert := assertions.New(t)
+			dsn := newDSN(t)
+
+			const runs = 4
+			results := make(chan []MigrationStatus, runs)
+			errs := make(chan error, runs)
+			var wg sync.WaitGroup
+			for i := 0; i < runs; i++ {
+				m, err := NewMigrator(backend, dsn)
+				assert.So(err, should.BeNil)
+				defer m.Close()
+				m.now = fixedClock(testNow)
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					result, err := m.Up(context.Background())
+					results <- result
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(results)
+			close(errs)
+
+			// Only one of the runs applies the migration
+			var applied []MigrationStatus
+			for result := range results {
+				applied = append(applied, result...)
+			}
+			for err := range errs {
+				assert.So(err, should.BeNil)
+			}
+			assert.So(applied, should.Resemble, []MigrationStatus{{Version: 1, Name: "create_catalog", AppliedAt: &testNow}})
+		})
+	}
+}
+
+func TestNewMigrator(t *testing.T) {
+	assert := assertions.New(t)
+
+	_, err := NewMigrator("mysql", "library")
+	assert.So(err, testutils.ShouldEqualError, fmt.Errorf("the backend must be sqlite or postgres, not 'mysql'"))
+}
+
+func Test_loadMigrations(t *testing.T) {
+	type state struct {
+		files fstest.MapFS
+	}
+	type expected struct {
+		versions []int
+		err      error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The migrations are sorted by version": {
+			state{files: fstest.MapFS{
+				"m/0002_add_index.up.sql":         {Data: []byte("CREATE INDEX")},
+				"m/0001_create_tables.up.sql":     {Data: []byte("CREATE TABLE")},
+				"m/0001_create_tables.down.sql":   {Data: []byte("DROP TABLE")},
+				"m/README.md":                     {Data: []byte("Not a migration")},
+				"m/0010_add_another_index.up.sql": {Data: []byte("CREATE INDEX")},
+			}},
+			expected{versions: []int{1, 2, 10}},
+		},
+		"A migration isn't numbered": {
+			state{files: fstest.MapFS{
+				"m/create_tables.up.sql": {Data: []byte("CREATE TABLE")},
+			}},
+			expected{err: fmt.Errorf("the migration create_tables.up.sql isn't named like 0001_name.up.sql")},
+		},
+		"A migration can't be applied": {
+			state{files: fstest.MapFS{
+				"m/0001_create_tables.down.sql": {Data: []byte("DROP TABLE")},
+			}},
+			expected{err: fmt.Errorf("the migration 0001_create_tables has no up.sql")},
+		},
+		"Two migrations share a version": {
+			state{files: fstest.MapFS{
+				"m/0001_create_tables.up.sql": {Data: []byte("CREATE TABLE")},
+				"m/0001_add_index.up.sql":     {Data: []byte("CREATE INDEX")},
+			}},
+			expected{err: fmt.Errorf("the migrations add_index and create_tables share version 1")},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			result, err := loadMigrations(tc.state.files, "m")
+
+			versions := make([]int, 0)
+			for _, m := range result {
+				versions = append(versions, m.version)
+			}
+			if tc.expected.err == nil {
+				assert.So(versions, should.Resemble, tc.expected.versions)
+			}
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE audit_events;
+DROP TABLE ledger;
+DROP TABLE loans;
+DROP TABLE holds;
+DROP TABLE copies;
+DROP TABLE books;
@@ -0,0 +1,93 @@
+-- Times are stored as fixed-width RFC 3339 text in UTC, so that they sort in time order
+CREATE TABLE books (
+    id          TEXT PRIMARY KEY,
+    title       TEXT NOT NULL,
+    author      TEXT NOT NULL,
+    isbn        TEXT NOT NULL,
+    description TEXT NOT NULL,
+    status      TEXT NOT NULL,
+    created_at  TEXT NOT NULL,
+    created_by  TEXT NOT NULL,
+    updated_at  TEXT NOT NULL,
+    updated_by  TEXT NOT NULL,
+    deleted_at  TEXT
+);
+
+-- A book in the trash keeps its ISBN until it's purged
+CREATE UNIQUE INDEX books_isbn ON books (isbn) WHERE isbn <> '';
+CREATE INDEX books_updated_at ON books (updated_at);
+
+CREATE TABLE copies (
+    barcode    TEXT PRIMARY KEY,
+    book_id    TEXT NOT NULL,
+    branch     TEXT NOT NULL,
+    location   TEXT NOT NULL,
+    condition  TEXT NOT NULL,
+    item_type  TEXT NOT NULL,
+    status     TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+CREATE INDEX copies_book_id ON copies (book_id);
+
+CREATE TABLE holds (
+    id         TEXT PRIMARY KEY,
+    book_id    TEXT NOT NULL,
+    patron_id  TEXT NOT NULL,
+    status     TEXT NOT NULL,
+    placed_at  TEXT NOT NULL,
+    barcode    TEXT NOT NULL,
+    expires_at TEXT
+);
+
+-- A patron can only be in a book's queue once
+CREATE UNIQUE INDEX holds_active_patron ON holds (book_id, patron_id) WHERE status IN ('waiting', 'ready');
+CREATE INDEX holds_book_id ON holds (book_id, placed_at);
+CREATE INDEX holds_expires_at ON holds (status, expires_at);
+
+CREATE TABLE loans (
+    id             TEXT PRIMARY KEY,
+    barcode        TEXT NOT NULL,
+    book_id        TEXT NOT NULL,
+    patron_id      TEXT NOT NULL,
+    patron_type    TEXT NOT NULL,
+    item_type      TEXT NOT NULL,
+    checked_out_at TEXT NOT NULL,
+    due_at         TEXT NOT NULL,
+    renewals       INTEGER NOT NULL,
+    returned_at    TEXT
+);
+
+-- A copy can only be on one open loan at a time
+CREATE UNIQUE INDEX loans_open_barcode ON loans (barcode) WHERE returned_at IS NULL;
+CREATE INDEX loans_patron_id ON loans (patron_id) WHERE returned_at IS NULL;
+CREATE INDEX loans_due_at ON loans (due_at) WHERE returned_at IS NULL;
+
+CREATE TABLE ledger (
+    id         TEXT PRIMARY KEY,
+    patron_id  TEXT NOT NULL,
+    type       TEXT NOT NULL,
+    amount     INTEGER NOT NULL,
+    loan_id    TEXT NOT NULL,
+    barcode    TEXT NOT NULL,
+    note       TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    created_by TEXT NOT NULL
+);
+
+CREATE INDEX ledger_patron_id ON ledger (patron_id, created_at);
+
+-- The books before and after each change are stored as JSON
+CREATE TABLE audit_events (
+    id        TEXT PRIMARY KEY,
+    book_id   TEXT NOT NULL,
+    barcode   TEXT NOT NULL,
+    action    TEXT NOT NULL,
+    actor     TEXT NOT NULL,
+    timestamp TEXT NOT NULL,
+    before    TEXT,
+    after     TEXT
+);
+
+CREATE INDEX audit_events_book_id ON audit_events (book_id, timestamp);
+CREATE INDEX audit_events_actor ON audit_events (actor, timestamp);
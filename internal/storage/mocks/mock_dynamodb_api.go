@@ -0,0 +1,949 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+type MockDynamoDBAPI struct {
+	BatchWriteItemStub        func(context.Context, *dynamodb.BatchWriteItemInput, ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	batchWriteItemMutex       sync.RWMutex
+	batchWriteItemArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.BatchWriteItemInput
+		arg3 []func(*dynamodb.Options)
+	}
+	batchWriteItemReturns struct {
+		result1 *dynamodb.BatchWriteItemOutput
+		result2 error
+	}
+	batchWriteItemReturnsOnCall map[int]struct {
+		result1 *dynamodb.BatchWriteItemOutput
+		result2 error
+	}
+	CreateTableStub        func(context.Context, *dynamodb.CreateTableInput, ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	createTableMutex       sync.RWMutex
+	createTableArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.CreateTableInput
+		arg3 []func(*dynamodb.Options)
+	}
+	createTableReturns struct {
+		result1 *dynamodb.CreateTableOutput
+		result2 error
+	}
+	createTableReturnsOnCall map[int]struct {
+		result1 *dynamodb.CreateTableOutput
+		result2 error
+	}
+	DeleteTableStub        func(context.Context, *dynamodb.DeleteTableInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error)
+	deleteTableMutex       sync.RWMutex
+	deleteTableArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.DeleteTableInput
+		arg3 []func(*dynamodb.Options)
+	}
+	deleteTableReturns struct {
+		result1 *dynamodb.DeleteTableOutput
+		result2 error
+	}
+	deleteTableReturnsOnCall map[int]struct {
+		result1 *dynamodb.DeleteTableOutput
+		result2 error
+	}
+	DescribeTableStub        func(context.Context, *dynamodb.DescribeTableInput, ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	describeTableMutex       sync.RWMutex
+	describeTableArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.DescribeTableInput
+		arg3 []func(*dynamodb.Options)
+	}
+	describeTableReturns struct {
+		result1 *dynamodb.DescribeTableOutput
+		result2 error
+	}
+	describeTableReturnsOnCall map[int]struct {
+		result1 *dynamodb.DescribeTableOutput
+		result2 error
+	}
+	GetItemStub        func(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	getItemMutex       sync.RWMutex
+	getItemArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.GetItemInput
+		arg3 []func(*dynamodb.Options)
+	}
+	getItemReturns struct {
+		result1 *dynamodb.GetItemOutput
+		result2 error
+	}
+	getItemReturnsOnCall map[int]struct {
+		result1 *dynamodb.GetItemOutput
+		result2 error
+	}
+	PutItemStub        func(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	putItemMutex       sync.RWMutex
+	putItemArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.PutItemInput
+		arg3 []func(*dynamodb.Options)
+	}
+	putItemReturns struct {
+		result1 *dynamodb.PutItemOutput
+		result2 error
+	}
+	putItemReturnsOnCall map[int]struct {
+		result1 *dynamodb.PutItemOutput
+		result2 error
+	}
+	QueryStub        func(context.Context, *dynamodb.QueryInput, ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	queryMutex       sync.RWMutex
+	queryArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.QueryInput
+		arg3 []func(*dynamodb.Options)
+	}
+	queryReturns struct {
+		result1 *dynamodb.QueryOutput
+		result2 error
+	}
+	queryReturnsOnCall map[int]struct {
+		result1 *dynamodb.QueryOutput
+		result2 error
+	}
+	ScanStub        func(context.Context, *dynamodb.ScanInput, ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	scanMutex       sync.RWMutex
+	scanArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.ScanInput
+		arg3 []func(*dynamodb.Options)
+	}
+	scanReturns struct {
+		result1 *dynamodb.ScanOutput
+		result2 error
+	}
+	scanReturnsOnCall map[int]struct {
+		result1 *dynamodb.ScanOutput
+		result2 error
+	}
+	TransactWriteItemsStub        func(context.Context, *dynamodb.TransactWriteItemsInput, ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	transactWriteItemsMutex       sync.RWMutex
+	transactWriteItemsArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.TransactWriteItemsInput
+		arg3 []func(*dynamodb.Options)
+	}
+	transactWriteItemsReturns struct {
+		result1 *dynamodb.TransactWriteItemsOutput
+		result2 error
+	}
+	transactWriteItemsReturnsOnCall map[int]struct {
+		result1 *dynamodb.TransactWriteItemsOutput
+		result2 error
+	}
+	UpdateItemStub        func(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	updateItemMutex       sync.RWMutex
+	updateItemArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.UpdateItemInput
+		arg3 []func(*dynamodb.Options)
+	}
+	updateItemReturns struct {
+		result1 *dynamodb.UpdateItemOutput
+		result2 error
+	}
+	updateItemReturnsOnCall map[int]struct {
+		result1 *dynamodb.UpdateItemOutput
+		result2 error
+	}
+	UpdateTableStub        func(context.Context, *dynamodb.UpdateTableInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+	updateTableMutex       sync.RWMutex
+	updateTableArgsForCall []struct {
+		arg1 context.Context
+		arg2 *dynamodb.UpdateTableInput
+		arg3 []func(*dynamodb.Options)
+	}
+	updateTableReturns struct {
+		result1 *dynamodb.UpdateTableOutput
+		result2 error
+	}
+	updateTableReturnsOnCall map[int]struct {
+		result1 *dynamodb.UpdateTableOutput
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *MockDynamoDBAPI) BatchWriteItem(arg1 context.Context, arg2 *dynamodb.BatchWriteItemInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	fake.batchWriteItemMutex.Lock()
+	ret, specificReturn := fake.batchWriteItemReturnsOnCall[len(fake.batchWriteItemArgsForCall)]
+	fake.batchWriteItemArgsForCall = append(fake.batchWriteItemArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.BatchWriteItemInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.BatchWriteItemStub
+	fakeReturns := fake.batchWriteItemReturns
+	fake.recordInvocation("BatchWriteItem", []interface{}{arg1, arg2, arg3})
+	fake.batchWriteItemMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) BatchWriteItemCallCount() int {
+	fake.batchWriteItemMutex.RLock()
+	defer fake.batchWriteItemMutex.RUnlock()
+	return len(fake.batchWriteItemArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) BatchWriteItemCalls(stub func(context.Context, *dynamodb.BatchWriteItemInput, ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)) {
+	fake.batchWriteItemMutex.Lock()
+	defer fake.batchWriteItemMutex.Unlock()
+	fake.BatchWriteItemStub = stub
+}
+
+func (fake *MockDynamoDBAPI) BatchWriteItemArgsForCall(i int) (context.Context, *dynamodb.BatchWriteItemInput, []func(*dynamodb.Options)) {
+	fake.batchWriteItemMutex.RLock()
+	defer fake.batchWriteItemMutex.RUnlock()
+	argsForCall := fake.batchWriteItemArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) BatchWriteItemReturns(result1 *dynamodb.BatchWriteItemOutput, result2 error) {
+	fake.batchWriteItemMutex.Lock()
+	defer fake.batchWriteItemMutex.Unlock()
+	fake.BatchWriteItemStub = nil
+	fake.batchWriteItemReturns = struct {
+		result1 *dynamodb.BatchWriteItemOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) BatchWriteItemReturnsOnCall(i int, result1 *dynamodb.BatchWriteItemOutput, result2 error) {
+	fake.batchWriteItemMutex.Lock()
+	defer fake.batchWriteItemMutex.Unlock()
+	fake.BatchWriteItemStub = nil
+	if fake.batchWriteItemReturnsOnCall == nil {
+		fake.batchWriteItemReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.BatchWriteItemOutput
+			result2 error
+		})
+	}
+	fake.batchWriteItemReturnsOnCall[i] = struct {
+		result1 *dynamodb.BatchWriteItemOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) CreateTable(arg1 context.Context, arg2 *dynamodb.CreateTableInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	fake.createTableMutex.Lock()
+	ret, specificReturn := fake.createTableReturnsOnCall[len(fake.createTableArgsForCall)]
+	fake.createTableArgsForCall = append(fake.createTableArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.CreateTableInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.CreateTableStub
+	fakeReturns := fake.createTableReturns
+	fake.recordInvocation("CreateTable", []interface{}{arg1, arg2, arg3})
+	fake.createTableMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) CreateTableCallCount() int {
+	fake.createTableMutex.RLock()
+	defer fake.createTableMutex.RUnlock()
+	return len(fake.createTableArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) CreateTableCalls(stub func(context.Context, *dynamodb.CreateTableInput, ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)) {
+	fake.createTableMutex.Lock()
+	defer fake.createTableMutex.Unlock()
+	fake.CreateTableStub = stub
+}
+
+func (fake *MockDynamoDBAPI) CreateTableArgsForCall(i int) (context.Context, *dynamodb.CreateTableInput, []func(*dynamodb.Options)) {
+	fake.createTableMutex.RLock()
+	defer fake.createTableMutex.RUnlock()
+	argsForCall := fake.createTableArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) CreateTableReturns(result1 *dynamodb.CreateTableOutput, result2 error) {
+	fake.createTableMutex.Lock()
+	defer fake.createTableMutex.Unlock()
+	fake.CreateTableStub = nil
+	fake.createTableReturns = struct {
+		result1 *dynamodb.CreateTableOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) CreateTableReturnsOnCall(i int, result1 *dynamodb.CreateTableOutput, result2 error) {
+	fake.createTableMutex.Lock()
+	defer fake.createTableMutex.Unlock()
+	fake.CreateTableStub = nil
+	if fake.createTableReturnsOnCall == nil {
+		fake.createTableReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.CreateTableOutput
+			result2 error
+		})
+	}
+	fake.createTableReturnsOnCall[i] = struct {
+		result1 *dynamodb.CreateTableOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) DeleteTable(arg1 context.Context, arg2 *dynamodb.DeleteTableInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	fake.deleteTableMutex.Lock()
+	ret, specificReturn := fake.deleteTableReturnsOnCall[len(fake.deleteTableArgsForCall)]
+	fake.deleteTableArgsForCall = append(fake.deleteTableArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.DeleteTableInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.DeleteTableStub
+	fakeReturns := fake.deleteTableReturns
+	fake.recordInvocation("DeleteTable", []interface{}{arg1, arg2, arg3})
+	fake.deleteTableMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) DeleteTableCallCount() int {
+	fake.deleteTableMutex.RLock()
+	defer fake.deleteTableMutex.RUnlock()
+	return len(fake.deleteTableArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) DeleteTableCalls(stub func(context.Context, *dynamodb.DeleteTableInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error)) {
+	fake.deleteTableMutex.Lock()
+	defer fake.deleteTableMutex.Unlock()
+	fake.DeleteTableStub = stub
+}
+
+func (fake *MockDynamoDBAPI) DeleteTableArgsForCall(i int) (context.Context, *dynamodb.DeleteTableInput, []func(*dynamodb.Options)) {
+	fake.deleteTableMutex.RLock()
+	defer fake.deleteTableMutex.RUnlock()
+	argsForCall := fake.deleteTableArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) DeleteTableReturns(result1 *dynamodb.DeleteTableOutput, result2 error) {
+	fake.deleteTableMutex.Lock()
+	defer fake.deleteTableMutex.Unlock()
+	fake.DeleteTableStub = nil
+	fake.deleteTableReturns = struct {
+		result1 *dynamodb.DeleteTableOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) DeleteTableReturnsOnCall(i int, result1 *dynamodb.DeleteTableOutput, result2 error) {
+	fake.deleteTableMutex.Lock()
+	defer fake.deleteTableMutex.Unlock()
+	fake.DeleteTableStub = nil
+	if fake.deleteTableReturnsOnCall == nil {
+		fake.deleteTableReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.DeleteTableOutput
+			result2 error
+		})
+	}
+	fake.deleteTableReturnsOnCall[i] = struct {
+		result1 *dynamodb.DeleteTableOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) DescribeTable(arg1 context.Context, arg2 *dynamodb.DescribeTableInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	fake.describeTableMutex.Lock()
+	ret, specificReturn := fake.describeTableReturnsOnCall[len(fake.describeTableArgsForCall)]
+	fake.describeTableArgsForCall = append(fake.describeTableArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.DescribeTableInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.DescribeTableStub
+	fakeReturns := fake.describeTableReturns
+	fake.recordInvocation("DescribeTable", []interface{}{arg1, arg2, arg3})
+	fake.describeTableMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) DescribeTableCallCount() int {
+	fake.describeTableMutex.RLock()
+	defer fake.describeTableMutex.RUnlock()
+	return len(fake.describeTableArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) DescribeTableCalls(stub func(context.Context, *dynamodb.DescribeTableInput, ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)) {
+	fake.describeTableMutex.Lock()
+	defer fake.describeTableMutex.Unlock()
+	fake.DescribeTableStub = stub
+}
+
+func (fake *MockDynamoDBAPI) DescribeTableArgsForCall(i int) (context.Context, *dynamodb.DescribeTableInput, []func(*dynamodb.Options)) {
+	fake.describeTableMutex.RLock()
+	defer fake.describeTableMutex.RUnlock()
+	argsForCall := fake.describeTableArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) DescribeTableReturns(result1 *dynamodb.DescribeTableOutput, result2 error) {
+	fake.describeTableMutex.Lock()
+	defer fake.describeTableMutex.Unlock()
+	fake.DescribeTableStub = nil
+	fake.describeTableReturns = struct {
+		result1 *dynamodb.DescribeTableOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) DescribeTableReturnsOnCall(i int, result1 *dynamodb.DescribeTableOutput, result2 error) {
+	fake.describeTableMutex.Lock()
+	defer fake.describeTableMutex.Unlock()
+	fake.DescribeTableStub = nil
+	if fake.describeTableReturnsOnCall == nil {
+		fake.describeTableReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.DescribeTableOutput
+			result2 error
+		})
+	}
+	fake.describeTableReturnsOnCall[i] = struct {
+		result1 *dynamodb.DescribeTableOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) GetItem(arg1 context.Context, arg2 *dynamodb.GetItemInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	fake.getItemMutex.Lock()
+	ret, specificReturn := fake.getItemReturnsOnCall[len(fake.getItemArgsForCall)]
+	fake.getItemArgsForCall = append(fake.getItemArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.GetItemInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.GetItemStub
+	fakeReturns := fake.getItemReturns
+	fake.recordInvocation("GetItem", []interface{}{arg1, arg2, arg3})
+	fake.getItemMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) GetItemCallCount() int {
+	fake.getItemMutex.RLock()
+	defer fake.getItemMutex.RUnlock()
+	return len(fake.getItemArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) GetItemCalls(stub func(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)) {
+	fake.getItemMutex.Lock()
+	defer fake.getItemMutex.Unlock()
+	fake.GetItemStub = stub
+}
+
+func (fake *MockDynamoDBAPI) GetItemArgsForCall(i int) (context.Context, *dynamodb.GetItemInput, []func(*dynamodb.Options)) {
+	fake.getItemMutex.RLock()
+	defer fake.getItemMutex.RUnlock()
+	argsForCall := fake.getItemArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) GetItemReturns(result1 *dynamodb.GetItemOutput, result2 error) {
+	fake.getItemMutex.Lock()
+	defer fake.getItemMutex.Unlock()
+	fake.GetItemStub = nil
+	fake.getItemReturns = struct {
+		result1 *dynamodb.GetItemOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) GetItemReturnsOnCall(i int, result1 *dynamodb.GetItemOutput, result2 error) {
+	fake.getItemMutex.Lock()
+	defer fake.getItemMutex.Unlock()
+	fake.GetItemStub = nil
+	if fake.getItemReturnsOnCall == nil {
+		fake.getItemReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.GetItemOutput
+			result2 error
+		})
+	}
+	fake.getItemReturnsOnCall[i] = struct {
+		result1 *dynamodb.GetItemOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) PutItem(arg1 context.Context, arg2 *dynamodb.PutItemInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	fake.putItemMutex.Lock()
+	ret, specificReturn := fake.putItemReturnsOnCall[len(fake.putItemArgsForCall)]
+	fake.putItemArgsForCall = append(fake.putItemArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.PutItemInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.PutItemStub
+	fakeReturns := fake.putItemReturns
+	fake.recordInvocation("PutItem", []interface{}{arg1, arg2, arg3})
+	fake.putItemMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) PutItemCallCount() int {
+	fake.putItemMutex.RLock()
+	defer fake.putItemMutex.RUnlock()
+	return len(fake.putItemArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) PutItemCalls(stub func(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)) {
+	fake.putItemMutex.Lock()
+	defer fake.putItemMutex.Unlock()
+	fake.PutItemStub = stub
+}
+
+func (fake *MockDynamoDBAPI) PutItemArgsForCall(i int) (context.Context, *dynamodb.PutItemInput, []func(*dynamodb.Options)) {
+	fake.putItemMutex.RLock()
+	defer fake.putItemMutex.RUnlock()
+	argsForCall := fake.putItemArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) PutItemReturns(result1 *dynamodb.PutItemOutput, result2 error) {
+	fake.putItemMutex.Lock()
+	defer fake.putItemMutex.Unlock()
+	fake.PutItemStub = nil
+	fake.putItemReturns = struct {
+		result1 *dynamodb.PutItemOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) PutItemReturnsOnCall(i int, result1 *dynamodb.PutItemOutput, result2 error) {
+	fake.putItemMutex.Lock()
+	defer fake.putItemMutex.Unlock()
+	fake.PutItemStub = nil
+	if fake.putItemReturnsOnCall == nil {
+		fake.putItemReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.PutItemOutput
+			result2 error
+		})
+	}
+	fake.putItemReturnsOnCall[i] = struct {
+		result1 *dynamodb.PutItemOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) Query(arg1 context.Context, arg2 *dynamodb.QueryInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	fake.queryMutex.Lock()
+	ret, specificReturn := fake.queryReturnsOnCall[len(fake.queryArgsForCall)]
+	fake.queryArgsForCall = append(fake.queryArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.QueryInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.QueryStub
+	fakeReturns := fake.queryReturns
+	fake.recordInvocation("Query", []interface{}{arg1, arg2, arg3})
+	fake.queryMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) QueryCallCount() int {
+	fake.queryMutex.RLock()
+	defer fake.queryMutex.RUnlock()
+	return len(fake.queryArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) QueryCalls(stub func(context.Context, *dynamodb.QueryInput, ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)) {
+	fake.queryMutex.Lock()
+	defer fake.queryMutex.Unlock()
+	fake.QueryStub = stub
+}
+
+func (fake *MockDynamoDBAPI) QueryArgsForCall(i int) (context.Context, *dynamodb.QueryInput, []func(*dynamodb.Options)) {
+	fake.queryMutex.RLock()
+	defer fake.queryMutex.RUnlock()
+	argsForCall := fake.queryArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) QueryReturns(result1 *dynamodb.QueryOutput, result2 error) {
+	fake.queryMutex.Lock()
+	defer fake.queryMutex.Unlock()
+	fake.QueryStub = nil
+	fake.queryReturns = struct {
+		result1 *dynamodb.QueryOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) QueryReturnsOnCall(i int, result1 *dynamodb.QueryOutput, result2 error) {
+	fake.queryMutex.Lock()
+	defer fake.queryMutex.Unlock()
+	fake.QueryStub = nil
+	if fake.queryReturnsOnCall == nil {
+		fake.queryReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.QueryOutput
+			result2 error
+		})
+	}
+	fake.queryReturnsOnCall[i] = struct {
+		result1 *dynamodb.QueryOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) Scan(arg1 context.Context, arg2 *dynamodb.ScanInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	fake.scanMutex.Lock()
+	ret, specificReturn := fake.scanReturnsOnCall[len(fake.scanArgsForCall)]
+	fake.scanArgsForCall = append(fake.scanArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.ScanInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.ScanStub
+	fakeReturns := fake.scanReturns
+	fake.recordInvocation("Scan", []interface{}{arg1, arg2, arg3})
+	fake.scanMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) ScanCallCount() int {
+	fake.scanMutex.RLock()
+	defer fake.scanMutex.RUnlock()
+	return len(fake.scanArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) ScanCalls(stub func(context.Context, *dynamodb.ScanInput, ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)) {
+	fake.scanMutex.Lock()
+	defer fake.scanMutex.Unlock()
+	fake.ScanStub = stub
+}
+
+func (fake *MockDynamoDBAPI) ScanArgsForCall(i int) (context.Context, *dynamodb.ScanInput, []func(*dynamodb.Options)) {
+	fake.scanMutex.RLock()
+	defer fake.scanMutex.RUnlock()
+	argsForCall := fake.scanArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) ScanReturns(result1 *dynamodb.ScanOutput, result2 error) {
+	fake.scanMutex.Lock()
+	defer fake.scanMutex.Unlock()
+	fake.ScanStub = nil
+	fake.scanReturns = struct {
+		result1 *dynamodb.ScanOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) ScanReturnsOnCall(i int, result1 *dynamodb.ScanOutput, result2 error) {
+	fake.scanMutex.Lock()
+	defer fake.scanMutex.Unlock()
+	fake.ScanStub = nil
+	if fake.scanReturnsOnCall == nil {
+		fake.scanReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.ScanOutput
+			result2 error
+		})
+	}
+	fake.scanReturnsOnCall[i] = struct {
+		result1 *dynamodb.ScanOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) TransactWriteItems(arg1 context.Context, arg2 *dynamodb.TransactWriteItemsInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	fake.transactWriteItemsMutex.Lock()
+	ret, specificReturn := fake.transactWriteItemsReturnsOnCall[len(fake.transactWriteItemsArgsForCall)]
+	fake.transactWriteItemsArgsForCall = append(fake.transactWriteItemsArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.TransactWriteItemsInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.TransactWriteItemsStub
+	fakeReturns := fake.transactWriteItemsReturns
+	fake.recordInvocation("TransactWriteItems", []interface{}{arg1, arg2, arg3})
+	fake.transactWriteItemsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) TransactWriteItemsCallCount() int {
+	fake.transactWriteItemsMutex.RLock()
+	defer fake.transactWriteItemsMutex.RUnlock()
+	return len(fake.transactWriteItemsArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) TransactWriteItemsCalls(stub func(context.Context, *dynamodb.TransactWriteItemsInput, ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)) {
+	fake.transactWriteItemsMutex.Lock()
+	defer fake.transactWriteItemsMutex.Unlock()
+	fake.TransactWriteItemsStub = stub
+}
+
+func (fake *MockDynamoDBAPI) TransactWriteItemsArgsForCall(i int) (context.Context, *dynamodb.TransactWriteItemsInput, []func(*dynamodb.Options)) {
+	fake.transactWriteItemsMutex.RLock()
+	defer fake.transactWriteItemsMutex.RUnlock()
+	argsForCall := fake.transactWriteItemsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) TransactWriteItemsReturns(result1 *dynamodb.TransactWriteItemsOutput, result2 error) {
+	fake.transactWriteItemsMutex.Lock()
+	defer fake.transactWriteItemsMutex.Unlock()
+	fake.TransactWriteItemsStub = nil
+	fake.transactWriteItemsReturns = struct {
+		result1 *dynamodb.TransactWriteItemsOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) TransactWriteItemsReturnsOnCall(i int, result1 *dynamodb.TransactWriteItemsOutput, result2 error) {
+	fake.transactWriteItemsMutex.Lock()
+	defer fake.transactWriteItemsMutex.Unlock()
+	fake.TransactWriteItemsStub = nil
+	if fake.transactWriteItemsReturnsOnCall == nil {
+		fake.transactWriteItemsReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.TransactWriteItemsOutput
+			result2 error
+		})
+	}
+	fake.transactWriteItemsReturnsOnCall[i] = struct {
+		result1 *dynamodb.TransactWriteItemsOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) UpdateItem(arg1 context.Context, arg2 *dynamodb.UpdateItemInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	fake.updateItemMutex.Lock()
+	ret, specificReturn := fake.updateItemReturnsOnCall[len(fake.updateItemArgsForCall)]
+	fake.updateItemArgsForCall = append(fake.updateItemArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.UpdateItemInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.UpdateItemStub
+	fakeReturns := fake.updateItemReturns
+	fake.recordInvocation("UpdateItem", []interface{}{arg1, arg2, arg3})
+	fake.updateItemMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) UpdateItemCallCount() int {
+	fake.updateItemMutex.RLock()
+	defer fake.updateItemMutex.RUnlock()
+	return len(fake.updateItemArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) UpdateItemCalls(stub func(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)) {
+	fake.updateItemMutex.Lock()
+	defer fake.updateItemMutex.Unlock()
+	fake.UpdateItemStub = stub
+}
+
+func (fake *MockDynamoDBAPI) UpdateItemArgsForCall(i int) (context.Context, *dynamodb.UpdateItemInput, []func(*dynamodb.Options)) {
+	fake.updateItemMutex.RLock()
+	defer fake.updateItemMutex.RUnlock()
+	argsForCall := fake.updateItemArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) UpdateItemReturns(result1 *dynamodb.UpdateItemOutput, result2 error) {
+	fake.updateItemMutex.Lock()
+	defer fake.updateItemMutex.Unlock()
+	fake.UpdateItemStub = nil
+	fake.updateItemReturns = struct {
+		result1 *dynamodb.UpdateItemOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) UpdateItemReturnsOnCall(i int, result1 *dynamodb.UpdateItemOutput, result2 error) {
+	fake.updateItemMutex.Lock()
+	defer fake.updateItemMutex.Unlock()
+	fake.UpdateItemStub = nil
+	if fake.updateItemReturnsOnCall == nil {
+		fake.updateItemReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.UpdateItemOutput
+			result2 error
+		})
+	}
+	fake.updateItemReturnsOnCall[i] = struct {
+		result1 *dynamodb.UpdateItemOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) UpdateTable(arg1 context.Context, arg2 *dynamodb.UpdateTableInput, arg3 ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	fake.updateTableMutex.Lock()
+	ret, specificReturn := fake.updateTableReturnsOnCall[len(fake.updateTableArgsForCall)]
+	fake.updateTableArgsForCall = append(fake.updateTableArgsForCall, struct {
+		arg1 context.Context
+		arg2 *dynamodb.UpdateTableInput
+		arg3 []func(*dynamodb.Options)
+	}{arg1, arg2, arg3})
+	stub := fake.UpdateTableStub
+	fakeReturns := fake.updateTableReturns
+	fake.recordInvocation("UpdateTable", []interface{}{arg1, arg2, arg3})
+	fake.updateTableMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *MockDynamoDBAPI) UpdateTableCallCount() int {
+	fake.updateTableMutex.RLock()
+	defer fake.updateTableMutex.RUnlock()
+	return len(fake.updateTableArgsForCall)
+}
+
+func (fake *MockDynamoDBAPI) UpdateTableCalls(stub func(context.Context, *dynamodb.UpdateTableInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)) {
+	fake.updateTableMutex.Lock()
+	defer fake.updateTableMutex.Unlock()
+	fake.UpdateTableStub = stub
+}
+
+func (fake *MockDynamoDBAPI) UpdateTableArgsForCall(i int) (context.Context, *dynamodb.UpdateTableInput, []func(*dynamodb.Options)) {
+	fake.updateTableMutex.RLock()
+	defer fake.updateTableMutex.RUnlock()
+	argsForCall := fake.updateTableArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *MockDynamoDBAPI) UpdateTableReturns(result1 *dynamodb.UpdateTableOutput, result2 error) {
+	fake.updateTableMutex.Lock()
+	defer fake.updateTableMutex.Unlock()
+	fake.UpdateTableStub = nil
+	fake.updateTableReturns = struct {
+		result1 *dynamodb.UpdateTableOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) UpdateTableReturnsOnCall(i int, result1 *dynamodb.UpdateTableOutput, result2 error) {
+	fake.updateTableMutex.Lock()
+	defer fake.updateTableMutex.Unlock()
+	fake.UpdateTableStub = nil
+	if fake.updateTableReturnsOnCall == nil {
+		fake.updateTableReturnsOnCall = make(map[int]struct {
+			result1 *dynamodb.UpdateTableOutput
+			result2 error
+		})
+	}
+	fake.updateTableReturnsOnCall[i] = struct {
+		result1 *dynamodb.UpdateTableOutput
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *MockDynamoDBAPI) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.batchWriteItemMutex.RLock()
+	defer fake.batchWriteItemMutex.RUnlock()
+	fake.createTableMutex.RLock()
+	defer fake.createTableMutex.RUnlock()
+	fake.deleteTableMutex.RLock()
+	defer fake.deleteTableMutex.RUnlock()
+	fake.describeTableMutex.RLock()
+	defer fake.describeTableMutex.RUnlock()
+	fake.getItemMutex.RLock()
+	defer fake.getItemMutex.RUnlock()
+	fake.putItemMutex.RLock()
+	defer fake.putItemMutex.RUnlock()
+	fake.queryMutex.RLock()
+	defer fake.queryMutex.RUnlock()
+	fake.scanMutex.RLock()
+	defer fake.scanMutex.RUnlock()
+	fake.transactWriteItemsMutex.RLock()
+	defer fake.transactWriteItemsMutex.RUnlock()
+	fake.updateItemMutex.RLock()
+	defer fake.updateItemMutex.RUnlock()
+	fake.updateTableMutex.RLock()
+	defer fake.updateTableMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *MockDynamoDBAPI) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
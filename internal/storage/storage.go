@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/aaron-zeisler/library-api/internal"
+)
+
+// BooksDB is implemented by every storage backend: the books service's storage, and the probe used by the
+// health checks
+type BooksDB interface {
+	Probe(ctx context.Context) error
+	GetBooks(ctx context.Context, filter internal.BookFilter) ([]internal.Book, error)
+	GetBookByID(ctx context.Context, bookID string) (internal.Book, error)
+	CreateBook(ctx context.Context, title, author, isbn, description string) (internal.Book, error)
+	UpdateBook(ctx context.Context, bookID string, book internal.Book) (internal.Book, error)
+	DeleteBook(ctx context.Context, bookID string) error
+	GetDeletedBooks(ctx context.Context) ([]internal.Book, error)
+	RestoreBook(ctx context.Context, bookID string) (internal.Book, error)
+	PurgeBook(ctx context.Context, bookID string) error
+	PurgeDeletedBooks(ctx context.Context, cutoff time.Time) (int, error)
+	GetCopies(ctx context.Context, bookID string) ([]internal.Copy, error)
+	GetCopyByBarcode(ctx context.Context, barcode string) (internal.Copy, error)
+	AddCopy(ctx context.Context, bookCopy internal.Copy) (internal.Copy, error)
+	UpdateCopyStatus(ctx context.Context, barcode string, from, to internal.BookStatus) (internal.Copy, error)
+	PlaceHold(ctx context.Context, hold internal.Hold) (internal.Hold, error)
+	GetHolds(ctx context.Context, bookID string) ([]internal.Hold, error)
+	GetExpiredHolds(ctx context.Context, now time.Time) ([]internal.Hold, error)
+	ReserveCopy(ctx context.Context, hold internal.Hold, barcode string, from internal.BookStatus, expiresAt time.Time) (internal.Hold, error)
+	CloseHold(ctx context.Context, hold internal.Hold, status internal.HoldStatus) error
+	CreateLoan(ctx context.Context, loan internal.Loan) (internal.Loan, error)
+	CheckOutCopy(ctx context.Context, loan internal.Loan, from internal.BookStatus, hold *internal.Hold) (internal.Copy, internal.Loan, error)
+	GetOpenLoan(ctx context.Context, barcode string) (internal.Loan, error)
+	GetOverdueLoans(ctx context.Context, now time.Time) ([]internal.Loan, error)
+	RenewLoan(ctx context.Context, loan internal.Loan, dueAt time.Time) (internal.Loan, error)
+	CloseLoan(ctx context.Context, loan internal.Loan, returnedAt time.Time) (internal.Loan, error)
+	GetPatronLoans(ctx context.Context, patronID string) ([]internal.Loan, error)
+	AddLedgerEntry(ctx context.Context, entry internal.LedgerEntry) (internal.LedgerEntry, error)
+	GetLedger(ctx context.Context, patronID string) ([]internal.LedgerEntry, error)
+	GetBookHistory(ctx context.Context, bookID string) ([]internal.AuditEvent, error)
+	GetAuditEvents(ctx context.Context, filter internal.AuditFilter) ([]internal.AuditEvent, error)
+}
+
+var (
+	_ BooksDB = (*staticBooksStorage)(nil)
+	_ BooksDB = (*dynamodbBooksStorage)(nil)
+	_ BooksDB = (*sqlBooksStorage)(nil)
+)
@@ -0,0 +1,918 @@
+// Package storagetest checks that a storage backend behaves the same as every other one. Each backend's
+// tests run the suite with a factory that opens an empty storage:
+//
+//	storagetest.RunBooksDBSuite(t, func(t *testing.T, now func() time.Time) storage.BooksDB {
+//		return storage.NewStaticBooksStorage(storage.WithSeedBooks(), storage.WithStaticClock(now))
+//	})
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/storage"
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+// Factory opens an empty storage that timestamps its changes with now, and closes it when the test ends
+type Factory func(t *testing.T, now func() time.Time) storage.BooksDB
+
+// start is the time on the storage's clock when each test begins. It's a whole second, which every backend
+// stores exactly.
+var start = time.Date(2021, time.March, 1, 9, 30, 0, 0, time.UTC)
+
+// clock only moves when a test advances it. The tests advance it between changes whose order they check,
+// since some backends order by time and others by when the change was written.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock on by a second and returns the new time
+func (c *clock) Advance() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+// Skip moves the clock on by d and returns the new time
+func (c *clock) Skip(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+// RunBooksDBSuite runs every test against its own storage from the factory
+func RunBooksDBSuite(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.BooksDB, c *clock)
+	}{
+		{"Books", testBooks},
+		{"Listing", testListing},
+		{"ISBN", testISBN},
+		{"Trash", testTrash},
+		{"Purge", testPurge},
+		{"Audit", testAudit},
+		{"Copies", testCopies},
+		{"Holds", testHolds},
+		{"Loans", testLoans},
+		{"CheckOut", testCheckOut},
+		{"Ledger", testLedger},
+		{"Concurrent", testConcurrent},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &clock{now: start}
+			tc.test(t, newStorage(t, c.Now), c)
+		})
+	}
+}
+
+func testBooks(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := internal.ContextWithActor(context.Background(), "sub:librarian-1")
+
+	assert.So(s.Probe(ctx), should.BeNil)
+
+	// A new book is checked in, whoever asks for it
+	created, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	assert.So(created.ID, should.NotBeEmpty)
+	assert.So(created, should.Resemble, internal.Book{
+		ID:          created.ID,
+		Title:       "Beloved",
+		Author:      "Toni Morrison",
+		ISBN:        "9781400033416",
+		Description: "124 was spiteful",
+		Status:      internal.CheckedIn,
+		CreatedAt:   start,
+		CreatedBy:   "sub:librarian-1",
+		UpdatedAt:   start,
+		UpdatedBy:   "sub:librarian-1",
+	})
+
+	found, err := s.GetBookByID(ctx, created.ID)
+	assert.So(err, should.BeNil)
+	assert.So(found, should.Resemble, created)
+
+	_, err = s.GetBookByID(ctx, "unknown")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: "unknown"})
+	_, err = s.UpdateBook(ctx, "unknown", created)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: "unknown"})
+	assert.So(s.DeleteBook(ctx, "unknown"), testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: "unknown"})
+
+	// An update replaces the book's details and status, but not its creation
+	edited := created
+	edited.Title = "Beloved: A Novel"
+	edited.Description = "124 was spiteful. Full of Baby's venom"
+	edited.Status = internal.CheckedOut
+	edited.CreatedAt = time.Time{}
+	edited.CreatedBy = "someone else"
+	updatedAt := c.Advance()
+	updated, err := s.UpdateBook(internal.ContextWithActor(context.Background(), "sub:librarian-2"), created.ID, edited)
+	assert.So(err, should.BeNil)
+	assert.So(updated, should.Resemble, internal.Book{
+		ID:          created.ID,
+		Title:       "Beloved: A Novel",
+		Author:      "Toni Morrison",
+		ISBN:        "9781400033416",
+		Description: "124 was spiteful. Full of Baby's venom",
+		Status:      internal.CheckedOut,
+		CreatedAt:   start,
+		CreatedBy:   "sub:librarian-1",
+		UpdatedAt:   updatedAt,
+		UpdatedBy:   "sub:librarian-2",
+	})
+
+	found, err = s.GetBookByID(ctx, created.ID)
+	assert.So(err, should.BeNil)
+	assert.So(found, should.Resemble, updated)
+
+	// A request without an actor is attributed to an unknown one
+	anonymous, err := s.CreateBook(context.Background(), "The Martian", "Andy Weir", "9781101905005", "I'm pretty much f*cked")
+	assert.So(err, should.BeNil)
+	assert.So(anonymous.CreatedBy, should.Equal, "unknown")
+}
+
+func testListing(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+
+	books, err := s.GetBooks(ctx, internal.BookFilter{})
+	assert.So(err, should.BeNil)
+	assert.So(books, should.BeEmpty)
+
+	created := make([]internal.Book, 0)
+	for i := 0; i < 4; i++ {
+		book, err := s.CreateBook(ctx, fmt.Sprintf("Volume %d", i), "Anonymous", "", "")
+		assert.So(err, should.BeNil)
+		created = append(created, book)
+		c.Advance()
+	}
+	assert.So(s.DeleteBook(ctx, created[3].ID), should.BeNil)
+
+	// The books are listed in ID order, without the ones in the trash
+	books, err = s.GetBooks(ctx, internal.BookFilter{})
+	assert.So(err, should.BeNil)
+	assert.So(books, should.Resemble, sortedByID(created[:3]))
+
+	// Asking for the changes since a time includes the books moved to the trash since then
+	deleted, err := s.GetDeletedBooks(ctx)
+	assert.So(err, should.BeNil)
+	changed, err := s.GetBooks(ctx, internal.BookFilter{UpdatedSince: created[2].UpdatedAt})
+	assert.So(err, should.BeNil)
+	assert.So(changed, should.Resemble, sortedByID([]internal.Book{created[2], deleted[0]}))
+
+	changed, err = s.GetBooks(ctx, internal.BookFilter{UpdatedSince: c.Now().Add(time.Second)})
+	assert.So(err, should.BeNil)
+	assert.So(changed, should.BeEmpty)
+
+	// The listings can be narrowed down to an ISBN, an author or a status
+	beloved, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+
+	byISBN, err := s.GetBooks(ctx, internal.BookFilter{ISBN: "9781400033416"})
+	assert.So(err, should.BeNil)
+	assert.So(byISBN, should.Resemble, []internal.Book{beloved})
+
+	byAuthor, err := s.GetBooks(ctx, internal.BookFilter{Author: "Anonymous"})
+	assert.So(err, should.BeNil)
+	assert.So(byAuthor, should.Resemble, sortedByID(created[:3]))
+
+	byStatus, err := s.GetBooks(ctx, internal.BookFilter{Status: internal.CheckedIn})
+	assert.So(err, should.BeNil)
+	assert.So(byStatus, should.Resemble, sortedByID(append(created[:3:3], beloved)))
+
+	byStatus, err = s.GetBooks(ctx, internal.BookFilter{Author: "Toni Morrison", Status: internal.CheckedOut})
+	assert.So(err, should.BeNil)
+	assert.So(byStatus, should.BeEmpty)
+}
+
+func testISBN(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+
+	beloved, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	_, err = s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "A second copy")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrDuplicateISBN{ISBN: "9781400033416"})
+
+	// Any number of books can be without an ISBN
+	martian, err := s.CreateBook(ctx, "The Martian", "Andy Weir", "", "I'm pretty much f*cked")
+	assert.So(err, should.BeNil)
+	_, err = s.CreateBook(ctx, "Artemis", "Andy Weir", "", "")
+	assert.So(err, should.BeNil)
+
+	taken := martian
+	taken.ISBN = "9781400033416"
+	_, err = s.UpdateBook(ctx, martian.ID, taken)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrDuplicateISBN{ISBN: "9781400033416"})
+
+	// A book keeps its own ISBN through an update, and gives up the old one when it's changed
+	edited := beloved
+	edited.Description = "124 was spiteful. Full of Baby's venom"
+	beloved, err = s.UpdateBook(ctx, beloved.ID, edited)
+	assert.So(err, should.BeNil)
+
+	edited = beloved
+	edited.ISBN = "9781400033423"
+	beloved, err = s.UpdateBook(ctx, beloved.ID, edited)
+	assert.So(err, should.BeNil)
+	reissue, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "A reissue")
+	assert.So(err, should.BeNil)
+
+	// A book in the trash keeps its ISBN, since it may be restored, until it's purged
+	assert.So(s.DeleteBook(ctx, beloved.ID), should.BeNil)
+	_, err = s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033423", "")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrDuplicateISBN{ISBN: "9781400033423"})
+	assert.So(s.PurgeBook(ctx, beloved.ID), should.BeNil)
+	_, err = s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033423", "")
+	assert.So(err, should.BeNil)
+
+	// A failed update doesn't change the book
+	found, err := s.GetBookByID(ctx, martian.ID)
+	assert.So(err, should.BeNil)
+	assert.So(found, should.Resemble, martian)
+	found, err = s.GetBookByID(ctx, reissue.ID)
+	assert.So(err, should.BeNil)
+	assert.So(found, should.Resemble, reissue)
+}
+
+func testTrash(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := internal.ContextWithActor(context.Background(), "sub:librarian-1")
+
+	kept, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	purged, err := s.CreateBook(ctx, "The Martian", "Andy Weir", "9781101905005", "I'm pretty much f*cked")
+	assert.So(err, should.BeNil)
+
+	deletedAt := c.Advance()
+	assert.So(s.DeleteBook(internal.ContextWithActor(context.Background(), "sub:librarian-2"), kept.ID), should.BeNil)
+	assert.So(s.DeleteBook(ctx, purged.ID), should.BeNil)
+
+	// Deleted books are hidden, and can't be deleted or updated again
+	_, err = s.GetBookByID(ctx, kept.ID)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: kept.ID})
+	assert.So(s.DeleteBook(ctx, kept.ID), testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: kept.ID})
+	_, err = s.UpdateBook(ctx, kept.ID, kept)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: kept.ID})
+
+	trashed := kept
+	trashed.DeletedAt = &deletedAt
+	trashed.UpdatedAt = deletedAt
+	trashed.UpdatedBy = "sub:librarian-2"
+	trash, err := s.GetDeletedBooks(ctx)
+	assert.So(err, should.BeNil)
+	assert.So(len(trash), should.Equal, 2)
+	assert.So(trash[0].ID, should.BeLessThan, trash[1].ID)
+	for _, book := range trash {
+		if book.ID == kept.ID {
+			assert.So(book, should.Resemble, trashed)
+		}
+	}
+
+	// A restored book comes back as it was, and can only be restored once
+	restoredAt := c.Advance()
+	restored, err := s.RestoreBook(ctx, kept.ID)
+	assert.So(err, should.BeNil)
+	expected := kept
+	expected.UpdatedAt = restoredAt
+	assert.So(restored, should.Resemble, expected)
+	_, err = s.RestoreBook(ctx, kept.ID)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: kept.ID})
+	_, err = s.RestoreBook(ctx, "unknown")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: "unknown"})
+
+	// Only books deleted before the cutoff are purged
+	count, err := s.PurgeDeletedBooks(ctx, deletedAt)
+	assert.So(err, should.BeNil)
+	assert.So(count, should.Equal, 0)
+	count, err = s.PurgeDeletedBooks(ctx, deletedAt.Add(time.Second))
+	assert.So(err, should.BeNil)
+	assert.So(count, should.Equal, 1)
+
+	books, err := s.GetBooks(ctx, internal.BookFilter{})
+	assert.So(err, should.BeNil)
+	assert.So(books, should.Resemble, []internal.Book{restored})
+	trash, err = s.GetDeletedBooks(ctx)
+	assert.So(err, should.BeNil)
+	assert.So(trash, should.BeEmpty)
+
+	assert.So(s.PurgeBook(ctx, purged.ID), testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: purged.ID})
+	_, err = s.RestoreBook(ctx, purged.ID)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: purged.ID})
+
+	// A book can be purged without going through the trash
+	assert.So(s.PurgeBook(ctx, kept.ID), should.BeNil)
+	_, err = s.GetBookByID(ctx, kept.ID)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: kept.ID})
+
+	history, err := s.GetBookHistory(ctx, purged.ID)
+	assert.So(err, should.BeNil)
+	assert.So(actions(history), should.Resemble, []internal.AuditAction{internal.AuditCreateBook, internal.AuditDeleteBook, internal.AuditPurgeBook})
+	assert.So(history[2].After, should.BeNil)
+}
+
+func testPurge(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+
+	// A book with more copies than fit in one transaction, a queue of holds, and loans open and closed
+	book, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	for i := 0; i < 120; i++ {
+		_, err = s.AddCopy(ctx, internal.Copy{Barcode: fmt.Sprintf("%04d", i), BookID: book.ID})
+		assert.So(err, should.BeNil)
+	}
+	for _, patronID := range []string{"patron-1", "patron-2"} {
+		c.Advance()
+		_, err = s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: patronID})
+		assert.So(err, should.BeNil)
+	}
+	_, err = s.CreateLoan(ctx, internal.Loan{Barcode: "0000", BookID: book.ID, PatronID: "patron-1", CheckedOutAt: c.Now(), DueAt: c.Now().Add(24 * time.Hour)})
+	assert.So(err, should.BeNil)
+	returned, err := s.CreateLoan(ctx, internal.Loan{Barcode: "0001", BookID: book.ID, PatronID: "patron-2", CheckedOutAt: c.Now(), DueAt: c.Now().Add(24 * time.Hour)})
+	assert.So(err, should.BeNil)
+	_, err = s.CloseLoan(ctx, returned, c.Advance())
+	assert.So(err, should.BeNil)
+
+	// Another book's copies and loans are left alone
+	other, err := s.CreateBook(ctx, "The Martian", "Andy Weir", "9781101905005", "I'm pretty much f*cked")
+	assert.So(err, should.BeNil)
+	otherCopy, err := s.AddCopy(ctx, internal.Copy{Barcode: "other-1", BookID: other.ID})
+	assert.So(err, should.BeNil)
+	otherLoan, err := s.CreateLoan(ctx, internal.Loan{Barcode: "other-1", BookID: other.ID, PatronID: "patron-1", CheckedOutAt: c.Now(), DueAt: c.Now().Add(48 * time.Hour)})
+	assert.So(err, should.BeNil)
+
+	assert.So(s.PurgeBook(ctx, book.ID), should.BeNil)
+
+	_, err = s.GetBookByID(ctx, book.ID)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: book.ID})
+	copies, err := s.GetCopies(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(copies, should.BeEmpty)
+	_, err = s.GetCopyByBarcode(ctx, "0119")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyNotFound{Barcode: "0119"})
+	holds, err := s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(holds, should.BeEmpty)
+	_, err = s.GetOpenLoan(ctx, "0000")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanNotFound{Barcode: "0000"})
+	loans, err := s.GetPatronLoans(ctx, "patron-1")
+	assert.So(err, should.BeNil)
+	assert.So(loans, should.Resemble, []internal.Loan{otherLoan})
+	overdue, err := s.GetOverdueLoans(ctx, c.Now().Add(72*time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(overdue, should.Resemble, []internal.Loan{otherLoan})
+
+	copies, err = s.GetCopies(ctx, other.ID)
+	assert.So(err, should.BeNil)
+	assert.So(copies, should.Resemble, []internal.Copy{otherCopy})
+
+	// The purged book's ISBN and barcodes can be used again
+	reissue, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "Reissued")
+	assert.So(err, should.BeNil)
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "0000", BookID: reissue.ID})
+	assert.So(err, should.BeNil)
+	_, err = s.CreateLoan(ctx, internal.Loan{Barcode: "0000", BookID: reissue.ID, PatronID: "patron-2", CheckedOutAt: c.Now(), DueAt: c.Now().Add(24 * time.Hour)})
+	assert.So(err, should.BeNil)
+}
+
+func testAudit(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := internal.ContextWithActor(context.Background(), "sub:librarian-1")
+
+	// Make one of every kind of change to a book
+	created, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	checkedOut := created
+	checkedOut.Status = internal.CheckedOut
+	c.Advance()
+	_, err = s.UpdateBook(ctx, created.ID, checkedOut)
+	assert.So(err, should.BeNil)
+	edited := checkedOut
+	edited.Description = "124 was spiteful. Full of Baby's venom"
+	editedAt := c.Advance()
+	_, err = s.UpdateBook(internal.ContextWithActor(context.Background(), "sub:librarian-2"), created.ID, edited)
+	assert.So(err, should.BeNil)
+	c.Advance()
+	assert.So(s.DeleteBook(ctx, created.ID), should.BeNil)
+	c.Advance()
+	_, err = s.RestoreBook(ctx, created.ID)
+	assert.So(err, should.BeNil)
+
+	// Changes to other books aren't part of the history. This one is made the next day, so searches of the
+	// audit log span more than one day.
+	c.Skip(24 * time.Hour)
+	_, err = s.CreateBook(ctx, "The Martian", "Andy Weir", "9781101905005", "I'm pretty much f*cked")
+	assert.So(err, should.BeNil)
+
+	history, err := s.GetBookHistory(context.Background(), created.ID)
+	assert.So(err, should.BeNil)
+	assert.So(actions(history), should.Resemble, []internal.AuditAction{internal.AuditCreateBook, internal.AuditCheckOut, internal.AuditUpdateBook, internal.AuditDeleteBook, internal.AuditRestoreBook})
+	for i, event := range history {
+		assert.So(event.ID, should.NotBeEmpty)
+		assert.So(event.BookID, should.Equal, created.ID)
+		assert.So(event.Timestamp, should.Equal, start.Add(time.Duration(i)*time.Second))
+	}
+
+	assert.So(history[0].Before, should.BeNil)
+	assert.So(history[0].After, should.Resemble, &created)
+	assert.So(history[0].Actor, should.Equal, "sub:librarian-1")
+	assert.So(history[2].Before.Description, should.Equal, "124 was spiteful")
+	assert.So(history[2].After.Description, should.Equal, "124 was spiteful. Full of Baby's venom")
+	assert.So(history[2].Actor, should.Equal, "sub:librarian-2")
+	assert.So(history[3].After.IsDeleted(), should.BeTrue)
+	assert.So(history[4].After.IsDeleted(), should.BeFalse)
+
+	history, err = s.GetBookHistory(ctx, "unknown")
+	assert.So(err, should.BeNil)
+	assert.So(history, should.BeEmpty)
+
+	// The events are searched by actor and time, oldest first
+	byActor, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Actor: "sub:librarian-2"})
+	assert.So(err, should.BeNil)
+	assert.So(actions(byActor), should.Resemble, []internal.AuditAction{internal.AuditUpdateBook})
+
+	since, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Since: editedAt})
+	assert.So(err, should.BeNil)
+	assert.So(actions(since), should.Resemble, []internal.AuditAction{internal.AuditUpdateBook, internal.AuditDeleteBook, internal.AuditRestoreBook, internal.AuditCreateBook})
+
+	both, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Actor: "sub:librarian-1", Since: editedAt})
+	assert.So(err, should.BeNil)
+	assert.So(actions(both), should.Resemble, []internal.AuditAction{internal.AuditDeleteBook, internal.AuditRestoreBook, internal.AuditCreateBook})
+
+	inTheFuture, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Since: c.Now().Add(time.Second)})
+	assert.So(err, should.BeNil)
+	assert.So(inTheFuture, should.BeEmpty)
+
+	// The log is read a page at a time, each continuing after the last event of the one before
+	firstPage, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Since: editedAt, Limit: 2})
+	assert.So(err, should.BeNil)
+	assert.So(actions(firstPage), should.Resemble, []internal.AuditAction{internal.AuditUpdateBook, internal.AuditDeleteBook})
+	secondPage, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Since: editedAt, After: &firstPage[1], Limit: 2})
+	assert.So(err, should.BeNil)
+	assert.So(actions(secondPage), should.Resemble, []internal.AuditAction{internal.AuditRestoreBook, internal.AuditCreateBook})
+	lastPage, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Since: editedAt, After: &secondPage[1], Limit: 2})
+	assert.So(err, should.BeNil)
+	assert.So(lastPage, should.BeEmpty)
+
+	byActorPage, err := s.GetAuditEvents(context.Background(), internal.AuditFilter{Actor: "sub:librarian-1", After: &firstPage[1], Limit: 1})
+	assert.So(err, should.BeNil)
+	assert.So(actions(byActorPage), should.Resemble, []internal.AuditAction{internal.AuditRestoreBook})
+}
+
+func testCopies(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := internal.ContextWithActor(context.Background(), "sub:librarian-1")
+
+	book, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: "unknown"})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: "unknown"})
+
+	// A new copy is checked in, whatever status it's given
+	addedAt := c.Advance()
+	second, err := s.AddCopy(ctx, internal.Copy{Barcode: "2", BookID: book.ID, Branch: "Eastside", Condition: internal.ConditionWorn, Status: internal.CheckedOut})
+	assert.So(err, should.BeNil)
+	assert.So(second, should.Resemble, internal.Copy{Barcode: "2", BookID: book.ID, Branch: "Eastside", Condition: internal.ConditionWorn, Status: internal.CheckedIn, UpdatedAt: addedAt})
+	c.Advance()
+	first, err := s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: book.ID, Branch: "Central", Location: "Fiction M", Condition: internal.ConditionNew, ItemType: internal.ItemBook})
+	assert.So(err, should.BeNil)
+
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: book.ID})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrDuplicateBarcode{Barcode: "1"})
+
+	found, err := s.GetCopyByBarcode(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(found, should.Resemble, first)
+	_, err = s.GetCopyByBarcode(ctx, "3")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyNotFound{Barcode: "3"})
+	_, err = s.UpdateCopyStatus(ctx, "3", internal.CheckedIn, internal.CheckedOut)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyNotFound{Barcode: "3"})
+
+	// The copies are listed in barcode order
+	copies, err := s.GetCopies(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(copies, should.Resemble, []internal.Copy{first, second})
+	copies, err = s.GetCopies(ctx, "unknown")
+	assert.So(err, should.BeNil)
+	assert.So(copies, should.BeEmpty)
+
+	// The book stays checked in while any copy is available
+	checkedOutAt := c.Advance()
+	checkedOut, err := s.UpdateCopyStatus(ctx, "1", internal.CheckedIn, internal.CheckedOut)
+	assert.So(err, should.BeNil)
+	assert.So(checkedOut.Status, should.Equal, internal.CheckedOut)
+	assert.So(checkedOut.UpdatedAt, should.Equal, checkedOutAt)
+	current, err := s.GetBookByID(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(current, should.Resemble, book)
+
+	_, err = s.UpdateCopyStatus(ctx, "1", internal.CheckedIn, internal.CheckedOut)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyStatusConflict{Barcode: "1", Status: internal.CheckedIn})
+
+	checkedOutAt = c.Advance()
+	_, err = s.UpdateCopyStatus(internal.ContextWithActor(context.Background(), "sub:librarian-2"), "2", internal.CheckedIn, internal.CheckedOut)
+	assert.So(err, should.BeNil)
+	current, err = s.GetBookByID(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(current.Status, should.Equal, internal.CheckedOut)
+	assert.So(current.UpdatedAt, should.Equal, checkedOutAt)
+	assert.So(current.UpdatedBy, should.Equal, "sub:librarian-2")
+
+	copies, err = s.GetCopies(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(internal.NewAvailability(copies), should.Resemble, internal.Availability{Available: 0, Total: 2})
+
+	c.Advance()
+	_, err = s.UpdateCopyStatus(ctx, "2", internal.CheckedOut, internal.CheckedIn)
+	assert.So(err, should.BeNil)
+	current, err = s.GetBookByID(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(current.Status, should.Equal, internal.CheckedIn)
+
+	history, err := s.GetBookHistory(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(actions(history), should.Resemble, []internal.AuditAction{internal.AuditCreateBook, internal.AuditAddCopy, internal.AuditAddCopy, internal.AuditCheckOut, internal.AuditCheckOut, internal.AuditCheckIn})
+	assert.So(history[4].Barcode, should.Equal, "2")
+	assert.So(history[4].Before.Status, should.Equal, internal.CheckedIn)
+	assert.So(history[4].After.Status, should.Equal, internal.CheckedOut)
+
+	// The copies of a book in the trash can't change, and no more can be added
+	assert.So(s.DeleteBook(ctx, book.ID), should.BeNil)
+	_, err = s.UpdateCopyStatus(ctx, "1", internal.CheckedOut, internal.CheckedIn)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: book.ID})
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "3", BookID: book.ID})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: book.ID})
+
+	// Purging the book removes its copies, and frees their barcodes
+	assert.So(s.PurgeBook(ctx, book.ID), should.BeNil)
+	copies, err = s.GetCopies(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(copies, should.BeEmpty)
+	_, err = s.GetCopyByBarcode(ctx, "1")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyNotFound{Barcode: "1"})
+}
+
+func testHolds(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+
+	book, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: book.ID})
+	assert.So(err, should.BeNil)
+	_, err = s.UpdateCopyStatus(ctx, "1", internal.CheckedIn, internal.CheckedOut)
+	assert.So(err, should.BeNil)
+
+	_, err = s.PlaceHold(ctx, internal.Hold{BookID: "unknown", PatronID: "patron-1"})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrBookNotFound{BookID: "unknown"})
+
+	// A new hold waits at the end of the queue, whatever it's given
+	placedAt := c.Advance()
+	expiresAt := placedAt.Add(72 * time.Hour)
+	first, err := s.PlaceHold(ctx, internal.Hold{ID: "mine", BookID: book.ID, PatronID: "patron-1", Status: internal.HoldReady, Barcode: "1", ExpiresAt: &expiresAt})
+	assert.So(err, should.BeNil)
+	assert.So(first.ID, should.NotBeEmpty)
+	assert.So(first.ID, should.NotEqual, "mine")
+	assert.So(first, should.Resemble, internal.Hold{ID: first.ID, BookID: book.ID, PatronID: "patron-1", Status: internal.HoldWaiting, PlacedAt: placedAt})
+	c.Advance()
+	second, err := s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: "patron-2"})
+	assert.So(err, should.BeNil)
+
+	_, err = s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: "patron-1"})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrDuplicateHold{BookID: book.ID, PatronID: "patron-1"})
+
+	holds, err := s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(holds, should.Resemble, []internal.Hold{first, second})
+	holds, err = s.GetHolds(ctx, "unknown")
+	assert.So(err, should.BeNil)
+	assert.So(holds, should.BeEmpty)
+
+	// The copy can only be reserved from the status it's in
+	_, err = s.ReserveCopy(ctx, first, "1", internal.CheckedIn, expiresAt)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyStatusConflict{Barcode: "1", Status: internal.CheckedIn})
+
+	c.Advance()
+	ready, err := s.ReserveCopy(ctx, first, "1", internal.CheckedOut, expiresAt)
+	assert.So(err, should.BeNil)
+	assert.So(ready, should.Resemble, internal.Hold{ID: first.ID, BookID: book.ID, PatronID: "patron-1", Status: internal.HoldReady, PlacedAt: placedAt, Barcode: "1", ExpiresAt: &expiresAt})
+
+	book, err = s.GetBookByID(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(book.Status, should.Equal, internal.OnHoldShelf)
+	bookCopy, err := s.GetCopyByBarcode(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(bookCopy.Status, should.Equal, internal.OnHoldShelf)
+
+	holds, err = s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(holds, should.Resemble, []internal.Hold{ready, second})
+
+	// A hold that isn't waiting can't be given a copy
+	_, err = s.UpdateCopyStatus(ctx, "1", internal.OnHoldShelf, internal.CheckedIn)
+	assert.So(err, should.BeNil)
+	_, err = s.ReserveCopy(ctx, ready, "1", internal.CheckedIn, expiresAt)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrHoldNotFound{HoldID: first.ID})
+	bookCopy, err = s.GetCopyByBarcode(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(bookCopy.Status, should.Equal, internal.CheckedIn)
+
+	// The hold expires once its expiry has passed
+	expired, err := s.GetExpiredHolds(ctx, expiresAt)
+	assert.So(err, should.BeNil)
+	assert.So(expired, should.BeEmpty)
+	expired, err = s.GetExpiredHolds(ctx, expiresAt.Add(time.Second))
+	assert.So(err, should.BeNil)
+	assert.So(expired, should.Resemble, []internal.Hold{ready})
+
+	// Closed holds leave the queue, and the patron can queue again
+	assert.So(s.CloseHold(ctx, ready, internal.HoldExpired), should.BeNil)
+	assert.So(s.CloseHold(ctx, ready, internal.HoldCancelled), testutils.ShouldEqualError, internal.ErrHoldNotFound{HoldID: first.ID})
+
+	holds, err = s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(holds, should.Resemble, []internal.Hold{second})
+	expired, err = s.GetExpiredHolds(ctx, expiresAt.Add(time.Second))
+	assert.So(err, should.BeNil)
+	assert.So(expired, should.BeEmpty)
+
+	c.Advance()
+	third, err := s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: "patron-1"})
+	assert.So(err, should.BeNil)
+	holds, err = s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(holds, should.Resemble, []internal.Hold{second, third})
+}
+
+func testLoans(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+
+	_, err := s.GetOpenLoan(ctx, "1")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanNotFound{Barcode: "1"})
+
+	// A new loan is open and hasn't been renewed, whatever it's given
+	returnedAt := start.Add(-time.Hour)
+	first, err := s.CreateLoan(ctx, internal.Loan{ID: "mine", Barcode: "1", BookID: "12345", PatronID: "patron-1", PatronType: internal.PatronStudent, ItemType: internal.ItemBook, CheckedOutAt: start, DueAt: start.Add(48 * time.Hour), Renewals: 2, ReturnedAt: &returnedAt})
+	assert.So(err, should.BeNil)
+	assert.So(first.ID, should.NotBeEmpty)
+	assert.So(first.ID, should.NotEqual, "mine")
+	assert.So(first, should.Resemble, internal.Loan{ID: first.ID, Barcode: "1", BookID: "12345", PatronID: "patron-1", PatronType: internal.PatronStudent, ItemType: internal.ItemBook, CheckedOutAt: start, DueAt: start.Add(48 * time.Hour)})
+	second, err := s.CreateLoan(ctx, internal.Loan{Barcode: "2", BookID: "12345", PatronID: "patron-2", CheckedOutAt: start.Add(time.Second), DueAt: start.Add(24 * time.Hour)})
+	assert.So(err, should.BeNil)
+
+	// A copy can only be on one loan at a time
+	_, err = s.CreateLoan(ctx, internal.Loan{Barcode: "1", BookID: "12345", PatronID: "patron-3", CheckedOutAt: start.Add(2 * time.Second)})
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyStatusConflict{Barcode: "1", Status: internal.CheckedIn})
+
+	openLoan, err := s.GetOpenLoan(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(openLoan, should.Resemble, first)
+
+	// The most overdue loan comes first
+	overdue, err := s.GetOverdueLoans(ctx, start.Add(24*time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(overdue, should.BeEmpty)
+	overdue, err = s.GetOverdueLoans(ctx, start.Add(24*time.Hour+time.Second))
+	assert.So(err, should.BeNil)
+	assert.So(overdue, should.Resemble, []internal.Loan{second})
+	overdue, err = s.GetOverdueLoans(ctx, start.Add(72*time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(overdue, should.Resemble, []internal.Loan{second, first})
+
+	// A loan can't be renewed or returned with a stale copy of it
+	renewed, err := s.RenewLoan(ctx, first, start.Add(96*time.Hour))
+	assert.So(err, should.BeNil)
+	expected := first
+	expected.DueAt = start.Add(96 * time.Hour)
+	expected.Renewals = 1
+	assert.So(renewed, should.Resemble, expected)
+	_, err = s.RenewLoan(ctx, first, start.Add(96*time.Hour))
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanChanged{LoanID: first.ID})
+	_, err = s.CloseLoan(ctx, first, start)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanChanged{LoanID: first.ID})
+
+	openLoan, err = s.GetOpenLoan(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(openLoan, should.Resemble, renewed)
+
+	returnedAt = start.Add(time.Hour)
+	returned, err := s.CloseLoan(ctx, renewed, returnedAt)
+	assert.So(err, should.BeNil)
+	expected.ReturnedAt = &returnedAt
+	assert.So(returned, should.Resemble, expected)
+	_, err = s.CloseLoan(ctx, renewed, returnedAt)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanChanged{LoanID: first.ID})
+	_, err = s.RenewLoan(ctx, returned, start.Add(96*time.Hour))
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanChanged{LoanID: first.ID})
+
+	// A returned loan is no longer open, lent to the patron or overdue
+	_, err = s.GetOpenLoan(ctx, "1")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanNotFound{Barcode: "1"})
+	patronLoans, err := s.GetPatronLoans(ctx, "patron-1")
+	assert.So(err, should.BeNil)
+	assert.So(patronLoans, should.BeEmpty)
+	patronLoans, err = s.GetPatronLoans(ctx, "patron-2")
+	assert.So(err, should.BeNil)
+	assert.So(patronLoans, should.Resemble, []internal.Loan{second})
+	overdue, err = s.GetOverdueLoans(ctx, start.Add(72*time.Hour))
+	assert.So(err, should.BeNil)
+	assert.So(overdue, should.Resemble, []internal.Loan{second})
+
+	// Once it's returned, the copy can be lent again
+	again, err := s.CreateLoan(ctx, internal.Loan{Barcode: "1", BookID: "12345", PatronID: "patron-3", CheckedOutAt: start.Add(2 * time.Hour), DueAt: start.Add(50 * time.Hour)})
+	assert.So(err, should.BeNil)
+	openLoan, err = s.GetOpenLoan(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(openLoan, should.Resemble, again)
+}
+
+func testCheckOut(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+
+	book, err := s.CreateBook(ctx, "Invisible Man", "Ralph Ellison", "9780679732761", "I am an invisible man")
+	assert.So(err, should.BeNil)
+	for _, barcode := range []string{"1", "2"} {
+		_, err = s.AddCopy(ctx, internal.Copy{Barcode: barcode, BookID: book.ID, ItemType: internal.ItemBook})
+		assert.So(err, should.BeNil)
+	}
+
+	loan := internal.Loan{Barcode: "1", BookID: book.ID, PatronID: "patron-1", PatronType: internal.PatronStudent, ItemType: internal.ItemBook, CheckedOutAt: start, DueAt: start.Add(48 * time.Hour)}
+
+	_, _, err = s.CheckOutCopy(ctx, internal.Loan{Barcode: "unknown", BookID: book.ID, PatronID: "patron-1"}, internal.CheckedIn, nil)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyNotFound{Barcode: "unknown"})
+
+	// The copy can only be checked out from the status it's in
+	_, _, err = s.CheckOutCopy(ctx, loan, internal.OnHoldShelf, nil)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyStatusConflict{Barcode: "1", Status: internal.OnHoldShelf})
+	_, err = s.GetOpenLoan(ctx, "1")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanNotFound{Barcode: "1"})
+
+	// The copy, the book and the loan change together
+	c.Advance()
+	bookCopy, opened, err := s.CheckOutCopy(ctx, loan, internal.CheckedIn, nil)
+	assert.So(err, should.BeNil)
+	assert.So(opened.ID, should.NotBeEmpty)
+	expected := loan
+	expected.ID = opened.ID
+	assert.So(opened, should.Resemble, expected)
+	assert.So(bookCopy.Status, should.Equal, internal.CheckedOut)
+
+	bookCopy, err = s.GetCopyByBarcode(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(bookCopy.Status, should.Equal, internal.CheckedOut)
+	openLoan, err := s.GetOpenLoan(ctx, "1")
+	assert.So(err, should.BeNil)
+	assert.So(openLoan, should.Resemble, opened)
+	patronLoans, err := s.GetPatronLoans(ctx, "patron-1")
+	assert.So(err, should.BeNil)
+	assert.So(patronLoans, should.Resemble, []internal.Loan{opened})
+
+	_, _, err = s.CheckOutCopy(ctx, loan, internal.CheckedIn, nil)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrCopyStatusConflict{Barcode: "1", Status: internal.CheckedIn})
+
+	// A copy on the hold shelf can't be checked out for a hold that's been closed
+	expiresAt := start.Add(72 * time.Hour)
+	hold, err := s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: "patron-2"})
+	assert.So(err, should.BeNil)
+	hold, err = s.ReserveCopy(ctx, hold, "2", internal.CheckedIn, expiresAt)
+	assert.So(err, should.BeNil)
+	assert.So(s.CloseHold(ctx, hold, internal.HoldCancelled), should.BeNil)
+
+	held := internal.Loan{Barcode: "2", BookID: book.ID, PatronID: "patron-2", CheckedOutAt: start, DueAt: start.Add(48 * time.Hour)}
+	_, _, err = s.CheckOutCopy(ctx, held, internal.OnHoldShelf, &hold)
+	assert.So(err, testutils.ShouldEqualError, internal.ErrHoldNotFound{HoldID: hold.ID})
+	bookCopy, err = s.GetCopyByBarcode(ctx, "2")
+	assert.So(err, should.BeNil)
+	assert.So(bookCopy.Status, should.Equal, internal.OnHoldShelf)
+	_, err = s.GetOpenLoan(ctx, "2")
+	assert.So(err, testutils.ShouldEqualError, internal.ErrLoanNotFound{Barcode: "2"})
+
+	// Checking out the copy for its hold fulfils the hold
+	c.Advance()
+	hold, err = s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: "patron-3"})
+	assert.So(err, should.BeNil)
+	hold, err = s.ReserveCopy(ctx, hold, "2", internal.OnHoldShelf, expiresAt)
+	assert.So(err, should.BeNil)
+
+	held.PatronID = "patron-3"
+	_, opened, err = s.CheckOutCopy(ctx, held, internal.OnHoldShelf, &hold)
+	assert.So(err, should.BeNil)
+	assert.So(opened.PatronID, should.Equal, "patron-3")
+	holds, err := s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(holds, should.BeEmpty)
+	assert.So(s.CloseHold(ctx, hold, internal.HoldCancelled), testutils.ShouldEqualError, internal.ErrHoldNotFound{HoldID: hold.ID})
+
+	book, err = s.GetBookByID(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(book.Status, should.Equal, internal.CheckedOut)
+}
+
+func testLedger(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := internal.ContextWithActor(context.Background(), "librarian-1")
+
+	// The storage assigns the entry's ID and records when and by whom it was made
+	charge, err := s.AddLedgerEntry(ctx, internal.LedgerEntry{ID: "mine", PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 150, LoanID: "loan-1", Barcode: "1", Note: "Returned 6 days late", CreatedBy: "someone else"})
+	assert.So(err, should.BeNil)
+	assert.So(charge.ID, should.NotBeEmpty)
+	assert.So(charge.ID, should.NotEqual, "mine")
+	assert.So(charge, should.Resemble, internal.LedgerEntry{ID: charge.ID, PatronID: "patron-1", Type: internal.LedgerCharge, Amount: 150, LoanID: "loan-1", Barcode: "1", Note: "Returned 6 days late", CreatedAt: start, CreatedBy: "librarian-1"})
+	c.Advance()
+	payment, err := s.AddLedgerEntry(ctx, internal.LedgerEntry{PatronID: "patron-1", Type: internal.LedgerPayment, Amount: 100})
+	assert.So(err, should.BeNil)
+	_, err = s.AddLedgerEntry(ctx, internal.LedgerEntry{PatronID: "patron-2", Type: internal.LedgerCharge, Amount: 25})
+	assert.So(err, should.BeNil)
+
+	// The entries are listed oldest first
+	ledger, err := s.GetLedger(ctx, "patron-1")
+	assert.So(err, should.BeNil)
+	assert.So(ledger, should.Resemble, []internal.LedgerEntry{charge, payment})
+	assert.So(internal.NewAccount("patron-1", ledger).Balance, should.Equal, internal.Money(50))
+
+	ledger, err = s.GetLedger(ctx, "patron-3")
+	assert.So(err, should.BeNil)
+	assert.So(ledger, should.BeEmpty)
+}
+
+// testConcurrent checks that status changes are atomic: only one of many concurrent check-outs of a copy
+// succeeds, and none of the holds placed at the same time is lost
+func testConcurrent(t *testing.T, s storage.BooksDB, c *clock) {
+	assert := assertions.New(t)
+	ctx := context.Background()
+
+	book, err := s.CreateBook(ctx, "Beloved", "Toni Morrison", "9781400033416", "124 was spiteful")
+	assert.So(err, should.BeNil)
+	_, err = s.AddCopy(ctx, internal.Copy{Barcode: "1", BookID: book.ID})
+	assert.So(err, should.BeNil)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		checkOuts int
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := s.UpdateCopyStatus(ctx, "1", internal.CheckedIn, internal.CheckedOut); err == nil {
+				mu.Lock()
+				checkOuts++
+				mu.Unlock()
+			}
+			_, _ = s.PlaceHold(ctx, internal.Hold{BookID: book.ID, PatronID: fmt.Sprintf("patron-%d", i)})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.So(checkOuts, should.Equal, 1)
+	holds, err := s.GetHolds(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(len(holds), should.Equal, 16)
+
+	book, err = s.GetBookByID(ctx, book.ID)
+	assert.So(err, should.BeNil)
+	assert.So(book.Status, should.Equal, internal.CheckedOut)
+}
+
+// actions lists the events' actions, in order
+func actions(events []internal.AuditEvent) []internal.AuditAction {
+	result := make([]internal.AuditAction, 0, len(events))
+	for _, event := range events {
+		result = append(result, event.Action)
+	}
+	return result
+}
+
+// sortedByID returns a copy of the books in ID order, the order every backend lists them in
+func sortedByID(books []internal.Book) []internal.Book {
+	result := append([]internal.Book(nil), books...)
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
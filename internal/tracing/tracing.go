@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/aaron-zeisler/library-api"
+
+// Tracer returns the tracer used throughout the project. Until Setup is called it's a no-op tracer.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Propagator reads and writes both the W3C traceparent header and X-Ray's X-Amzn-Trace-Id header
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, xray.Propagator{})
+}
+
+// Setup installs a global tracer provider that exports every span to the exporter as soon as it ends.
+// Spans are exported synchronously because Lambda freezes the container between invocations, which
+// would strand spans waiting in a batch. The returned function flushes and shuts the provider down.
+func Setup(exporter sdktrace.SpanExporter) func(ctx context.Context) error {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithIDGenerator(xray.NewIDGenerator()), // Trace IDs that X-Ray accepts
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(Propagator())
+
+	return provider.Shutdown
+}
+
+// NewExporterFromEnv returns the exporter named by the TRACING_EXPORTER environment variable: "stdout"
+// writes spans as JSON to stdout, and "none" or an empty value disables tracing (a nil exporter)
+func NewExporterFromEnv() (sdktrace.SpanExporter, error) {
+	switch exporter := os.Getenv("TRACING_EXPORTER"); exporter {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown TRACING_EXPORTER '%s'", exporter)
+	}
+}
+
+// Extract returns a copy of ctx carrying the remote span context found in the request headers
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	carrier := propagation.HeaderCarrier(http.Header{})
+	for k, v := range headers {
+		carrier.Set(k, v)
+	}
+	return Propagator().Extract(ctx, carrier)
+}
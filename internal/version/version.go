@@ -0,0 +1,23 @@
+package version
+
+import "runtime"
+
+// These are set at build time with -ldflags "-X", see the Makefile's build target
+var (
+	GitSHA    = "unknown"
+	BuildTime = "unknown"
+)
+
+type Info struct {
+	GitSHA    string `json:"git_sha"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+func Get() Info {
+	return Info{
+		GitSHA:    GitSHA,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -0,0 +1,68 @@
+package lambdas
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aaron-zeisler/library-api/internal"
+	"github.com/aaron-zeisler/library-api/internal/books"
+	"github.com/aaron-zeisler/library-api/internal/policy"
+)
+
+// NewBooksOptionsFromEnv configures the books service from the environment: TRASH_RETENTION_DAYS sets how
+// long deleted books are kept, HOLD_SHELF_DAYS how long a copy waits on the hold shelf, POLICY_FILE the
+// circulation policy (see policy.Load), and MAX_BALANCE how much a patron may owe, in minor units, and
+// still borrow. The policy file is validated here, so a bad policy stops the function from starting.
+func NewBooksOptionsFromEnv() ([]books.ServiceOption, error) {
+	result := make([]books.ServiceOption, 0)
+
+	retention, err := daysFromEnv("TRASH_RETENTION_DAYS")
+	if err != nil {
+		return nil, err
+	}
+	if retention > 0 {
+		result = append(result, books.WithTrashRetention(retention))
+	}
+
+	holdShelfTime, err := daysFromEnv("HOLD_SHELF_DAYS")
+	if err != nil {
+		return nil, err
+	}
+	if holdShelfTime > 0 {
+		result = append(result, books.WithHoldShelfTime(holdShelfTime))
+	}
+
+	if path := os.Getenv("POLICY_FILE"); path != "" {
+		p, err := policy.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, books.WithPolicy(p))
+	}
+
+	if value := os.Getenv("MAX_BALANCE"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("MAX_BALANCE must be a whole number of minor units, not '%s'", value)
+		}
+		result = append(result, books.WithBalanceLimit(internal.Money(limit)))
+	}
+
+	return result, nil
+}
+
+// daysFromEnv reads a whole number of days from the environment variable, or zero if it isn't set
+func daysFromEnv(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return 0, fmt.Errorf("%s must be a positive whole number of days, not '%s'", name, value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
@@ -0,0 +1,65 @@
+package lambdas
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+func TestNewBooksOptionsFromEnv(t *testing.T) {
+	type state struct {
+		env map[string]string
+	}
+	type expected struct {
+		numOptions int
+		err        error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"Nothing is configured": {
+			state{env: map[string]string{}},
+			expected{numOptions: 0},
+		},
+		"Both windows are configured": {
+			state{env: map[string]string{"TRASH_RETENTION_DAYS": "14", "HOLD_SHELF_DAYS": "5"}},
+			expected{numOptions: 2},
+		},
+		"A window isn't a number of days": {
+			state{env: map[string]string{"HOLD_SHELF_DAYS": "a week"}},
+			expected{err: errors.New("HOLD_SHELF_DAYS must be a positive whole number of days, not 'a week'")},
+		},
+		"The policy file is configured": {
+			state{env: map[string]string{"POLICY_FILE": "../internal/policy/default.yaml", "MAX_BALANCE": "500"}},
+			expected{numOptions: 2},
+		},
+		"The policy file doesn't exist": {
+			state{env: map[string]string{"POLICY_FILE": "missing.yaml"}},
+			expected{err: errors.New("failed to read the policy file: open missing.yaml: no such file or directory")},
+		},
+		"The balance limit isn't a number": {
+			state{env: map[string]string{"MAX_BALANCE": "ten dollars"}},
+			expected{err: errors.New("MAX_BALANCE must be a whole number of minor units, not 'ten dollars'")},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			for _, name := range []string{"TRASH_RETENTION_DAYS", "HOLD_SHELF_DAYS", "POLICY_FILE", "MAX_BALANCE"} {
+				t.Setenv(name, tc.state.env[name])
+			}
+
+			result, err := NewBooksOptionsFromEnv()
+
+			assert.So(len(result), should.Equal, tc.expected.numOptions)
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
@@ -2,33 +2,183 @@ package lambdas
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 )
 
-var corsHeaders = map[string]string{
-	"Access-Control-Allow-Headers": "Content-Type,X-Amz-Date,Authorization,X-Api-Key,X-Amz-Security-Token",
-	"Access-Control-Allow-Origin":  "*",
-	"Access-Control-Allow-Methods": "OPTIONS,POST,GET,PUT,DELETE",
+// CORSPolicy decides which browser origins may call the API
+type CORSPolicy struct {
+	// AllowedOrigins holds exact origins ("https://library.example.com"), wildcard subdomains
+	// ("https://*.example.com"), or "*" to allow every origin
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
 }
 
-func applyCORS(response events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+var DefaultCORSPolicy = CORSPolicy{
+	AllowedOrigins: []string{"*"},
+	AllowedMethods: []string{"OPTIONS", "POST", "GET", "PUT", "DELETE"},
+	AllowedHeaders: []string{"Content-Type", "X-Amz-Date", "Authorization", "X-Api-Key", "X-Amz-Security-Token", "X-Correlation-ID"},
+	ExposedHeaders: []string{"ETag", "Link", "X-Request-ID", "X-Correlation-ID", "Server-Timing", "X-Next-Page-Token", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
+	MaxAge:         10 * time.Minute,
+}
+
+// NewCORSPolicyFromEnv starts from DefaultCORSPolicy and overrides it with the CORS_ALLOWED_ORIGINS,
+// CORS_ALLOWED_HEADERS, CORS_EXPOSED_HEADERS (comma-separated), CORS_ALLOW_CREDENTIALS (a boolean) and
+// CORS_MAX_AGE (in seconds) environment variables, so each stage can have its own policy
+func NewCORSPolicyFromEnv() (CORSPolicy, error) {
+	policy := DefaultCORSPolicy
+
+	if value := os.Getenv("CORS_ALLOWED_ORIGINS"); value != "" {
+		policy.AllowedOrigins = splitList(value)
+	}
+	if value := os.Getenv("CORS_ALLOWED_HEADERS"); value != "" {
+		policy.AllowedHeaders = splitList(value)
+	}
+	if value := os.Getenv("CORS_EXPOSED_HEADERS"); value != "" {
+		policy.ExposedHeaders = splitList(value)
+	}
+	if value := os.Getenv("CORS_ALLOW_CREDENTIALS"); value != "" {
+		allowCredentials, err := strconv.ParseBool(value)
+		if err != nil {
+			return CORSPolicy{}, fmt.Errorf("CORS_ALLOW_CREDENTIALS is not a boolean: %w", err)
+		}
+		policy.AllowCredentials = allowCredentials
+	}
+	if value := os.Getenv("CORS_MAX_AGE"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return CORSPolicy{}, fmt.Errorf("CORS_MAX_AGE is not a number of seconds: %w", err)
+		}
+		policy.MaxAge = time.Duration(seconds) * time.Second
+	}
+
+	return policy, nil
+}
+
+func splitList(value string) []string {
+	result := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin header for the request's origin, or
+// an empty string if the origin isn't allowed
+func (p CORSPolicy) allowOrigin(origin string) string {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			// Browsers reject a wildcard on credentialed requests, so the origin is echoed instead
+			if p.AllowCredentials && origin != "" {
+				return origin
+			}
+			return "*"
+		}
+
+		if origin == "" {
+			continue
+		}
+
+		if strings.EqualFold(allowed, origin) || matchesWildcardSubdomain(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// matchesWildcardSubdomain reports whether origin is a subdomain of a pattern like "https://*.example.com".
+// The bare domain ("https://example.com") doesn't match the pattern.
+func matchesWildcardSubdomain(pattern, origin string) bool {
+	schemeSeparator := strings.Index(pattern, "://*.")
+	if schemeSeparator < 0 {
+		return false
+	}
+	scheme, domain := pattern[:schemeSeparator], pattern[schemeSeparator+len("://*"):]
+
+	prefix := scheme + "://"
+	if !strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) {
+		return false
+	}
+	host := origin[len(prefix):]
+
+	return len(host) > len(domain) && strings.HasSuffix(strings.ToLower(host), strings.ToLower(domain))
+}
+
+func (p CORSPolicy) apply(request events.APIGatewayProxyRequest, response events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
 	if len(response.Headers) == 0 {
 		response.Headers = make(map[string]string)
 	}
 
-	for k, v := range corsHeaders {
-		response.Headers[k] = v
+	response.Headers["Vary"] = "Origin"
+
+	allowOrigin := p.allowOrigin(headerValue(request, "Origin"))
+	if allowOrigin == "" {
+		return response
+	}
+
+	response.Headers["Access-Control-Allow-Origin"] = allowOrigin
+	if p.AllowCredentials {
+		response.Headers["Access-Control-Allow-Credentials"] = "true"
+	}
+	if len(p.ExposedHeaders) > 0 {
+		response.Headers["Access-Control-Expose-Headers"] = strings.Join(p.ExposedHeaders, ",")
 	}
 	return response
 }
 
-type lambdaFunction func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
-
-func CORSWrapper(f lambdaFunction) lambdaFunction {
+// Wrap adds the policy's CORS headers to every response of the lambda function
+func (p CORSPolicy) Wrap(f LambdaFunction) LambdaFunction {
 	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 		response, err := f(ctx, request)
-		response = applyCORS(response)
+		response = p.apply(request, response)
 		return response, err
 	}
 }
+
+// Preflight answers a browser's OPTIONS preflight request
+func (p CORSPolicy) Preflight(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	response := p.apply(request, events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent})
+
+	if _, ok := response.Headers["Access-Control-Allow-Origin"]; !ok {
+		response.StatusCode = http.StatusForbidden
+		return response, nil
+	}
+
+	response.Headers["Access-Control-Allow-Methods"] = strings.Join(p.AllowedMethods, ",")
+	response.Headers["Access-Control-Allow-Headers"] = strings.Join(p.AllowedHeaders, ",")
+	if p.MaxAge > 0 {
+		response.Headers["Access-Control-Max-Age"] = strconv.Itoa(int(p.MaxAge.Seconds()))
+	}
+	return response, nil
+}
+
+// CORSWrapper applies DefaultCORSPolicy to the lambda function. It's a Middleware, as is CORSPolicy.Wrap.
+func CORSWrapper(f LambdaFunction) LambdaFunction {
+	return DefaultCORSPolicy.Wrap(f)
+}
+
+// headerValue looks up a request header without regard to its case, since API Gateway passes headers
+// through as the client sent them
+func headerValue(request events.APIGatewayProxyRequest, name string) string {
+	if value, ok := request.Headers[name]; ok {
+		return value
+	}
+	for k, v := range request.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
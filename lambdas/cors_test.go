@@ -0,0 +1,179 @@
+package lambdas
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+)
+
+func TestCORSPolicy_Wrap(t *testing.T) {
+	policy := CORSPolicy{
+		AllowedOrigins:   []string{"https://library.example.com", "https://*.branches.example.com"},
+		ExposedHeaders:   []string{"ETag"},
+		AllowCredentials: true,
+	}
+
+	type state struct {
+		origin string
+	}
+	type expected struct {
+		headers map[string]string
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"An exact origin is echoed": {
+			state{
+				origin: "https://library.example.com",
+			},
+			expected{
+				headers: map[string]string{
+					"Vary":                             "Origin",
+					"Access-Control-Allow-Origin":      "https://library.example.com",
+					"Access-Control-Allow-Credentials": "true",
+					"Access-Control-Expose-Headers":    "ETag",
+				},
+			},
+		},
+		"A wildcard subdomain is echoed": {
+			state{
+				origin: "https://downtown.branches.example.com",
+			},
+			expected{
+				headers: map[string]string{
+					"Vary":                             "Origin",
+					"Access-Control-Allow-Origin":      "https://downtown.branches.example.com",
+					"Access-Control-Allow-Credentials": "true",
+					"Access-Control-Expose-Headers":    "ETag",
+				},
+			},
+		},
+		"The bare domain of a wildcard doesn't match": {
+			state{
+				origin: "https://branches.example.com",
+			},
+			expected{
+				headers: map[string]string{"Vary": "Origin"},
+			},
+		},
+		"A different scheme doesn't match": {
+			state{
+				origin: "http://library.example.com",
+			},
+			expected{
+				headers: map[string]string{"Vary": "Origin"},
+			},
+		},
+		"An unknown origin gets no CORS headers": {
+			state{
+				origin: "https://evil.example.org",
+			},
+			expected{
+				headers: map[string]string{"Vary": "Origin"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			f := policy.Wrap(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			})
+
+			result, err := f(context.Background(), events.APIGatewayProxyRequest{Headers: map[string]string{"origin": tc.state.origin}})
+
+			assert.So(result.Headers, should.Resemble, tc.expected.headers)
+			assert.So(err, should.BeNil)
+		})
+	}
+}
+
+func TestDefaultCORSPolicy(t *testing.T) {
+	assert := assertions.New(t)
+
+	f := DefaultCORSPolicy.Wrap(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	})
+
+	result, err := f(context.Background(), events.APIGatewayProxyRequest{Headers: map[string]string{"origin": "https://library.example.com"}})
+
+	// Browsers only let a client read the validators and the pagination headers if they're exposed
+	exposed := strings.Split(result.Headers["Access-Control-Expose-Headers"], ",")
+	for _, header := range []string{"ETag", "Link", "X-Next-Page-Token"} {
+		assert.So(exposed, should.Contain, header)
+	}
+	assert.So(err, should.BeNil)
+}
+
+func TestCORSPolicy_Preflight(t *testing.T) {
+	type state struct {
+		policy CORSPolicy
+		origin string
+	}
+	type expected struct {
+		statusCode int
+		headers    map[string]string
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"An allowed origin gets the preflight headers": {
+			state{
+				policy: CORSPolicy{
+					AllowedOrigins: []string{"*"},
+					AllowedMethods: []string{"GET", "POST"},
+					AllowedHeaders: []string{"Content-Type"},
+					MaxAge:         time.Minute,
+				},
+				origin: "https://library.example.com",
+			},
+			expected{
+				statusCode: http.StatusNoContent,
+				headers: map[string]string{
+					"Vary":                         "Origin",
+					"Access-Control-Allow-Origin":  "*",
+					"Access-Control-Allow-Methods": "GET,POST",
+					"Access-Control-Allow-Headers": "Content-Type",
+					"Access-Control-Max-Age":       "60",
+				},
+			},
+		},
+		"A disallowed origin is forbidden": {
+			state{
+				policy: CORSPolicy{
+					AllowedOrigins: []string{"https://library.example.com"},
+				},
+				origin: "https://evil.example.org",
+			},
+			expected{
+				statusCode: http.StatusForbidden,
+				headers:    map[string]string{"Vary": "Origin"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			result, err := tc.state.policy.Preflight(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodOptions,
+				Headers:    map[string]string{"Origin": tc.state.origin},
+			})
+
+			assert.So(result.StatusCode, should.Equal, tc.expected.statusCode)
+			assert.So(result.Headers, should.Resemble, tc.expected.headers)
+			assert.So(err, should.BeNil)
+		})
+	}
+}
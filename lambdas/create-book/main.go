@@ -1,23 +1,35 @@
 package main
 
 import (
+	"os"
+
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/sirupsen/logrus"
 
 	"github.com/aaron-zeisler/library-api/internal/books"
-	"github.com/aaron-zeisler/library-api/internal/storage"
+	"github.com/aaron-zeisler/library-api/internal/metrics"
 	"github.com/aaron-zeisler/library-api/lambdas"
 )
 
 func main() {
-	db := storage.NewDynamoDBBooksStorage()
+	sink := metrics.NewEMFSink(os.Stdout, "LibraryAPI")
 
 	//TODO: Read these log settings from environment variables
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.JSONFormatter{})
 	logger.SetLevel(logrus.DebugLevel)
 
-	service := books.NewService(db, books.WithLogger(logger))
+	db, err := lambdas.NewBooksDBFromEnv(sink)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to configure the storage")
+	}
+
+	service := books.NewService(db, books.WithLogger(logger), books.WithMetrics(sink))
+
+	middleware, err := lambdas.DefaultMiddleware(logger, sink)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to configure the middleware")
+	}
 
-	lambda.Start(lambdas.CORSWrapper(service.CreateBook))
+	lambda.Start(middleware(service.CreateBook))
 }
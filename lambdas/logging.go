@@ -0,0 +1,73 @@
+package lambdas
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aaron-zeisler/library-api/internal/identity"
+	"github.com/aaron-zeisler/library-api/internal/logging"
+)
+
+const correlationIDHeader = "X-Correlation-ID"
+
+// Logging puts a logger in the context that tags every line with the request's identifiers, and logs
+// one access line per request with its status and latency. The X-Correlation-ID header is generated if
+// the client didn't send one, and echoed in the response so that calls can be traced across services.
+func Logging(logger *logrus.Logger) Middleware {
+	return func(f LambdaFunction) LambdaFunction {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			start := time.Now()
+
+			correlationID := headerValue(request, correlationIDHeader)
+			if correlationID == "" {
+				correlationID = uuid.New().String()
+			}
+
+			fields := logrus.Fields{
+				"request_id":     request.RequestContext.RequestID,
+				"correlation_id": correlationID,
+				"route":          request.Resource,
+				"method":         request.HTTPMethod,
+				"caller":         identity.FromRequest(request).String(),
+			}
+			if lc, ok := lambdacontext.FromContext(ctx); ok {
+				fields["aws_request_id"] = lc.AwsRequestID
+			}
+			if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+				fields["trace_id"] = spanContext.TraceID().String()
+			}
+			entry := logger.WithFields(fields)
+
+			response, err := f(logging.NewContext(ctx, entry), request)
+
+			if len(response.Headers) == 0 {
+				response.Headers = make(map[string]string)
+			}
+			response.Headers[correlationIDHeader] = correlationID
+
+			accessEntry := entry.WithFields(logrus.Fields{
+				"path":       request.Path,
+				"status":     response.StatusCode,
+				"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			})
+			if err != nil {
+				accessEntry = accessEntry.WithError(err)
+			}
+			accessEntry.Info("access")
+
+			return response, err
+		}
+	}
+}
+
+// loggerFromContext returns the request's logger, or the standard logger for requests that didn't go
+// through the Logging middleware
+func loggerFromContext(ctx context.Context) *logrus.Entry {
+	return logging.FromContextOr(ctx, logrus.StandardLogger())
+}
@@ -0,0 +1,95 @@
+package lambdas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/logging"
+)
+
+func TestLogging(t *testing.T) {
+	type state struct {
+		headers map[string]string
+	}
+	type expected struct {
+		correlationID string // Empty when a new one should be generated
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The client's correlation ID is kept": {
+			state{
+				headers: map[string]string{"x-correlation-id": "kiosk-42"},
+			},
+			expected{
+				correlationID: "kiosk-42",
+			},
+		},
+		"A correlation ID is generated if the client didn't send one": {
+			state{
+				headers: map[string]string{},
+			},
+			expected{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			logger, hook := test.NewNullLogger()
+
+			var handlerLogged bool
+			f := Logging(logger)(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				entry, ok := logging.FromContext(ctx)
+				handlerLogged = ok
+				entry.Info("inside the handler")
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound}, nil
+			})
+
+			result, err := f(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Resource:   "/book/{book_id}",
+				Path:       "/book/12345",
+				Headers:    tc.state.headers,
+				RequestContext: events.APIGatewayProxyRequestContext{
+					RequestID: "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+					Identity:  events.APIGatewayRequestIdentity{SourceIP: "10.0.0.1"},
+				},
+			})
+			assert.So(err, should.BeNil)
+
+			correlationID := result.Headers["X-Correlation-ID"]
+			if tc.expected.correlationID != "" {
+				assert.So(correlationID, should.Equal, tc.expected.correlationID)
+			} else {
+				_, uuidErr := uuid.Parse(correlationID)
+				assert.So(uuidErr, should.BeNil)
+			}
+
+			// The handler's line and the access line are both tagged with the request's fields
+			assert.So(handlerLogged, should.BeTrue)
+			assert.So(len(hook.AllEntries()), should.Equal, 2)
+			for _, entry := range hook.AllEntries() {
+				assert.So(entry.Data["request_id"], should.Equal, "c6af9ac6-7b61-11e6-9a41-93e8deadbeef")
+				assert.So(entry.Data["correlation_id"], should.Equal, correlationID)
+				assert.So(entry.Data["route"], should.Equal, "/book/{book_id}")
+				assert.So(entry.Data["method"], should.Equal, http.MethodGet)
+				assert.So(entry.Data["caller"], should.Equal, "ip:10.0.0.1")
+			}
+
+			access := hook.LastEntry()
+			assert.So(access.Message, should.Equal, "access")
+			assert.So(access.Data["status"], should.Equal, http.StatusNotFound)
+			assert.So(access.Data["latency_ms"], should.HaveSameTypeAs, float64(0))
+		})
+	}
+}
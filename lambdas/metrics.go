@@ -0,0 +1,37 @@
+package lambdas
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/aaron-zeisler/library-api/internal/metrics"
+)
+
+// Metrics emits the request count and latency of every route, and the number of errors by status class
+func Metrics(sink metrics.Sink) Middleware {
+	return func(f LambdaFunction) LambdaFunction {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			start := time.Now()
+			response, err := f(ctx, request)
+
+			route := map[string]string{"Route": fmt.Sprintf("%s %s", request.HTTPMethod, request.Resource)}
+			data := []metrics.Datum{
+				metrics.Counter("RequestCount", route),
+				metrics.Duration("RequestLatency", start, route),
+			}
+			if response.StatusCode >= 400 || err != nil {
+				statusClass := fmt.Sprintf("%dxx", response.StatusCode/100)
+				if err != nil {
+					statusClass = "5xx" // Lambda turns a returned error into a 502
+				}
+				data = append(data, metrics.Counter("Errors", map[string]string{"Route": route["Route"], "StatusClass": statusClass}))
+			}
+			sink.Emit(data...)
+
+			return response, err
+		}
+	}
+}
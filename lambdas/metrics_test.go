@@ -0,0 +1,71 @@
+package lambdas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	type state struct {
+		statusCode int
+	}
+	type expected struct {
+		errors map[string]float64 // Error counts by status class
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"A successful request isn't an error": {
+			state{
+				statusCode: http.StatusOK,
+			},
+			expected{
+				errors: map[string]float64{"4xx": 0, "5xx": 0},
+			},
+		},
+		"A 404 is counted as a 4xx": {
+			state{
+				statusCode: http.StatusNotFound,
+			},
+			expected{
+				errors: map[string]float64{"4xx": 1, "5xx": 0},
+			},
+		},
+		"A 500 is counted as a 5xx": {
+			state{
+				statusCode: http.StatusInternalServerError,
+			},
+			expected{
+				errors: map[string]float64{"4xx": 0, "5xx": 1},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			sink := metrics.NewMemorySink()
+			f := Metrics(sink)(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: tc.state.statusCode}, nil
+			})
+
+			_, err := f(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/books"})
+
+			assert.So(err, should.BeNil)
+			route := map[string]string{"Route": "GET /books"}
+			assert.So(sink.Sum("RequestCount", route), should.Equal, 1)
+			for statusClass, count := range tc.expected.errors {
+				assert.So(sink.Sum("Errors", map[string]string{"Route": "GET /books", "StatusClass": statusClass}), should.Equal, count)
+			}
+		})
+	}
+}
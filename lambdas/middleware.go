@@ -0,0 +1,136 @@
+package lambdas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aaron-zeisler/library-api/internal/metrics"
+	"github.com/aaron-zeisler/library-api/internal/tracing"
+)
+
+// LambdaFunction is the signature of every API Gateway handler in this project
+type LambdaFunction func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Middleware wraps a LambdaFunction with behavior that runs before and/or after it
+type Middleware func(f LambdaFunction) LambdaFunction
+
+// Chain combines middlewares into one. The first middleware is the outermost, so it sees the request
+// first and the response last.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(f LambdaFunction) LambdaFunction {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			f = middlewares[i](f)
+		}
+		return f
+	}
+}
+
+// DefaultMiddleware is the chain shared by every lambda function. CORS is outermost so that every
+// response, including errors produced by the other middlewares, carries the CORS headers. It also installs
+// the span exporter named by TRACING_EXPORTER, if there is one.
+func DefaultMiddleware(logger *logrus.Logger, sink metrics.Sink) (Middleware, error) {
+	exporter, err := tracing.NewExporterFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure tracing: %w", err)
+	}
+	if exporter != nil {
+		tracing.Setup(exporter)
+	}
+
+	cors, err := NewCORSPolicyFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the CORS policy: %w", err)
+	}
+
+	limiter, err := NewRateLimiterFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the rate limiter: %w", err)
+	}
+
+	return Chain(
+		cors.Wrap,
+		RequestID(),
+		Tracing(),
+		Logging(logger),
+		Metrics(sink),
+		Recovery(),
+		Timing(),
+		RateLimit(limiter),
+	), nil
+}
+
+// Recovery turns a panic in the lambda function into a 500 response, logging the panic and its stack
+func Recovery() Middleware {
+	return func(f LambdaFunction) LambdaFunction {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (response events.APIGatewayProxyResponse, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					loggerFromContext(ctx).WithFields(logrus.Fields{
+						"panic": fmt.Sprint(r),
+						"stack": string(debug.Stack()),
+					}).Error("recovered from a panic in the lambda function")
+
+					response = events.APIGatewayProxyResponse{
+						StatusCode: http.StatusInternalServerError,
+						Body:       `{"error":"internal server error"}`,
+					}
+					err = nil
+				}
+			}()
+
+			return f(ctx, request)
+		}
+	}
+}
+
+type requestIDKey struct{}
+
+// RequestID puts API Gateway's request ID in the context and echoes it in the X-Request-ID response
+// header, so that a client can quote it when reporting a problem
+func RequestID() Middleware {
+	return func(f LambdaFunction) LambdaFunction {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			requestID := request.RequestContext.RequestID
+			ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+
+			response, err := f(ctx, request)
+			if requestID != "" {
+				if len(response.Headers) == 0 {
+					response.Headers = make(map[string]string)
+				}
+				response.Headers["X-Request-ID"] = requestID
+			}
+			return response, err
+		}
+	}
+}
+
+// RequestIDFromContext returns the request ID stored by the RequestID middleware
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Timing measures how long the lambda function takes and reports it to the client in the Server-Timing
+// response header. The latency is logged by the Logging middleware.
+func Timing() Middleware {
+	return func(f LambdaFunction) LambdaFunction {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			start := time.Now()
+			response, err := f(ctx, request)
+			elapsed := time.Since(start)
+
+			if len(response.Headers) == 0 {
+				response.Headers = make(map[string]string)
+			}
+			response.Headers["Server-Timing"] = fmt.Sprintf("app;dur=%.1f", float64(elapsed.Microseconds())/1000)
+			return response, err
+		}
+	}
+}
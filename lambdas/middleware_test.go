@@ -0,0 +1,115 @@
+package lambdas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/logging"
+)
+
+func TestChain(t *testing.T) {
+	assert := assertions.New(t)
+
+	calls := make([]string, 0)
+	record := func(name string) Middleware {
+		return func(f LambdaFunction) LambdaFunction {
+			return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				calls = append(calls, "before "+name)
+				response, err := f(ctx, request)
+				calls = append(calls, "after "+name)
+				return response, err
+			}
+		}
+	}
+
+	f := Chain(record("first"), record("second"))(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		calls = append(calls, "handler")
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	})
+
+	_, err := f(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.So(err, should.BeNil)
+	assert.So(calls, should.Resemble, []string{"before first", "before second", "handler", "after second", "after first"})
+}
+
+func TestRecovery(t *testing.T) {
+	type state struct {
+		handler LambdaFunction
+	}
+	type expected struct {
+		statusCode int
+		logged     bool
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"A panic becomes a 500": {
+			state{
+				handler: func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+					panic("the database is on fire")
+				},
+			},
+			expected{
+				statusCode: http.StatusInternalServerError,
+				logged:     true,
+			},
+		},
+		"A normal response is untouched": {
+			state{
+				handler: func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+					return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+				},
+			},
+			expected{
+				statusCode: http.StatusOK,
+				logged:     false,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			logger, hook := test.NewNullLogger()
+			ctx := logging.NewContext(context.Background(), logrus.NewEntry(logger))
+
+			result, err := Recovery()(tc.state.handler)(ctx, events.APIGatewayProxyRequest{})
+
+			assert.So(result.StatusCode, should.Equal, tc.expected.statusCode)
+			assert.So(err, should.BeNil)
+			assert.So(hook.LastEntry() != nil, should.Equal, tc.expected.logged)
+			if tc.expected.logged {
+				assert.So(hook.LastEntry().Level, should.Equal, logrus.ErrorLevel)
+				assert.So(hook.LastEntry().Data["stack"], should.NotBeEmpty)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	assert := assertions.New(t)
+
+	var seen string
+	f := RequestID()(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		seen = RequestIDFromContext(ctx)
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+	})
+
+	result, err := f(context.Background(), events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"},
+	})
+
+	assert.So(err, should.BeNil)
+	assert.So(seen, should.Equal, "c6af9ac6-7b61-11e6-9a41-93e8deadbeef")
+	assert.So(result.Headers["X-Request-ID"], should.Equal, "c6af9ac6-7b61-11e6-9a41-93e8deadbeef")
+}
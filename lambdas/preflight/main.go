@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aaron-zeisler/library-api/lambdas"
+)
+
+func main() {
+	cors, err := lambdas.NewCORSPolicyFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to configure the CORS policy")
+	}
+
+	lambda.Start(cors.Preflight)
+}
@@ -0,0 +1,88 @@
+package lambdas
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/aaron-zeisler/library-api/internal/identity"
+	"github.com/aaron-zeisler/library-api/internal/ratelimit"
+)
+
+// NewRateLimiterFromEnv builds a rate limiter from the RATE_LIMITS environment variable (see
+// ratelimit.ParseLimits). Buckets are kept in the DynamoDB table named by RATE_LIMIT_TABLE if it's set,
+// and in memory otherwise.
+func NewRateLimiterFromEnv() (ratelimit.Limiter, error) {
+	limits, err := ratelimit.ParseLimits(os.Getenv("RATE_LIMITS"))
+	if err != nil {
+		return ratelimit.Limiter{}, err
+	}
+
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if tableName := os.Getenv("RATE_LIMIT_TABLE"); tableName != "" {
+		dynamodbStore, err := ratelimit.NewDynamoDBStore(tableName)
+		if err != nil {
+			return ratelimit.Limiter{}, err
+		}
+		store = dynamodbStore
+	}
+
+	return ratelimit.NewLimiter(store, ratelimit.WithLimits(limits)), nil
+}
+
+// RateLimit rejects requests with a 429 once the client has used up its bucket for the route. If the
+// bucket can't be read the request is let through, so an outage of the store doesn't take the whole API
+// down with it.
+func RateLimit(limiter ratelimit.Limiter) Middleware {
+	return func(f LambdaFunction) LambdaFunction {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			route := fmt.Sprintf("%s %s", request.HTTPMethod, request.Resource)
+			client := identity.FromRequest(request)
+
+			result, err := limiter.Allow(ctx, route, client.String())
+			if err != nil {
+				loggerFromContext(ctx).WithError(err).Warn("failed to apply the rate limit")
+				return f(ctx, request)
+			}
+
+			if !result.Allowed {
+				response := events.APIGatewayProxyResponse{
+					StatusCode: http.StatusTooManyRequests,
+					Body:       `{"error":"rate limit exceeded"}`,
+				}
+				response = applyRateLimitHeaders(response, result)
+				response.Headers["Retry-After"] = strconv.Itoa(ceilSeconds(result.RetryAfter))
+				return response, nil
+			}
+
+			response, err := f(ctx, request)
+			response = applyRateLimitHeaders(response, result)
+			return response, err
+		}
+	}
+}
+
+func applyRateLimitHeaders(response events.APIGatewayProxyResponse, result ratelimit.Result) events.APIGatewayProxyResponse {
+	if result.Limit == 0 { // The route isn't rate limited
+		return response
+	}
+
+	if len(response.Headers) == 0 {
+		response.Headers = make(map[string]string)
+	}
+
+	response.Headers["X-RateLimit-Limit"] = strconv.Itoa(result.Limit)
+	response.Headers["X-RateLimit-Remaining"] = strconv.Itoa(result.Remaining)
+	response.Headers["X-RateLimit-Reset"] = strconv.Itoa(ceilSeconds(result.Reset))
+	return response
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,117 @@
+package lambdas
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/logging"
+	"github.com/aaron-zeisler/library-api/internal/ratelimit"
+)
+
+// failingStore is a bucket store that can't be reached
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("the table is unavailable")
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2021, 2, 14, 12, 0, 0, 0, time.UTC)
+	limits := []ratelimit.LimiterOption{
+		ratelimit.WithRouteLimit("GET /books", ratelimit.Limit{Burst: 2, Rate: 0.5}),
+		ratelimit.WithClock(func() time.Time { return now }),
+	}
+
+	type state struct {
+		store    ratelimit.Store
+		resource string
+		requests int // How many requests the client sends; the last one's response is checked
+	}
+	type expected struct {
+		statusCode int
+		calls      int // How many requests reach the handler
+		headers    map[string]string
+		logged     bool
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"A route without a limit gets no headers": {
+			state{store: ratelimit.NewMemoryStore(), resource: "/books/{id}", requests: 3},
+			expected{statusCode: http.StatusOK, calls: 3, headers: map[string]string{"Content-Type": "application/json"}},
+		},
+		"A request within the limit is passed on with the bucket's state": {
+			state{store: ratelimit.NewMemoryStore(), resource: "/books", requests: 1},
+			expected{
+				statusCode: http.StatusOK,
+				calls:      1,
+				headers: map[string]string{
+					"Content-Type":          "application/json",
+					"X-RateLimit-Limit":     "2",
+					"X-RateLimit-Remaining": "1",
+					"X-RateLimit-Reset":     "2",
+				},
+			},
+		},
+		"A request over the limit is rejected with when to retry": {
+			state{store: ratelimit.NewMemoryStore(), resource: "/books", requests: 3},
+			expected{
+				statusCode: http.StatusTooManyRequests,
+				calls:      2,
+				headers: map[string]string{
+					"X-RateLimit-Limit":     "2",
+					"X-RateLimit-Remaining": "0",
+					"X-RateLimit-Reset":     "4",
+					"Retry-After":           "2",
+				},
+			},
+		},
+		"The request is let through when the store fails": {
+			state{store: failingStore{}, resource: "/books", requests: 3},
+			expected{statusCode: http.StatusOK, calls: 3, headers: map[string]string{"Content-Type": "application/json"}, logged: true},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+			logger, hook := test.NewNullLogger()
+			ctx := logging.NewContext(context.Background(), logrus.NewEntry(logger))
+
+			calls := 0
+			f := RateLimit(ratelimit.NewLimiter(tc.state.store, limits...))(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				calls++
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: map[string]string{"Content-Type": "application/json"}}, nil
+			})
+			request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: tc.state.resource}
+			request.RequestContext.Identity.SourceIP = "203.0.113.7"
+
+			var (
+				response events.APIGatewayProxyResponse
+				err      error
+			)
+			for i := 0; i < tc.state.requests; i++ {
+				response, err = f(ctx, request)
+				assert.So(err, should.BeNil)
+			}
+
+			assert.So(response.StatusCode, should.Equal, tc.expected.statusCode)
+			assert.So(calls, should.Equal, tc.expected.calls)
+			assert.So(response.Headers, should.Resemble, tc.expected.headers)
+			assert.So(hook.LastEntry() != nil, should.Equal, tc.expected.logged)
+			if tc.expected.logged {
+				assert.So(hook.LastEntry().Level, should.Equal, logrus.WarnLevel)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package lambdas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+
+	"github.com/aaron-zeisler/library-api/internal/metrics"
+	"github.com/aaron-zeisler/library-api/internal/storage"
+)
+
+// DefaultSQLitePath is the SQLite database used when SQLITE_PATH isn't set
+const DefaultSQLitePath = "library.db"
+
+// NewBooksDBFromEnv opens the storage named by STORAGE_BACKEND: "dynamodb", the default, with its table's
+// name prefixed by TABLE_PREFIX, "sqlite" for a branch that runs on a single box, with its database at
+// SQLITE_PATH, "postgres" for an on-prem deployment, with its database at DATABASE_URL or in the secret
+// at DATABASE_SECRET_ARN, or "memory" for a throwaway library seeded with a few classics
+func NewBooksDBFromEnv(sink metrics.Sink) (storage.BooksDB, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "dynamodb":
+		opts := []storage.DynamoBooksStorageOption{storage.WithMetrics(sink)}
+		if prefix := os.Getenv("TABLE_PREFIX"); prefix != "" {
+			opts = append(opts, storage.WithTablePrefix(prefix))
+		}
+		return storage.NewDynamoDBBooksStorage(opts...)
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = DefaultSQLitePath
+		}
+		return storage.NewSQLiteBooksStorage(context.Background(), path, storage.WithSQLMetrics(sink))
+	case "postgres":
+		dsn, err := databaseURL(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewPostgresBooksStorage(context.Background(), dsn, storage.WithSQLMetrics(sink))
+	case "memory":
+		return storage.NewStaticBooksStorage(), nil
+	default:
+		return nil, fmt.Errorf("STORAGE_BACKEND must be dynamodb, sqlite, postgres or memory, not '%s'", backend)
+	}
+}
+
+// databaseURL is the postgres DSN. On-prem it's DATABASE_URL, while a function reads it from the Secrets
+// Manager secret at DATABASE_SECRET_ARN when it starts, so that the credentials aren't in its configuration.
+func databaseURL(ctx context.Context) (string, error) {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn, nil
+	}
+
+	secretARN := os.Getenv("DATABASE_SECRET_ARN")
+	if secretARN == "" {
+		return "", errors.New("DATABASE_URL or DATABASE_SECRET_ARN must be set for the postgres backend")
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load the AWS configuration: %w", err)
+	}
+	secret, err := secretsmanager.NewFromConfig(awsConfig).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretARN),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read the database secret: %w", err)
+	}
+	if secret.SecretString == nil {
+		return "", errors.New("failed to read the database secret: it doesn't have a string value")
+	}
+	return *secret.SecretString, nil
+}
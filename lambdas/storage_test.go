@@ -0,0 +1,97 @@
+package lambdas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+
+	"github.com/aaron-zeisler/library-api/internal/metrics"
+	"github.com/aaron-zeisler/library-api/internal/testutils"
+)
+
+func TestNewBooksDBFromEnv(t *testing.T) {
+	type state struct {
+		env map[string]string
+	}
+	type expected struct {
+		backend string
+		err     error
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"Nothing is configured": {
+			state{env: map[string]string{}},
+			expected{backend: "*storage.dynamodbBooksStorage"},
+		},
+		"SQLite is configured": {
+			state{env: map[string]string{"STORAGE_BACKEND": "sqlite", "SQLITE_PATH": filepath.Join(t.TempDir(), "library.db")}},
+			expected{backend: "*storage.sqlBooksStorage"},
+		},
+		"Postgres is configured without a database": {
+			state{env: map[string]string{"STORAGE_BACKEND": "postgres"}},
+			expected{err: errors.New("DATABASE_URL or DATABASE_SECRET_ARN must be set for the postgres backend")},
+		},
+		"Postgres is configured with a secret that can't be read": {
+			state{env: map[string]string{"STORAGE_BACKEND": "postgres", "DATABASE_SECRET_ARN": "arn:aws:secretsmanager:us-west-1:123456789012:secret:library-db"}},
+			expected{err: errors.New("failed to read the database secret: operation error Secrets Manager: GetSecretValue")},
+		},
+		"Postgres is configured with a secret that isn't a string": {
+			state{env: map[string]string{"STORAGE_BACKEND": "postgres", "DATABASE_SECRET_ARN": "arn:aws:secretsmanager:us-west-1:123456789012:secret:binary"}},
+			expected{err: errors.New("failed to read the database secret: it doesn't have a string value")},
+		},
+		"Memory is configured": {
+			state{env: map[string]string{"STORAGE_BACKEND": "memory"}},
+			expected{backend: "*storage.staticBooksStorage"},
+		},
+		"The backend is unknown": {
+			state{env: map[string]string{"STORAGE_BACKEND": "mysql"}},
+			expected{err: errors.New("STORAGE_BACKEND must be dynamodb, sqlite, postgres or memory, not 'mysql'")},
+		},
+	}
+
+	// Secrets Manager has a binary secret and nothing else
+	secrets := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct{ SecretId string }
+		_ = json.NewDecoder(r.Body).Decode(&input)
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if strings.HasSuffix(input.SecretId, ":binary") {
+			fmt.Fprintf(w, `{"ARN":%q,"SecretBinary":"cG9zdGdyZXM="}`, input.SecretId)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`)
+	}))
+	defer secrets.Close()
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", secrets.URL)
+	t.Setenv("AWS_REGION", "us-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			for _, name := range []string{"STORAGE_BACKEND", "SQLITE_PATH", "DATABASE_URL", "DATABASE_SECRET_ARN"} {
+				t.Setenv(name, tc.state.env[name])
+			}
+
+			result, err := NewBooksDBFromEnv(metrics.NewNoopSink())
+
+			if tc.expected.err == nil {
+				assert.So(fmt.Sprintf("%T", result), should.Equal, tc.expected.backend)
+			}
+			assert.So(err, testutils.ShouldEqualError, tc.expected.err)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package lambdas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/aaron-zeisler/library-api/internal/tracing"
+)
+
+// Tracing starts a server span for the request, continuing the trace from the traceparent or
+// X-Amzn-Trace-Id header if the caller sent one
+func Tracing() Middleware {
+	return func(f LambdaFunction) LambdaFunction {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			ctx = tracing.Extract(ctx, request.Headers)
+
+			route := fmt.Sprintf("%s %s", request.HTTPMethod, request.Resource)
+			ctx, span := tracing.Tracer().Start(ctx, route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.method", request.HTTPMethod),
+					attribute.String("http.route", request.Resource),
+					attribute.String("http.target", request.Path),
+					attribute.String("aws.request_id", request.RequestContext.RequestID),
+				),
+			)
+			defer span.End()
+
+			response, err := f(ctx, request)
+
+			span.SetAttributes(attribute.Int("http.status_code", response.StatusCode))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			} else if response.StatusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(response.StatusCode))
+			}
+
+			return response, err
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package lambdas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/aaron-zeisler/library-api/internal/tracing"
+)
+
+func TestTracing(t *testing.T) {
+	type state struct {
+		headers    map[string]string
+		statusCode int
+	}
+	type expected struct {
+		traceID string // Empty when a new trace should be started
+		status  codes.Code
+	}
+	testCases := map[string]struct {
+		state    state
+		expected expected
+	}{
+		"The trace is continued from the traceparent header": {
+			state{
+				headers:    map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+				statusCode: http.StatusOK,
+			},
+			expected{
+				traceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				status:  codes.Unset,
+			},
+		},
+		"The trace is continued from the X-Amzn-Trace-Id header": {
+			state{
+				headers:    map[string]string{"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"},
+				statusCode: http.StatusOK,
+			},
+			expected{
+				traceID: "5759e988bd862e3fe1be46a994272793",
+				status:  codes.Unset,
+			},
+		},
+		"A server error marks the span as failed": {
+			state{
+				headers:    map[string]string{},
+				statusCode: http.StatusInternalServerError,
+			},
+			expected{
+				status: codes.Error,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assertions.New(t)
+
+			exporter := tracetest.NewInMemoryExporter()
+			shutdown := tracing.Setup(exporter)
+			defer shutdown(context.Background())
+
+			f := Tracing()(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: tc.state.statusCode}, nil
+			})
+
+			_, err := f(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Resource:   "/book/{book_id}",
+				Headers:    tc.state.headers,
+			})
+			assert.So(err, should.BeNil)
+
+			spans := exporter.GetSpans()
+			assert.So(len(spans), should.Equal, 1)
+			assert.So(spans[0].Name, should.Equal, "GET /book/{book_id}")
+			assert.So(spans[0].Status.Code, should.Equal, tc.expected.status)
+			assert.So(spans[0].Attributes, should.Contain, attribute.Int("http.status_code", tc.state.statusCode))
+			if tc.expected.traceID != "" {
+				assert.So(spans[0].SpanContext.TraceID().String(), should.Equal, tc.expected.traceID)
+				assert.So(spans[0].Parent.IsRemote(), should.BeTrue)
+			} else {
+				assert.So(spans[0].Parent.IsValid(), should.BeFalse)
+			}
+		})
+	}
+}